@@ -2,36 +2,70 @@ package main
 
 import (
 	"context"
+	"errors"
+	"expvar"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
+	"syscall"
+	"time"
 
-	"github.com/gorilla/mux"
-	"github.com/igorrius/go-vector-search/internal/app"
-	"github.com/igorrius/go-vector-search/internal/infra/ai"
-	"github.com/igorrius/go-vector-search/internal/infra/persistence"
+	"github.com/igorrius/go-vector-search/internal/server"
 )
 
-type config struct {
-	httpPort        int
-	typesenseHost   string
-	typesensePort   int
-	typesenseAPIKey string
-	googleAIApiKey  string
-}
-
-func loadConfig() config {
-	httpPort, _ := strconv.Atoi(getEnv("HTTP_PORT", "8080"))
+func loadConfig() server.Config {
 	typesensePort, _ := strconv.Atoi(getEnv("TYPESENSE_PORT", "8080"))
+	chunkSize, _ := strconv.Atoi(getEnv("CHUNK_SIZE", "1000"))
+	chunkOverlapSentences, _ := strconv.Atoi(getEnv("CHUNK_OVERLAP_SENTENCES", "1"))
+	hnswM, _ := strconv.Atoi(getEnv("HNSW_M", "16"))
+	hnswEfConstruct, _ := strconv.Atoi(getEnv("HNSW_EF_CONSTRUCTION", "200"))
+	hnswEfSearch, _ := strconv.Atoi(getEnv("HNSW_EF_SEARCH", "64"))
+	localEmbeddingDimension, _ := strconv.Atoi(getEnv("LOCAL_EMBEDDING_DIMENSION", "256"))
+	jobWorkers, _ := strconv.Atoi(getEnv("JOB_WORKERS", "4"))
+	jobMaxAttempts, _ := strconv.Atoi(getEnv("JOB_MAX_ATTEMPTS", "5"))
+	jobRetention, _ := time.ParseDuration(getEnv("JOB_RETENTION", "168h"))
+	cacheEntries, _ := strconv.Atoi(getEnv("EMBEDDING_CACHE_SIZE", "10000"))
+	cacheTTL, _ := time.ParseDuration(getEnv("EMBEDDING_CACHE_TTL", "0"))
+	cacheMaxMB, _ := strconv.Atoi(getEnv("EMBEDDING_CACHE_MAX_MB", "256"))
+	dedupThreshold, _ := strconv.ParseFloat(getEnv("DEDUP_THRESHOLD", "0.98"), 64)
+	maxUploadMB, _ := strconv.Atoi(getEnv("MAX_UPLOAD_MB", "32"))
+	historyTokens, _ := strconv.Atoi(getEnv("CONVERSATION_HISTORY_TOKENS", "2000"))
 
-	return config{
-		httpPort:        httpPort,
-		typesenseHost:   getEnv("TYPESENSE_HOST", "localhost"),
-		typesensePort:   typesensePort,
-		typesenseAPIKey: getEnv("TYPESENSE_API_KEY", ""),
-		googleAIApiKey:  getEnv("GOOGLE_API_KEY", ""),
+	return server.Config{
+		Storage:                 getEnv("STORAGE", "typesense"),
+		VectorMetric:            getEnv("VECTOR_METRIC", "cosine"),
+		HNSWDir:                 getEnv("HNSW_DIR", "data/hnsw"),
+		HNSWM:                   hnswM,
+		HNSWEfConstruct:         hnswEfConstruct,
+		HNSWEfSearch:            hnswEfSearch,
+		Quantization:            getEnv("QUANTIZATION", "int8"),
+		QuantizedDir:            getEnv("QUANTIZED_DIR", "data/quantized"),
+		TypesenseHost:           getEnv("TYPESENSE_HOST", "localhost"),
+		TypesensePort:           typesensePort,
+		TypesenseAPIKey:         getEnv("TYPESENSE_API_KEY", ""),
+		AIProvider:              getEnv("AI_PROVIDER", "google"),
+		GoogleAIApiKey:          getEnv("GOOGLE_API_KEY", ""),
+		LocalEmbeddingDimension: localEmbeddingDimension,
+		ChunkSize:               chunkSize,
+		ChunkOverlapSentences:   chunkOverlapSentences,
+		SearchFusion:            getEnv("SEARCH_FUSION", ""),
+		Reranker:                getEnv("RERANKER", ""),
+		JobsDir:                 getEnv("JOBS_DIR", "data/jobs"),
+		JobWorkers:              jobWorkers,
+		JobMaxAttempts:          jobMaxAttempts,
+		JobRetention:            jobRetention,
+		CacheEntries:            cacheEntries,
+		CacheTTL:                cacheTTL,
+		CacheDir:                getEnv("EMBEDDING_CACHE_DIR", ""),
+		CacheMaxMB:              cacheMaxMB,
+		DedupThreshold:          dedupThreshold,
+		DedupPolicy:             getEnv("DEDUP_POLICY", "keep"),
+		MaxUploadMB:             maxUploadMB,
+		ConversationsDir:        getEnv("CONVERSATIONS_DIR", "data/conversations"),
+		HistoryTokens:           historyTokens,
 	}
 }
 
@@ -44,45 +78,46 @@ func getEnv(key, fallback string) string {
 
 func main() {
 	cfg := loadConfig()
-	ctx := context.Background()
+	httpPort, _ := strconv.Atoi(getEnv("HTTP_PORT", "8080"))
+	shutdownTimeout, _ := time.ParseDuration(getEnv("SHUTDOWN_TIMEOUT", "30s"))
 
-	// Initialize infrastructure components
-	typesenseRepo, err := persistence.NewTypesenseRepository(persistence.TypesenseConfig{
-		Host:   cfg.typesenseHost,
-		Port:   cfg.typesensePort,
-		APIKey: cfg.typesenseAPIKey,
-	})
-	if err != nil {
-		log.Fatalf("Failed to create Typesense repository: %v", err)
-	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	embeddingGenerator, err := ai.NewGoogleEmbeddingGenerator(ctx, cfg.googleAIApiKey)
+	srv, err := server.New(context.Background(), cfg)
 	if err != nil {
-		log.Fatalf("Failed to create Google embedding generator: %v", err)
+		log.Fatalf("Failed to create server: %v", err)
 	}
+	expvar.Publish("embedding_cache", expvar.Func(func() any { return srv.EmbeddingCacheStats() }))
 
-	summarizer, err := ai.NewGoogleSummarizer(ctx, cfg.googleAIApiKey)
-	if err != nil {
-		log.Fatalf("Failed to create Google summarizer: %v", err)
+	// Start server
+	httpServer := &http.Server{
+		Addr:    fmt.Sprintf(":%d", httpPort),
+		Handler: srv.Handler(),
 	}
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Printf("Starting server on %s", httpServer.Addr)
+		serveErr <- httpServer.ListenAndServe()
+	}()
 
-	// Initialize application handlers
-	indexDocumentHandler := app.NewIndexDocumentHandler(typesenseRepo, embeddingGenerator)
-	searchDocumentsHandler := app.NewSearchDocumentsHandler(embeddingGenerator, typesenseRepo, summarizer)
-	httpHandlers := app.NewHTTPHandlers(indexDocumentHandler, searchDocumentsHandler)
-
-	// Set up HTTP router
-	router := mux.NewRouter()
-	router.HandleFunc("/api/v1/documents", httpHandlers.IndexDocumentHandler).Methods("POST")
-	router.HandleFunc("/api/v1/search", httpHandlers.SearchDocumentsHandler).Methods("GET")
-	router.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	}).Methods("GET")
-
-	// Start server
-	addr := fmt.Sprintf(":%d", cfg.httpPort)
-	log.Printf("Starting server on %s", addr)
-	if err := http.ListenAndServe(addr, router); err != nil {
+	select {
+	case err := <-serveErr:
+		srv.Close()
 		log.Fatalf("Failed to start server: %v", err)
+	case <-ctx.Done():
+	}
+
+	// Stop accepting requests, let the running ones finish, then stop the job workers and
+	// persist the stores.
+	log.Printf("Shutting down")
+	stop()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("Failed to shut down HTTP server: %v", err)
+	}
+	if err := srv.Close(); err != nil {
+		log.Fatalf("Failed to close server: %v", err)
 	}
 }
@@ -0,0 +1,172 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultAskLimit is the number of chunks given to the model when the query does not set a limit.
+const DefaultAskLimit = 5
+
+// ErrEmptyQuestion is returned when a question is blank.
+var ErrEmptyQuestion = errors.New("question is empty")
+
+// citationMarker matches citation markers such as "[2]" or "[1, 3]".
+var citationMarker = regexp.MustCompile(`\[(\d+(?:\s*,\s*\d+)*)\]`)
+
+// AskQuery represents a question answered from the indexed documents.
+type AskQuery struct {
+	Question string
+	// Search selects the chunks given to the model as context. Its Query is replaced by the
+	// question, and a zero Limit means DefaultAskLimit.
+	Search SearchDocumentsQuery
+}
+
+// AnswerResult represents an answer with the sources it was given and the citations of them.
+type AnswerResult struct {
+	Answer    string
+	Citations []Citation
+	Sources   []Source
+	// Uncited is set when the answer cites none of the sources, so it may not be grounded in
+	// them. It is also set when no source was found and the question was not answered.
+	Uncited bool
+}
+
+// Citation is a source cited by a marker of an answer.
+type Citation struct {
+	// Source is the 1-based position of the cited source, the n of its marker.
+	Source int
+	// Start and End are the byte offsets of the marker in the answer.
+	Start int
+	End   int
+	// DocumentID, ParentID, StartOffset and EndOffset locate the cited chunk as in Source.
+	DocumentID  string
+	ParentID    string
+	StartOffset int
+	EndOffset   int
+}
+
+// AskHandler handles the AskQuery.
+type AskHandler struct {
+	search   *SearchDocumentsHandler
+	answerer Answerer
+}
+
+// NewAskHandler creates a new AskHandler retrieving the context with the search handler.
+func NewAskHandler(search *SearchDocumentsHandler, answerer Answerer) *AskHandler {
+	return &AskHandler{search: search, answerer: answerer}
+}
+
+// Handle handles the AskQuery. The retrieved chunks are numbered in order of relevance and given
+// to the answerer, whose citation markers are resolved to the chunks.
+func (h *AskHandler) Handle(ctx context.Context, query AskQuery) (*AnswerResult, error) {
+	sources, err := h.sources(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if len(sources) == 0 {
+		return &AnswerResult{Uncited: true}, nil
+	}
+
+	answer, err := h.answerer.Answer(ctx, query.Question, passagesOf(sources))
+	if err != nil {
+		return nil, fmt.Errorf("failed to answer question: %w", err)
+	}
+	return answerResult(answer, sources), nil
+}
+
+// HandleStream handles the AskQuery like Handle while streaming the result: sources receives the
+// result with only its sources once they are retrieved, and delta every piece of the answer as it
+// is generated. The returned result is complete. An error returned by a callback stops the answer
+// and is returned.
+func (h *AskHandler) HandleStream(ctx context.Context, query AskQuery, sources func(*AnswerResult) error, delta func(string) error) (*AnswerResult, error) {
+	found, err := h.sources(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if err := sources(&AnswerResult{Sources: found}); err != nil {
+		return nil, err
+	}
+	if len(found) == 0 {
+		return &AnswerResult{Uncited: true}, nil
+	}
+
+	var answer strings.Builder
+	err = AsStreamingAnswerer(h.answerer).AnswerStream(ctx, query.Question, passagesOf(found), func(text string) error {
+		answer.WriteString(text)
+		return delta(text)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to answer question: %w", err)
+	}
+	return answerResult(answer.String(), found), nil
+}
+
+// sources retrieves the chunks answering a question.
+func (h *AskHandler) sources(ctx context.Context, query AskQuery) ([]Source, error) {
+	if strings.TrimSpace(query.Question) == "" {
+		return nil, ErrEmptyQuestion
+	}
+	search := query.Search
+	search.Query = query.Question
+	if search.Limit == 0 {
+		search.Limit = DefaultAskLimit
+	}
+
+	hits, err := h.search.retrieve(ctx, search)
+	if err != nil {
+		return nil, err
+	}
+	return sourcesOf(hits), nil
+}
+
+// passagesOf returns the text of the sources given to the answerer, headed by their titles.
+func passagesOf(sources []Source) []string {
+	passages := make([]string, len(sources))
+	for i, source := range sources {
+		passages[i] = source.Snippet
+		if title := source.Metadata.Title; title != "" {
+			passages[i] = title + "\n" + source.Snippet
+		}
+	}
+	return passages
+}
+
+func answerResult(answer string, sources []Source) *AnswerResult {
+	citations := ParseCitations(answer, sources)
+	return &AnswerResult{
+		Answer:    answer,
+		Citations: citations,
+		Sources:   sources,
+		Uncited:   len(citations) == 0,
+	}
+}
+
+// ParseCitations returns the citations of the [n] markers of an answer in order. A marker may cite
+// several sources, e.g. "[1, 3]", and markers of sources out of range are ignored.
+func ParseCitations(answer string, sources []Source) []Citation {
+	var citations []Citation
+	for _, loc := range citationMarker.FindAllStringSubmatchIndex(answer, -1) {
+		for _, field := range strings.Split(answer[loc[2]:loc[3]], ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil || n < 1 || n > len(sources) {
+				continue
+			}
+			source := sources[n-1]
+			citations = append(citations, Citation{
+				Source:      n,
+				Start:       loc[0],
+				End:         loc[1],
+				DocumentID:  source.DocumentID,
+				ParentID:    source.ParentID,
+				StartOffset: source.StartOffset,
+				EndOffset:   source.EndOffset,
+			})
+		}
+	}
+	return citations
+}
@@ -0,0 +1,157 @@
+package app
+
+import (
+	"context"
+	"testing"
+
+	"github.com/igorrius/go-vector-search/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockAnswerer struct {
+	mock.Mock
+}
+
+func (m *MockAnswerer) Answer(ctx context.Context, question string, passages []string) (string, error) {
+	args := m.Called(ctx, question, passages)
+	return args.String(0), args.Error(1)
+}
+
+func TestParseCitations(t *testing.T) {
+	sources := []Source{
+		{DocumentID: "a#0", ParentID: "a", StartOffset: 0, EndOffset: 10},
+		{DocumentID: "b#2", ParentID: "b", StartOffset: 20, EndOffset: 30},
+	}
+
+	t.Run("should resolve markers to their sources", func(t *testing.T) {
+		// Act
+		citations := ParseCitations("Go is fast [2]. It compiles [1, 2][7].", sources)
+
+		// Assert
+		assert.Equal(t, []Citation{
+			{Source: 2, Start: 11, End: 14, DocumentID: "b#2", ParentID: "b", StartOffset: 20, EndOffset: 30},
+			{Source: 1, Start: 28, End: 34, DocumentID: "a#0", ParentID: "a", StartOffset: 0, EndOffset: 10},
+			{Source: 2, Start: 28, End: 34, DocumentID: "b#2", ParentID: "b", StartOffset: 20, EndOffset: 30},
+		}, citations)
+	})
+
+	t.Run("should ignore text that is not a marker", func(t *testing.T) {
+		// Act
+		citations := ParseCitations("See [the docs] or a[0] or [x].", sources)
+
+		// Assert
+		assert.Empty(t, citations)
+	})
+}
+
+func TestAskHandler_Handle(t *testing.T) {
+	ctx := context.Background()
+	embedding := []float32{1, 2, 3}
+	hits := []SearchHit{
+		{Document: domain.Document{ID: "a#0", ParentID: "a", Content: "Go compiles fast.", EndOffset: 17, Metadata: domain.Metadata{Title: "Go"}}},
+		{Document: domain.Document{ID: "b#0", ParentID: "b", Content: "Rust has no GC.", EndOffset: 15}},
+	}
+
+	newHandler := func(hits []SearchHit) (*AskHandler, *MockAnswerer) {
+		embedder := new(MockEmbeddingGenerator)
+		embedder.On("Generate", ctx, "Is Go fast?").Return(embedding, nil)
+		store := new(MockVectorStore)
+		store.On("Search", ctx, SearchOptions{Query: "Is Go fast?", Embedding: embedding, Limit: DefaultAskLimit}).Return(hits, nil)
+		answerer := new(MockAnswerer)
+		return NewAskHandler(NewSearchDocumentsHandler(embedder, store, nil, nil), answerer), answerer
+	}
+
+	t.Run("should answer from the numbered chunks with citations", func(t *testing.T) {
+		// Arrange
+		handler, answerer := newHandler(hits)
+		answerer.On("Answer", ctx, "Is Go fast?", []string{"Go\nGo compiles fast.", "Rust has no GC."}).Return("Yes [1].", nil)
+
+		// Act
+		result, err := handler.Handle(ctx, AskQuery{Question: "Is Go fast?"})
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, "Yes [1].", result.Answer)
+		assert.Len(t, result.Sources, 2)
+		assert.Equal(t, []Citation{{Source: 1, Start: 4, End: 7, DocumentID: "a#0", ParentID: "a", EndOffset: 17}}, result.Citations)
+		assert.False(t, result.Uncited)
+	})
+
+	t.Run("should flag an answer without citations", func(t *testing.T) {
+		// Arrange
+		handler, answerer := newHandler(hits)
+		answerer.On("Answer", ctx, "Is Go fast?", mock.Anything).Return("Yes.", nil)
+
+		// Act
+		result, err := handler.Handle(ctx, AskQuery{Question: "Is Go fast?"})
+
+		// Assert
+		require.NoError(t, err)
+		assert.Empty(t, result.Citations)
+		assert.True(t, result.Uncited)
+	})
+
+	t.Run("should not ask the model without sources", func(t *testing.T) {
+		// Arrange
+		handler, answerer := newHandler([]SearchHit{})
+
+		// Act
+		result, err := handler.Handle(ctx, AskQuery{Question: "Is Go fast?"})
+
+		// Assert
+		require.NoError(t, err)
+		assert.Empty(t, result.Answer)
+		assert.True(t, result.Uncited)
+		answerer.AssertNotCalled(t, "Answer", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("should reject a blank question", func(t *testing.T) {
+		// Arrange
+		handler, _ := newHandler(hits)
+
+		// Act
+		_, err := handler.Handle(ctx, AskQuery{Question: "  "})
+
+		// Assert
+		assert.ErrorIs(t, err, ErrEmptyQuestion)
+	})
+}
+
+func TestAskHandler_HandleStream(t *testing.T) {
+	ctx := context.Background()
+	embedding := []float32{1, 2, 3}
+	hits := []SearchHit{{Document: domain.Document{ID: "a#0", ParentID: "a", Content: "Go compiles fast."}}}
+	embedder := new(MockEmbeddingGenerator)
+	embedder.On("Generate", ctx, "Is Go fast?").Return(embedding, nil)
+	store := new(MockVectorStore)
+	store.On("Search", ctx, SearchOptions{Query: "Is Go fast?", Embedding: embedding, Limit: DefaultAskLimit}).Return(hits, nil)
+	answerer := new(MockAnswerer)
+	answerer.On("Answer", ctx, "Is Go fast?", []string{"Go compiles fast."}).Return("Yes [1].", nil)
+	handler := NewAskHandler(NewSearchDocumentsHandler(embedder, store, nil, nil), answerer)
+
+	t.Run("should stream the sources and the answer with its citations", func(t *testing.T) {
+		// Arrange
+		var events []string
+
+		// Act
+		result, err := handler.HandleStream(ctx, AskQuery{Question: "Is Go fast?"},
+			func(result *AnswerResult) error {
+				events = append(events, "sources:"+result.Sources[0].DocumentID)
+				return nil
+			},
+			func(delta string) error {
+				events = append(events, "delta:"+delta)
+				return nil
+			},
+		)
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, []string{"sources:a#0", "delta:Yes [1]."}, events)
+		assert.Equal(t, "Yes [1].", result.Answer)
+		assert.Len(t, result.Citations, 1)
+		assert.False(t, result.Uncited)
+	})
+}
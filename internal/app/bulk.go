@@ -0,0 +1,263 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/igorrius/go-vector-search/internal/domain"
+)
+
+const (
+	// BulkBatchSize is the largest number of documents indexed together by a bulk import.
+	BulkBatchSize = 32
+	// bulkEmbedChunks is the number of chunks up to which the chunks of several documents are
+	// embedded in one request.
+	bulkEmbedChunks = 256
+	// bulkEmbedConcurrency limits the embedding requests run concurrently for one batch.
+	bulkEmbedConcurrency = 8
+)
+
+// ErrDuplicateID is reported for a document of a bulk import whose ID an earlier document of the
+// same batch has.
+var ErrDuplicateID = errors.New("document ID repeated in the batch")
+
+// BulkIndexDocumentsHandler indexes batches of IndexDocumentCommand, embedding the chunks of
+// several documents per request and saving them in one round trip when the repository is a
+// BatchSaver.
+type BulkIndexDocumentsHandler struct {
+	repo     domain.DocumentRepository
+	embedder EmbeddingGenerator
+	chunker  Chunker
+	dedup    *DuplicateDetector
+}
+
+// NewBulkIndexDocumentsHandler creates a new BulkIndexDocumentsHandler. Without a
+// DuplicateDetector, the duplicate policy of the commands is ignored.
+func NewBulkIndexDocumentsHandler(repo domain.DocumentRepository, embedder EmbeddingGenerator, chunker Chunker, dedup *DuplicateDetector) *BulkIndexDocumentsHandler {
+	return &BulkIndexDocumentsHandler{
+		repo:     repo,
+		embedder: embedder,
+		chunker:  chunker,
+		dedup:    dedup,
+	}
+}
+
+// bulkDocument is a command of a batch with its chunks.
+type bulkDocument struct {
+	cmd    IndexDocumentCommand
+	chunks []*domain.Document
+	// replaced is set when a previous version of the document is stored.
+	replaced bool
+	result   IndexDocumentResult
+	err      error
+}
+
+// indexed reports whether the document is still to be stored.
+func (d *bulkDocument) indexed() bool {
+	return d.err == nil && !d.result.Skipped
+}
+
+// Handle indexes the commands and returns the result and the error of each one in order, the
+// error being nil for those indexed. A failing document does not stop the others; a document
+// whose chunks were only partly saved reports the first error. A document with the ID of an
+// earlier one fails with ErrDuplicateID. Exact duplicates are also found among the earlier
+// documents of the batch.
+func (h *BulkIndexDocumentsHandler) Handle(ctx context.Context, cmds []IndexDocumentCommand) ([]IndexDocumentResult, []error) {
+	docs := make([]bulkDocument, len(cmds))
+	ids := make(map[string]bool)
+	seen := make(map[string]string)
+	for i, cmd := range cmds {
+		doc := &docs[i]
+		cmd.OnDuplicate = h.dedup.resolve(cmd.OnDuplicate)
+		doc.cmd = cmd
+		if ids[cmd.ID] {
+			doc.err = ErrDuplicateID
+			continue
+		}
+		ids[cmd.ID] = true
+		doc.chunks, doc.replaced, doc.err = h.prepare(ctx, cmd)
+		if doc.err != nil {
+			continue
+		}
+		hash := doc.chunks[0].Metadata.ContentHash
+		if h.dedup.applies(cmd.OnDuplicate) {
+			doc.result.DuplicateOf, doc.err = h.dedup.FindExact(ctx, cmd.ID, hash)
+			if doc.result.DuplicateOf == "" && seen[hash] != cmd.ID {
+				doc.result.DuplicateOf = seen[hash]
+			}
+			doc.result.Skipped = doc.result.DuplicateOf != "" && cmd.OnDuplicate == DuplicateSkip
+		}
+		if doc.indexed() {
+			seen[hash] = cmd.ID
+		}
+	}
+
+	h.embed(ctx, docs)
+
+	for i := range docs {
+		doc := &docs[i]
+		if !doc.indexed() || !h.dedup.applies(doc.cmd.OnDuplicate) || doc.result.DuplicateOf != "" {
+			continue
+		}
+		embeddings := make([][]float32, len(doc.chunks))
+		for j, chunk := range doc.chunks {
+			embeddings[j] = chunk.Embedding
+		}
+		doc.result.DuplicateOf, doc.err = h.dedup.FindNear(ctx, doc.cmd.ID, embeddings)
+		doc.result.Skipped = doc.result.DuplicateOf != "" && doc.cmd.OnDuplicate == DuplicateSkip
+	}
+
+	var batch []*domain.Document
+	var owners []int
+	for i, doc := range docs {
+		if !doc.indexed() {
+			continue
+		}
+		batch = append(batch, doc.chunks...)
+		for range doc.chunks {
+			owners = append(owners, i)
+		}
+	}
+
+	for i, err := range h.save(ctx, batch) {
+		if err != nil && docs[owners[i]].err == nil {
+			docs[owners[i]].err = err
+		}
+	}
+
+	for i := range docs {
+		doc := &docs[i]
+		if doc.indexed() && doc.replaced {
+			doc.err = deleteChunksFrom(ctx, h.repo, doc.cmd.ID, len(doc.chunks))
+		}
+		if doc.indexed() && doc.result.DuplicateOf != "" && doc.cmd.OnDuplicate == DuplicateReplace {
+			doc.err = h.dedup.Remove(ctx, doc.result.DuplicateOf)
+		}
+	}
+
+	results := make([]IndexDocumentResult, len(docs))
+	errs := make([]error, len(docs))
+	for i, doc := range docs {
+		results[i] = doc.result
+		errs[i] = doc.err
+	}
+	return results, errs
+}
+
+// prepare splits the command content into chunks carrying the stamped metadata. It reports
+// whether a previous version of the document is stored.
+func (h *BulkIndexDocumentsHandler) prepare(ctx context.Context, cmd IndexDocumentCommand) ([]*domain.Document, bool, error) {
+	chunks := h.chunker.Chunk(cmd.Content)
+	if len(chunks) == 0 {
+		return nil, false, ErrEmptyDocument
+	}
+
+	metadata, replaced, err := stampMetadata(ctx, h.repo, cmd)
+	if err != nil {
+		return nil, false, err
+	}
+
+	docs := make([]*domain.Document, len(chunks))
+	for i, chunk := range chunks {
+		docs[i] = domain.NewChunk(cmd.ID, i, chunk.Content, chunk.Start, chunk.End)
+		docs[i].SetMetadata(metadata)
+	}
+	return docs, replaced, nil
+}
+
+// embed generates the embeddings of the prepared documents, recording the error of each
+// document. The chunks of consecutive documents are embedded together in requests of up to
+// bulkEmbedChunks chunks; a document with more chunks has a request of its own. When a request
+// of several documents fails, they are embedded one by one, so that only the failing documents
+// report an error.
+func (h *BulkIndexDocumentsHandler) embed(ctx context.Context, docs []bulkDocument) {
+	var groups [][]*bulkDocument
+	var group []*bulkDocument
+	size := 0
+	for i := range docs {
+		doc := &docs[i]
+		if !doc.indexed() {
+			continue
+		}
+		if len(group) > 0 && size+len(doc.chunks) > bulkEmbedChunks {
+			groups = append(groups, group)
+			group, size = nil, 0
+		}
+		group = append(group, doc)
+		size += len(doc.chunks)
+	}
+	if len(group) > 0 {
+		groups = append(groups, group)
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, bulkEmbedConcurrency)
+	for _, group := range groups {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			err := h.embedGroup(ctx, group)
+			if err == nil {
+				return
+			}
+			if len(group) == 1 {
+				group[0].err = err
+				return
+			}
+			for _, doc := range group {
+				doc.err = h.embedGroup(ctx, []*bulkDocument{doc})
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// embedGroup embeds the chunks of the documents in one request and hands the embeddings back to
+// the chunks by their offset.
+func (h *BulkIndexDocumentsHandler) embedGroup(ctx context.Context, group []*bulkDocument) error {
+	var contents []string
+	for _, doc := range group {
+		for _, chunk := range doc.chunks {
+			contents = append(contents, chunk.Content)
+		}
+	}
+	embeddings, err := generateEmbeddings(ctx, h.embedder, h.repo, contents)
+	if err != nil {
+		return err
+	}
+	for _, doc := range group {
+		for _, chunk := range doc.chunks {
+			chunk.SetEmbedding(embeddings[0])
+			embeddings = embeddings[1:]
+		}
+	}
+	return nil
+}
+
+// save stores the chunks and returns the error of each one in order.
+func (h *BulkIndexDocumentsHandler) save(ctx context.Context, chunks []*domain.Document) []error {
+	if len(chunks) == 0 {
+		return nil
+	}
+
+	if saver, ok := h.repo.(BatchSaver); ok {
+		errs, err := saver.SaveBatch(ctx, chunks)
+		if err != nil {
+			errs = make([]error, len(chunks))
+			for i := range errs {
+				errs[i] = err
+			}
+		}
+		return errs
+	}
+
+	errs := make([]error, len(chunks))
+	for i, chunk := range chunks {
+		errs[i] = h.repo.Save(ctx, chunk)
+	}
+	return errs
+}
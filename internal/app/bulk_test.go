@@ -0,0 +1,139 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/igorrius/go-vector-search/internal/app"
+	"github.com/igorrius/go-vector-search/internal/domain"
+	"github.com/igorrius/go-vector-search/internal/infra/persistence/memory"
+)
+
+// MockBatchRepository is a MockDocumentRepository that saves batches.
+type MockBatchRepository struct {
+	MockDocumentRepository
+}
+
+func (m *MockBatchRepository) SaveBatch(ctx context.Context, docs []*domain.Document) ([]error, error) {
+	args := m.Called(ctx, docs)
+	return args.Get(0).([]error), args.Error(1)
+}
+
+func TestBulkIndexDocumentsHandler_Handle(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should report the outcome of every document", func(t *testing.T) {
+		// Arrange
+		store := memory.NewStore(app.Cosine)
+		embedder := new(MockEmbeddingGenerator)
+		embedder.On("Generate", mock.Anything, "First sentence.").Return([]float32{1, 0, 0}, nil)
+		embedder.On("Generate", mock.Anything, "Second sentence.").Return([]float32{0, 1, 0}, nil)
+		embedder.On("Generate", mock.Anything, "Broken.").Return([]float32(nil), errors.New("quota exceeded"))
+		handler := app.NewBulkIndexDocumentsHandler(store, embedder, app.NewSentenceChunker(20, 0), nil)
+
+		// Act
+		_, errs := handler.Handle(ctx, []app.IndexDocumentCommand{
+			{ID: "a", Content: "First sentence. Second sentence."},
+			{ID: "b", Content: " "},
+			{ID: "c", Content: "Broken."},
+		})
+
+		// Assert
+		require.Len(t, errs, 3)
+		assert.NoError(t, errs[0])
+		assert.ErrorIs(t, errs[1], app.ErrEmptyDocument)
+		assert.EqualError(t, errs[2], "quota exceeded")
+		second, err := store.FindByID(ctx, domain.ChunkID("a", 1))
+		require.NoError(t, err)
+		assert.Equal(t, []float32{0, 1, 0}, second.Embedding)
+		_, err = store.FindByID(ctx, domain.ChunkID("c", 0))
+		assert.ErrorIs(t, err, domain.ErrDocumentNotFound)
+	})
+
+	t.Run("should embed the chunks of all documents in one request", func(t *testing.T) {
+		// Arrange
+		store := memory.NewStore(app.Cosine)
+		embedder := new(MockBatchEmbeddingGenerator)
+		embedder.On("GenerateBatch", mock.Anything, []string{"First sentence.", "Second sentence.", "Third sentence."}).
+			Return([][]float32{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}, nil).Once()
+		handler := app.NewBulkIndexDocumentsHandler(store, embedder, app.NewSentenceChunker(20, 0), nil)
+
+		// Act
+		_, errs := handler.Handle(ctx, []app.IndexDocumentCommand{
+			{ID: "a", Content: "First sentence. Second sentence."},
+			{ID: "b", Content: "Third sentence."},
+		})
+
+		// Assert
+		assert.Equal(t, []error{nil, nil}, errs)
+		third, err := store.FindByID(ctx, domain.ChunkID("b", 0))
+		require.NoError(t, err)
+		assert.Equal(t, []float32{0, 0, 1}, third.Embedding)
+		embedder.AssertExpectations(t)
+	})
+
+	t.Run("should reject a repeated document ID", func(t *testing.T) {
+		// Arrange
+		store := memory.NewStore(app.Cosine)
+		embedder := new(MockEmbeddingGenerator)
+		embedder.On("Generate", mock.Anything, "First.").Return([]float32{1, 0, 0}, nil)
+		handler := app.NewBulkIndexDocumentsHandler(store, embedder, app.NewSentenceChunker(20, 0), nil)
+
+		// Act
+		_, errs := handler.Handle(ctx, []app.IndexDocumentCommand{
+			{ID: "a", Content: "First."},
+			{ID: "a", Content: "Second."},
+		})
+
+		// Assert
+		assert.NoError(t, errs[0])
+		assert.ErrorIs(t, errs[1], app.ErrDuplicateID)
+		found, err := store.FindByID(ctx, domain.ChunkID("a", 0))
+		require.NoError(t, err)
+		assert.Equal(t, "First.", found.Content)
+	})
+
+	t.Run("should delete the chunks of a longer previous version", func(t *testing.T) {
+		// Arrange
+		store := memory.NewStore(app.Cosine)
+		embedder := new(MockEmbeddingGenerator)
+		embedder.On("Generate", mock.Anything, mock.Anything).Return([]float32{1, 0, 0}, nil)
+		handler := app.NewBulkIndexDocumentsHandler(store, embedder, app.NewSentenceChunker(20, 0), nil)
+		_, errs := handler.Handle(ctx, []app.IndexDocumentCommand{{ID: "a", Content: "First sentence. Second sentence."}})
+		require.NoError(t, errs[0])
+
+		// Act
+		_, errs = handler.Handle(ctx, []app.IndexDocumentCommand{{ID: "a", Content: "Only sentence."}})
+
+		// Assert
+		require.NoError(t, errs[0])
+		_, err := store.FindByID(ctx, domain.ChunkID("a", 1))
+		assert.ErrorIs(t, err, domain.ErrDocumentNotFound)
+	})
+
+	t.Run("should save all chunks in one batch", func(t *testing.T) {
+		// Arrange
+		repo := new(MockBatchRepository)
+		repo.On("FindByID", ctx, mock.Anything).Return((*domain.Document)(nil), domain.ErrDocumentNotFound)
+		repo.On("SaveBatch", ctx, mock.MatchedBy(func(docs []*domain.Document) bool {
+			return len(docs) == 2 && docs[0].ID == domain.ChunkID("a", 0) && docs[1].ID == domain.ChunkID("b", 0)
+		})).Return([]error{nil, errors.New("rejected")}, nil)
+		embedder := new(MockEmbeddingGenerator)
+		embedder.On("Generate", mock.Anything, mock.Anything).Return([]float32{1, 0, 0}, nil)
+		handler := app.NewBulkIndexDocumentsHandler(repo, embedder, app.NewFixedSizeChunker(100, 0), nil)
+
+		// Act
+		_, errs := handler.Handle(ctx, []app.IndexDocumentCommand{{ID: "a", Content: "alpha"}, {ID: "b", Content: "beta"}})
+
+		// Assert
+		assert.NoError(t, errs[0])
+		assert.EqualError(t, errs[1], "rejected")
+		repo.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+		repo.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,222 @@
+package app
+
+import (
+	"strings"
+	"unicode"
+)
+
+const defaultChunkSize = 1000
+
+// Chunk is a span of a larger text.
+type Chunk struct {
+	Content string
+	// Start and End are the character offsets of the chunk in the original text, End being exclusive.
+	Start int
+	End   int
+}
+
+// Chunker splits a text into chunks that are indexed as separate documents.
+type Chunker interface {
+	Chunk(text string) []Chunk
+}
+
+// FixedSizeChunker splits a text into chunks of a fixed number of characters,
+// with consecutive chunks sharing an overlap.
+type FixedSizeChunker struct {
+	size    int
+	overlap int
+}
+
+// NewFixedSizeChunker creates a new FixedSizeChunker. A non-positive size falls back to the default
+// chunk size and the overlap is clamped to [0, size).
+func NewFixedSizeChunker(size, overlap int) *FixedSizeChunker {
+	if size <= 0 {
+		size = defaultChunkSize
+	}
+	overlap = max(0, min(overlap, size-1))
+	return &FixedSizeChunker{size: size, overlap: overlap}
+}
+
+// Chunk splits the text into fixed-size chunks.
+func (c *FixedSizeChunker) Chunk(text string) []Chunk {
+	runes := []rune(text)
+	return fixedSizeChunks(runes, 0, len(runes), c.size, c.overlap)
+}
+
+// SentenceChunker groups whole sentences into chunks of at most maxSize characters.
+// Consecutive chunks share the given number of sentences.
+type SentenceChunker struct {
+	maxSize int
+	overlap int
+}
+
+// NewSentenceChunker creates a new SentenceChunker. A non-positive maxSize falls back to the default
+// chunk size and a negative overlap is treated as zero.
+func NewSentenceChunker(maxSize, overlap int) *SentenceChunker {
+	if maxSize <= 0 {
+		maxSize = defaultChunkSize
+	}
+	return &SentenceChunker{maxSize: maxSize, overlap: max(0, overlap)}
+}
+
+// Chunk splits the text into sentence-aligned chunks.
+func (c *SentenceChunker) Chunk(text string) []Chunk {
+	runes := []rune(text)
+	return c.chunkRange(runes, 0, len(runes))
+}
+
+func (c *SentenceChunker) chunkRange(runes []rune, from, to int) []Chunk {
+	sentences := sentenceSpans(runes, from, to)
+
+	var chunks []Chunk
+	for i := 0; i < len(sentences); {
+		start := sentences[i].start
+		j := i
+		for j < len(sentences) && sentences[j].end-start <= c.maxSize {
+			j++
+		}
+
+		// A single sentence longer than maxSize is split at fixed positions.
+		if j == i {
+			chunks = append(chunks, fixedSizeChunks(runes, sentences[i].start, sentences[i].end, c.maxSize, 0)...)
+			i++
+			continue
+		}
+
+		if chunk, ok := trimmedChunk(runes, start, sentences[j-1].end); ok {
+			chunks = append(chunks, chunk)
+		}
+		if j == len(sentences) {
+			break
+		}
+		i = max(j-c.overlap, i+1)
+	}
+
+	return chunks
+}
+
+// MarkdownChunker splits a Markdown text at its headings so every chunk belongs to a single section.
+// Sections longer than maxSize are further split into sentence-aligned chunks.
+type MarkdownChunker struct {
+	maxSize   int
+	sentences *SentenceChunker
+}
+
+// NewMarkdownChunker creates a new MarkdownChunker. The overlap, in sentences, applies to oversized
+// sections only.
+func NewMarkdownChunker(maxSize, overlap int) *MarkdownChunker {
+	sentences := NewSentenceChunker(maxSize, overlap)
+	return &MarkdownChunker{maxSize: sentences.maxSize, sentences: sentences}
+}
+
+// Chunk splits the text into heading-aligned chunks.
+func (c *MarkdownChunker) Chunk(text string) []Chunk {
+	runes := []rune(text)
+
+	var chunks []Chunk
+	for _, section := range markdownSections(runes) {
+		if section.end-section.start <= c.maxSize {
+			if chunk, ok := trimmedChunk(runes, section.start, section.end); ok {
+				chunks = append(chunks, chunk)
+			}
+			continue
+		}
+		chunks = append(chunks, c.sentences.chunkRange(runes, section.start, section.end)...)
+	}
+
+	return chunks
+}
+
+type span struct {
+	start int
+	end   int
+}
+
+func fixedSizeChunks(runes []rune, from, to, size, overlap int) []Chunk {
+	var chunks []Chunk
+	for start := from; start < to; start = max(start+size-overlap, start+1) {
+		end := min(start+size, to)
+		if chunk, ok := trimmedChunk(runes, start, end); ok {
+			chunks = append(chunks, chunk)
+		}
+		if end == to {
+			break
+		}
+	}
+	return chunks
+}
+
+// sentenceSpans splits runes[from:to] into sentences. A sentence ends after '.', '!' or '?'
+// followed by whitespace, or at a blank line.
+func sentenceSpans(runes []rune, from, to int) []span {
+	var spans []span
+	start := from
+	for i := from; i < to; i++ {
+		end := -1
+		switch {
+		case strings.ContainsRune(".!?", runes[i]) && (i+1 == to || unicode.IsSpace(runes[i+1])):
+			end = i + 1
+		case runes[i] == '\n' && i+1 < to && runes[i+1] == '\n':
+			end = i
+		}
+		if end > start {
+			spans = append(spans, span{start: start, end: end})
+			start = end
+		}
+	}
+	if start < to {
+		spans = append(spans, span{start: start, end: to})
+	}
+	return spans
+}
+
+// markdownSections splits runes into sections starting at ATX headings.
+// Headings inside fenced code blocks are ignored.
+func markdownSections(runes []rune) []span {
+	var spans []span
+	start := 0
+	inFence := false
+	for lineStart := 0; lineStart < len(runes); {
+		lineEnd := lineStart
+		for lineEnd < len(runes) && runes[lineEnd] != '\n' {
+			lineEnd++
+		}
+		line := strings.TrimLeft(string(runes[lineStart:lineEnd]), " ")
+
+		switch {
+		case strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~"):
+			inFence = !inFence
+		case !inFence && isMarkdownHeading(line) && lineStart > start:
+			spans = append(spans, span{start: start, end: lineStart})
+			start = lineStart
+		}
+
+		lineStart = lineEnd + 1
+	}
+	if start < len(runes) {
+		spans = append(spans, span{start: start, end: len(runes)})
+	}
+	return spans
+}
+
+func isMarkdownHeading(line string) bool {
+	level := 0
+	for level < len(line) && line[level] == '#' {
+		level++
+	}
+	return level >= 1 && level <= 6 && (level == len(line) || line[level] == ' ' || line[level] == '\t')
+}
+
+// trimmedChunk returns the chunk for runes[start:end] without surrounding whitespace.
+func trimmedChunk(runes []rune, start, end int) (Chunk, bool) {
+	for start < end && unicode.IsSpace(runes[start]) {
+		start++
+	}
+	for end > start && unicode.IsSpace(runes[end-1]) {
+		end--
+	}
+	if start == end {
+		return Chunk{}, false
+	}
+	return Chunk{Content: string(runes[start:end]), Start: start, End: end}, true
+}
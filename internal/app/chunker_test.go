@@ -0,0 +1,96 @@
+package app_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/igorrius/go-vector-search/internal/app"
+)
+
+// assertSpans checks that every chunk points back to its exact span in the original text.
+func assertSpans(t *testing.T, text string, chunks []app.Chunk) {
+	t.Helper()
+	runes := []rune(text)
+	for _, chunk := range chunks {
+		assert.Equal(t, chunk.Content, string(runes[chunk.Start:chunk.End]))
+	}
+}
+
+func TestFixedSizeChunker_Chunk(t *testing.T) {
+	text := "abcdefghij"
+
+	chunks := app.NewFixedSizeChunker(4, 1).Chunk(text)
+
+	assert.Equal(t, []app.Chunk{
+		{Content: "abcd", Start: 0, End: 4},
+		{Content: "defg", Start: 3, End: 7},
+		{Content: "ghij", Start: 6, End: 10},
+	}, chunks)
+	assertSpans(t, text, chunks)
+}
+
+func TestFixedSizeChunker_Chunk_Unicode(t *testing.T) {
+	text := "привіт світ"
+
+	chunks := app.NewFixedSizeChunker(6, 0).Chunk(text)
+
+	assert.Equal(t, []string{"привіт", "світ"}, []string{chunks[0].Content, chunks[1].Content})
+	assertSpans(t, text, chunks)
+}
+
+func TestSentenceChunker_Chunk(t *testing.T) {
+	text := "One is short. Two is short too! Three?\n\nFour starts a paragraph"
+
+	t.Run("should group sentences up to the max size", func(t *testing.T) {
+		chunks := app.NewSentenceChunker(40, 0).Chunk(text)
+
+		assert.Equal(t, []string{
+			"One is short. Two is short too! Three?",
+			"Four starts a paragraph",
+		}, contents(chunks))
+		assertSpans(t, text, chunks)
+	})
+
+	t.Run("should repeat overlapping sentences", func(t *testing.T) {
+		chunks := app.NewSentenceChunker(32, 1).Chunk(text)
+
+		assert.Equal(t, []string{
+			"One is short. Two is short too!",
+			"Two is short too! Three?",
+			"Three?\n\nFour starts a paragraph",
+		}, contents(chunks))
+		assertSpans(t, text, chunks)
+	})
+
+	t.Run("should split sentences longer than the max size", func(t *testing.T) {
+		long := strings.Repeat("a", 25) + "."
+
+		chunks := app.NewSentenceChunker(10, 0).Chunk(long)
+
+		assert.Len(t, chunks, 3)
+		assertSpans(t, long, chunks)
+	})
+}
+
+func TestMarkdownChunker_Chunk(t *testing.T) {
+	text := "Intro text.\n\n# Title\nBody of title.\n\n```\n# not a heading\n```\n## Sub\nSub body."
+
+	chunks := app.NewMarkdownChunker(100, 0).Chunk(text)
+
+	assert.Equal(t, []string{
+		"Intro text.",
+		"# Title\nBody of title.\n\n```\n# not a heading\n```",
+		"## Sub\nSub body.",
+	}, contents(chunks))
+	assertSpans(t, text, chunks)
+}
+
+func contents(chunks []app.Chunk) []string {
+	var out []string
+	for _, chunk := range chunks {
+		out = append(out, chunk.Content)
+	}
+	return out
+}
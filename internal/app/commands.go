@@ -2,40 +2,356 @@ package app
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/igorrius/go-vector-search/internal/domain"
 )
 
 // IndexDocumentCommand is a command to index a document.
 type IndexDocumentCommand struct {
-	ID      string
-	Content string
+	ID       string
+	Content  string
+	Metadata domain.Metadata
+	// OnDuplicate decides what happens when the document duplicates an indexed one. Empty selects
+	// the default policy of the DuplicateDetector.
+	OnDuplicate DuplicatePolicy
+}
+
+// ErrEmptyDocument is returned when a document has no content to index.
+var ErrEmptyDocument = errors.New("document has no content")
+
+// DimensionMismatchError is returned when an embedding does not match the dimension declared by the store.
+type DimensionMismatchError struct {
+	Expected int
+	Actual   int
+}
+
+func (e *DimensionMismatchError) Error() string {
+	return fmt.Sprintf("embedding dimension mismatch: store expects %d, got %d", e.Expected, e.Actual)
 }
 
 // IndexDocumentHandler handles the IndexDocumentCommand.
 type IndexDocumentHandler struct {
 	repo     domain.DocumentRepository
 	embedder EmbeddingGenerator
+	chunker  Chunker
+	dedup    *DuplicateDetector
 }
 
-// NewIndexDocumentHandler creates a new IndexDocumentHandler.
-func NewIndexDocumentHandler(repo domain.DocumentRepository, embedder EmbeddingGenerator) *IndexDocumentHandler {
+// NewIndexDocumentHandler creates a new IndexDocumentHandler. Without a DuplicateDetector, the
+// duplicate policy of the commands is ignored and every document is indexed.
+func NewIndexDocumentHandler(repo domain.DocumentRepository, embedder EmbeddingGenerator, chunker Chunker, dedup *DuplicateDetector) *IndexDocumentHandler {
 	return &IndexDocumentHandler{
 		repo:     repo,
 		embedder: embedder,
+		chunker:  chunker,
+		dedup:    dedup,
+	}
+}
+
+// Handle handles the IndexDocumentCommand. The content is split into chunks, which are embedded
+// in one batch and saved as separate documents referring to cmd.ID as their parent; chunks left
+// over from a longer previous version are deleted. Unless the policy is DuplicateKeep, an exact
+// duplicate is looked up before embedding and a near duplicate after it.
+func (h *IndexDocumentHandler) Handle(ctx context.Context, cmd IndexDocumentCommand) (*IndexDocumentResult, error) {
+	chunks := h.chunker.Chunk(cmd.Content)
+	if len(chunks) == 0 {
+		return nil, ErrEmptyDocument
+	}
+
+	metadata, replaced, err := stampMetadata(ctx, h.repo, cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd.OnDuplicate = h.dedup.resolve(cmd.OnDuplicate)
+	detect := h.dedup.applies(cmd.OnDuplicate)
+	result := &IndexDocumentResult{}
+	if detect {
+		if result.DuplicateOf, err = h.dedup.FindExact(ctx, cmd.ID, metadata.ContentHash); err != nil {
+			return nil, err
+		}
+		if result.DuplicateOf != "" && cmd.OnDuplicate == DuplicateSkip {
+			result.Skipped = true
+			return result, nil
+		}
+	}
+
+	contents := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		contents[i] = chunk.Content
+	}
+	embeddings, err := generateEmbeddings(ctx, h.embedder, h.repo, contents)
+	if err != nil {
+		return nil, err
+	}
+
+	if detect && result.DuplicateOf == "" {
+		if result.DuplicateOf, err = h.dedup.FindNear(ctx, cmd.ID, embeddings); err != nil {
+			return nil, err
+		}
+		if result.DuplicateOf != "" && cmd.OnDuplicate == DuplicateSkip {
+			result.Skipped = true
+			return result, nil
+		}
+	}
+
+	for i, chunk := range chunks {
+		doc := domain.NewChunk(cmd.ID, i, chunk.Content, chunk.Start, chunk.End)
+		doc.SetMetadata(metadata)
+		doc.SetEmbedding(embeddings[i])
+
+		if err := h.repo.Save(ctx, doc); err != nil {
+			return nil, err
+		}
+	}
+	if replaced {
+		if err := deleteChunksFrom(ctx, h.repo, cmd.ID, len(chunks)); err != nil {
+			return nil, err
+		}
+	}
+
+	if result.DuplicateOf != "" && cmd.OnDuplicate == DuplicateReplace {
+		if err := h.dedup.Remove(ctx, result.DuplicateOf); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// stampMetadata sets the content hash and the timestamps of the command metadata, keeping the
+// creation time of a previously indexed version of the document. It reports whether such a
+// version is stored.
+func stampMetadata(ctx context.Context, repo domain.DocumentRepository, cmd IndexDocumentCommand) (domain.Metadata, bool, error) {
+	metadata := cmd.Metadata
+	metadata.ContentHash = ContentHash(cmd.Content)
+	now := time.Now().UTC()
+	metadata.CreatedAt = now
+	metadata.UpdatedAt = now
+
+	existing, err := repo.FindByID(ctx, domain.ChunkID(cmd.ID, 0))
+	switch {
+	case err == nil:
+		if !existing.Metadata.CreatedAt.IsZero() {
+			metadata.CreatedAt = existing.Metadata.CreatedAt
+		}
+		return metadata, true, nil
+	case errors.Is(err, domain.ErrDocumentNotFound):
+		return metadata, false, nil
+	default:
+		return domain.Metadata{}, false, err
+	}
+}
+
+// deleteChunksFrom deletes the chunks of a document from the given index on. Chunk indexes are
+// contiguous, so the first missing chunk ends the deletion.
+func deleteChunksFrom(ctx context.Context, repo domain.DocumentRepository, id string, from int) error {
+	for i := from; ; i++ {
+		err := repo.Delete(ctx, domain.ChunkID(id, i))
+		if errors.Is(err, domain.ErrDocumentNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// generateEmbeddings embeds the contents, in a single batch when there are several, and checks
+// the embeddings against the dimension declared by the repository.
+func generateEmbeddings(ctx context.Context, embedder EmbeddingGenerator, repo domain.DocumentRepository, contents []string) ([][]float32, error) {
+	var embeddings [][]float32
+	if len(contents) == 1 {
+		embedding, err := embedder.Generate(ctx, contents[0])
+		if err != nil {
+			return nil, err
+		}
+		embeddings = [][]float32{embedding}
+	} else {
+		var err error
+		embeddings, err = AsBatchEmbeddingGenerator(embedder).GenerateBatch(ctx, contents)
+		if err != nil {
+			return nil, err
+		}
+		if len(embeddings) != len(contents) {
+			return nil, fmt.Errorf("received %d embeddings for %d contents", len(embeddings), len(contents))
+		}
+	}
+
+	if store, ok := repo.(DimensionedStore); ok {
+		for _, embedding := range embeddings {
+			if len(embedding) != store.EmbeddingDimension() {
+				return nil, &DimensionMismatchError{Expected: store.EmbeddingDimension(), Actual: len(embedding)}
+			}
+		}
+	}
+	return embeddings, nil
+}
+
+// MetadataPatch holds the metadata fields to change. Nil fields keep their stored value; Tags and
+// Attributes replace the stored ones as a whole.
+type MetadataPatch struct {
+	Title      *string
+	Summary    *string
+	SourceURI  *string
+	MIMEType   *string
+	Tags       []string
+	Attributes map[string]string
+}
+
+// Apply returns the metadata with the patched fields replaced.
+func (p MetadataPatch) Apply(metadata domain.Metadata) domain.Metadata {
+	if p.Title != nil {
+		metadata.Title = *p.Title
+	}
+	if p.Summary != nil {
+		metadata.Summary = *p.Summary
+	}
+	if p.SourceURI != nil {
+		metadata.SourceURI = *p.SourceURI
+	}
+	if p.MIMEType != nil {
+		metadata.MIMEType = *p.MIMEType
+	}
+	if p.Tags != nil {
+		metadata.Tags = p.Tags
+	}
+	if p.Attributes != nil {
+		metadata.Attributes = p.Attributes
 	}
+	return metadata
 }
 
-// Handle handles the IndexDocumentCommand.
-func (h *IndexDocumentHandler) Handle(ctx context.Context, cmd IndexDocumentCommand) error {
-	doc := domain.NewDocument(cmd.ID, cmd.Content)
+// UpdateDocumentCommand is a command to update the content or metadata of an indexed document.
+type UpdateDocumentCommand struct {
+	ID string
+	// Content replaces the content of the document when set. Nil keeps the stored chunks.
+	Content  *string
+	Metadata MetadataPatch
+}
 
-	embedding, err := h.embedder.Generate(ctx, doc.Content)
+// UpdateDocumentHandler handles the UpdateDocumentCommand.
+type UpdateDocumentHandler struct {
+	store    DocumentStore
+	embedder EmbeddingGenerator
+	chunker  Chunker
+}
+
+// NewUpdateDocumentHandler creates a new UpdateDocumentHandler.
+func NewUpdateDocumentHandler(store DocumentStore, embedder EmbeddingGenerator, chunker Chunker) *UpdateDocumentHandler {
+	return &UpdateDocumentHandler{
+		store:    store,
+		embedder: embedder,
+		chunker:  chunker,
+	}
+}
+
+// Handle handles the UpdateDocumentCommand. The patched metadata is saved on every chunk. New
+// content is chunked again and only chunks whose content was not stored before are embedded;
+// chunks left over from the previous content are deleted.
+func (h *UpdateDocumentHandler) Handle(ctx context.Context, cmd UpdateDocumentCommand) error {
+	stored, err := loadChunks(ctx, h.store, cmd.ID)
 	if err != nil {
 		return err
 	}
 
-	doc.SetEmbedding(embedding)
+	metadata := cmd.Metadata.Apply(stored[0].Metadata)
+	metadata.UpdatedAt = time.Now().UTC()
+	if cmd.Content != nil {
+		metadata.ContentHash = ContentHash(*cmd.Content)
+	}
+
+	if cmd.Content == nil {
+		for i := range stored {
+			doc := &stored[i]
+			doc.SetMetadata(metadata)
+			if err := h.store.Save(ctx, doc); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	chunks := h.chunker.Chunk(*cmd.Content)
+	if len(chunks) == 0 {
+		return ErrEmptyDocument
+	}
+
+	embeddings := make(map[string][]float32, len(stored))
+	for _, doc := range stored {
+		embeddings[doc.Content] = doc.Embedding
+	}
 
-	return h.repo.Save(ctx, doc)
+	var changed []string
+	for _, chunk := range chunks {
+		if _, ok := embeddings[chunk.Content]; !ok {
+			// Reserve the content so that repeated chunks are embedded once.
+			embeddings[chunk.Content] = nil
+			changed = append(changed, chunk.Content)
+		}
+	}
+	if len(changed) > 0 {
+		generated, err := generateEmbeddings(ctx, h.embedder, h.store, changed)
+		if err != nil {
+			return err
+		}
+		for i, content := range changed {
+			embeddings[content] = generated[i]
+		}
+	}
+
+	saved := make(map[string]bool, len(chunks))
+	for i, chunk := range chunks {
+		doc := domain.NewChunk(cmd.ID, i, chunk.Content, chunk.Start, chunk.End)
+		doc.SetMetadata(metadata)
+		doc.SetEmbedding(embeddings[chunk.Content])
+
+		if err := h.store.Save(ctx, doc); err != nil {
+			return err
+		}
+		saved[doc.ID] = true
+	}
+
+	for _, doc := range stored {
+		if saved[doc.ID] {
+			continue
+		}
+		if err := h.store.Delete(ctx, doc.ID); err != nil && !errors.Is(err, domain.ErrDocumentNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteDocumentCommand is a command to delete an indexed document with all of its chunks.
+type DeleteDocumentCommand struct {
+	ID string
+}
+
+// DeleteDocumentHandler handles the DeleteDocumentCommand.
+type DeleteDocumentHandler struct {
+	store DocumentStore
+}
+
+// NewDeleteDocumentHandler creates a new DeleteDocumentHandler.
+func NewDeleteDocumentHandler(store DocumentStore) *DeleteDocumentHandler {
+	return &DeleteDocumentHandler{store: store}
+}
+
+// Handle handles the DeleteDocumentCommand. It returns domain.ErrDocumentNotFound when no chunk
+// of the document is stored.
+func (h *DeleteDocumentHandler) Handle(ctx context.Context, cmd DeleteDocumentCommand) error {
+	chunks, err := loadChunks(ctx, h.store, cmd.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, doc := range chunks {
+		if err := h.store.Delete(ctx, doc.ID); err != nil && !errors.Is(err, domain.ErrDocumentNotFound) {
+			return err
+		}
+	}
+	return nil
 }
@@ -3,12 +3,15 @@ package app_test
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 
 	"github.com/igorrius/go-vector-search/internal/app"
 	"github.com/igorrius/go-vector-search/internal/domain"
+	"github.com/igorrius/go-vector-search/internal/infra/persistence/memory"
 )
 
 // MockDocumentRepository is a mock for the DocumentRepository interface.
@@ -26,6 +29,21 @@ func (m *MockDocumentRepository) FindByID(ctx context.Context, id string) (*doma
 	return args.Get(0).(*domain.Document), args.Error(1)
 }
 
+func (m *MockDocumentRepository) Delete(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+// MockDimensionedRepository is a MockDocumentRepository that declares an embedding dimension.
+type MockDimensionedRepository struct {
+	MockDocumentRepository
+	dim int
+}
+
+func (m *MockDimensionedRepository) EmbeddingDimension() int {
+	return m.dim
+}
+
 // MockEmbeddingGenerator is a mock for the EmbeddingGenerator interface.
 type MockEmbeddingGenerator struct {
 	mock.Mock
@@ -36,27 +54,283 @@ func (m *MockEmbeddingGenerator) Generate(ctx context.Context, content string) (
 	return args.Get(0).([]float32), args.Error(1)
 }
 
+func (m *MockEmbeddingGenerator) ModelName() string {
+	return "mock-embedding"
+}
+
+func (m *MockEmbeddingGenerator) Dimension() int {
+	return 3
+}
+
+// matchChunk matches a saved chunk against the expected one, ignoring the metadata timestamps
+// which are checked to be set.
+func matchChunk(expected *domain.Document) interface{} {
+	return mock.MatchedBy(func(doc *domain.Document) bool {
+		if doc.Metadata.CreatedAt.IsZero() || doc.Metadata.UpdatedAt.IsZero() {
+			return false
+		}
+		actual := *doc
+		actual.Metadata.CreatedAt = expected.Metadata.CreatedAt
+		actual.Metadata.UpdatedAt = expected.Metadata.UpdatedAt
+		return assert.ObjectsAreEqual(*expected, actual)
+	})
+}
+
 func TestIndexDocumentHandler_Handle(t *testing.T) {
 	ctx := context.Background()
 	repo := new(MockDocumentRepository)
 	embedder := new(MockEmbeddingGenerator)
-	handler := app.NewIndexDocumentHandler(repo, embedder)
+	handler := app.NewIndexDocumentHandler(repo, embedder, app.NewSentenceChunker(20, 0), nil)
 
+	metadata := domain.Metadata{
+		Title:      "Test",
+		MIMEType:   "text/plain",
+		Tags:       []string{"a", "b"},
+		Attributes: map[string]string{"author": "me"},
+	}
 	cmd := app.IndexDocumentCommand{
-		ID:      "test-id",
-		Content: "test content",
+		ID:       "test-id",
+		Content:  "First sentence. Second sentence.",
+		Metadata: metadata,
 	}
 
+	stored := metadata
+	stored.ContentHash = app.ContentHash(cmd.Content)
+
 	embedding := []float32{1.0, 2.0, 3.0}
-	doc := domain.NewDocument(cmd.ID, cmd.Content)
-	doc.SetEmbedding(embedding)
+	first := domain.NewChunk(cmd.ID, 0, "First sentence.", 0, 15)
+	first.SetEmbedding(embedding)
+	first.SetMetadata(stored)
+	second := domain.NewChunk(cmd.ID, 1, "Second sentence.", 16, 32)
+	second.SetEmbedding(embedding)
+	second.SetMetadata(stored)
+
+	repo.On("FindByID", ctx, first.ID).Return((*domain.Document)(nil), domain.ErrDocumentNotFound)
+	embedder.On("Generate", ctx, "First sentence.").Return(embedding, nil)
+	embedder.On("Generate", ctx, "Second sentence.").Return(embedding, nil)
+	repo.On("Save", ctx, matchChunk(first)).Return(nil)
+	repo.On("Save", ctx, matchChunk(second)).Return(nil)
+
+	_, err := handler.Handle(ctx, cmd)
+
+	assert.NoError(t, err)
+	repo.AssertExpectations(t)
+	embedder.AssertExpectations(t)
+}
 
-	embedder.On("Generate", ctx, cmd.Content).Return(embedding, nil)
-	repo.On("Save", ctx, doc).Return(nil)
+func TestIndexDocumentHandler_Handle_KeepsCreatedAt(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockDocumentRepository)
+	embedder := new(MockEmbeddingGenerator)
+	handler := app.NewIndexDocumentHandler(repo, embedder, app.NewFixedSizeChunker(100, 0), nil)
+
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	existing := domain.NewChunk("test-id", 0, "old content", 0, 11)
+	existing.SetMetadata(domain.Metadata{CreatedAt: createdAt, UpdatedAt: createdAt})
 
-	err := handler.Handle(ctx, cmd)
+	repo.On("FindByID", ctx, existing.ID).Return(existing, nil)
+	embedder.On("Generate", ctx, "new content").Return([]float32{1.0, 2.0, 3.0}, nil)
+	repo.On("Save", ctx, mock.MatchedBy(func(doc *domain.Document) bool {
+		return doc.Metadata.CreatedAt.Equal(createdAt) && doc.Metadata.UpdatedAt.After(createdAt)
+	})).Return(nil)
+	repo.On("Delete", ctx, domain.ChunkID("test-id", 1)).Return(domain.ErrDocumentNotFound)
+
+	_, err := handler.Handle(ctx, app.IndexDocumentCommand{ID: "test-id", Content: "new content"})
 
 	assert.NoError(t, err)
 	repo.AssertExpectations(t)
+}
+
+func TestIndexDocumentHandler_Handle_RemovesStaleChunks(t *testing.T) {
+	ctx := context.Background()
+	store, embedder := indexForUpdate(t, ctx)
+	embedder.On("Generate", ctx, "Only sentence.").Return([]float32{0, 0, 1}, nil).Once()
+	handler := app.NewIndexDocumentHandler(store, embedder, app.NewSentenceChunker(20, 0), nil)
+
+	_, err := handler.Handle(ctx, app.IndexDocumentCommand{ID: "test-id", Content: "Only sentence."})
+
+	require.NoError(t, err)
+	first, err := store.FindByID(ctx, domain.ChunkID("test-id", 0))
+	require.NoError(t, err)
+	assert.Equal(t, "Only sentence.", first.Content)
+	_, err = store.FindByID(ctx, domain.ChunkID("test-id", 1))
+	assert.ErrorIs(t, err, domain.ErrDocumentNotFound)
+}
+
+func TestIndexDocumentHandler_Handle_DimensionMismatch(t *testing.T) {
+	ctx := context.Background()
+	repo := &MockDimensionedRepository{dim: 768}
+	embedder := new(MockEmbeddingGenerator)
+	handler := app.NewIndexDocumentHandler(repo, embedder, app.NewFixedSizeChunker(100, 0), nil)
+
+	cmd := app.IndexDocumentCommand{
+		ID:      "test-id",
+		Content: "test content",
+	}
+
+	repo.On("FindByID", ctx, domain.ChunkID(cmd.ID, 0)).Return((*domain.Document)(nil), domain.ErrDocumentNotFound)
+	embedder.On("Generate", ctx, cmd.Content).Return([]float32{1.0, 2.0, 3.0}, nil)
+
+	_, err := handler.Handle(ctx, cmd)
+
+	var mismatch *app.DimensionMismatchError
+	assert.ErrorAs(t, err, &mismatch)
+	assert.Equal(t, 768, mismatch.Expected)
+	assert.Equal(t, 3, mismatch.Actual)
+	repo.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+	embedder.AssertExpectations(t)
+}
+
+func TestIndexDocumentHandler_Handle_EmptyDocument(t *testing.T) {
+	repo := new(MockDocumentRepository)
+	embedder := new(MockEmbeddingGenerator)
+	handler := app.NewIndexDocumentHandler(repo, embedder, app.NewFixedSizeChunker(100, 0), nil)
+
+	_, err := handler.Handle(context.Background(), app.IndexDocumentCommand{ID: "test-id", Content: "  \n "})
+
+	assert.ErrorIs(t, err, app.ErrEmptyDocument)
+	embedder.AssertNotCalled(t, "Generate", mock.Anything, mock.Anything)
+}
+
+// indexForUpdate indexes "First sentence. Second sentence." as two chunks of "test-id" in a
+// memory store.
+func indexForUpdate(t *testing.T, ctx context.Context) (*memory.Store, *MockEmbeddingGenerator) {
+	t.Helper()
+	store := memory.NewStore(app.Cosine)
+	embedder := new(MockEmbeddingGenerator)
+	embedder.On("Generate", ctx, "First sentence.").Return([]float32{1, 0, 0}, nil).Once()
+	embedder.On("Generate", ctx, "Second sentence.").Return([]float32{0, 1, 0}, nil).Once()
+
+	index := app.NewIndexDocumentHandler(store, embedder, app.NewSentenceChunker(20, 0), nil)
+	_, err := index.Handle(ctx, app.IndexDocumentCommand{
+		ID:       "test-id",
+		Content:  "First sentence. Second sentence.",
+		Metadata: domain.Metadata{Title: "Test", Tags: []string{"a"}},
+	})
+	require.NoError(t, err)
+	return store, embedder
+}
+
+func TestUpdateDocumentHandler_Handle_Metadata(t *testing.T) {
+	ctx := context.Background()
+	store, embedder := indexForUpdate(t, ctx)
+	handler := app.NewUpdateDocumentHandler(store, embedder, app.NewSentenceChunker(20, 0))
+
+	title := "Renamed"
+	err := handler.Handle(ctx, app.UpdateDocumentCommand{ID: "test-id", Metadata: app.MetadataPatch{Title: &title}})
+
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		doc, err := store.FindByID(ctx, domain.ChunkID("test-id", i))
+		require.NoError(t, err)
+		assert.Equal(t, "Renamed", doc.Metadata.Title)
+		assert.Equal(t, []string{"a"}, doc.Metadata.Tags)
+		assert.False(t, doc.Metadata.UpdatedAt.Before(doc.Metadata.CreatedAt))
+	}
+	embedder.AssertNumberOfCalls(t, "Generate", 2)
+}
+
+func TestUpdateDocumentHandler_Handle_Content(t *testing.T) {
+	ctx := context.Background()
+	store, embedder := indexForUpdate(t, ctx)
+	handler := app.NewUpdateDocumentHandler(store, embedder, app.NewSentenceChunker(20, 0))
+	embedder.On("Generate", ctx, "Third sentence.").Return([]float32{0, 0, 1}, nil).Once()
+
+	content := "Third sentence. First sentence."
+	err := handler.Handle(ctx, app.UpdateDocumentCommand{ID: "test-id", Content: &content})
+
+	require.NoError(t, err)
+	first, err := store.FindByID(ctx, domain.ChunkID("test-id", 0))
+	require.NoError(t, err)
+	assert.Equal(t, "Third sentence.", first.Content)
+	second, err := store.FindByID(ctx, domain.ChunkID("test-id", 1))
+	require.NoError(t, err)
+	assert.Equal(t, "First sentence.", second.Content)
+	assert.Equal(t, []float32{1, 0, 0}, second.Embedding)
+	assert.Equal(t, "Test", second.Metadata.Title)
+	embedder.AssertExpectations(t)
+	embedder.AssertNumberOfCalls(t, "Generate", 3)
+}
+
+func TestUpdateDocumentHandler_Handle_RemovesStaleChunks(t *testing.T) {
+	ctx := context.Background()
+	store, embedder := indexForUpdate(t, ctx)
+	handler := app.NewUpdateDocumentHandler(store, embedder, app.NewSentenceChunker(20, 0))
+
+	content := "Second sentence."
+	err := handler.Handle(ctx, app.UpdateDocumentCommand{ID: "test-id", Content: &content})
+
+	require.NoError(t, err)
+	doc, err := store.FindByID(ctx, domain.ChunkID("test-id", 0))
+	require.NoError(t, err)
+	assert.Equal(t, "Second sentence.", doc.Content)
+	_, err = store.FindByID(ctx, domain.ChunkID("test-id", 1))
+	assert.ErrorIs(t, err, domain.ErrDocumentNotFound)
+	embedder.AssertNumberOfCalls(t, "Generate", 2)
+}
+
+func TestUpdateDocumentHandler_Handle_NotFound(t *testing.T) {
+	handler := app.NewUpdateDocumentHandler(memory.NewStore(app.Cosine), new(MockEmbeddingGenerator), app.NewFixedSizeChunker(100, 0))
+
+	err := handler.Handle(context.Background(), app.UpdateDocumentCommand{ID: "missing"})
+
+	assert.ErrorIs(t, err, domain.ErrDocumentNotFound)
+}
+
+func TestDeleteDocumentHandler_Handle(t *testing.T) {
+	ctx := context.Background()
+	store, _ := indexForUpdate(t, ctx)
+	require.NoError(t, store.Save(ctx, domain.NewChunk("other-id", 0, "Other.", 0, 6)))
+	handler := app.NewDeleteDocumentHandler(store)
+
+	err := handler.Handle(ctx, app.DeleteDocumentCommand{ID: "test-id"})
+
+	require.NoError(t, err)
+	page, err := store.List(ctx, app.ListOptions{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, page.Documents, 1)
+	assert.Equal(t, "other-id", page.Documents[0].ParentID)
+	assert.ErrorIs(t, handler.Handle(ctx, app.DeleteDocumentCommand{ID: "test-id"}), domain.ErrDocumentNotFound)
+}
+
+// MockBatchEmbeddingGenerator is a MockEmbeddingGenerator that embeds batches.
+type MockBatchEmbeddingGenerator struct {
+	MockEmbeddingGenerator
+}
+
+func (m *MockBatchEmbeddingGenerator) GenerateBatch(ctx context.Context, contents []string) ([][]float32, error) {
+	args := m.Called(ctx, contents)
+	return args.Get(0).([][]float32), args.Error(1)
+}
+
+func TestIndexDocumentHandler_Handle_Batch(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStore(app.Cosine)
+	embedder := new(MockBatchEmbeddingGenerator)
+	handler := app.NewIndexDocumentHandler(store, embedder, app.NewSentenceChunker(20, 0), nil)
+
+	embedder.On("GenerateBatch", ctx, []string{"First sentence.", "Second sentence."}).Return([][]float32{{1, 0, 0}, {0, 1, 0}}, nil)
+
+	_, err := handler.Handle(ctx, app.IndexDocumentCommand{ID: "test-id", Content: "First sentence. Second sentence."})
+
+	require.NoError(t, err)
+	second, err := store.FindByID(ctx, domain.ChunkID("test-id", 1))
+	require.NoError(t, err)
+	assert.Equal(t, []float32{0, 1, 0}, second.Embedding)
 	embedder.AssertExpectations(t)
+	embedder.AssertNotCalled(t, "Generate", mock.Anything, mock.Anything)
+}
+
+func TestAsBatchEmbeddingGenerator(t *testing.T) {
+	ctx := context.Background()
+	embedder := new(MockEmbeddingGenerator)
+	embedder.On("Generate", ctx, "a").Return([]float32{1, 0, 0}, nil)
+	embedder.On("Generate", ctx, "b").Return([]float32{0, 1, 0}, nil)
+
+	embeddings, err := app.AsBatchEmbeddingGenerator(embedder).GenerateBatch(ctx, []string{"a", "b"})
+
+	require.NoError(t, err)
+	assert.Equal(t, [][]float32{{1, 0, 0}, {0, 1, 0}}, embeddings)
+	batch := new(MockBatchEmbeddingGenerator)
+	assert.Same(t, batch, app.AsBatchEmbeddingGenerator(batch))
 }
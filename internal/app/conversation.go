@@ -0,0 +1,229 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// DefaultHistoryTokens is the default token budget of the history used to rewrite a follow-up.
+const DefaultHistoryTokens = 2000
+
+// ErrConversationNotFound is returned by a ConversationStore when no conversation has the
+// requested ID.
+var ErrConversationNotFound = errors.New("conversation not found")
+
+// MessageRole tells who wrote a message.
+type MessageRole string
+
+const (
+	RoleUser      MessageRole = "user"
+	RoleAssistant MessageRole = "assistant"
+)
+
+// Message is a turn of a conversation.
+type Message struct {
+	Role    MessageRole
+	Content string
+	// Query is the standalone search query the user message was rewritten into, which the
+	// following assistant message answers.
+	Query string
+	// Sources, Citations and Uncited describe the answer of an assistant message as in
+	// AnswerResult.
+	Sources   []Source   `json:",omitempty"`
+	Citations []Citation `json:",omitempty"`
+	Uncited   bool       `json:",omitempty"`
+	CreatedAt time.Time
+}
+
+// Conversation is a session of questions and answers about the indexed documents.
+type Conversation struct {
+	ID        string
+	Messages  []Message
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// ConversationStore persists conversations.
+type ConversationStore interface {
+	Save(ctx context.Context, conversation *Conversation) error
+	FindByID(ctx context.Context, id string) (*Conversation, error)
+}
+
+// ConversationConfig holds the configuration of a ConversationHandler. Zero values select the
+// defaults.
+type ConversationConfig struct {
+	// HistoryTokens is the estimated number of tokens of the most recent messages used to
+	// rewrite a follow-up. Default DefaultHistoryTokens.
+	HistoryTokens int
+}
+
+// SendMessageCommand represents a question sent to a conversation.
+type SendMessageCommand struct {
+	ConversationID string
+	Content        string
+	// Search selects the chunks the answer is based on as in AskQuery.
+	Search SearchDocumentsQuery
+}
+
+// ConversationHandler handles conversations. Follow-up questions are rewritten into standalone
+// queries using the history before they are answered like an AskQuery.
+type ConversationHandler struct {
+	store    ConversationStore
+	ask      *AskHandler
+	rewriter QueryRewriter
+	cfg      ConversationConfig
+
+	// locks serialize the updates of each conversation, so concurrent messages are not lost.
+	locks conversationLocks
+}
+
+// conversationLocks hands out a mutex per conversation ID, kept while it is in use.
+type conversationLocks struct {
+	mu    sync.Mutex
+	locks map[string]*conversationLock
+}
+
+type conversationLock struct {
+	sync.Mutex
+	// users is the number of callers holding or waiting for the lock.
+	users int
+}
+
+// lock locks the conversation and returns the function unlocking it.
+func (l *conversationLocks) lock(id string) func() {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[string]*conversationLock)
+	}
+	lock, ok := l.locks[id]
+	if !ok {
+		lock = &conversationLock{}
+		l.locks[id] = lock
+	}
+	lock.users++
+	l.mu.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if lock.users--; lock.users == 0 {
+			delete(l.locks, id)
+		}
+	}
+}
+
+// NewConversationHandler creates a new ConversationHandler. A nil rewriter searches for follow-ups
+// as they are written.
+func NewConversationHandler(store ConversationStore, ask *AskHandler, rewriter QueryRewriter, cfg ConversationConfig) *ConversationHandler {
+	if cfg.HistoryTokens <= 0 {
+		cfg.HistoryTokens = DefaultHistoryTokens
+	}
+	return &ConversationHandler{store: store, ask: ask, rewriter: rewriter, cfg: cfg}
+}
+
+// Create starts an empty conversation.
+func (h *ConversationHandler) Create(ctx context.Context) (*Conversation, error) {
+	now := time.Now().UTC()
+	conversation := &Conversation{ID: uuid.New().String(), Messages: []Message{}, CreatedAt: now, UpdatedAt: now}
+	if err := h.store.Save(ctx, conversation); err != nil {
+		return nil, fmt.Errorf("failed to save conversation: %w", err)
+	}
+	return conversation, nil
+}
+
+// Get returns the conversation with the given ID.
+func (h *ConversationHandler) Get(ctx context.Context, id string) (*Conversation, error) {
+	return h.store.FindByID(ctx, id)
+}
+
+// Send answers a question in a conversation and returns the answer. The question and the answer
+// are appended to the conversation.
+func (h *ConversationHandler) Send(ctx context.Context, cmd SendMessageCommand) (*Message, error) {
+	if strings.TrimSpace(cmd.Content) == "" {
+		return nil, ErrEmptyQuestion
+	}
+	conversation, err := h.store.FindByID(ctx, cmd.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := cmd.Content
+	if history := TrimHistory(conversation.Messages, h.cfg.HistoryTokens); h.rewriter != nil && len(history) > 0 {
+		rewritten, err := h.rewriter.Rewrite(ctx, history, cmd.Content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to rewrite query: %w", err)
+		}
+		if rewritten = strings.TrimSpace(rewritten); rewritten != "" {
+			query = rewritten
+		}
+	}
+
+	result, err := h.ask.Handle(ctx, AskQuery{Question: query, Search: cmd.Search})
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	question := Message{Role: RoleUser, Content: cmd.Content, Query: query, CreatedAt: now}
+	answer := Message{
+		Role:      RoleAssistant,
+		Content:   result.Answer,
+		Query:     query,
+		Sources:   result.Sources,
+		Citations: result.Citations,
+		Uncited:   result.Uncited,
+		CreatedAt: now,
+	}
+
+	defer h.locks.lock(cmd.ConversationID)()
+	// Messages sent while the answer was generated are kept.
+	conversation, err = h.store.FindByID(ctx, cmd.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+	conversation.Messages = append(conversation.Messages, question, answer)
+	conversation.UpdatedAt = now
+	if err := h.store.Save(ctx, conversation); err != nil {
+		return nil, fmt.Errorf("failed to save conversation: %w", err)
+	}
+	return &answer, nil
+}
+
+// TrimHistory returns the most recent messages whose estimated tokens fit the budget, oldest
+// first.
+func TrimHistory(messages []Message, budget int) []Message {
+	start := len(messages)
+	for start > 0 {
+		tokens := messageTokens(messages[start-1])
+		if tokens > budget {
+			break
+		}
+		budget -= tokens
+		start--
+	}
+	return messages[start:]
+}
+
+// messageTokens estimates the tokens of a message as given to a QueryRewriter: its content and
+// the labels of its sources.
+func messageTokens(m Message) int {
+	tokens := EstimateTokens(m.Content)
+	for _, source := range m.Sources {
+		tokens += EstimateTokens(source.Label()) + 1
+	}
+	return tokens
+}
+
+// EstimateTokens estimates the number of model tokens of a text as one per four characters.
+func EstimateTokens(s string) int {
+	return (utf8.RuneCountInString(s) + 3) / 4
+}
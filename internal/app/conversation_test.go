@@ -0,0 +1,208 @@
+package app
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/igorrius/go-vector-search/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockQueryRewriter struct {
+	mock.Mock
+}
+
+func (m *MockQueryRewriter) Rewrite(ctx context.Context, history []Message, question string) (string, error) {
+	args := m.Called(ctx, history, question)
+	return args.String(0), args.Error(1)
+}
+
+// fakeConversationStore is an in-memory ConversationStore.
+type fakeConversationStore struct {
+	mu            sync.Mutex
+	conversations map[string]Conversation
+}
+
+func (s *fakeConversationStore) Save(_ context.Context, conversation *Conversation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conversations == nil {
+		s.conversations = make(map[string]Conversation)
+	}
+	c := *conversation
+	c.Messages = append([]Message(nil), conversation.Messages...)
+	s.conversations[c.ID] = c
+	return nil
+}
+
+func (s *fakeConversationStore) FindByID(_ context.Context, id string) (*Conversation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.conversations[id]
+	if !ok {
+		return nil, ErrConversationNotFound
+	}
+	c.Messages = append([]Message(nil), c.Messages...)
+	return &c, nil
+}
+
+func TestConversationHandler_Send(t *testing.T) {
+	ctx := context.Background()
+	embedding := []float32{1, 2, 3}
+
+	newHandler := func(rewriter QueryRewriter, queries ...string) (*ConversationHandler, *MockAnswerer) {
+		embedder := new(MockEmbeddingGenerator)
+		store := new(MockVectorStore)
+		answerer := new(MockAnswerer)
+		for i, query := range queries {
+			embedder.On("Generate", ctx, query).Return(embedding, nil)
+			hits := []SearchHit{{Document: domain.Document{ID: query + "#0", ParentID: query, Content: "about " + query}}}
+			store.On("Search", ctx, SearchOptions{Query: query, Embedding: embedding, Limit: DefaultAskLimit}).Return(hits, nil)
+			answerer.On("Answer", ctx, query, mock.Anything).Return(strings.Repeat("x", i)+"answer [1]", nil)
+		}
+		ask := NewAskHandler(NewSearchDocumentsHandler(embedder, store, nil, nil), answerer)
+		return NewConversationHandler(&fakeConversationStore{}, ask, rewriter, ConversationConfig{}), answerer
+	}
+
+	t.Run("should answer the first question as it is written", func(t *testing.T) {
+		// Arrange
+		rewriter := new(MockQueryRewriter)
+		handler, _ := newHandler(rewriter, "Which databases are supported?")
+		conversation, err := handler.Create(ctx)
+		require.NoError(t, err)
+
+		// Act
+		answer, err := handler.Send(ctx, SendMessageCommand{ConversationID: conversation.ID, Content: "Which databases are supported?"})
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, RoleAssistant, answer.Role)
+		assert.Equal(t, "answer [1]", answer.Content)
+		assert.Equal(t, "Which databases are supported?#0", answer.Sources[0].DocumentID)
+		assert.Len(t, answer.Citations, 1)
+		rewriter.AssertNotCalled(t, "Rewrite", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("should rewrite a follow-up using the history", func(t *testing.T) {
+		// Arrange
+		rewriter := new(MockQueryRewriter)
+		handler, _ := newHandler(rewriter, "Which databases are supported?", "How is Typesense configured?")
+		conversation, err := handler.Create(ctx)
+		require.NoError(t, err)
+		_, err = handler.Send(ctx, SendMessageCommand{ConversationID: conversation.ID, Content: "Which databases are supported?"})
+		require.NoError(t, err)
+		rewriter.On("Rewrite", ctx, mock.MatchedBy(func(history []Message) bool {
+			return len(history) == 2 && history[0].Role == RoleUser && history[1].Role == RoleAssistant
+		}), "How is the second one configured?").Return(" How is Typesense configured? ", nil)
+
+		// Act
+		answer, err := handler.Send(ctx, SendMessageCommand{ConversationID: conversation.ID, Content: "How is the second one configured?"})
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, "How is Typesense configured?", answer.Query)
+		assert.Equal(t, "xanswer [1]", answer.Content)
+		stored, err := handler.Get(ctx, conversation.ID)
+		require.NoError(t, err)
+		require.Len(t, stored.Messages, 4)
+		assert.Equal(t, "How is the second one configured?", stored.Messages[2].Content)
+		assert.Equal(t, "How is Typesense configured?", stored.Messages[2].Query)
+		assert.Equal(t, "xanswer [1]", stored.Messages[3].Content)
+	})
+
+	t.Run("should return ErrConversationNotFound for an unknown conversation", func(t *testing.T) {
+		// Arrange
+		handler, _ := newHandler(nil)
+
+		// Act
+		_, err := handler.Send(ctx, SendMessageCommand{ConversationID: "missing", Content: "Hello?"})
+
+		// Assert
+		assert.ErrorIs(t, err, ErrConversationNotFound)
+	})
+}
+
+func TestTrimHistory(t *testing.T) {
+	messages := []Message{
+		{Role: RoleUser, Content: strings.Repeat("a", 40)},
+		{Role: RoleAssistant, Content: strings.Repeat("b", 40), Sources: []Source{{Metadata: domain.Metadata{Title: "Guide"}}}},
+		{Role: RoleUser, Content: strings.Repeat("c", 40)},
+	}
+
+	tests := []struct {
+		name   string
+		budget int
+		want   []Message
+	}{
+		{name: "should keep everything within the budget", budget: 100, want: messages},
+		{name: "should drop the oldest messages", budget: 23, want: messages[1:]},
+		{name: "should not split a message", budget: 22, want: messages[2:]},
+		{name: "should drop everything for a tiny budget", budget: 5, want: messages[3:]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			got := TrimHistory(messages, tt.budget)
+
+			// Assert
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("should count the document ID of a source without title", func(t *testing.T) {
+		// Arrange
+		untitled := []Message{
+			{Role: RoleAssistant, Content: strings.Repeat("b", 40), Sources: []Source{{DocumentID: strings.Repeat("d", 40)}}},
+		}
+
+		// Act
+		dropped := TrimHistory(untitled, 20)
+		kept := TrimHistory(untitled, 21)
+
+		// Assert
+		assert.Empty(t, dropped)
+		assert.Equal(t, untitled, kept)
+	})
+}
+
+func TestConversationLocks(t *testing.T) {
+	t.Run("should lock conversations independently", func(t *testing.T) {
+		// Arrange
+		var locks conversationLocks
+		unlockA := locks.lock("a")
+
+		// Act
+		lockedB := make(chan struct{})
+		go func() {
+			locks.lock("b")()
+			close(lockedB)
+		}()
+		lockedA := make(chan struct{})
+		go func() {
+			locks.lock("a")()
+			close(lockedA)
+		}()
+
+		// Assert
+		select {
+		case <-lockedB:
+		case <-time.After(5 * time.Second):
+			t.Fatal("conversation b waited for conversation a")
+		}
+		select {
+		case <-lockedA:
+			t.Fatal("conversation a was locked twice")
+		case <-time.After(10 * time.Millisecond):
+		}
+		unlockA()
+		<-lockedA
+		locks.mu.Lock()
+		defer locks.mu.Unlock()
+		assert.Empty(t, locks.locks)
+	})
+}
@@ -0,0 +1,192 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/igorrius/go-vector-search/internal/domain"
+)
+
+// duplicateSearchLimit is the number of nearest chunks searched for every chunk of a new document.
+const duplicateSearchLimit = 5
+
+// DuplicatePolicy decides what happens to a document that duplicates an indexed one.
+type DuplicatePolicy string
+
+const (
+	// DuplicateKeep indexes the document next to its duplicates without looking for them.
+	DuplicateKeep DuplicatePolicy = "keep"
+	// DuplicateSkip does not index a document that duplicates an indexed one.
+	DuplicateSkip DuplicatePolicy = "skip"
+	// DuplicateReplace indexes the document and deletes the indexed duplicate.
+	DuplicateReplace DuplicatePolicy = "replace"
+)
+
+// ParseDuplicatePolicy parses a duplicate policy name. An empty name selects DuplicateKeep.
+func ParseDuplicatePolicy(name string) (DuplicatePolicy, error) {
+	switch DuplicatePolicy(name) {
+	case "", DuplicateKeep:
+		return DuplicateKeep, nil
+	case DuplicateSkip, DuplicateReplace:
+		return DuplicatePolicy(name), nil
+	default:
+		return "", fmt.Errorf("unknown duplicate policy %q", name)
+	}
+}
+
+// requestDuplicatePolicy parses the policy named by a request. An empty name is kept empty, so
+// that the DuplicateDetector applies its default.
+func requestDuplicatePolicy(name string) (DuplicatePolicy, error) {
+	if name == "" {
+		return "", nil
+	}
+	return ParseDuplicatePolicy(name)
+}
+
+// ContentHash returns the hash identifying the content of a document. Contents differing only in
+// whitespace have the same hash.
+func ContentHash(content string) string {
+	sum := sha256.Sum256([]byte(normalizeContent(content)))
+	return hex.EncodeToString(sum[:])
+}
+
+// IndexDocumentResult is the outcome of indexing a document.
+type IndexDocumentResult struct {
+	// DuplicateOf is the ID of an indexed document duplicating the content; empty when none was
+	// found or the policy is DuplicateKeep.
+	DuplicateOf string
+	// Skipped reports that the document was not indexed under DuplicateSkip.
+	Skipped bool
+}
+
+// DuplicateDetector finds indexed documents duplicating a new one. Exact duplicates share the
+// content hash; near duplicates are found by embedding similarity when the store is also a
+// VectorStore and a threshold is set.
+type DuplicateDetector struct {
+	store     DocumentStore
+	threshold float64
+	policy    DuplicatePolicy
+}
+
+// NewDuplicateDetector creates a new DuplicateDetector. A document is a near duplicate of an
+// indexed one when each of its chunks has a chunk of it with a Similarity of at least threshold,
+// and the indexed document has no more chunks. A threshold of zero only detects exact duplicates.
+// The policy applies to commands that set none; empty selects DuplicateKeep.
+func NewDuplicateDetector(store DocumentStore, threshold float64, policy DuplicatePolicy) *DuplicateDetector {
+	if policy == "" {
+		policy = DuplicateKeep
+	}
+	return &DuplicateDetector{
+		store:     store,
+		threshold: threshold,
+		policy:    policy,
+	}
+}
+
+// resolve returns the policy of a command, or the default policy when it sets none.
+func (d *DuplicateDetector) resolve(policy DuplicatePolicy) DuplicatePolicy {
+	if d == nil || policy != "" {
+		return policy
+	}
+	return d.policy
+}
+
+// applies reports whether duplicates are looked up under the policy; never for a nil detector.
+func (d *DuplicateDetector) applies(policy DuplicatePolicy) bool {
+	return d != nil && policy != "" && policy != DuplicateKeep
+}
+
+// FindExact returns the ID of an indexed document other than id whose content has the hash, or
+// an empty string.
+func (d *DuplicateDetector) FindExact(ctx context.Context, id, hash string) (string, error) {
+	zero := 0.0
+	page, err := d.store.List(ctx, ListOptions{
+		Filter: AndFilter{Filters: []Filter{
+			EqualFilter{Field: "content_hash", Value: hash},
+			RangeFilter{Field: "chunk_index", Min: &zero, Max: &zero},
+		}},
+		Limit: 2,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to find duplicates: %w", err)
+	}
+	for _, doc := range page.Documents {
+		if parent := parentID(&doc); parent != id {
+			return parent, nil
+		}
+	}
+	return "", nil
+}
+
+// FindNear returns the ID of an indexed document other than id that is a near duplicate of the
+// document with the chunk embeddings, or an empty string.
+func (d *DuplicateDetector) FindNear(ctx context.Context, id string, embeddings [][]float32) (string, error) {
+	search, ok := d.store.(VectorStore)
+	if !ok || d.threshold <= 0 || len(embeddings) == 0 {
+		return "", nil
+	}
+
+	// matches counts, per indexed document, the new chunks with a similar chunk in it.
+	matches := make(map[string]int)
+	var candidates []string
+	for _, embedding := range embeddings {
+		hits, err := search.Search(ctx, SearchOptions{Embedding: embedding, Limit: duplicateSearchLimit})
+		if err != nil {
+			return "", fmt.Errorf("failed to find duplicates: %w", err)
+		}
+		matched := make(map[string]bool)
+		for _, hit := range hits {
+			parent := parentID(&hit.Document)
+			if parent != id && hit.Similarity >= d.threshold && !matched[parent] {
+				matched[parent] = true
+				if matches[parent] == 0 {
+					candidates = append(candidates, parent)
+				}
+				matches[parent]++
+			}
+		}
+	}
+
+	for _, parent := range candidates {
+		if matches[parent] < len(embeddings) {
+			continue
+		}
+		chunks, err := loadChunks(ctx, d.store, parent)
+		if err != nil {
+			return "", fmt.Errorf("failed to find duplicates: %w", err)
+		}
+		if len(chunks) <= len(embeddings) {
+			return parent, nil
+		}
+	}
+	return "", nil
+}
+
+// Remove deletes the indexed document with all of its chunks.
+func (d *DuplicateDetector) Remove(ctx context.Context, id string) error {
+	chunks, err := loadChunks(ctx, d.store, id)
+	if errors.Is(err, domain.ErrDocumentNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, doc := range chunks {
+		if err := d.store.Delete(ctx, doc.ID); err != nil && !errors.Is(err, domain.ErrDocumentNotFound) {
+			return fmt.Errorf("failed to delete duplicate %q: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// parentID returns the ID of the document a stored chunk belongs to. A document indexed before
+// chunking was introduced is its own parent.
+func parentID(doc *domain.Document) string {
+	if doc.ParentID == "" {
+		return doc.ID
+	}
+	return doc.ParentID
+}
@@ -0,0 +1,165 @@
+package app_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/igorrius/go-vector-search/internal/app"
+	"github.com/igorrius/go-vector-search/internal/domain"
+	"github.com/igorrius/go-vector-search/internal/infra/persistence/memory"
+)
+
+func TestParseDuplicatePolicy(t *testing.T) {
+	for name, want := range map[string]app.DuplicatePolicy{
+		"":        app.DuplicateKeep,
+		"keep":    app.DuplicateKeep,
+		"skip":    app.DuplicateSkip,
+		"replace": app.DuplicateReplace,
+	} {
+		policy, err := app.ParseDuplicatePolicy(name)
+		assert.NoError(t, err, name)
+		assert.Equal(t, want, policy, name)
+	}
+
+	_, err := app.ParseDuplicatePolicy("drop")
+	assert.Error(t, err)
+}
+
+func TestContentHash(t *testing.T) {
+	assert.Equal(t, app.ContentHash("hello world"), app.ContentHash(" hello\n world\n"))
+	assert.NotEqual(t, app.ContentHash("hello world"), app.ContentHash("hello there"))
+}
+
+func TestIndexDocumentHandler_Handle_Duplicates(t *testing.T) {
+	ctx := context.Background()
+
+	// setup indexes "original" and returns a handler detecting near duplicates with a threshold
+	// of 0.99 and the default policy. The embedder maps every content to the same vector except
+	// "unrelated".
+	setup := func(t *testing.T, policy app.DuplicatePolicy) (*memory.Store, *MockEmbeddingGenerator, *app.IndexDocumentHandler) {
+		store := memory.NewStore(app.Cosine)
+		embedder := new(MockEmbeddingGenerator)
+		embedder.On("Generate", mock.Anything, "unrelated").Return([]float32{0, 1, 0}, nil)
+		embedder.On("Generate", mock.Anything, mock.Anything).Return([]float32{1, 0, 0}, nil)
+		handler := app.NewIndexDocumentHandler(store, embedder, app.NewFixedSizeChunker(100, 0), app.NewDuplicateDetector(store, 0.99, policy))
+		_, err := handler.Handle(ctx, app.IndexDocumentCommand{ID: "original", Content: "Hello world."})
+		require.NoError(t, err)
+		return store, embedder, handler
+	}
+
+	t.Run("should skip an exact duplicate without embedding it", func(t *testing.T) {
+		// Arrange
+		store, embedder, handler := setup(t, app.DuplicateKeep)
+
+		// Act
+		result, err := handler.Handle(ctx, app.IndexDocumentCommand{ID: "copy", Content: " Hello  world.\n", OnDuplicate: app.DuplicateSkip})
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, &app.IndexDocumentResult{DuplicateOf: "original", Skipped: true}, result)
+		_, err = store.FindByID(ctx, domain.ChunkID("copy", 0))
+		assert.ErrorIs(t, err, domain.ErrDocumentNotFound)
+		embedder.AssertNumberOfCalls(t, "Generate", 1)
+	})
+
+	t.Run("should replace a near duplicate", func(t *testing.T) {
+		// Arrange
+		store, _, handler := setup(t, app.DuplicateKeep)
+
+		// Act
+		result, err := handler.Handle(ctx, app.IndexDocumentCommand{ID: "edited", Content: "Hello, world!", OnDuplicate: app.DuplicateReplace})
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, &app.IndexDocumentResult{DuplicateOf: "original"}, result)
+		_, err = store.FindByID(ctx, domain.ChunkID("original", 0))
+		assert.ErrorIs(t, err, domain.ErrDocumentNotFound)
+		_, err = store.FindByID(ctx, domain.ChunkID("edited", 0))
+		assert.NoError(t, err)
+	})
+
+	t.Run("should index a document without duplicates", func(t *testing.T) {
+		// Arrange
+		store, _, handler := setup(t, app.DuplicateKeep)
+
+		// Act
+		result, err := handler.Handle(ctx, app.IndexDocumentCommand{ID: "other", Content: "unrelated", OnDuplicate: app.DuplicateSkip})
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, &app.IndexDocumentResult{}, result)
+		_, err = store.FindByID(ctx, domain.ChunkID("other", 0))
+		assert.NoError(t, err)
+	})
+
+	t.Run("should keep both documents by default", func(t *testing.T) {
+		// Arrange
+		store, _, handler := setup(t, app.DuplicateKeep)
+
+		// Act
+		result, err := handler.Handle(ctx, app.IndexDocumentCommand{ID: "copy", Content: "Hello world."})
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, &app.IndexDocumentResult{}, result)
+		_, err = store.FindByID(ctx, domain.ChunkID("copy", 0))
+		assert.NoError(t, err)
+		_, err = store.FindByID(ctx, domain.ChunkID("original", 0))
+		assert.NoError(t, err)
+	})
+
+	t.Run("should apply the default policy to a command without one", func(t *testing.T) {
+		// Arrange
+		store, _, handler := setup(t, app.DuplicateSkip)
+
+		// Act
+		result, err := handler.Handle(ctx, app.IndexDocumentCommand{ID: "copy", Content: "Hello world."})
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, &app.IndexDocumentResult{DuplicateOf: "original", Skipped: true}, result)
+		_, err = store.FindByID(ctx, domain.ChunkID("copy", 0))
+		assert.ErrorIs(t, err, domain.ErrDocumentNotFound)
+	})
+
+	t.Run("should let the command override the default policy", func(t *testing.T) {
+		// Arrange
+		store, _, handler := setup(t, app.DuplicateSkip)
+
+		// Act
+		result, err := handler.Handle(ctx, app.IndexDocumentCommand{ID: "copy", Content: "Hello world.", OnDuplicate: app.DuplicateKeep})
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, &app.IndexDocumentResult{}, result)
+		_, err = store.FindByID(ctx, domain.ChunkID("copy", 0))
+		assert.NoError(t, err)
+	})
+}
+
+func TestBulkIndexDocumentsHandler_Handle_Duplicates(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := memory.NewStore(app.Cosine)
+	embedder := new(MockEmbeddingGenerator)
+	embedder.On("Generate", mock.Anything, mock.Anything).Return([]float32{1, 0, 0}, nil)
+	handler := app.NewBulkIndexDocumentsHandler(store, embedder, app.NewFixedSizeChunker(100, 0), app.NewDuplicateDetector(store, 0, app.DuplicateKeep))
+
+	// Act
+	results, errs := handler.Handle(ctx, []app.IndexDocumentCommand{
+		{ID: "a", Content: "alpha", OnDuplicate: app.DuplicateSkip},
+		{ID: "b", Content: "alpha", OnDuplicate: app.DuplicateSkip},
+		{ID: "c", Content: "beta", OnDuplicate: app.DuplicateSkip},
+	})
+
+	// Assert
+	assert.Equal(t, []error{nil, nil, nil}, errs)
+	assert.Equal(t, []app.IndexDocumentResult{{}, {DuplicateOf: "a", Skipped: true}, {}}, results)
+	_, err := store.FindByID(ctx, domain.ChunkID("b", 0))
+	assert.ErrorIs(t, err, domain.ErrDocumentNotFound)
+	embedder.AssertNumberOfCalls(t, "Generate", 2)
+}
@@ -0,0 +1,258 @@
+package app
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultEmbeddingCacheEntries = 10000
+
+// ErrEmbeddingNotCached is returned by an EmbeddingCacheStore when it holds no embedding for a key.
+var ErrEmbeddingNotCached = errors.New("embedding not cached")
+
+// CachedEmbedding is an embedding kept by an EmbeddingCacheStore.
+type CachedEmbedding struct {
+	Embedding []float32
+	CreatedAt time.Time
+}
+
+// EmbeddingCacheStore is a persistent tier of a CachingEmbeddingGenerator, consulted after the
+// in-memory tier. It bounds its own size.
+type EmbeddingCacheStore interface {
+	Get(ctx context.Context, key string) (*CachedEmbedding, error)
+	Put(ctx context.Context, key string, entry *CachedEmbedding) error
+}
+
+// EmbeddingCacheConfig holds the configuration of a CachingEmbeddingGenerator. Zero values select
+// the defaults.
+type EmbeddingCacheConfig struct {
+	// MaxEntries is the number of embeddings kept in memory, least recently used evicted first.
+	// Default 10000.
+	MaxEntries int
+	// TTL is the age after which a cached embedding is recomputed. Zero keeps embeddings forever.
+	TTL time.Duration
+	// Store is the optional persistent tier.
+	Store EmbeddingCacheStore
+}
+
+// EmbeddingCacheStats counts the lookups of a CachingEmbeddingGenerator.
+type EmbeddingCacheStats struct {
+	// Hits is the number of embeddings served from memory.
+	Hits uint64
+	// StoreHits is the number of embeddings served from the persistent tier.
+	StoreHits uint64
+	// Misses is the number of embeddings computed by the model.
+	Misses uint64
+}
+
+// EmbeddingCacheKey returns the cache key of the content embedded by the model: a hash of the
+// model name and the content with its whitespace collapsed.
+func EmbeddingCacheKey(model, content string) string {
+	sum := sha256.Sum256([]byte(model + "\x00" + normalizeContent(content)))
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeContent trims the content and collapses its runs of whitespace into single spaces.
+func normalizeContent(content string) string {
+	return strings.Join(strings.Fields(content), " ")
+}
+
+// CachingEmbeddingGenerator is an EmbeddingGenerator that reuses the embeddings of contents it
+// has seen, so that re-indexed documents and repeated queries do not call the model again.
+type CachingEmbeddingGenerator struct {
+	next EmbeddingGenerator
+	cfg  EmbeddingCacheConfig
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+
+	hits      atomic.Uint64
+	storeHits atomic.Uint64
+	misses    atomic.Uint64
+}
+
+// embeddingCacheEntry is an element of the in-memory LRU list.
+type embeddingCacheEntry struct {
+	key string
+	CachedEmbedding
+}
+
+// NewCachingEmbeddingGenerator creates a new CachingEmbeddingGenerator in front of next.
+func NewCachingEmbeddingGenerator(next EmbeddingGenerator, cfg EmbeddingCacheConfig) *CachingEmbeddingGenerator {
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = defaultEmbeddingCacheEntries
+	}
+	return &CachingEmbeddingGenerator{
+		next:    next,
+		cfg:     cfg,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// Generate returns the cached embedding of the content, generating it on a miss.
+func (g *CachingEmbeddingGenerator) Generate(ctx context.Context, content string) ([]float32, error) {
+	key := EmbeddingCacheKey(g.next.ModelName(), content)
+	if embedding, ok := g.lookup(ctx, key); ok {
+		return embedding, nil
+	}
+
+	embedding, err := g.next.Generate(ctx, content)
+	if err != nil {
+		return nil, err
+	}
+	g.store(ctx, key, embedding)
+	return embedding, nil
+}
+
+// GenerateBatch returns the embeddings of the contents in order, generating the missing ones in
+// a single batch.
+func (g *CachingEmbeddingGenerator) GenerateBatch(ctx context.Context, contents []string) ([][]float32, error) {
+	embeddings := make([][]float32, len(contents))
+	missing := make(map[string][]int)
+	var keys, pending []string
+	for i, content := range contents {
+		key := EmbeddingCacheKey(g.next.ModelName(), content)
+		if positions, ok := missing[key]; ok {
+			missing[key] = append(positions, i)
+			continue
+		}
+		if embedding, ok := g.lookup(ctx, key); ok {
+			embeddings[i] = embedding
+			continue
+		}
+		missing[key] = []int{i}
+		keys = append(keys, key)
+		pending = append(pending, content)
+	}
+	if len(pending) == 0 {
+		return embeddings, nil
+	}
+
+	generated, err := AsBatchEmbeddingGenerator(g.next).GenerateBatch(ctx, pending)
+	if err != nil {
+		return nil, err
+	}
+	if len(generated) != len(pending) {
+		return nil, fmt.Errorf("received %d embeddings for %d contents", len(generated), len(pending))
+	}
+	for i, key := range keys {
+		g.store(ctx, key, generated[i])
+		for _, position := range missing[key] {
+			embeddings[position] = generated[i]
+		}
+	}
+	return embeddings, nil
+}
+
+// ModelName returns the name of the underlying embedding model.
+func (g *CachingEmbeddingGenerator) ModelName() string {
+	return g.next.ModelName()
+}
+
+// Dimension returns the length of the vectors produced by the underlying model.
+func (g *CachingEmbeddingGenerator) Dimension() int {
+	return g.next.Dimension()
+}
+
+// Stats returns the lookup counters.
+func (g *CachingEmbeddingGenerator) Stats() EmbeddingCacheStats {
+	return EmbeddingCacheStats{
+		Hits:      g.hits.Load(),
+		StoreHits: g.storeHits.Load(),
+		Misses:    g.misses.Load(),
+	}
+}
+
+// Close closes the persistent tier if it holds resources.
+func (g *CachingEmbeddingGenerator) Close() error {
+	if closer, ok := g.cfg.Store.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// lookup returns a copy of the unexpired embedding cached for the key, promoting an embedding
+// found in the persistent tier into memory. Errors of the persistent tier count as misses.
+func (g *CachingEmbeddingGenerator) lookup(ctx context.Context, key string) ([]float32, bool) {
+	g.mu.Lock()
+	if elem, ok := g.entries[key]; ok {
+		entry := elem.Value.(*embeddingCacheEntry)
+		if !g.expired(entry.CreatedAt) {
+			g.lru.MoveToFront(elem)
+			embedding := append([]float32(nil), entry.Embedding...)
+			g.mu.Unlock()
+			g.hits.Add(1)
+			return embedding, true
+		}
+		g.lru.Remove(elem)
+		delete(g.entries, key)
+	}
+	g.mu.Unlock()
+
+	if g.cfg.Store != nil {
+		entry, err := g.cfg.Store.Get(ctx, key)
+		if err != nil && !errors.Is(err, ErrEmbeddingNotCached) {
+			log.Printf("Embedding cache: %v", err)
+		}
+		if err == nil && !g.expired(entry.CreatedAt) {
+			g.remember(key, *entry)
+			g.storeHits.Add(1)
+			return append([]float32(nil), entry.Embedding...), true
+		}
+	}
+
+	g.misses.Add(1)
+	return nil, false
+}
+
+// store caches a generated embedding in memory and in the persistent tier.
+func (g *CachingEmbeddingGenerator) store(ctx context.Context, key string, embedding []float32) {
+	entry := CachedEmbedding{
+		Embedding: append([]float32(nil), embedding...),
+		CreatedAt: time.Now().UTC(),
+	}
+	g.remember(key, entry)
+
+	if g.cfg.Store != nil {
+		if err := g.cfg.Store.Put(ctx, key, &entry); err != nil {
+			log.Printf("Embedding cache: %v", err)
+		}
+	}
+}
+
+// remember puts the entry at the front of the in-memory tier, evicting the least recently used
+// entries beyond MaxEntries.
+func (g *CachingEmbeddingGenerator) remember(key string, entry CachedEmbedding) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if elem, ok := g.entries[key]; ok {
+		elem.Value.(*embeddingCacheEntry).CachedEmbedding = entry
+		g.lru.MoveToFront(elem)
+		return
+	}
+	g.entries[key] = g.lru.PushFront(&embeddingCacheEntry{key: key, CachedEmbedding: entry})
+	for g.lru.Len() > g.cfg.MaxEntries {
+		oldest := g.lru.Back()
+		g.lru.Remove(oldest)
+		delete(g.entries, oldest.Value.(*embeddingCacheEntry).key)
+	}
+}
+
+func (g *CachingEmbeddingGenerator) expired(createdAt time.Time) bool {
+	return g.cfg.TTL > 0 && time.Since(createdAt) > g.cfg.TTL
+}
+
+var _ BatchEmbeddingGenerator = (*CachingEmbeddingGenerator)(nil)
@@ -0,0 +1,122 @@
+package app_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/igorrius/go-vector-search/internal/app"
+)
+
+// fakeEmbeddingCacheStore is an in-memory EmbeddingCacheStore.
+type fakeEmbeddingCacheStore map[string]*app.CachedEmbedding
+
+func (s fakeEmbeddingCacheStore) Get(_ context.Context, key string) (*app.CachedEmbedding, error) {
+	entry, ok := s[key]
+	if !ok {
+		return nil, app.ErrEmbeddingNotCached
+	}
+	return entry, nil
+}
+
+func (s fakeEmbeddingCacheStore) Put(_ context.Context, key string, entry *app.CachedEmbedding) error {
+	s[key] = entry
+	return nil
+}
+
+func TestEmbeddingCacheKey(t *testing.T) {
+	key := app.EmbeddingCacheKey("model", "hello world")
+
+	assert.Equal(t, key, app.EmbeddingCacheKey("model", "  hello\n\tworld "))
+	assert.NotEqual(t, key, app.EmbeddingCacheKey("other-model", "hello world"))
+	assert.NotEqual(t, key, app.EmbeddingCacheKey("model", "Hello world"))
+}
+
+func TestCachingEmbeddingGenerator(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should embed repeated content once", func(t *testing.T) {
+		// Arrange
+		embedder := new(MockEmbeddingGenerator)
+		embedder.On("Generate", ctx, "hello").Return([]float32{1, 0, 0}, nil).Once()
+		cache := app.NewCachingEmbeddingGenerator(embedder, app.EmbeddingCacheConfig{})
+
+		// Act
+		first, err1 := cache.Generate(ctx, "hello")
+		second, err2 := cache.Generate(ctx, " hello ")
+
+		// Assert
+		require.NoError(t, err1)
+		require.NoError(t, err2)
+		assert.Equal(t, first, second)
+		assert.Equal(t, app.EmbeddingCacheStats{Hits: 1, Misses: 1}, cache.Stats())
+		embedder.AssertExpectations(t)
+	})
+
+	t.Run("should generate only the missing contents of a batch", func(t *testing.T) {
+		// Arrange
+		embedder := new(MockBatchEmbeddingGenerator)
+		embedder.On("Generate", ctx, "a").Return([]float32{1, 0, 0}, nil).Once()
+		embedder.On("GenerateBatch", ctx, []string{"b", "c"}).Return([][]float32{{0, 1, 0}, {0, 0, 1}}, nil).Once()
+		cache := app.NewCachingEmbeddingGenerator(embedder, app.EmbeddingCacheConfig{})
+		_, err := cache.Generate(ctx, "a")
+		require.NoError(t, err)
+
+		// Act
+		embeddings, err := cache.GenerateBatch(ctx, []string{"b", "a", "c", "b"})
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, [][]float32{{0, 1, 0}, {1, 0, 0}, {0, 0, 1}, {0, 1, 0}}, embeddings)
+		assert.Equal(t, app.EmbeddingCacheStats{Hits: 1, Misses: 3}, cache.Stats())
+		embedder.AssertExpectations(t)
+	})
+
+	t.Run("should evict the least recently used embedding", func(t *testing.T) {
+		// Arrange
+		embedder := new(MockEmbeddingGenerator)
+		embedder.On("Generate", ctx, mock.Anything).Return([]float32{1, 0, 0}, nil)
+		cache := app.NewCachingEmbeddingGenerator(embedder, app.EmbeddingCacheConfig{MaxEntries: 2})
+		for _, content := range []string{"a", "b", "a", "c"} {
+			_, err := cache.Generate(ctx, content)
+			require.NoError(t, err)
+		}
+
+		// Act
+		_, errA := cache.Generate(ctx, "a")
+		_, errB := cache.Generate(ctx, "b")
+
+		// Assert
+		require.NoError(t, errA)
+		require.NoError(t, errB)
+		embedder.AssertNumberOfCalls(t, "Generate", 4)
+	})
+
+	t.Run("should serve embeddings from the store and ignore expired ones", func(t *testing.T) {
+		// Arrange
+		store := fakeEmbeddingCacheStore{
+			app.EmbeddingCacheKey("mock-embedding", "fresh"): {Embedding: []float32{1, 0, 0}, CreatedAt: time.Now()},
+			app.EmbeddingCacheKey("mock-embedding", "stale"): {Embedding: []float32{1, 0, 0}, CreatedAt: time.Now().Add(-2 * time.Hour)},
+		}
+		embedder := new(MockEmbeddingGenerator)
+		embedder.On("Generate", ctx, "stale").Return([]float32{0, 1, 0}, nil).Once()
+		cache := app.NewCachingEmbeddingGenerator(embedder, app.EmbeddingCacheConfig{TTL: time.Hour, Store: store})
+
+		// Act
+		fresh, err1 := cache.Generate(ctx, "fresh")
+		stale, err2 := cache.Generate(ctx, "stale")
+
+		// Assert
+		require.NoError(t, err1)
+		require.NoError(t, err2)
+		assert.Equal(t, []float32{1, 0, 0}, fresh)
+		assert.Equal(t, []float32{0, 1, 0}, stale)
+		assert.Equal(t, []float32{0, 1, 0}, store[app.EmbeddingCacheKey("mock-embedding", "stale")].Embedding)
+		assert.Equal(t, app.EmbeddingCacheStats{StoreHits: 1, Misses: 1}, cache.Stats())
+		embedder.AssertExpectations(t)
+	})
+}
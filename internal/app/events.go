@@ -0,0 +1,108 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// StreamDelta is the data of a "delta" event: the next piece of the generated text.
+type StreamDelta struct {
+	Text string
+}
+
+// StreamError is the data of an "error" event, which ends a stream failing after it started.
+type StreamError struct {
+	Error string
+}
+
+// SummaryDone is the data of the "done" event of a streamed search.
+type SummaryDone struct {
+	Summary string
+}
+
+// AnswerDone is the data of the "done" event of a streamed answer.
+type AnswerDone struct {
+	Answer    string
+	Citations []Citation
+	Uncited   bool
+}
+
+// acceptsEventStream reports whether the client asks for server-sent events.
+func acceptsEventStream(r *http.Request) bool {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		if mediaType, _, err := mime.ParseMediaType(accept); err == nil && mediaType == "text/event-stream" {
+			return true
+		}
+	}
+	return false
+}
+
+// eventStream writes server-sent events with JSON data. The response headers are written with
+// the first event, so errors before it can still be reported with a status code.
+type eventStream struct {
+	w       http.ResponseWriter
+	started bool
+}
+
+func (s *eventStream) send(event string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if !s.started {
+		s.w.Header().Set("Content-Type", "text/event-stream")
+		s.w.Header().Set("Cache-Control", "no-cache")
+		s.w.WriteHeader(http.StatusOK)
+		s.started = true
+	}
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+		return err
+	}
+	return http.NewResponseController(s.w).Flush()
+}
+
+// fail reports an error: with a status code if the stream has not started, and as an "error"
+// event otherwise. Nothing is written once the client has gone away.
+func (s *eventStream) fail(r *http.Request, err error, message string) {
+	if !s.started {
+		writeSearchError(s.w, err, message)
+		return
+	}
+	if r.Context().Err() == nil {
+		s.send("error", StreamError{Error: message})
+	}
+}
+
+// streamSearch answers a search with server-sent events: a "sources" event with the result
+// without its summary, "delta" events with the pieces of the summary, and a final "done" event.
+func (h *HTTPHandlers) streamSearch(w http.ResponseWriter, r *http.Request, query SearchDocumentsQuery) {
+	stream := &eventStream{w: w}
+	result, err := h.searchDocumentsHandler.HandleStream(r.Context(), query,
+		func(result *SearchResult) error { return stream.send("sources", result) },
+		func(delta string) error { return stream.send("delta", StreamDelta{Text: delta}) },
+	)
+	if err != nil {
+		stream.fail(r, err, "Failed to search documents")
+		return
+	}
+	stream.send("done", SummaryDone{Summary: result.Summary})
+}
+
+// streamAnswer answers a question with server-sent events: a "sources" event with the retrieved
+// sources, "delta" events with the pieces of the answer, and a final "done" event with the whole
+// answer and its citations.
+func (h *HTTPHandlers) streamAnswer(w http.ResponseWriter, r *http.Request, query AskQuery) {
+	stream := &eventStream{w: w}
+	result, err := h.askHandler.HandleStream(r.Context(), query,
+		func(result *AnswerResult) error { return stream.send("sources", result) },
+		func(delta string) error { return stream.send("delta", StreamDelta{Text: delta}) },
+	)
+	if err != nil {
+		stream.fail(r, err, "Failed to answer question")
+		return
+	}
+	stream.send("done", AnswerDone{Answer: result.Answer, Citations: result.Citations, Uncited: result.Uncited})
+}
@@ -0,0 +1,418 @@
+package app
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Filter is a structured metadata filter expression applied to vector search.
+// It is one of EqualFilter, InFilter, RangeFilter, TagFilter, AndFilter or OrFilter.
+type Filter interface {
+	filter()
+}
+
+// EqualFilter matches documents whose field equals the value.
+type EqualFilter struct {
+	Field string
+	Value string
+}
+
+// InFilter matches documents whose field equals any of the values.
+type InFilter struct {
+	Field  string
+	Values []string
+}
+
+// RangeFilter matches documents whose numeric or date field lies within [Min, Max].
+// A nil bound is open. Date fields are compared as Unix seconds.
+type RangeFilter struct {
+	Field string
+	Min   *float64
+	Max   *float64
+}
+
+// TagFilter matches documents tagged with any of the tags.
+type TagFilter struct {
+	Tags []string
+}
+
+// AndFilter matches documents matching all of the filters.
+type AndFilter struct {
+	Filters []Filter
+}
+
+// OrFilter matches documents matching any of the filters.
+type OrFilter struct {
+	Filters []Filter
+}
+
+func (EqualFilter) filter() {}
+func (InFilter) filter()    {}
+func (RangeFilter) filter() {}
+func (TagFilter) filter()   {}
+func (AndFilter) filter()   {}
+func (OrFilter) filter()    {}
+
+// FilterError is returned for a malformed or unsupported filter expression.
+type FilterError struct {
+	Reason string
+}
+
+func (e *FilterError) Error() string {
+	return "invalid filter: " + e.Reason
+}
+
+func filterErrorf(format string, args ...interface{}) *FilterError {
+	return &FilterError{Reason: fmt.Sprintf(format, args...)}
+}
+
+// FieldKind is the type of a filterable field.
+type FieldKind int
+
+const (
+	StringField FieldKind = iota
+	NumberField
+	DateField
+	TagsField
+)
+
+// AttributeFieldPrefix prefixes filter fields referring to custom metadata attributes,
+// e.g. "attributes.author".
+const AttributeFieldPrefix = "attributes."
+
+var filterableFields = map[string]FieldKind{
+	"parent_id":    StringField,
+	"title":        StringField,
+	"source_uri":   StringField,
+	"mime_type":    StringField,
+	"content_hash": StringField,
+	"tags":         TagsField,
+	"chunk_index":  NumberField,
+	"created_at":   DateField,
+	"updated_at":   DateField,
+}
+
+// FilterFieldKind returns the kind of a filterable field and whether the field can be filtered on.
+func FilterFieldKind(field string) (FieldKind, bool) {
+	if name, ok := strings.CutPrefix(field, AttributeFieldPrefix); ok {
+		return StringField, name != ""
+	}
+	kind, ok := filterableFields[field]
+	return kind, ok
+}
+
+// ParseFilter parses a filter expression. An empty expression yields a nil Filter.
+//
+// Conditions are combined with AND and OR, AND binding tighter, and grouped with parentheses:
+//
+//	field:value           equality; for tags, membership
+//	field:[a, b, c]       any of the values
+//	field:min..max        inclusive range, either bound may be omitted
+//
+// Values containing spaces or special characters are double-quoted. Dates are given as
+// YYYY-MM-DD, a date-only upper bound including the whole day, or as quoted RFC 3339 timestamps,
+// e.g. created_at:"2024-01-01T00:00:00Z..".
+func ParseFilter(expr string) (Filter, error) {
+	tokens, err := tokenizeFilter(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{tokens: tokens}
+	if len(p.tokens) == 0 {
+		return nil, nil
+	}
+
+	f, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, filterErrorf("unexpected %q", p.tokens[p.pos].text)
+	}
+	return f, nil
+}
+
+type filterToken struct {
+	text   string
+	quoted bool
+}
+
+// tokenizeFilter splits an expression into words, quoted strings and the symbols ( ) [ ] , :
+func tokenizeFilter(expr string) ([]filterToken, error) {
+	var tokens []filterToken
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case strings.ContainsRune("()[],:", r):
+			tokens = append(tokens, filterToken{text: string(r)})
+			i++
+		case r == '"':
+			j := i + 1
+			var b strings.Builder
+			for j < len(runes) && runes[j] != '"' {
+				if runes[j] == '\\' && j+1 < len(runes) {
+					j++
+				}
+				b.WriteRune(runes[j])
+				j++
+			}
+			if j == len(runes) {
+				return nil, filterErrorf("unterminated quoted value")
+			}
+			tokens = append(tokens, filterToken{text: b.String(), quoted: true})
+			i = j + 1
+		default:
+			j := i
+			for j < len(runes) && !unicode.IsSpace(runes[j]) && !strings.ContainsRune("()[],:\"", runes[j]) {
+				j++
+			}
+			tokens = append(tokens, filterToken{text: string(runes[i:j])})
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+}
+
+func (p *filterParser) peek() (filterToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return filterToken{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *filterParser) next() (filterToken, error) {
+	tok, ok := p.peek()
+	if !ok {
+		return filterToken{}, filterErrorf("unexpected end of expression")
+	}
+	p.pos++
+	return tok, nil
+}
+
+func (p *filterParser) isKeyword(word string) bool {
+	tok, ok := p.peek()
+	return ok && !tok.quoted && strings.EqualFold(tok.text, word)
+}
+
+func (p *filterParser) isSymbol(symbol string) bool {
+	tok, ok := p.peek()
+	return ok && !tok.quoted && tok.text == symbol
+}
+
+func (p *filterParser) expect(symbol string) error {
+	tok, err := p.next()
+	if err != nil {
+		return filterErrorf("expected %q", symbol)
+	}
+	if tok.quoted || tok.text != symbol {
+		return filterErrorf("expected %q, got %q", symbol, tok.text)
+	}
+	return nil
+}
+
+func (p *filterParser) parseOr() (Filter, error) {
+	f, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	filters := []Filter{f}
+	for p.isKeyword("OR") {
+		p.pos++
+		f, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, f)
+	}
+	if len(filters) == 1 {
+		return filters[0], nil
+	}
+	return OrFilter{Filters: filters}, nil
+}
+
+func (p *filterParser) parseAnd() (Filter, error) {
+	f, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	filters := []Filter{f}
+	for p.isKeyword("AND") {
+		p.pos++
+		f, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, f)
+	}
+	if len(filters) == 1 {
+		return filters[0], nil
+	}
+	return AndFilter{Filters: filters}, nil
+}
+
+func (p *filterParser) parseTerm() (Filter, error) {
+	if p.isSymbol("(") {
+		p.pos++
+		f, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return f, nil
+	}
+	return p.parseCondition()
+}
+
+func (p *filterParser) parseCondition() (Filter, error) {
+	tok, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	field := tok.text
+	kind, ok := FilterFieldKind(field)
+	if tok.quoted || !ok {
+		return nil, filterErrorf("unknown field %q", field)
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+
+	if p.isSymbol("[") {
+		p.pos++
+		values, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		return FieldFilter(field, values...)
+	}
+
+	tok, err = p.next()
+	if err != nil {
+		return nil, err
+	}
+	if !tok.quoted && strings.ContainsAny(tok.text, "()[],:") {
+		return nil, filterErrorf("expected a value for %q, got %q", field, tok.text)
+	}
+	if tok.text == "" {
+		return nil, filterErrorf("empty value for %q", field)
+	}
+
+	if kind == StringField && !tok.quoted && strings.Contains(tok.text, "..") {
+		return nil, filterErrorf("field %q does not support ranges", field)
+	}
+	return FieldFilter(field, tok.text)
+}
+
+func (p *filterParser) parseList() ([]string, error) {
+	var values []string
+	for {
+		tok, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		if !tok.quoted && strings.ContainsAny(tok.text, "()[],:") {
+			return nil, filterErrorf("unexpected %q in value list", tok.text)
+		}
+		values = append(values, tok.text)
+
+		tok, err = p.next()
+		if err != nil {
+			return nil, filterErrorf("unterminated value list")
+		}
+		switch {
+		case !tok.quoted && tok.text == "]":
+			return values, nil
+		case !tok.quoted && tok.text == ",":
+		default:
+			return nil, filterErrorf("expected \",\" or \"]\", got %q", tok.text)
+		}
+	}
+}
+
+// FieldFilter builds the condition for a single field from raw values, following the rules of
+// ParseFilter: several values match any of them, and a single value of a numeric or date field
+// may be a min..max range.
+func FieldFilter(field string, values ...string) (Filter, error) {
+	kind, ok := FilterFieldKind(field)
+	if !ok {
+		return nil, filterErrorf("unknown field %q", field)
+	}
+	if len(values) == 0 {
+		return nil, filterErrorf("no value for %q", field)
+	}
+
+	switch {
+	case kind == TagsField:
+		return TagFilter{Tags: values}, nil
+	case kind == StringField && len(values) == 1:
+		return EqualFilter{Field: field, Value: values[0]}, nil
+	case kind == StringField:
+		return InFilter{Field: field, Values: values}, nil
+	case len(values) > 1:
+		return nil, filterErrorf("field %q does not support value lists", field)
+	case !strings.Contains(values[0], ".."):
+		// An exact number or date is a range with equal bounds.
+		return parseRange(field, kind, values[0]+".."+values[0])
+	default:
+		return parseRange(field, kind, values[0])
+	}
+}
+
+func parseRange(field string, kind FieldKind, text string) (Filter, error) {
+	lower, upper, _ := strings.Cut(text, "..")
+	if lower == "" && upper == "" {
+		return nil, filterErrorf("range for %q needs at least one bound", field)
+	}
+
+	f := RangeFilter{Field: field}
+	for _, bound := range []struct {
+		text  string
+		upper bool
+		dest  **float64
+	}{{lower, false, &f.Min}, {upper, true, &f.Max}} {
+		if bound.text == "" {
+			continue
+		}
+		v, err := parseBound(kind, bound.text, bound.upper)
+		if err != nil {
+			return nil, filterErrorf("invalid value %q for %q", bound.text, field)
+		}
+		*bound.dest = &v
+	}
+
+	if f.Min != nil && f.Max != nil && *f.Min > *f.Max {
+		return nil, filterErrorf("empty range for %q", field)
+	}
+	return f, nil
+}
+
+// parseBound parses a range bound. A date-only upper bound includes the whole day.
+func parseBound(kind FieldKind, text string, upper bool) (float64, error) {
+	if kind == NumberField {
+		return strconv.ParseFloat(text, 64)
+	}
+	if t, err := time.Parse(time.RFC3339, text); err == nil {
+		return float64(t.Unix()), nil
+	}
+	t, err := time.Parse(time.DateOnly, text)
+	if err != nil {
+		return 0, err
+	}
+	if upper {
+		t = t.AddDate(0, 0, 1).Add(-time.Second)
+	}
+	return float64(t.Unix()), nil
+}
@@ -0,0 +1,131 @@
+package app_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/igorrius/go-vector-search/internal/app"
+)
+
+func float(f float64) *float64 {
+	return &f
+}
+
+func TestParseFilter(t *testing.T) {
+	day := func(s string) float64 {
+		d, _ := time.Parse(time.DateOnly, s)
+		return float64(d.Unix())
+	}
+
+	tests := []struct {
+		name string
+		expr string
+		want app.Filter
+	}{
+		{
+			name: "empty",
+			expr: "  ",
+			want: nil,
+		},
+		{
+			name: "equality",
+			expr: `mime_type:text/plain`,
+			want: app.EqualFilter{Field: "mime_type", Value: "text/plain"},
+		},
+		{
+			name: "quoted value",
+			expr: `title:"Release notes: v1.2"`,
+			want: app.EqualFilter{Field: "title", Value: "Release notes: v1.2"},
+		},
+		{
+			name: "value list",
+			expr: `source_uri:[a, "b c"]`,
+			want: app.InFilter{Field: "source_uri", Values: []string{"a", "b c"}},
+		},
+		{
+			name: "tag membership",
+			expr: `tags:go`,
+			want: app.TagFilter{Tags: []string{"go"}},
+		},
+		{
+			name: "attribute",
+			expr: `attributes.author:me`,
+			want: app.EqualFilter{Field: "attributes.author", Value: "me"},
+		},
+		{
+			name: "numeric range",
+			expr: `chunk_index:2..5`,
+			want: app.RangeFilter{Field: "chunk_index", Min: float(2), Max: float(5)},
+		},
+		{
+			name: "open date range includes the whole last day",
+			expr: `created_at:..2024-01-31`,
+			want: app.RangeFilter{Field: "created_at", Max: float(day("2024-02-01") - 1)},
+		},
+		{
+			name: "quoted timestamp range",
+			expr: `updated_at:"2024-01-01T00:00:00Z.."`,
+			want: app.RangeFilter{Field: "updated_at", Min: float(day("2024-01-01"))},
+		},
+		{
+			name: "AND binds tighter than OR",
+			expr: `tags:a OR tags:b AND mime_type:x`,
+			want: app.OrFilter{Filters: []app.Filter{
+				app.TagFilter{Tags: []string{"a"}},
+				app.AndFilter{Filters: []app.Filter{
+					app.TagFilter{Tags: []string{"b"}},
+					app.EqualFilter{Field: "mime_type", Value: "x"},
+				}},
+			}},
+		},
+		{
+			name: "parentheses",
+			expr: `(tags:a or tags:b) and mime_type:x`,
+			want: app.AndFilter{Filters: []app.Filter{
+				app.OrFilter{Filters: []app.Filter{
+					app.TagFilter{Tags: []string{"a"}},
+					app.TagFilter{Tags: []string{"b"}},
+				}},
+				app.EqualFilter{Field: "mime_type", Value: "x"},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := app.ParseFilter(tt.expr)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseFilter_Invalid(t *testing.T) {
+	for _, expr := range []string{
+		`unknown:x`,
+		`title`,
+		`title:`,
+		`title:a..b`,
+		`chunk_index:abc`,
+		`chunk_index:[1, 2]`,
+		`created_at:2024-13-01`,
+		`chunk_index:5..2`,
+		`chunk_index:..`,
+		`(tags:a`,
+		`tags:a tags:b`,
+		`tags:[a, b`,
+		`title:"unterminated`,
+		`tags:a AND`,
+	} {
+		t.Run(expr, func(t *testing.T) {
+			_, err := app.ParseFilter(expr)
+
+			var filterErr *app.FilterError
+			assert.ErrorAs(t, err, &filterErr)
+		})
+	}
+}
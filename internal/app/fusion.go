@@ -0,0 +1,201 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// rrfK dampens the influence of top ranks in reciprocal rank fusion, as proposed by Cormack et al.
+const rrfK = 60
+
+// FusionMethod selects how FusionVectorStore merges the rankings of its retrievers.
+type FusionMethod int
+
+const (
+	// ReciprocalRankFusion scores a document by the sum of weight/(60+rank) over the retrievers.
+	ReciprocalRankFusion FusionMethod = iota
+	// WeightedScoreFusion scores a document by the weighted sum of its min-max normalized
+	// relevance in every retriever.
+	WeightedScoreFusion
+)
+
+// Retriever is a source of hits combined by FusionVectorStore.
+type Retriever struct {
+	Name  string
+	Store VectorStore
+	// Weight scales the contribution of the retriever; zero means 1.
+	Weight float64
+	// Options adapts the search options for this retriever, e.g. VectorOnly or KeywordOnly.
+	// Nil passes them through unchanged.
+	Options func(SearchOptions) SearchOptions
+}
+
+// VectorOnly turns the search options into a pure vector search.
+func VectorOnly(opts SearchOptions) SearchOptions {
+	opts.Text = ""
+	opts.Alpha = 0
+	return opts
+}
+
+// KeywordOnly turns the search options into a pure keyword search for the query text. The
+// embedding is dropped, so stores do not return vector matches at all.
+func KeywordOnly(opts SearchOptions) SearchOptions {
+	opts.Text = opts.Query
+	opts.Embedding = nil
+	opts.Alpha = 0
+	return opts
+}
+
+// FusionVectorStore implements VectorStore by querying several retrievers concurrently and
+// merging their results into a single ranking deduplicated by document ID.
+// A failing retriever is skipped as long as another one succeeds.
+type FusionVectorStore struct {
+	method     FusionMethod
+	retrievers []Retriever
+}
+
+// NewFusionVectorStore creates a new FusionVectorStore.
+func NewFusionVectorStore(method FusionMethod, retrievers ...Retriever) *FusionVectorStore {
+	return &FusionVectorStore{
+		method:     method,
+		retrievers: retrievers,
+	}
+}
+
+// Search fans the search out to all retrievers and returns the fused page of hits.
+// Every retriever is asked for enough hits to fill the requested page on its own.
+func (s *FusionVectorStore) Search(ctx context.Context, opts SearchOptions) ([]SearchHit, error) {
+	perRetriever := opts
+	perRetriever.Limit = opts.Offset + opts.Limit
+	perRetriever.Offset = 0
+
+	results := make([][]SearchHit, len(s.retrievers))
+	keyword := make([]bool, len(s.retrievers))
+	errs := make([]error, len(s.retrievers))
+	var wg sync.WaitGroup
+	for i, r := range s.retrievers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			o := perRetriever
+			if r.Options != nil {
+				o = r.Options(o)
+			}
+			keyword[i] = len(o.Embedding) == 0
+			results[i], errs[i] = r.Store.Search(ctx, o)
+			if errs[i] != nil {
+				errs[i] = fmt.Errorf("retriever %q: %w", r.Name, errs[i])
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	succeeded := 0
+	for _, err := range errs {
+		// An invalid filter fails every retriever alike and is reported to the caller.
+		var filterErr *FilterError
+		if errors.As(err, &filterErr) {
+			return nil, filterErr
+		}
+		if err == nil {
+			succeeded++
+		}
+	}
+	if succeeded == 0 && len(s.retrievers) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	fused := s.fuse(results, keyword)
+	if opts.Offset >= len(fused) {
+		return nil, nil
+	}
+	return fused[opts.Offset:min(opts.Offset+opts.Limit, len(fused))], nil
+}
+
+// fuse merges the rankings of the retrievers, keeping the best vector and keyword scores seen
+// for each document. keyword reports the retrievers that searched by keyword only, whose hits
+// have no vector score.
+func (s *FusionVectorStore) fuse(results [][]SearchHit, keyword []bool) []SearchHit {
+	byID := make(map[string]*SearchHit)
+	// scored holds the documents whose merged hit has a vector score.
+	scored := make(map[string]bool)
+	var order []string
+
+	for i, hits := range results {
+		weight := s.retrievers[i].Weight
+		if weight == 0 {
+			weight = 1
+		}
+		relevance := normalizedRelevance(hits, keyword[i])
+
+		for rank, hit := range hits {
+			var contribution float64
+			switch s.method {
+			case WeightedScoreFusion:
+				contribution = weight * relevance[rank]
+			default:
+				contribution = weight / float64(rrfK+rank+1)
+			}
+
+			merged, ok := byID[hit.Document.ID]
+			if !ok {
+				merged = &SearchHit{Document: hit.Document, TextMatch: hit.TextMatch}
+				byID[hit.Document.ID] = merged
+				order = append(order, hit.Document.ID)
+			}
+			if !keyword[i] && (!scored[hit.Document.ID] || hit.Score > merged.Score) {
+				merged.Score = hit.Score
+				merged.Similarity = hit.Similarity
+				merged.Distance = hit.Distance
+				scored[hit.Document.ID] = true
+			}
+			merged.TextMatch = max(merged.TextMatch, hit.TextMatch)
+			merged.FusionScore += contribution
+		}
+	}
+
+	fused := make([]SearchHit, 0, len(order))
+	for _, id := range order {
+		fused = append(fused, *byID[id])
+	}
+	sort.SliceStable(fused, func(i, j int) bool {
+		return fused[i].FusionScore > fused[j].FusionScore
+	})
+	return fused
+}
+
+// normalizedRelevance min-max normalizes the relevance of a retriever's hits into [0, 1].
+// The relevance of a hit is its vector score, or its keyword score for a keyword-only retriever.
+func normalizedRelevance(hits []SearchHit, keyword bool) []float64 {
+	relevance := make([]float64, len(hits))
+	lo, hi := 0.0, 0.0
+	for i, hit := range hits {
+		relevance[i] = hit.Score
+		if keyword {
+			relevance[i] = hit.TextMatch
+		}
+		if i == 0 || relevance[i] < lo {
+			lo = relevance[i]
+		}
+		if i == 0 || relevance[i] > hi {
+			hi = relevance[i]
+		}
+	}
+	for i := range relevance {
+		if hi == lo {
+			relevance[i] = 1
+			continue
+		}
+		relevance[i] = (relevance[i] - lo) / (hi - lo)
+	}
+	return relevance
+}
+
+var _ VectorStore = (*FusionVectorStore)(nil)
@@ -0,0 +1,140 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/igorrius/go-vector-search/internal/domain"
+)
+
+func hitsFor(ids ...string) []SearchHit {
+	var hits []SearchHit
+	for i, id := range ids {
+		score := 1 - float64(i)*0.1
+		hits = append(hits, SearchHit{Document: domain.Document{ID: id}, Score: score, Distance: 1 - score})
+	}
+	return hits
+}
+
+func ids(hits []SearchHit) []string {
+	var out []string
+	for _, hit := range hits {
+		out = append(out, hit.Document.ID)
+	}
+	return out
+}
+
+func TestFusionVectorStore_Search(t *testing.T) {
+	ctx := context.Background()
+	opts := SearchOptions{Query: "q", Embedding: []float32{1}, Limit: 3}
+
+	t.Run("should fuse rankings with reciprocal rank fusion", func(t *testing.T) {
+		vector := new(MockVectorStore)
+		keyword := new(MockVectorStore)
+		vector.On("Search", ctx, VectorOnly(opts)).Return(hitsFor("a", "b", "c"), nil)
+		keyword.On("Search", ctx, KeywordOnly(opts)).Return(hitsFor("c", "d", "a"), nil)
+
+		store := NewFusionVectorStore(ReciprocalRankFusion,
+			Retriever{Name: "vector", Store: vector, Options: VectorOnly},
+			Retriever{Name: "keyword", Store: keyword, Options: KeywordOnly},
+		)
+		hits, err := store.Search(ctx, opts)
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a", "c", "b"}, ids(hits))
+		assert.InDelta(t, 1.0/61+1.0/63, hits[0].FusionScore, 1e-9)
+		vector.AssertExpectations(t)
+		keyword.AssertExpectations(t)
+	})
+
+	t.Run("should weight normalized scores", func(t *testing.T) {
+		first := new(MockVectorStore)
+		second := new(MockVectorStore)
+		first.On("Search", ctx, opts).Return(hitsFor("a", "b"), nil)
+		second.On("Search", ctx, opts).Return(hitsFor("b", "a"), nil)
+
+		store := NewFusionVectorStore(WeightedScoreFusion,
+			Retriever{Name: "first", Store: first, Weight: 1},
+			Retriever{Name: "second", Store: second, Weight: 3},
+		)
+		hits, err := store.Search(ctx, opts)
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"b", "a"}, ids(hits))
+		assert.Equal(t, 3.0, hits[0].FusionScore)
+		assert.Equal(t, 1.0, hits[0].Score)
+	})
+
+	t.Run("should rank vector hits by their score even when it is zero", func(t *testing.T) {
+		vector := new(MockVectorStore)
+		vector.On("Search", ctx, opts).Return([]SearchHit{
+			{Document: domain.Document{ID: "a"}, Score: 0.5},
+			{Document: domain.Document{ID: "b"}, Score: 0, TextMatch: 100},
+		}, nil)
+
+		store := NewFusionVectorStore(WeightedScoreFusion, Retriever{Name: "vector", Store: vector})
+		hits, err := store.Search(ctx, opts)
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a", "b"}, ids(hits))
+	})
+
+	t.Run("should fetch the whole page from every retriever and cut the fused result", func(t *testing.T) {
+		paged := opts
+		paged.Limit = 2
+		paged.Offset = 1
+		retriever := new(MockVectorStore)
+		retriever.On("Search", ctx, SearchOptions{Query: "q", Embedding: []float32{1}, Limit: 3}).Return(hitsFor("a", "b", "c"), nil)
+
+		hits, err := NewFusionVectorStore(ReciprocalRankFusion, Retriever{Name: "only", Store: retriever}).Search(ctx, paged)
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"b", "c"}, ids(hits))
+	})
+
+	t.Run("should tolerate a failing retriever", func(t *testing.T) {
+		healthy := new(MockVectorStore)
+		broken := new(MockVectorStore)
+		healthy.On("Search", ctx, opts).Return(hitsFor("a"), nil)
+		broken.On("Search", ctx, opts).Return([]SearchHit(nil), errors.New("unavailable"))
+
+		store := NewFusionVectorStore(ReciprocalRankFusion,
+			Retriever{Name: "healthy", Store: healthy},
+			Retriever{Name: "broken", Store: broken},
+		)
+		hits, err := store.Search(ctx, opts)
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a"}, ids(hits))
+	})
+
+	t.Run("should fail when every retriever fails", func(t *testing.T) {
+		broken := new(MockVectorStore)
+		broken.On("Search", ctx, mock.Anything).Return([]SearchHit(nil), errors.New("unavailable"))
+
+		_, err := NewFusionVectorStore(ReciprocalRankFusion, Retriever{Name: "broken", Store: broken}).Search(ctx, opts)
+
+		assert.ErrorContains(t, err, `retriever "broken": unavailable`)
+	})
+
+	t.Run("should report an invalid filter", func(t *testing.T) {
+		healthy := new(MockVectorStore)
+		rejecting := new(MockVectorStore)
+		healthy.On("Search", ctx, opts).Return(hitsFor("a"), nil)
+		rejecting.On("Search", ctx, opts).Return([]SearchHit(nil), &FilterError{Reason: "bad"})
+
+		store := NewFusionVectorStore(ReciprocalRankFusion,
+			Retriever{Name: "healthy", Store: healthy},
+			Retriever{Name: "rejecting", Store: rejecting},
+		)
+		_, err := store.Search(ctx, opts)
+
+		var filterErr *FilterError
+		assert.ErrorAs(t, err, &filterErr)
+	})
+}
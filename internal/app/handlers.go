@@ -1,79 +1,510 @@
 package app
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
-	"io"
+	"errors"
+	"fmt"
+	"io/fs"
+	"mime"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"github.com/igorrius/go-vector-search/internal/domain"
 )
 
 // HTTPHandlers holds the command and query handlers.
 type HTTPHandlers struct {
-	indexDocumentHandler   *IndexDocumentHandler
+	jobQueue               *JobQueue
 	searchDocumentsHandler *SearchDocumentsHandler
+	getDocumentHandler     *GetDocumentHandler
+	listDocumentsHandler   *ListDocumentsHandler
+	updateDocumentHandler  *UpdateDocumentHandler
+	deleteDocumentHandler  *DeleteDocumentHandler
+	bulkIndexHandler       *BulkIndexDocumentsHandler
+	askHandler             *AskHandler
+	conversationHandler    *ConversationHandler
+	extractor              TextExtractor
+	uploads                UploadConfig
 }
 
-// NewHTTPHandlers creates a new HTTPHandlers.
-func NewHTTPHandlers(indexDocumentHandler *IndexDocumentHandler, searchDocumentsHandler *SearchDocumentsHandler) *HTTPHandlers {
+// NewHTTPHandlers creates a new HTTPHandlers. A nil extractor indexes uploaded files as they are,
+// without detecting their type.
+func NewHTTPHandlers(
+	jobQueue *JobQueue,
+	searchDocumentsHandler *SearchDocumentsHandler,
+	getDocumentHandler *GetDocumentHandler,
+	listDocumentsHandler *ListDocumentsHandler,
+	updateDocumentHandler *UpdateDocumentHandler,
+	deleteDocumentHandler *DeleteDocumentHandler,
+	bulkIndexHandler *BulkIndexDocumentsHandler,
+	askHandler *AskHandler,
+	conversationHandler *ConversationHandler,
+	extractor TextExtractor,
+	uploads UploadConfig,
+) *HTTPHandlers {
+	if uploads.MaxBodySize <= 0 {
+		uploads.MaxBodySize = DefaultMaxUploadSize
+	}
 	return &HTTPHandlers{
-		indexDocumentHandler:   indexDocumentHandler,
+		jobQueue:               jobQueue,
 		searchDocumentsHandler: searchDocumentsHandler,
+		getDocumentHandler:     getDocumentHandler,
+		listDocumentsHandler:   listDocumentsHandler,
+		updateDocumentHandler:  updateDocumentHandler,
+		deleteDocumentHandler:  deleteDocumentHandler,
+		bulkIndexHandler:       bulkIndexHandler,
+		askHandler:             askHandler,
+		conversationHandler:    conversationHandler,
+		extractor:              extractor,
+		uploads:                uploads,
 	}
 }
 
 // IndexDocumentRequest is the request body for indexing a document.
 type IndexDocumentRequest struct {
-	ID      string `json:"id"`
-	Content string `json:"content"`
+	ID         string            `json:"id"`
+	Content    string            `json:"content"`
+	Title      string            `json:"title"`
+	Summary    string            `json:"summary"`
+	SourceURI  string            `json:"source_uri"`
+	MIMEType   string            `json:"mime_type"`
+	Tags       []string          `json:"tags"`
+	Attributes map[string]string `json:"attributes"`
+	// OnDuplicate is the DuplicatePolicy: "keep", "skip" or "replace". Empty selects the server
+	// default.
+	OnDuplicate string `json:"on_duplicate"`
+}
+
+func (req IndexDocumentRequest) command() (IndexDocumentCommand, error) {
+	policy, err := requestDuplicatePolicy(req.OnDuplicate)
+	if err != nil {
+		return IndexDocumentCommand{}, err
+	}
+	return IndexDocumentCommand{
+		ID:      req.ID,
+		Content: req.Content,
+		Metadata: domain.Metadata{
+			Title:      req.Title,
+			Summary:    req.Summary,
+			SourceURI:  req.SourceURI,
+			MIMEType:   req.MIMEType,
+			Tags:       req.Tags,
+			Attributes: req.Attributes,
+		},
+		OnDuplicate: policy,
+	}, nil
+}
+
+// JobResult represents the state of an ingestion job.
+type JobResult struct {
+	ID         string
+	DocumentID string
+	Status     JobStatus
+	Attempts   int
+	Error      string `json:",omitempty"`
+	// DuplicateOf is the ID of the indexed document the document duplicates.
+	DuplicateOf string `json:",omitempty"`
+	Skipped     bool   `json:",omitempty"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
 }
 
-// IndexDocumentHandler handles the POST /api/v1/documents endpoint.
+func jobResult(job *Job) JobResult {
+	return JobResult{
+		ID:          job.ID,
+		DocumentID:  job.Command.ID,
+		Status:      job.Status,
+		Attempts:    job.Attempts,
+		Error:       job.Error,
+		DuplicateOf: job.DuplicateOf,
+		Skipped:     job.Skipped,
+		CreatedAt:   job.CreatedAt,
+		UpdatedAt:   job.UpdatedAt,
+	}
+}
+
+// IndexDocumentHandler handles the POST /api/v1/documents endpoint. The document is indexed by a
+// background job, whose state is returned and can be polled at the Location of the response. A
+// multipart request may upload several files, each indexed as its own document; the response then
+// lists the job of every file.
 func (h *HTTPHandlers) IndexDocumentHandler(w http.ResponseWriter, r *http.Request) {
-	var cmd IndexDocumentCommand
+	r.Body = http.MaxBytesReader(w, r.Body, h.uploads.MaxBodySize)
 
 	contentType := r.Header.Get("Content-Type")
-	if contentType == "application/json" {
-		var req IndexDocumentRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType == "multipart/form-data" {
+		h.uploadDocuments(w, r)
+		return
+	}
+	if contentType != "application/json" {
+		http.Error(w, "Unsupported content type", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	var req IndexDocumentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err, "Invalid request body")
+		return
+	}
+	cmd, err := req.command()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if cmd.ID == "" {
+		cmd.ID = uuid.New().String()
+	}
+
+	job, err := h.jobQueue.Submit(r.Context(), cmd)
+	if err != nil {
+		if errors.Is(err, ErrEmptyDocument) {
+			http.Error(w, "Document has no content", http.StatusBadRequest)
 			return
 		}
-		cmd.ID = req.ID
-		cmd.Content = req.Content
-	} else if _, _, err := r.FormFile("file"); err == nil {
-		file, _, err := r.FormFile("file")
-		if err != nil {
-			http.Error(w, "Invalid file", http.StatusBadRequest)
+		http.Error(w, "Failed to index document", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/v1/jobs/"+job.ID)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(jobResult(job))
+}
+
+// uploadDocuments indexes the files of a multipart request. Nothing is indexed unless every file
+// can be: all files are extracted before the first job is submitted, and the jobs are queued
+// only once all are persisted.
+func (h *HTTPHandlers) uploadDocuments(w http.ResponseWriter, r *http.Request) {
+	upload, err := readUpload(r)
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		http.Error(w, "Failed to store upload", http.StatusInternalServerError)
+		return
+	}
+	if err != nil {
+		writeBodyError(w, err, "Invalid multipart body")
+		return
+	}
+	defer upload.Close()
+	if len(upload.files) == 0 {
+		http.Error(w, "No file uploaded", http.StatusBadRequest)
+		return
+	}
+
+	cmds, err := h.uploadCommands(r.Context(), upload.files, upload.values)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, ErrUnsupportedMediaType) {
+			status = http.StatusUnsupportedMediaType
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	jobs, err := h.jobQueue.SubmitAll(r.Context(), cmds)
+	if err != nil {
+		http.Error(w, "Failed to index document", http.StatusInternalServerError)
+		return
+	}
+	result := UploadResult{Files: make([]UploadedFileResult, 0, len(jobs))}
+	for i, job := range jobs {
+		result.Files = append(result.Files, UploadedFileResult{Filename: upload.files[i].filename, JobResult: jobResult(job)})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if len(result.Files) == 1 {
+		w.Header().Set("Location", "/api/v1/jobs/"+result.Files[0].ID)
+	}
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(result)
+}
+
+// writeBodyError writes 413 for a request body over the size limit and 400 with the message for
+// any other error reading it.
+func writeBodyError(w http.ResponseWriter, err error, message string) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, fmt.Sprintf("Request body exceeds %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+		return
+	}
+	http.Error(w, message, http.StatusBadRequest)
+}
+
+// GetJobHandler handles the GET /api/v1/jobs/{id} endpoint.
+func (h *HTTPHandlers) GetJobHandler(w http.ResponseWriter, r *http.Request) {
+	job, err := h.jobQueue.Get(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		if errors.Is(err, ErrJobNotFound) {
+			http.Error(w, "Job not found", http.StatusNotFound)
 			return
 		}
-		defer file.Close()
+		http.Error(w, "Failed to get job", http.StatusInternalServerError)
+		return
+	}
 
-		content, err := io.ReadAll(file)
-		if err != nil {
-			http.Error(w, "Failed to read file", http.StatusInternalServerError)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(jobResult(job))
+}
+
+const (
+	// maxBulkLineSize is the longest accepted line of a bulk request.
+	maxBulkLineSize = 8 << 20
+	// maxBulkBatchBytes flushes a bulk batch early once its lines add up to this size, bounding
+	// the memory held by a request.
+	maxBulkBatchBytes = 4 << 20
+)
+
+// BulkIndexResult reports the outcome of one line of a bulk request. Status is "ok", "skipped"
+// for a duplicate under the skip policy, or "error".
+type BulkIndexResult struct {
+	Line        int    `json:"line"`
+	ID          string `json:"id,omitempty"`
+	Status      string `json:"status"`
+	DuplicateOf string `json:"duplicate_of,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// bulkLine is a parsed line of a bulk request waiting for its batch to be indexed.
+type bulkLine struct {
+	number int
+	cmd    IndexDocumentCommand
+	err    error
+}
+
+// BulkIndexDocumentsHandler handles the POST /api/v1/documents:bulk endpoint. The body holds one
+// IndexDocumentRequest per line. Lines are read as they arrive and indexed in batches, and a
+// BulkIndexResult is streamed back for every non-empty line in order, so failing lines do not
+// abort the others.
+func (h *HTTPHandlers) BulkIndexDocumentsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	encoder := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+
+	var pending []bulkLine
+	size := 0
+	flush := func() {
+		var cmds []IndexDocumentCommand
+		for _, line := range pending {
+			if line.err == nil {
+				cmds = append(cmds, line.cmd)
+			}
+		}
+		results, errs := h.bulkIndexHandler.Handle(r.Context(), cmds)
+
+		for _, line := range pending {
+			var result IndexDocumentResult
+			err := line.err
+			if err == nil {
+				result, err = results[0], errs[0]
+				results, errs = results[1:], errs[1:]
+			}
+			encoder.Encode(bulkIndexResult(line.number, line.cmd.ID, result, err))
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		pending, size = pending[:0], 0
+	}
+
+	scanner := bufio.NewScanner(r.Body)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxBulkLineSize)
+	number := 0
+	for scanner.Scan() {
+		number++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		line := bulkLine{number: number}
+		var req IndexDocumentRequest
+		if err := json.Unmarshal(text, &req); err != nil {
+			line.err = fmt.Errorf("invalid JSON: %w", err)
+		} else if line.cmd, line.err = req.command(); line.err == nil {
+			if line.cmd.ID == "" {
+				line.cmd.ID = uuid.New().String()
+			}
+		}
+		pending = append(pending, line)
+
+		size += len(text)
+		if len(pending) >= BulkBatchSize || size >= maxBulkBatchBytes {
+			flush()
+		}
+	}
+	if len(pending) > 0 {
+		flush()
+	}
+	if err := scanner.Err(); err != nil {
+		encoder.Encode(bulkIndexResult(number+1, "", IndexDocumentResult{}, fmt.Errorf("failed to read request: %w", err)))
+	}
+}
+
+func bulkIndexResult(line int, id string, result IndexDocumentResult, err error) BulkIndexResult {
+	switch {
+	case err == nil && result.Skipped:
+		return BulkIndexResult{Line: line, ID: id, Status: "skipped", DuplicateOf: result.DuplicateOf}
+	case err == nil:
+		return BulkIndexResult{Line: line, ID: id, Status: "ok", DuplicateOf: result.DuplicateOf}
+	case errors.Is(err, ErrEmptyDocument):
+		return BulkIndexResult{Line: line, ID: id, Status: "error", Error: "document has no content"}
+	default:
+		return BulkIndexResult{Line: line, ID: id, Status: "error", Error: err.Error()}
+	}
+}
+
+// PatchDocumentRequest is the request body for partially updating a document. Omitted fields
+// keep their stored value.
+type PatchDocumentRequest struct {
+	Content    *string           `json:"content"`
+	Title      *string           `json:"title"`
+	Summary    *string           `json:"summary"`
+	SourceURI  *string           `json:"source_uri"`
+	MIMEType   *string           `json:"mime_type"`
+	Tags       []string          `json:"tags"`
+	Attributes map[string]string `json:"attributes"`
+}
+
+// GetDocumentHandler handles the GET /api/v1/documents/{id} endpoint.
+func (h *HTTPHandlers) GetDocumentHandler(w http.ResponseWriter, r *http.Request) {
+	result, err := h.getDocumentHandler.Handle(r.Context(), GetDocumentQuery{ID: mux.Vars(r)["id"]})
+	if err != nil {
+		writeDocumentError(w, err, "Failed to get document")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// ListDocumentsHandler handles the GET /api/v1/documents endpoint. Documents are filtered like
+// search results and paged with the "cursor" and "limit" parameters.
+func (h *HTTPHandlers) ListDocumentsHandler(w http.ResponseWriter, r *http.Request) {
+	filter, err := searchFilterFromQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	query := ListDocumentsQuery{Filter: filter, Cursor: r.URL.Query().Get("cursor")}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if query.Limit, err = strconv.Atoi(v); err != nil {
+			http.Error(w, fmt.Sprintf("invalid limit %q", v), http.StatusBadRequest)
 			return
 		}
-		cmd.Content = string(content)
-		cmd.ID = r.FormValue("id")
-	} else {
-		http.Error(w, "Unsupported content type", http.StatusUnsupportedMediaType)
+	}
+
+	result, err := h.listDocumentsHandler.Handle(r.Context(), query)
+	if err != nil {
+		writeDocumentError(w, err, "Failed to list documents")
 		return
 	}
 
-	if cmd.ID == "" {
-		cmd.ID = uuid.New().String()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// ReplaceDocumentHandler handles the PUT /api/v1/documents/{id} endpoint. The content and all
+// metadata are replaced; unchanged chunks keep their embedding.
+func (h *HTTPHandlers) ReplaceDocumentHandler(w http.ResponseWriter, r *http.Request) {
+	var req IndexDocumentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
 	}
 
-	if err := h.indexDocumentHandler.Handle(r.Context(), cmd); err != nil {
-		http.Error(w, "Failed to index document", http.StatusInternalServerError)
+	cmd := UpdateDocumentCommand{
+		ID:      mux.Vars(r)["id"],
+		Content: &req.Content,
+		Metadata: MetadataPatch{
+			Title:      &req.Title,
+			Summary:    &req.Summary,
+			SourceURI:  &req.SourceURI,
+			MIMEType:   &req.MIMEType,
+			Tags:       req.Tags,
+			Attributes: req.Attributes,
+		},
+	}
+	if cmd.Metadata.Tags == nil {
+		cmd.Metadata.Tags = []string{}
+	}
+	if cmd.Metadata.Attributes == nil {
+		cmd.Metadata.Attributes = map[string]string{}
+	}
+
+	h.updateDocument(w, r, cmd)
+}
+
+// PatchDocumentHandler handles the PATCH /api/v1/documents/{id} endpoint. Only the fields present
+// in the body are changed, and the document is embedded again only if its content changes.
+func (h *HTTPHandlers) PatchDocumentHandler(w http.ResponseWriter, r *http.Request) {
+	var req PatchDocumentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	w.WriteHeader(http.StatusAccepted)
+	h.updateDocument(w, r, UpdateDocumentCommand{
+		ID:      mux.Vars(r)["id"],
+		Content: req.Content,
+		Metadata: MetadataPatch{
+			Title:      req.Title,
+			Summary:    req.Summary,
+			SourceURI:  req.SourceURI,
+			MIMEType:   req.MIMEType,
+			Tags:       req.Tags,
+			Attributes: req.Attributes,
+		},
+	})
+}
+
+func (h *HTTPHandlers) updateDocument(w http.ResponseWriter, r *http.Request, cmd UpdateDocumentCommand) {
+	if err := h.updateDocumentHandler.Handle(r.Context(), cmd); err != nil {
+		writeDocumentError(w, err, "Failed to update document")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteDocumentHandler handles the DELETE /api/v1/documents/{id} endpoint, deleting all chunks
+// of the document.
+func (h *HTTPHandlers) DeleteDocumentHandler(w http.ResponseWriter, r *http.Request) {
+	if err := h.deleteDocumentHandler.Handle(r.Context(), DeleteDocumentCommand{ID: mux.Vars(r)["id"]}); err != nil {
+		writeDocumentError(w, err, "Failed to delete document")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeDocumentError maps an error of the document handlers to a status code, replacing
+// unexpected errors with the message.
+func writeDocumentError(w http.ResponseWriter, err error, message string) {
+	var filterErr *FilterError
+	switch {
+	case errors.Is(err, domain.ErrDocumentNotFound):
+		http.Error(w, "Document not found", http.StatusNotFound)
+	case errors.Is(err, ErrEmptyDocument):
+		http.Error(w, "Document has no content", http.StatusBadRequest)
+	case errors.As(err, &filterErr):
+		http.Error(w, filterErr.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrInvalidListQuery), errors.Is(err, ErrInvalidCursor):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, message, http.StatusInternalServerError)
+	}
 }
 
-// SearchDocumentsHandler handles the GET /api/v1/search endpoint.
+// SearchDocumentsHandler handles the GET /api/v1/search endpoint. Clients accepting
+// text/event-stream receive the result as server-sent events while the summary is generated.
 func (h *HTTPHandlers) SearchDocumentsHandler(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query().Get("q")
 	if query == "" {
@@ -81,13 +512,262 @@ func (h *HTTPHandlers) SearchDocumentsHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	searchQuery := SearchDocumentsQuery{Query: query}
+	filter, err := searchFilterFromQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	searchQuery := SearchDocumentsQuery{Query: query, Filter: filter}
+	if err := parseSearchParams(r.URL.Query(), &searchQuery); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if acceptsEventStream(r) {
+		h.streamSearch(w, r, searchQuery)
+		return
+	}
+
 	result, err := h.searchDocumentsHandler.Handle(r.Context(), searchQuery)
 	if err != nil {
-		http.Error(w, "Failed to search documents", http.StatusInternalServerError)
+		writeSearchError(w, err, "Failed to search documents")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// writeSearchError writes 400 for an invalid query and 500 with the message for any other error.
+func writeSearchError(w http.ResponseWriter, err error, message string) {
+	var filterErr *FilterError
+	switch {
+	case errors.As(err, &filterErr):
+		http.Error(w, filterErr.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrInvalidSearchQuery), errors.Is(err, ErrEmptyQuestion):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, message, http.StatusInternalServerError)
+	}
+}
+
+// AskRequest is the request body for asking a question.
+type AskRequest struct {
+	Question string `json:"question"`
+}
+
+// AskHandler handles the POST /api/v1/ask endpoint. The question is answered from the chunks
+// retrieved for it, which are selected by the query parameters of GET /api/v1/search. Clients
+// accepting text/event-stream receive the result as server-sent events.
+func (h *HTTPHandlers) AskHandler(w http.ResponseWriter, r *http.Request) {
+	var req AskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	filter, err := searchFilterFromQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	query := AskQuery{Question: req.Question, Search: SearchDocumentsQuery{Filter: filter}}
+	if err := parseSearchParams(r.URL.Query(), &query.Search); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if acceptsEventStream(r) {
+		h.streamAnswer(w, r, query)
+		return
+	}
+
+	result, err := h.askHandler.Handle(r.Context(), query)
+	if err != nil {
+		writeSearchError(w, err, "Failed to answer question")
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(result)
 }
+
+// CreateConversationHandler handles the POST /api/v1/conversations endpoint.
+func (h *HTTPHandlers) CreateConversationHandler(w http.ResponseWriter, r *http.Request) {
+	conversation, err := h.conversationHandler.Create(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to create conversation", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/v1/conversations/"+conversation.ID)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(conversation)
+}
+
+// GetConversationHandler handles the GET /api/v1/conversations/{id} endpoint.
+func (h *HTTPHandlers) GetConversationHandler(w http.ResponseWriter, r *http.Request) {
+	conversation, err := h.conversationHandler.Get(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		if errors.Is(err, ErrConversationNotFound) {
+			http.Error(w, "Conversation not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to get conversation", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(conversation)
+}
+
+// SendMessageRequest is the request body for sending a message to a conversation.
+type SendMessageRequest struct {
+	Content string `json:"content"`
+}
+
+// SendMessageHandler handles the POST /api/v1/conversations/{id}/messages endpoint. The question
+// is answered like by POST /api/v1/ask after it is rewritten using the history, and the answer is
+// returned as the assistant message.
+func (h *HTTPHandlers) SendMessageHandler(w http.ResponseWriter, r *http.Request) {
+	var req SendMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	filter, err := searchFilterFromQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	cmd := SendMessageCommand{
+		ConversationID: mux.Vars(r)["id"],
+		Content:        req.Content,
+		Search:         SearchDocumentsQuery{Filter: filter},
+	}
+	if err := parseSearchParams(r.URL.Query(), &cmd.Search); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	message, err := h.conversationHandler.Send(r.Context(), cmd)
+	if err != nil {
+		if errors.Is(err, ErrConversationNotFound) {
+			http.Error(w, "Conversation not found", http.StatusNotFound)
+			return
+		}
+		writeSearchError(w, err, "Failed to answer message")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(message)
+}
+
+// parseSearchParams reads the "limit", "offset", "page", "min_score", "mode", "alpha", "mmr" and
+// "lambda" parameters into the query. A 1-based page is converted into an offset and cannot be
+// combined with one.
+func parseSearchParams(values url.Values, query *SearchDocumentsQuery) error {
+	var err error
+	if v := values.Get("limit"); v != "" {
+		if query.Limit, err = strconv.Atoi(v); err != nil {
+			return fmt.Errorf("invalid limit %q", v)
+		}
+	}
+	if v := values.Get("offset"); v != "" {
+		if query.Offset, err = strconv.Atoi(v); err != nil {
+			return fmt.Errorf("invalid offset %q", v)
+		}
+	}
+	if v := values.Get("page"); v != "" {
+		if values.Has("offset") {
+			return fmt.Errorf("page and offset cannot be combined")
+		}
+		page, err := strconv.Atoi(v)
+		if err != nil || page < 1 {
+			return fmt.Errorf("invalid page %q", v)
+		}
+		limit := query.Limit
+		if limit == 0 {
+			limit = DefaultSearchLimit
+		}
+		query.Offset = (page - 1) * limit
+	}
+	if v := values.Get("min_score"); v != "" {
+		minScore, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("invalid min_score %q", v)
+		}
+		query.MinScore = &minScore
+	}
+	switch mode := values.Get("mode"); mode {
+	case "", "vector":
+	case "hybrid":
+		query.Hybrid = true
+	default:
+		return fmt.Errorf("invalid mode %q", mode)
+	}
+	if v := values.Get("alpha"); v != "" {
+		alpha, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("invalid alpha %q", v)
+		}
+		query.Alpha = &alpha
+	}
+	if v := values.Get("mmr"); v != "" {
+		if query.MMR, err = strconv.ParseBool(v); err != nil {
+			return fmt.Errorf("invalid mmr %q", v)
+		}
+	}
+	if v := values.Get("lambda"); v != "" {
+		lambda, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("invalid lambda %q", v)
+		}
+		query.MMRLambda = &lambda
+	}
+	return nil
+}
+
+// searchFilterFromQuery builds the search filter from the "filter" expression parameter and from
+// shorthand parameters named after filterable fields, e.g. "tags=go&mime_type=text/plain".
+// All conditions are combined with AND.
+func searchFilterFromQuery(values url.Values) (Filter, error) {
+	var filters []Filter
+
+	expr, err := ParseFilter(values.Get("filter"))
+	if err != nil {
+		return nil, err
+	}
+	if expr != nil {
+		filters = append(filters, expr)
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		if _, ok := FilterFieldKind(key); ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		f, err := FieldFilter(key, values[key]...)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, f)
+	}
+
+	switch len(filters) {
+	case 0:
+		return nil, nil
+	case 1:
+		return filters[0], nil
+	default:
+		return AndFilter{Filters: filters}, nil
+	}
+}
@@ -0,0 +1,481 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/igorrius/go-vector-search/internal/domain"
+)
+
+func TestHTTPHandlers_SearchDocumentsHandler_InvalidFilter(t *testing.T) {
+	embedder := new(MockEmbeddingGenerator)
+	store := new(MockVectorStore)
+	summarizer := new(MockSummarizer)
+	handlers := NewHTTPHandlers(nil, NewSearchDocumentsHandler(embedder, store, summarizer, nil), nil, nil, nil, nil, nil, nil, nil, nil, UploadConfig{})
+
+	for _, target := range []string{
+		"/api/v1/search?q=test&filter=tags:",
+		"/api/v1/search?q=test&chunk_index=abc",
+		"/api/v1/search?q=test&limit=ten",
+		"/api/v1/search?q=test&page=0",
+		"/api/v1/search?q=test&page=2&offset=10",
+		"/api/v1/search?q=test&min_score=high",
+		"/api/v1/search?q=test&mode=keyword",
+		"/api/v1/search?q=test&mode=hybrid&alpha=lots",
+		"/api/v1/search?q=test&mmr=maybe",
+		"/api/v1/search?q=test&mmr=true&lambda=x",
+	} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		rec := httptest.NewRecorder()
+
+		handlers.SearchDocumentsHandler(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code, target)
+	}
+	embedder.AssertNotCalled(t, "Generate", mock.Anything, mock.Anything)
+}
+
+func TestHTTPHandlers_DocumentHandlers(t *testing.T) {
+	store := new(MockDocumentStore)
+	store.On("List", mock.Anything, mock.Anything).Return(&DocumentPage{}, nil)
+	store.On("FindByID", mock.Anything, "missing").Return((*domain.Document)(nil), domain.ErrDocumentNotFound)
+	handlers := NewHTTPHandlers(nil, nil,
+		NewGetDocumentHandler(store),
+		NewListDocumentsHandler(store),
+		NewUpdateDocumentHandler(store, new(MockEmbeddingGenerator), NewFixedSizeChunker(100, 0)),
+		NewDeleteDocumentHandler(store),
+		nil,
+		nil,
+		nil,
+		nil,
+		UploadConfig{},
+	)
+
+	tests := []struct {
+		name    string
+		method  string
+		target  string
+		body    string
+		handler http.HandlerFunc
+		status  int
+	}{
+		{"get missing", http.MethodGet, "/api/v1/documents/missing", "", handlers.GetDocumentHandler, http.StatusNotFound},
+		{"patch missing", http.MethodPatch, "/api/v1/documents/missing", `{"title":"x"}`, handlers.PatchDocumentHandler, http.StatusNotFound},
+		{"put invalid body", http.MethodPut, "/api/v1/documents/missing", `{`, handlers.ReplaceDocumentHandler, http.StatusBadRequest},
+		{"delete missing", http.MethodDelete, "/api/v1/documents/missing", "", handlers.DeleteDocumentHandler, http.StatusNotFound},
+		{"list", http.MethodGet, "/api/v1/documents?tags=go&limit=5", "", handlers.ListDocumentsHandler, http.StatusOK},
+		{"list invalid limit", http.MethodGet, "/api/v1/documents?limit=1000", "", handlers.ListDocumentsHandler, http.StatusBadRequest},
+		{"list invalid filter", http.MethodGet, "/api/v1/documents?filter=tags:", "", handlers.ListDocumentsHandler, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			req = mux.SetURLVars(req, map[string]string{"id": "missing"})
+			rec := httptest.NewRecorder()
+
+			tt.handler(rec, req)
+
+			assert.Equal(t, tt.status, rec.Code)
+		})
+	}
+}
+
+func TestHTTPHandlers_BulkIndexDocumentsHandler(t *testing.T) {
+	store := new(MockDocumentStore)
+	store.On("FindByID", mock.Anything, mock.Anything).Return((*domain.Document)(nil), domain.ErrDocumentNotFound)
+	store.On("Save", mock.Anything, mock.Anything).Return(nil)
+	embedder := new(MockEmbeddingGenerator)
+	embedder.On("Generate", mock.Anything, mock.Anything).Return([]float32{1, 0, 0}, nil)
+	handlers := NewHTTPHandlers(nil, nil, nil, nil, nil, nil, NewBulkIndexDocumentsHandler(store, embedder, NewFixedSizeChunker(100, 0), nil), nil, nil, nil, UploadConfig{})
+
+	body := strings.Join([]string{
+		`{"id": "a", "content": "alpha", "tags": ["go"]}`,
+		``,
+		`{"id": "b", "content": `,
+		`{"id": "c", "content": ""}`,
+		`{"content": "delta"}`,
+	}, "\n")
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/documents:bulk", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	handlers.BulkIndexDocumentsHandler(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var results []BulkIndexResult
+	decoder := json.NewDecoder(rec.Body)
+	for decoder.More() {
+		var result BulkIndexResult
+		assert.NoError(t, decoder.Decode(&result))
+		results = append(results, result)
+	}
+	if assert.Len(t, results, 4) {
+		assert.Equal(t, BulkIndexResult{Line: 1, ID: "a", Status: "ok"}, results[0])
+		assert.Equal(t, 3, results[1].Line)
+		assert.Equal(t, "error", results[1].Status)
+		assert.Contains(t, results[1].Error, "invalid JSON")
+		assert.Equal(t, BulkIndexResult{Line: 4, ID: "c", Status: "error", Error: "document has no content"}, results[2])
+		assert.Equal(t, "ok", results[3].Status)
+		assert.NotEmpty(t, results[3].ID)
+	}
+}
+
+type MockJobStore struct {
+	mock.Mock
+}
+
+func (m *MockJobStore) Save(ctx context.Context, job *Job) error {
+	args := m.Called(ctx, job)
+	return args.Error(0)
+}
+
+func (m *MockJobStore) FindByID(ctx context.Context, id string) (*Job, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*Job), args.Error(1)
+}
+
+func (m *MockJobStore) Unfinished(ctx context.Context) ([]*Job, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]*Job), args.Error(1)
+}
+
+func (m *MockJobStore) DeleteFinished(ctx context.Context, before time.Time) (int, error) {
+	args := m.Called(ctx, before)
+	return args.Int(0), args.Error(1)
+}
+
+func TestHTTPHandlers_JobHandlers(t *testing.T) {
+	store := new(MockJobStore)
+	store.On("Save", mock.Anything, mock.MatchedBy(func(job *Job) bool {
+		return job.Status == JobPending && job.Command.ID == "doc1"
+	})).Return(nil)
+	store.On("FindByID", mock.Anything, "missing").Return((*Job)(nil), ErrJobNotFound)
+	handlers := NewHTTPHandlers(NewJobQueue(store, nil, JobQueueConfig{}), nil, nil, nil, nil, nil, nil, nil, nil, nil, UploadConfig{})
+
+	t.Run("should accept a document as a pending job", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/documents", strings.NewReader(`{"id": "doc1", "content": "hello"}`))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+
+		handlers.IndexDocumentHandler(rec, req)
+
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+		var result JobResult
+		assert.NoError(t, json.NewDecoder(rec.Body).Decode(&result))
+		assert.Equal(t, "doc1", result.DocumentID)
+		assert.Equal(t, JobPending, result.Status)
+		assert.Equal(t, "/api/v1/jobs/"+result.ID, rec.Header().Get("Location"))
+	})
+
+	t.Run("should reject an unknown duplicate policy", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/documents", strings.NewReader(`{"id": "doc1", "content": "hello", "on_duplicate": "drop"}`))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+
+		handlers.IndexDocumentHandler(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("should return 404 for an unknown job", func(t *testing.T) {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/v1/jobs/missing", nil), map[string]string{"id": "missing"})
+		rec := httptest.NewRecorder()
+
+		handlers.GetJobHandler(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+// stubExtractor is a TextExtractor rejecting PNG images and returning the file name as the text
+// of other files.
+type stubExtractor struct{}
+
+func (stubExtractor) Extract(_ context.Context, _ []byte, filename, _ string) (*ExtractedText, error) {
+	if strings.HasSuffix(filename, ".png") {
+		return nil, fmt.Errorf("%w: image/png", ErrUnsupportedMediaType)
+	}
+	return &ExtractedText{Content: "text of " + filename, MIMEType: "application/pdf", Title: "Title of " + filename}, nil
+}
+
+// multipartRequest builds an upload of files given as field name and file name pairs, followed by
+// form fields.
+func multipartRequest(files [][2]string, fields map[string]string) *http.Request {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for _, file := range files {
+		part, _ := form.CreateFormFile(file[0], file[1])
+		part.Write([]byte("%PDF-1.7"))
+	}
+	for name, value := range fields {
+		form.WriteField(name, value)
+	}
+	form.Close()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/documents", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	return req
+}
+
+func TestHTTPHandlers_IndexDocumentHandler_Upload(t *testing.T) {
+	newHandlers := func(uploads UploadConfig) (*HTTPHandlers, *MockJobStore) {
+		store := new(MockJobStore)
+		store.On("Save", mock.Anything, mock.Anything).Return(nil)
+		return NewHTTPHandlers(NewJobQueue(store, nil, JobQueueConfig{}), nil, nil, nil, nil, nil, nil, nil, nil, stubExtractor{}, uploads), store
+	}
+
+	t.Run("should index the extracted text with the detected type", func(t *testing.T) {
+		handlers, store := newHandlers(UploadConfig{})
+		rec := httptest.NewRecorder()
+
+		handlers.IndexDocumentHandler(rec, multipartRequest([][2]string{{"file", "report.pdf"}}, map[string]string{"id": "doc1"}))
+
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+		var result UploadResult
+		assert.NoError(t, json.NewDecoder(rec.Body).Decode(&result))
+		assert.Len(t, result.Files, 1)
+		assert.Equal(t, "report.pdf", result.Files[0].Filename)
+		assert.Equal(t, "doc1", result.Files[0].DocumentID)
+		assert.Equal(t, "/api/v1/jobs/"+result.Files[0].ID, rec.Header().Get("Location"))
+		store.AssertCalled(t, "Save", mock.Anything, mock.MatchedBy(func(job *Job) bool {
+			return job.Command.Content == "text of report.pdf" &&
+				job.Command.Metadata.MIMEType == "application/pdf" &&
+				job.Command.Metadata.Title == "Title of report.pdf"
+		}))
+	})
+
+	t.Run("should index every file with its own fields", func(t *testing.T) {
+		handlers, store := newHandlers(UploadConfig{})
+		rec := httptest.NewRecorder()
+
+		handlers.IndexDocumentHandler(rec, multipartRequest(
+			[][2]string{{"a", "a.pdf"}, {"b", "b.pdf"}, {"c", "c.pdf"}},
+			map[string]string{"a.id": "doc-a", "b.id": "doc-b", "b.title": "B", "tags": "shared"},
+		))
+
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+		var result UploadResult
+		assert.NoError(t, json.NewDecoder(rec.Body).Decode(&result))
+		assert.Len(t, result.Files, 3)
+		assert.Equal(t, "doc-a", result.Files[0].DocumentID)
+		assert.Equal(t, "doc-b", result.Files[1].DocumentID)
+		assert.NotEmpty(t, result.Files[2].DocumentID)
+		assert.Empty(t, rec.Header().Get("Location"))
+		store.AssertCalled(t, "Save", mock.Anything, mock.MatchedBy(func(job *Job) bool {
+			return job.Command.ID == "doc-b" && job.Command.Metadata.Title == "B" &&
+				assert.ObjectsAreEqual([]string{"shared"}, job.Command.Metadata.Tags)
+		}))
+	})
+
+	t.Run("should reject an ID shared by several files", func(t *testing.T) {
+		handlers, store := newHandlers(UploadConfig{})
+		rec := httptest.NewRecorder()
+
+		handlers.IndexDocumentHandler(rec, multipartRequest([][2]string{{"file", "a.pdf"}, {"file", "b.pdf"}}, map[string]string{"id": "doc1"}))
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		store.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+	})
+
+	t.Run("should return 415 for an unsupported file type", func(t *testing.T) {
+		handlers, store := newHandlers(UploadConfig{})
+		rec := httptest.NewRecorder()
+
+		handlers.IndexDocumentHandler(rec, multipartRequest([][2]string{{"a", "a.pdf"}, {"b", "image.png"}}, nil))
+
+		assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+		store.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+	})
+
+	t.Run("should cancel the saved jobs when a later one cannot be saved", func(t *testing.T) {
+		store := new(MockJobStore)
+		store.On("Save", mock.Anything, mock.MatchedBy(func(job *Job) bool { return job.Command.ID == "doc-b" })).Return(errors.New("disk full"))
+		store.On("Save", mock.Anything, mock.Anything).Return(nil)
+		queue := NewJobQueue(store, nil, JobQueueConfig{})
+		handlers := NewHTTPHandlers(queue, nil, nil, nil, nil, nil, nil, nil, nil, stubExtractor{}, UploadConfig{})
+		rec := httptest.NewRecorder()
+
+		handlers.IndexDocumentHandler(rec, multipartRequest(
+			[][2]string{{"a", "a.pdf"}, {"b", "b.pdf"}},
+			map[string]string{"a.id": "doc-a", "b.id": "doc-b"},
+		))
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		store.AssertCalled(t, "Save", mock.Anything, mock.MatchedBy(func(job *Job) bool {
+			return job.Command.ID == "doc-a" && job.Status == JobFailed
+		}))
+		assert.Empty(t, queue.queue)
+	})
+
+	t.Run("should delete the spooled files", func(t *testing.T) {
+		tmp := t.TempDir()
+		t.Setenv("TMPDIR", tmp)
+		handlers, _ := newHandlers(UploadConfig{})
+
+		accepted := httptest.NewRecorder()
+		handlers.IndexDocumentHandler(accepted, multipartRequest([][2]string{{"a", "a.pdf"}, {"b", "b.pdf"}}, nil))
+		rejected := httptest.NewRecorder()
+		handlers.IndexDocumentHandler(rejected, multipartRequest([][2]string{{"a", "a.pdf"}, {"b", "image.png"}}, nil))
+
+		assert.Equal(t, http.StatusAccepted, accepted.Code)
+		assert.Equal(t, http.StatusUnsupportedMediaType, rejected.Code)
+		entries, err := os.ReadDir(tmp)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("should return 413 for a body over the limit", func(t *testing.T) {
+		handlers, _ := newHandlers(UploadConfig{MaxBodySize: 64})
+		rec := httptest.NewRecorder()
+
+		handlers.IndexDocumentHandler(rec, multipartRequest([][2]string{{"file", "report.pdf"}}, map[string]string{"title": strings.Repeat("x", 100)}))
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	})
+}
+
+func TestHTTPHandlers_AskHandler(t *testing.T) {
+	handlers := NewHTTPHandlers(nil, nil, nil, nil, nil, nil, nil, NewAskHandler(nil, nil), nil, nil, UploadConfig{})
+
+	tests := []struct {
+		name  string
+		url   string
+		body  string
+		wants int
+	}{
+		{name: "should reject an invalid body", url: "/api/v1/ask", body: "{", wants: http.StatusBadRequest},
+		{name: "should reject a blank question", url: "/api/v1/ask", body: `{"question": " "}`, wants: http.StatusBadRequest},
+		{name: "should reject an invalid filter", url: "/api/v1/ask?filter=tags:", body: `{"question": "why?"}`, wants: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.url, strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+
+			handlers.AskHandler(rec, req)
+
+			assert.Equal(t, tt.wants, rec.Code)
+		})
+	}
+}
+
+func TestHTTPHandlers_Conversations(t *testing.T) {
+	conversations := NewConversationHandler(&fakeConversationStore{}, NewAskHandler(nil, nil), nil, ConversationConfig{})
+	handlers := NewHTTPHandlers(nil, nil, nil, nil, nil, nil, nil, nil, conversations, nil, UploadConfig{})
+
+	t.Run("should create a conversation with its location", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+
+		handlers.CreateConversationHandler(rec, httptest.NewRequest(http.MethodPost, "/api/v1/conversations", nil))
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		var conversation Conversation
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&conversation))
+		assert.Equal(t, "/api/v1/conversations/"+conversation.ID, rec.Header().Get("Location"))
+		assert.Empty(t, conversation.Messages)
+	})
+
+	t.Run("should answer an unknown conversation with not found", func(t *testing.T) {
+		get := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/v1/conversations/missing", nil), map[string]string{"id": "missing"})
+		send := mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/api/v1/conversations/missing/messages", strings.NewReader(`{"content": "why?"}`)), map[string]string{"id": "missing"})
+		getRec, sendRec := httptest.NewRecorder(), httptest.NewRecorder()
+
+		handlers.GetConversationHandler(getRec, get)
+		handlers.SendMessageHandler(sendRec, send)
+
+		assert.Equal(t, http.StatusNotFound, getRec.Code)
+		assert.Equal(t, http.StatusNotFound, sendRec.Code)
+	})
+
+	t.Run("should reject a blank message", func(t *testing.T) {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/api/v1/conversations/c/messages", strings.NewReader(`{"content": " "}`)), map[string]string{"id": "c"})
+		rec := httptest.NewRecorder()
+
+		handlers.SendMessageHandler(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestHTTPHandlers_SearchDocumentsHandler_Stream(t *testing.T) {
+	embedder := new(MockEmbeddingGenerator)
+	embedder.On("Generate", mock.Anything, "go").Return([]float32{1, 2, 3}, nil)
+	store := new(MockVectorStore)
+	store.On("Search", mock.Anything, mock.Anything).Return([]SearchHit{{Document: domain.Document{ID: "doc1", Content: "first"}}}, nil)
+	summarizer := new(MockStreamingSummarizer)
+	summarizer.On("SummarizeStream", mock.Anything, []string{"first"}).Return([]string{"A ", "summary."}, nil)
+	handlers := NewHTTPHandlers(nil, NewSearchDocumentsHandler(embedder, store, summarizer, nil), nil, nil, nil, nil, nil, nil, nil, nil, UploadConfig{})
+
+	t.Run("should send the sources, the summary deltas and done as events", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/search?q=go", nil)
+		req.Header.Set("Accept", "text/event-stream")
+		rec := httptest.NewRecorder()
+
+		handlers.SearchDocumentsHandler(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "text/event-stream", rec.Header().Get("Content-Type"))
+		var events []string
+		for _, block := range strings.Split(strings.TrimSpace(rec.Body.String()), "\n\n") {
+			events = append(events, strings.SplitN(block, "\n", 2)[0])
+		}
+		assert.Equal(t, []string{"event: sources", "event: delta", "event: delta", "event: done"}, events)
+		assert.Contains(t, rec.Body.String(), `data: {"Text":"summary."}`)
+		assert.Contains(t, rec.Body.String(), `data: {"Summary":"A summary."}`)
+	})
+
+	t.Run("should answer an invalid query with a status code", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/search?q=go&limit=1000", nil)
+		req.Header.Set("Accept", "text/event-stream")
+		rec := httptest.NewRecorder()
+
+		handlers.SearchDocumentsHandler(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestSearchFilterFromQuery(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/search?q=test&filter=tags:go&mime_type=text/plain&mime_type=text/html", nil)
+
+	filter, err := searchFilterFromQuery(req.URL.Query())
+
+	assert.NoError(t, err)
+	assert.Equal(t, AndFilter{Filters: []Filter{
+		TagFilter{Tags: []string{"go"}},
+		InFilter{Field: "mime_type", Values: []string{"text/plain", "text/html"}},
+	}}, filter)
+}
+
+func TestParseSearchParams(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/search?q=test&limit=20&page=3&min_score=0.5&mode=hybrid&alpha=0.2&mmr=true&lambda=0.7", nil)
+	query := SearchDocumentsQuery{Query: "test"}
+
+	err := parseSearchParams(req.URL.Query(), &query)
+
+	minScore, alpha, lambda := 0.5, 0.2, 0.7
+	assert.NoError(t, err)
+	assert.Equal(t, SearchDocumentsQuery{
+		Query:     "test",
+		Limit:     20,
+		Offset:    40,
+		MinScore:  &minScore,
+		Hybrid:    true,
+		Alpha:     &alpha,
+		MMR:       true,
+		MMRLambda: &lambda,
+	}, query)
+}
@@ -2,6 +2,7 @@ package app
 
 import (
 	"context"
+	"errors"
 
 	"github.com/igorrius/go-vector-search/internal/domain"
 )
@@ -9,14 +10,228 @@ import (
 // EmbeddingGenerator generates a vector embedding for a given content.
 type EmbeddingGenerator interface {
 	Generate(ctx context.Context, content string) ([]float32, error)
+	// ModelName returns the name of the embedding model.
+	ModelName() string
+	// Dimension returns the length of the vectors produced by the model.
+	Dimension() int
+}
+
+// BatchEmbeddingGenerator is an EmbeddingGenerator that embeds many contents in one request.
+type BatchEmbeddingGenerator interface {
+	EmbeddingGenerator
+	// GenerateBatch returns the embeddings of the contents in order.
+	GenerateBatch(ctx context.Context, contents []string) ([][]float32, error)
+}
+
+// AsBatchEmbeddingGenerator returns the generator itself if it supports batches, or an adapter
+// embedding the contents one at a time otherwise.
+func AsBatchEmbeddingGenerator(g EmbeddingGenerator) BatchEmbeddingGenerator {
+	if batch, ok := g.(BatchEmbeddingGenerator); ok {
+		return batch
+	}
+	return singleEmbeddingGenerator{g}
+}
+
+// singleEmbeddingGenerator adapts a single-item EmbeddingGenerator to BatchEmbeddingGenerator.
+type singleEmbeddingGenerator struct {
+	EmbeddingGenerator
+}
+
+func (g singleEmbeddingGenerator) GenerateBatch(ctx context.Context, contents []string) ([][]float32, error) {
+	embeddings := make([][]float32, len(contents))
+	for i, content := range contents {
+		embedding, err := g.Generate(ctx, content)
+		if err != nil {
+			return nil, err
+		}
+		embeddings[i] = embedding
+	}
+	return embeddings, nil
+}
+
+// DimensionedStore is implemented by stores whose embedding field has a fixed, declared dimension.
+type DimensionedStore interface {
+	EmbeddingDimension() int
+}
+
+// BatchSaver is implemented by repositories that store many documents in one round trip.
+type BatchSaver interface {
+	// SaveBatch saves the documents and returns the error of each one in order, nil for those
+	// saved. The returned error is set when the batch failed as a whole.
+	SaveBatch(ctx context.Context, docs []*domain.Document) ([]error, error)
+}
+
+// ErrInvalidCursor is returned by a DocumentStore for a cursor it did not issue.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ListOptions selects a page of stored documents.
+type ListOptions struct {
+	// Filter restricts the listing to matching documents. Nil matches all documents.
+	Filter Filter
+	// Cursor is the NextCursor of the previous page; empty starts at the first page.
+	Cursor string
+	// Limit is the maximum number of documents to return.
+	Limit int
+}
+
+// DocumentPage is a page of stored documents.
+type DocumentPage struct {
+	Documents []domain.Document
+	// NextCursor continues the listing after this page; empty on the last page.
+	NextCursor string
+}
+
+// DocumentStore is a DocumentRepository whose documents can be listed page by page.
+type DocumentStore interface {
+	domain.DocumentRepository
+	// List returns the documents matching the filter in a stable, store-defined order. A filter
+	// the store cannot apply yields a *FilterError.
+	List(ctx context.Context, opts ListOptions) (*DocumentPage, error)
+}
+
+// SearchOptions holds the parameters of a vector search.
+type SearchOptions struct {
+	// Query is the original query text, available to stores that transform the search.
+	Query string
+	// Embedding is the query vector. Nil searches by keyword only for Text, without any vector
+	// matching.
+	Embedding []float32
+	// Filter restricts the search to matching documents. Nil matches all documents.
+	Filter Filter
+	// Limit is the maximum number of hits to return.
+	Limit int
+	// Offset is the number of best hits to skip.
+	Offset int
+	// MinScore drops hits whose similarity score is below it. Nil keeps all hits.
+	MinScore *float64
+	// Text enables hybrid search when set: documents are also matched by keyword against it.
+	Text string
+	// Alpha is the weight of the vector ranking in hybrid search, from 0 (keyword only)
+	// to 1 (vector only). The keyword ranking is weighted 1-Alpha.
+	Alpha float64
+}
+
+// MeetsMinScore reports whether a hit with the similarity score is kept by MinScore.
+func (o SearchOptions) MeetsMinScore(score float64) bool {
+	return o.MinScore == nil || score >= *o.MinScore
+}
+
+// SearchHit is a document returned by a vector search.
+type SearchHit struct {
+	Document domain.Document
+	// Distance is the vector distance between the query and the document embedding.
+	Distance float64
+	// Score is the similarity derived from Distance with DistanceMetric.Score; higher is more similar.
+	Score float64
+	// Similarity is Distance normalized into [0, 1] with DistanceMetric.Similarity, comparable
+	// across metrics and stores.
+	Similarity float64
+	// TextMatch is the keyword relevance score of a hybrid search; zero for pure vector search.
+	TextMatch float64
+	// FusionScore is the fused relevance of a hit merged from several retrievers.
+	FusionScore float64
+	// RerankScore is the relevance assigned by a Reranker; zero when the hits were not reranked.
+	RerankScore float64
 }
 
 // VectorStore defines the interface for a vector store.
 type VectorStore interface {
-	Search(ctx context.Context, embedding []float32) ([]domain.Document, error)
+	// Search returns the hits closest to the embedding, best first. A filter the store cannot
+	// apply yields a *FilterError.
+	Search(ctx context.Context, opts SearchOptions) ([]SearchHit, error)
+}
+
+// ErrUnsupportedMediaType is returned by a TextExtractor for a file type it cannot read.
+var ErrUnsupportedMediaType = errors.New("unsupported media type")
+
+// ExtractedText is the text of an uploaded file.
+type ExtractedText struct {
+	Content string
+	// MIMEType is the detected type of the file.
+	MIMEType string
+	// Title is the title declared by the file; empty if it has none.
+	Title string
+}
+
+// TextExtractor extracts the text of uploaded files. The type is detected from the data, the
+// file name and the declared content type.
+type TextExtractor interface {
+	Extract(ctx context.Context, data []byte, filename, contentType string) (*ExtractedText, error)
 }
 
 // Summarizer defines the interface for a text summarizer.
 type Summarizer interface {
 	Summarize(ctx context.Context, content []string) (string, error)
 }
+
+// StreamingSummarizer is a Summarizer that streams the summary as it is generated.
+type StreamingSummarizer interface {
+	Summarizer
+	// SummarizeStream calls yield with every piece of the summary in order. An error returned by
+	// yield stops the generation and is returned.
+	SummarizeStream(ctx context.Context, content []string, yield func(delta string) error) error
+}
+
+// AsStreamingSummarizer returns the summarizer itself if it streams, or an adapter yielding the
+// whole summary at once otherwise.
+func AsStreamingSummarizer(s Summarizer) StreamingSummarizer {
+	if streaming, ok := s.(StreamingSummarizer); ok {
+		return streaming
+	}
+	return wholeSummarizer{s}
+}
+
+// wholeSummarizer adapts a Summarizer to StreamingSummarizer.
+type wholeSummarizer struct {
+	Summarizer
+}
+
+func (s wholeSummarizer) SummarizeStream(ctx context.Context, content []string, yield func(delta string) error) error {
+	summary, err := s.Summarize(ctx, content)
+	if err != nil {
+		return err
+	}
+	return yield(summary)
+}
+
+// Answerer answers a question from numbered passages. The answer cites the passages supporting
+// it as [n] markers, where n is the 1-based position of the passage.
+type Answerer interface {
+	Answer(ctx context.Context, question string, passages []string) (string, error)
+}
+
+// QueryRewriter rewrites a follow-up question into a standalone search query, resolving its
+// references to the history of the conversation.
+type QueryRewriter interface {
+	Rewrite(ctx context.Context, history []Message, question string) (string, error)
+}
+
+// StreamingAnswerer is an Answerer that streams the answer as it is generated.
+type StreamingAnswerer interface {
+	Answerer
+	// AnswerStream calls yield with every piece of the answer in order. An error returned by
+	// yield stops the generation and is returned.
+	AnswerStream(ctx context.Context, question string, passages []string, yield func(delta string) error) error
+}
+
+// AsStreamingAnswerer returns the answerer itself if it streams, or an adapter yielding the whole
+// answer at once otherwise.
+func AsStreamingAnswerer(a Answerer) StreamingAnswerer {
+	if streaming, ok := a.(StreamingAnswerer); ok {
+		return streaming
+	}
+	return wholeAnswerer{a}
+}
+
+// wholeAnswerer adapts an Answerer to StreamingAnswerer.
+type wholeAnswerer struct {
+	Answerer
+}
+
+func (a wholeAnswerer) AnswerStream(ctx context.Context, question string, passages []string, yield func(delta string) error) error {
+	answer, err := a.Answer(ctx, question, passages)
+	if err != nil {
+		return err
+	}
+	return yield(answer)
+}
@@ -0,0 +1,332 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultJobWorkers        = 4
+	defaultJobMaxAttempts    = 5
+	defaultJobInitialBackoff = time.Second
+	defaultJobMaxBackoff     = time.Minute
+	defaultJobRetention      = 7 * 24 * time.Hour
+	// jobPruneInterval is the longest time between two prunings of finished jobs.
+	jobPruneInterval = time.Hour
+)
+
+// JobStatus is the state of an ingestion job.
+type JobStatus string
+
+const (
+	JobPending   JobStatus = "pending"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+)
+
+// ErrJobNotFound is returned by a JobStore when no job has the requested ID.
+var ErrJobNotFound = errors.New("job not found")
+
+// Job is a queued IndexDocumentCommand with its processing state.
+type Job struct {
+	ID     string
+	Status JobStatus
+	// Command is the queued command. Its Content is dropped once the job has finished.
+	Command IndexDocumentCommand
+	// Attempts is the number of times the job was started.
+	Attempts int
+	// Error is the error of the last failed attempt.
+	Error string
+	// DuplicateOf is the ID of an indexed document the succeeded job found to be a duplicate.
+	DuplicateOf string
+	// Skipped reports that the document was not indexed because it is a duplicate.
+	Skipped   bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// JobStore persists jobs so that they survive a restart.
+type JobStore interface {
+	Save(ctx context.Context, job *Job) error
+	FindByID(ctx context.Context, id string) (*Job, error)
+	// Unfinished returns the jobs that are pending or were running, oldest first.
+	Unfinished(ctx context.Context) ([]*Job, error)
+	// DeleteFinished deletes the succeeded and failed jobs last updated before the given time and
+	// returns their number.
+	DeleteFinished(ctx context.Context, before time.Time) (int, error)
+}
+
+// JobQueueConfig holds the configuration of a JobQueue. Zero values select the defaults.
+type JobQueueConfig struct {
+	// Workers is the number of jobs processed concurrently. Default 4.
+	Workers int
+	// MaxAttempts is the number of times a job is tried before it fails. Default 5.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry, doubled for every further one. Default 1s.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between retries. Default 1m.
+	MaxBackoff time.Duration
+	// Retention is how long succeeded and failed jobs are kept before they are deleted. Default
+	// 7 days; negative keeps them forever.
+	Retention time.Duration
+}
+
+// JobQueue indexes documents in the background. Submitted jobs are persisted before they are
+// queued, and jobs left unfinished by a previous process are resumed by Start. Finished jobs are
+// deleted once they are older than the retention.
+type JobQueue struct {
+	store   JobStore
+	handler *IndexDocumentHandler
+	cfg     JobQueueConfig
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   []string
+	stopped bool
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewJobQueue creates a new JobQueue processing jobs with the handler.
+func NewJobQueue(store JobStore, handler *IndexDocumentHandler, cfg JobQueueConfig) *JobQueue {
+	if cfg.Workers <= 0 {
+		cfg.Workers = defaultJobWorkers
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaultJobMaxAttempts
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = defaultJobInitialBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = defaultJobMaxBackoff
+	}
+	if cfg.Retention == 0 {
+		cfg.Retention = defaultJobRetention
+	}
+
+	q := &JobQueue{
+		store:   store,
+		handler: handler,
+		cfg:     cfg,
+		done:    make(chan struct{}),
+	}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// Start requeues the unfinished jobs of a previous process and starts the workers and the pruning
+// of finished jobs.
+func (q *JobQueue) Start(ctx context.Context) error {
+	jobs, err := q.store.Unfinished(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load unfinished jobs: %w", err)
+	}
+	for _, job := range jobs {
+		if job.Status == JobRunning {
+			job.Status = JobPending
+			job.UpdatedAt = time.Now().UTC()
+			if err := q.store.Save(ctx, job); err != nil {
+				return fmt.Errorf("failed to requeue job %q: %w", job.ID, err)
+			}
+		}
+		q.enqueue(job.ID)
+	}
+
+	for i := 0; i < q.cfg.Workers; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+	if q.cfg.Retention > 0 {
+		q.wg.Add(1)
+		go q.prune()
+	}
+	return nil
+}
+
+// Stop stops the workers after their current jobs. Queued jobs stay pending in the store.
+func (q *JobQueue) Stop() {
+	q.mu.Lock()
+	if !q.stopped {
+		q.stopped = true
+		close(q.done)
+	}
+	q.cond.Broadcast()
+	q.mu.Unlock()
+	q.wg.Wait()
+}
+
+// Submit persists a job for the command and queues it. A command without content is rejected
+// with ErrEmptyDocument.
+func (q *JobQueue) Submit(ctx context.Context, cmd IndexDocumentCommand) (*Job, error) {
+	jobs, err := q.SubmitAll(ctx, []IndexDocumentCommand{cmd})
+	if err != nil {
+		return nil, err
+	}
+	return jobs[0], nil
+}
+
+// SubmitAll persists a job for each command and queues them once all are persisted, so that
+// either all of them are processed or none. A command without content rejects all with
+// ErrEmptyDocument; when a job cannot be persisted, those persisted before it are canceled.
+func (q *JobQueue) SubmitAll(ctx context.Context, cmds []IndexDocumentCommand) ([]*Job, error) {
+	for _, cmd := range cmds {
+		if strings.TrimSpace(cmd.Content) == "" {
+			return nil, ErrEmptyDocument
+		}
+	}
+
+	now := time.Now().UTC()
+	jobs := make([]*Job, 0, len(cmds))
+	for _, cmd := range cmds {
+		job := &Job{
+			ID:        uuid.New().String(),
+			Status:    JobPending,
+			Command:   cmd,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		if err := q.store.Save(ctx, job); err != nil {
+			q.cancel(context.WithoutCancel(ctx), jobs)
+			return nil, fmt.Errorf("failed to save job: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	for _, job := range jobs {
+		q.enqueue(job.ID)
+	}
+	return jobs, nil
+}
+
+// cancel marks the persisted but unqueued jobs as failed, so that they are not resumed by Start.
+func (q *JobQueue) cancel(ctx context.Context, jobs []*Job) {
+	for _, job := range jobs {
+		job.Status = JobFailed
+		job.Error = "canceled: another job of the request could not be saved"
+		job.Command.Content = ""
+		job.UpdatedAt = time.Now().UTC()
+		if err := q.store.Save(ctx, job); err != nil {
+			log.Printf("Job %s: %v", job.ID, err)
+		}
+	}
+}
+
+// Get returns the job with the given ID.
+func (q *JobQueue) Get(ctx context.Context, id string) (*Job, error) {
+	return q.store.FindByID(ctx, id)
+}
+
+func (q *JobQueue) enqueue(id string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.queue = append(q.queue, id)
+	q.cond.Signal()
+}
+
+// next blocks until a job is queued and returns its ID, or false once the queue is stopped.
+func (q *JobQueue) next() (string, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.queue) == 0 && !q.stopped {
+		q.cond.Wait()
+	}
+	if q.stopped {
+		return "", false
+	}
+	id := q.queue[0]
+	q.queue = q.queue[1:]
+	return id, true
+}
+
+func (q *JobQueue) work() {
+	defer q.wg.Done()
+	for {
+		id, ok := q.next()
+		if !ok {
+			return
+		}
+		if err := q.run(context.Background(), id); err != nil {
+			log.Printf("Job %s: %v", id, err)
+		}
+	}
+}
+
+// prune deletes the finished jobs older than the retention right away and then periodically,
+// until the queue is stopped.
+func (q *JobQueue) prune() {
+	defer q.wg.Done()
+	ticker := time.NewTicker(min(q.cfg.Retention, jobPruneInterval))
+	defer ticker.Stop()
+	for {
+		if _, err := q.store.DeleteFinished(context.Background(), time.Now().UTC().Add(-q.cfg.Retention)); err != nil {
+			log.Printf("Job pruning: %v", err)
+		}
+		select {
+		case <-q.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// run makes one attempt at the job. A failed attempt is retried after a backoff unless the error
+// is permanent or the attempts are exhausted. The returned error concerns the job store.
+func (q *JobQueue) run(ctx context.Context, id string) error {
+	job, err := q.store.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if job.Status != JobPending {
+		return nil
+	}
+
+	job.Status = JobRunning
+	job.Attempts++
+	job.UpdatedAt = time.Now().UTC()
+	if err := q.store.Save(ctx, job); err != nil {
+		return err
+	}
+
+	result, err := q.handler.Handle(ctx, job.Command)
+	job.UpdatedAt = time.Now().UTC()
+	switch {
+	case err == nil:
+		job.Status = JobSucceeded
+		job.Error = ""
+		job.Command.Content = ""
+		job.DuplicateOf = result.DuplicateOf
+		job.Skipped = result.Skipped
+	case permanentJobError(err) || job.Attempts >= q.cfg.MaxAttempts:
+		job.Status = JobFailed
+		job.Error = err.Error()
+		job.Command.Content = ""
+	default:
+		job.Status = JobPending
+		job.Error = err.Error()
+		time.AfterFunc(q.backoff(job.Attempts), func() { q.enqueue(job.ID) })
+	}
+	return q.store.Save(ctx, job)
+}
+
+// backoff returns the delay before the retry following the given attempt.
+func (q *JobQueue) backoff(attempt int) time.Duration {
+	delay := q.cfg.InitialBackoff
+	for i := 1; i < attempt && delay < q.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, q.cfg.MaxBackoff)
+}
+
+// permanentJobError reports whether retrying cannot make the job succeed.
+func permanentJobError(err error) bool {
+	var mismatch *DimensionMismatchError
+	return errors.Is(err, ErrEmptyDocument) || errors.As(err, &mismatch)
+}
@@ -0,0 +1,208 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/igorrius/go-vector-search/internal/app"
+	"github.com/igorrius/go-vector-search/internal/domain"
+	"github.com/igorrius/go-vector-search/internal/infra/persistence/memory"
+)
+
+// fakeJobStore is an in-memory JobStore.
+type fakeJobStore struct {
+	mu   sync.Mutex
+	jobs map[string]app.Job
+}
+
+func newFakeJobStore(jobs ...app.Job) *fakeJobStore {
+	s := &fakeJobStore{jobs: make(map[string]app.Job)}
+	for _, job := range jobs {
+		s.jobs[job.ID] = job
+	}
+	return s
+}
+
+func (s *fakeJobStore) Save(_ context.Context, job *app.Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[job.ID] = *job
+	return nil
+}
+
+func (s *fakeJobStore) FindByID(_ context.Context, id string) (*app.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil, app.ErrJobNotFound
+	}
+	return &job, nil
+}
+
+func (s *fakeJobStore) Unfinished(_ context.Context) ([]*app.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var jobs []*app.Job
+	for _, job := range s.jobs {
+		if job.Status == app.JobPending || job.Status == app.JobRunning {
+			jobs = append(jobs, &job)
+		}
+	}
+	return jobs, nil
+}
+
+func (s *fakeJobStore) DeleteFinished(_ context.Context, before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	deleted := 0
+	for id, job := range s.jobs {
+		if (job.Status == app.JobSucceeded || job.Status == app.JobFailed) && job.UpdatedAt.Before(before) {
+			delete(s.jobs, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// waitForJob polls the queue until the job has finished.
+func waitForJob(t *testing.T, q *app.JobQueue, id string) *app.Job {
+	t.Helper()
+	var job *app.Job
+	require.Eventually(t, func() bool {
+		var err error
+		job, err = q.Get(context.Background(), id)
+		require.NoError(t, err)
+		return job.Status == app.JobSucceeded || job.Status == app.JobFailed
+	}, 5*time.Second, time.Millisecond)
+	return job
+}
+
+func TestJobQueue(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should index a submitted document in the background", func(t *testing.T) {
+		// Arrange
+		repo := memory.NewStore(app.Cosine)
+		embedder := new(MockEmbeddingGenerator)
+		embedder.On("Generate", mock.Anything, "hello").Return([]float32{1, 0, 0}, nil)
+		q := app.NewJobQueue(newFakeJobStore(), app.NewIndexDocumentHandler(repo, embedder, app.NewFixedSizeChunker(100, 0), nil), app.JobQueueConfig{Workers: 2})
+		require.NoError(t, q.Start(ctx))
+		defer q.Stop()
+
+		// Act
+		job, err := q.Submit(ctx, app.IndexDocumentCommand{ID: "doc", Content: "hello"})
+		require.NoError(t, err)
+		finished := waitForJob(t, q, job.ID)
+
+		// Assert
+		assert.Equal(t, app.JobSucceeded, finished.Status)
+		assert.Equal(t, 1, finished.Attempts)
+		assert.Empty(t, finished.Command.Content)
+		_, err = repo.FindByID(ctx, domain.ChunkID("doc", 0))
+		assert.NoError(t, err)
+	})
+
+	t.Run("should retry a failed attempt", func(t *testing.T) {
+		// Arrange
+		embedder := new(MockEmbeddingGenerator)
+		embedder.On("Generate", mock.Anything, "hello").Return([]float32(nil), errors.New("unavailable")).Once()
+		embedder.On("Generate", mock.Anything, "hello").Return([]float32{1, 0, 0}, nil)
+		handler := app.NewIndexDocumentHandler(memory.NewStore(app.Cosine), embedder, app.NewFixedSizeChunker(100, 0), nil)
+		q := app.NewJobQueue(newFakeJobStore(), handler, app.JobQueueConfig{InitialBackoff: time.Millisecond})
+		require.NoError(t, q.Start(ctx))
+		defer q.Stop()
+
+		// Act
+		job, err := q.Submit(ctx, app.IndexDocumentCommand{ID: "doc", Content: "hello"})
+		require.NoError(t, err)
+		finished := waitForJob(t, q, job.ID)
+
+		// Assert
+		assert.Equal(t, app.JobSucceeded, finished.Status)
+		assert.Equal(t, 2, finished.Attempts)
+		assert.Empty(t, finished.Error)
+	})
+
+	t.Run("should fail after the last attempt", func(t *testing.T) {
+		// Arrange
+		embedder := new(MockEmbeddingGenerator)
+		embedder.On("Generate", mock.Anything, "hello").Return([]float32(nil), errors.New("unavailable"))
+		handler := app.NewIndexDocumentHandler(memory.NewStore(app.Cosine), embedder, app.NewFixedSizeChunker(100, 0), nil)
+		q := app.NewJobQueue(newFakeJobStore(), handler, app.JobQueueConfig{MaxAttempts: 3, InitialBackoff: time.Millisecond})
+		require.NoError(t, q.Start(ctx))
+		defer q.Stop()
+
+		// Act
+		job, err := q.Submit(ctx, app.IndexDocumentCommand{ID: "doc", Content: "hello"})
+		require.NoError(t, err)
+		finished := waitForJob(t, q, job.ID)
+
+		// Assert
+		assert.Equal(t, app.JobFailed, finished.Status)
+		assert.Equal(t, 3, finished.Attempts)
+		assert.Empty(t, finished.Command.Content)
+		assert.Equal(t, "unavailable", finished.Error)
+	})
+
+	t.Run("should resume jobs left running by a previous process", func(t *testing.T) {
+		// Arrange
+		embedder := new(MockEmbeddingGenerator)
+		embedder.On("Generate", mock.Anything, "hello").Return([]float32{1, 0, 0}, nil)
+		handler := app.NewIndexDocumentHandler(memory.NewStore(app.Cosine), embedder, app.NewFixedSizeChunker(100, 0), nil)
+		store := newFakeJobStore(app.Job{ID: "job", Status: app.JobRunning, Attempts: 1, Command: app.IndexDocumentCommand{ID: "doc", Content: "hello"}})
+		q := app.NewJobQueue(store, handler, app.JobQueueConfig{})
+
+		// Act
+		require.NoError(t, q.Start(ctx))
+		defer q.Stop()
+		finished := waitForJob(t, q, "job")
+
+		// Assert
+		assert.Equal(t, app.JobSucceeded, finished.Status)
+		assert.Equal(t, 2, finished.Attempts)
+	})
+
+	t.Run("should delete finished jobs after the retention", func(t *testing.T) {
+		// Arrange
+		old := time.Now().UTC().Add(-time.Hour)
+		store := newFakeJobStore(
+			app.Job{ID: "succeeded", Status: app.JobSucceeded, UpdatedAt: old},
+			app.Job{ID: "failed", Status: app.JobFailed, UpdatedAt: old},
+			app.Job{ID: "recent", Status: app.JobSucceeded, UpdatedAt: time.Now().UTC()},
+		)
+		q := app.NewJobQueue(store, nil, app.JobQueueConfig{Retention: time.Minute})
+
+		// Act
+		require.NoError(t, q.Start(ctx))
+		defer q.Stop()
+
+		// Assert
+		require.Eventually(t, func() bool {
+			_, err := q.Get(ctx, "succeeded")
+			return errors.Is(err, app.ErrJobNotFound)
+		}, 5*time.Second, time.Millisecond)
+		_, err := q.Get(ctx, "failed")
+		assert.ErrorIs(t, err, app.ErrJobNotFound)
+		_, err = q.Get(ctx, "recent")
+		assert.NoError(t, err)
+	})
+
+	t.Run("should reject a document without content", func(t *testing.T) {
+		// Arrange
+		q := app.NewJobQueue(newFakeJobStore(), nil, app.JobQueueConfig{})
+
+		// Act
+		_, err := q.Submit(ctx, app.IndexDocumentCommand{ID: "doc", Content: " "})
+
+		// Assert
+		assert.ErrorIs(t, err, app.ErrEmptyDocument)
+	})
+}
@@ -0,0 +1,89 @@
+package app
+
+import (
+	"fmt"
+	"math"
+)
+
+// DistanceMetric is the function a vector store compares embeddings with.
+type DistanceMetric string
+
+const (
+	// Cosine compares the angle between vectors. It is the default.
+	Cosine DistanceMetric = "cosine"
+	// InnerProduct compares vectors by their dot product, which equals Cosine for normalized vectors.
+	InnerProduct DistanceMetric = "ip"
+	// Euclidean compares vectors by their L2 distance.
+	Euclidean DistanceMetric = "l2"
+)
+
+// ParseDistanceMetric parses a metric name. An empty name yields Cosine.
+func ParseDistanceMetric(name string) (DistanceMetric, error) {
+	switch m := DistanceMetric(name); m {
+	case "":
+		return Cosine, nil
+	case Cosine, InnerProduct, Euclidean:
+		return m, nil
+	default:
+		return "", fmt.Errorf("unknown distance metric %q", name)
+	}
+}
+
+// Distance returns the distance between two vectors of equal length, lower being closer.
+// Cosine and inner product distances are 1-cosine and 1-dot, as reported by Typesense.
+func (m DistanceMetric) Distance(a, b []float32) float64 {
+	switch m {
+	case InnerProduct:
+		return 1 - Dot(a, b)
+	case Euclidean:
+		var sum float64
+		for i := range a {
+			d := float64(a[i]) - float64(b[i])
+			sum += d * d
+		}
+		return math.Sqrt(sum)
+	default:
+		return 1 - cosineSimilarity(a, b)
+	}
+}
+
+// Score converts a distance into the raw similarity reported as SearchHit.Score: 1-distance for
+// cosine and inner product, 1/(1+distance) for Euclidean.
+func (m DistanceMetric) Score(distance float64) float64 {
+	if m == Euclidean {
+		return 1 / (1 + distance)
+	}
+	return 1 - distance
+}
+
+// Similarity converts a distance into a similarity in [0, 1] that is comparable across metrics,
+// 1 meaning identical direction or position. Inner products are mapped like cosines and clamped,
+// which is exact for normalized vectors.
+func (m DistanceMetric) Similarity(distance float64) float64 {
+	if m == Euclidean {
+		return 1 / (1 + distance)
+	}
+	return min(max((2-distance)/2, 0), 1)
+}
+
+// Dot returns the dot product of two vectors of equal length.
+func Dot(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
+
+// cosineSimilarity returns the cosine similarity of two vectors, or 0 if they differ in length
+// or either is zero.
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	norms := Dot(a, a) * Dot(b, b)
+	if norms == 0 {
+		return 0
+	}
+	return Dot(a, b) / math.Sqrt(norms)
+}
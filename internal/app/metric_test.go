@@ -0,0 +1,50 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDistanceMetric(t *testing.T) {
+	a := []float32{1, 0}
+	tests := []struct {
+		name           string
+		metric         DistanceMetric
+		b              []float32
+		wantDistance   float64
+		wantScore      float64
+		wantSimilarity float64
+	}{
+		{"cosine of identical vectors", Cosine, []float32{2, 0}, 0, 1, 1},
+		{"cosine of opposite vectors", Cosine, []float32{-1, 0}, 2, -1, 0},
+		{"cosine of orthogonal vectors", Cosine, []float32{0, 1}, 1, 0, 0.5},
+		{"inner product of unit vectors", InnerProduct, []float32{1, 0}, 0, 1, 1},
+		{"inner product beyond the unit range", InnerProduct, []float32{3, 0}, -2, 3, 1},
+		{"euclidean of identical vectors", Euclidean, []float32{1, 0}, 0, 1, 1},
+		{"euclidean of distant vectors", Euclidean, []float32{1, 3}, 3, 0.25, 0.25},
+	}
+	for _, tt := range tests {
+		t.Run("should compute "+tt.name, func(t *testing.T) {
+			d := tt.metric.Distance(a, tt.b)
+
+			assert.InDelta(t, tt.wantDistance, d, 1e-9)
+			assert.InDelta(t, tt.wantScore, tt.metric.Score(d), 1e-9)
+			assert.InDelta(t, tt.wantSimilarity, tt.metric.Similarity(d), 1e-9)
+		})
+	}
+
+	t.Run("should parse metric names", func(t *testing.T) {
+		m, err := ParseDistanceMetric("")
+		require.NoError(t, err)
+		assert.Equal(t, Cosine, m)
+
+		m, err = ParseDistanceMetric("l2")
+		require.NoError(t, err)
+		assert.Equal(t, Euclidean, m)
+
+		_, err = ParseDistanceMetric("manhattan")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,84 @@
+package app
+
+import "math"
+
+// DefaultMMRLambda balances relevance and diversity equally in maximal marginal relevance.
+const DefaultMMRLambda = 0.5
+
+const (
+	// mmrCandidateFactor is how many candidates per returned hit are fetched for MMR re-ranking.
+	mmrCandidateFactor = 3
+	// maxMMRCandidates caps the candidate pool, staying within the page size limits of stores.
+	maxMMRCandidates = 250
+)
+
+// MaximalMarginalRelevance picks n hits from candidates that are relevant to the query but not
+// redundant with each other. Each step selects the candidate maximizing
+//
+//	lambda*rel(d) - (1-lambda)*max sim(d, s) over the already selected s
+//
+// where rel is the relevance the candidates were ranked by, see mmrRelevance, and sim the cosine
+// similarity of the embeddings. A lambda of 1 keeps the relevance order.
+func MaximalMarginalRelevance(candidates []SearchHit, n int, lambda float64) []SearchHit {
+	n = min(n, len(candidates))
+	relevance := mmrRelevance(candidates)
+
+	// redundancy[i] is the highest similarity of candidate i to any selected hit.
+	redundancy := make([]float64, len(candidates))
+	for i := range redundancy {
+		redundancy[i] = math.Inf(-1)
+	}
+	used := make([]bool, len(candidates))
+
+	selected := make([]SearchHit, 0, n)
+	for len(selected) < n {
+		best, bestScore := -1, math.Inf(-1)
+		for i := range candidates {
+			if used[i] {
+				continue
+			}
+			score := lambda * relevance[i]
+			if len(selected) > 0 {
+				score -= (1 - lambda) * redundancy[i]
+			}
+			if score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+
+		used[best] = true
+		selected = append(selected, candidates[best])
+		for i := range candidates {
+			if !used[i] {
+				redundancy[i] = max(redundancy[i], cosineSimilarity(candidates[i].Document.Embedding, candidates[best].Document.Embedding))
+			}
+		}
+	}
+
+	return selected
+}
+
+// mmrRelevance returns the relevance of the candidates in [0, 1]: the RerankScore of reranked
+// hits, otherwise the FusionScore of fused hits relative to the best one, otherwise the
+// Similarity of the vector search.
+func mmrRelevance(candidates []SearchHit) []float64 {
+	var reranked bool
+	var bestFusion float64
+	for _, c := range candidates {
+		reranked = reranked || c.RerankScore != 0
+		bestFusion = max(bestFusion, c.FusionScore)
+	}
+
+	relevance := make([]float64, len(candidates))
+	for i, c := range candidates {
+		switch {
+		case reranked:
+			relevance[i] = c.RerankScore
+		case bestFusion > 0:
+			relevance[i] = c.FusionScore / bestFusion
+		default:
+			relevance[i] = c.Similarity
+		}
+	}
+	return relevance
+}
@@ -0,0 +1,89 @@
+package app
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/igorrius/go-vector-search/internal/domain"
+)
+
+func embeddedHit(id string, similarity float64, embedding ...float32) SearchHit {
+	return SearchHit{Document: domain.Document{ID: id, Content: id, Embedding: embedding}, Similarity: similarity}
+}
+
+func TestMaximalMarginalRelevance(t *testing.T) {
+	candidates := []SearchHit{
+		embeddedHit("a", 0.99, 1, 0.1),
+		embeddedHit("a-duplicate", 0.98, 1, 0.11),
+		embeddedHit("b", 0.6, 0.6, -0.8),
+	}
+
+	t.Run("should skip near-duplicates", func(t *testing.T) {
+		hits := MaximalMarginalRelevance(candidates, 2, 0.5)
+
+		assert.Equal(t, []string{"a", "b"}, ids(hits))
+	})
+
+	t.Run("should keep the relevance order with lambda 1", func(t *testing.T) {
+		hits := MaximalMarginalRelevance(candidates, 3, 1)
+
+		assert.Equal(t, []string{"a", "a-duplicate", "b"}, ids(hits))
+	})
+
+	t.Run("should keep the rerank order with lambda 1", func(t *testing.T) {
+		reranked := []SearchHit{candidates[2], candidates[1], candidates[0]}
+		for i := range reranked {
+			reranked[i].RerankScore = 1 - float64(i)*0.1
+		}
+
+		hits := MaximalMarginalRelevance(reranked, 3, 1)
+
+		assert.Equal(t, []string{"b", "a-duplicate", "a"}, ids(hits))
+	})
+
+	t.Run("should keep the fused order with lambda 1", func(t *testing.T) {
+		fused := []SearchHit{candidates[2], candidates[0], candidates[1]}
+		for i := range fused {
+			fused[i].FusionScore = 1 / float64(61+i)
+		}
+
+		hits := MaximalMarginalRelevance(fused, 3, 1)
+
+		assert.Equal(t, []string{"b", "a", "a-duplicate"}, ids(hits))
+	})
+
+	t.Run("should return all candidates when asked for more", func(t *testing.T) {
+		hits := MaximalMarginalRelevance(candidates[:1], 5, 0.5)
+
+		assert.Len(t, hits, 1)
+	})
+}
+
+func TestSearchDocumentsHandler_Handle_MMR(t *testing.T) {
+	ctx := context.Background()
+	embedding := []float32{1, 0}
+	candidates := []SearchHit{
+		embeddedHit("a", 0.99, 1, 0.1),
+		embeddedHit("a-duplicate", 0.98, 1, 0.11),
+		embeddedHit("b", 0.6, 0.6, -0.8),
+	}
+
+	embedder := new(MockEmbeddingGenerator)
+	store := new(MockVectorStore)
+	summarizer := new(MockSummarizer)
+	embedder.On("Generate", ctx, "q").Return(embedding, nil)
+	store.On("Search", ctx, SearchOptions{Query: "q", Embedding: embedding, Limit: 6}).Return(candidates, nil)
+	summarizer.On("Summarize", ctx, []string{"a", "b"}).Return("summary", nil)
+
+	handler := NewSearchDocumentsHandler(embedder, store, summarizer, nil)
+	result, err := handler.Handle(ctx, SearchDocumentsQuery{Query: "q", Limit: 2, MMR: true})
+
+	assert.NoError(t, err)
+	assert.Len(t, result.Sources, 2)
+	assert.Equal(t, "b", result.Sources[1].DocumentID)
+	store.AssertExpectations(t)
+	summarizer.AssertNotCalled(t, "Summarize", mock.Anything, []string{"a", "a-duplicate"})
+}
@@ -2,73 +2,422 @@ package app
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/igorrius/go-vector-search/internal/domain"
+)
+
+const (
+	// DefaultSearchLimit is the number of hits returned when the query does not set a limit.
+	DefaultSearchLimit = 10
+	// MaxSearchLimit is the largest accepted limit.
+	MaxSearchLimit = 100
+	// DefaultHybridAlpha is the vector weight of a hybrid search that does not set one.
+	DefaultHybridAlpha = 0.5
+	// DefaultListLimit is the number of documents listed when the query does not set a limit.
+	DefaultListLimit = 20
+	// MaxListLimit is the largest accepted list limit.
+	MaxListLimit = 100
 )
 
 // SearchDocumentsQuery represents a query to search for documents.
 type SearchDocumentsQuery struct {
 	Query string
+	// Filter restricts the search to documents matching the metadata filter. Nil matches all.
+	Filter Filter
+	// Limit is the page size; zero means DefaultSearchLimit.
+	Limit int
+	// Offset is the number of best hits to skip.
+	Offset int
+	// MinScore drops hits with a lower similarity score before they are summarized; nil keeps
+	// all hits.
+	MinScore *float64
+	// Hybrid combines keyword matching of Query with vector search.
+	Hybrid bool
+	// Alpha is the vector weight of a hybrid search in [0, 1]; nil means DefaultHybridAlpha.
+	Alpha *float64
+	// MMR re-ranks over-fetched hits with maximal marginal relevance to drop near-duplicates.
+	MMR bool
+	// MMRLambda trades relevance (1) for diversity (0); nil means DefaultMMRLambda.
+	MMRLambda *float64
 }
 
 // SearchResult represents the result of a document search.
 type SearchResult struct {
 	Summary string
 	Sources []Source
+	Limit   int
+	Offset  int
 }
 
 // Source represents a source document for a search result.
+// ParentID, StartOffset and EndOffset locate the snippet in the originally uploaded file.
 type Source struct {
-	DocumentID string
-	Snippet    string
+	DocumentID  string
+	ParentID    string
+	ChunkIndex  int
+	StartOffset int
+	EndOffset   int
+	Snippet     string
+	Metadata    domain.Metadata
+	Distance    float64
+	Score       float64
+	Similarity  float64
+	TextMatch   float64
+	RerankScore float64
 }
 
+// Label names the source to a model: its title, or its document ID when it has none.
+func (s Source) Label() string {
+	if s.Metadata.Title != "" {
+		return s.Metadata.Title
+	}
+	return s.DocumentID
+}
+
+// ErrInvalidSearchQuery is returned for a query with out-of-range paging or scoring parameters.
+var ErrInvalidSearchQuery = errors.New("invalid search query")
+
 // SearchDocumentsHandler handles the SearchDocumentsQuery.
 type SearchDocumentsHandler struct {
 	embedder   EmbeddingGenerator
 	store      VectorStore
 	summarizer Summarizer
+	reranker   Reranker
 }
 
 // NewSearchDocumentsHandler creates a new SearchDocumentsHandler.
-func NewSearchDocumentsHandler(embedder EmbeddingGenerator, store VectorStore, summarizer Summarizer) *SearchDocumentsHandler {
+// A nil reranker leaves the hits in the order of the store.
+func NewSearchDocumentsHandler(embedder EmbeddingGenerator, store VectorStore, summarizer Summarizer, reranker Reranker) *SearchDocumentsHandler {
 	return &SearchDocumentsHandler{
 		embedder:   embedder,
 		store:      store,
 		summarizer: summarizer,
+		reranker:   reranker,
 	}
 }
 
 // Handle handles the SearchDocumentsQuery.
 func (h *SearchDocumentsHandler) Handle(ctx context.Context, query SearchDocumentsQuery) (*SearchResult, error) {
-	embedding, err := h.embedder.Generate(ctx, query.Query)
+	if query.Limit == 0 {
+		query.Limit = DefaultSearchLimit
+	}
+	hits, err := h.retrieve(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 
-	docs, err := h.store.Search(ctx, embedding)
+	// Nothing is left to summarize when every match was too weak.
+	var summary string
+	if len(hits) > 0 {
+		summary, err = h.summarizer.Summarize(ctx, contentsOf(hits))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &SearchResult{
+		Summary: summary,
+		Sources: sourcesOf(hits),
+		Limit:   query.Limit,
+		Offset:  query.Offset,
+	}, nil
+}
+
+// HandleStream handles the SearchDocumentsQuery like Handle while streaming the result: sources
+// receives the result without its summary once the hits are retrieved, and delta every piece of
+// the summary as it is generated. The returned result holds the whole summary. An error returned
+// by a callback stops the search and is returned.
+func (h *SearchDocumentsHandler) HandleStream(ctx context.Context, query SearchDocumentsQuery, sources func(*SearchResult) error, delta func(string) error) (*SearchResult, error) {
+	if query.Limit == 0 {
+		query.Limit = DefaultSearchLimit
+	}
+	hits, err := h.retrieve(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 
-	var content []string
-	for _, doc := range docs {
-		content = append(content, doc.Content)
+	result := &SearchResult{
+		Sources: sourcesOf(hits),
+		Limit:   query.Limit,
+		Offset:  query.Offset,
+	}
+	if err := sources(result); err != nil {
+		return nil, err
+	}
+	if len(hits) == 0 {
+		return result, nil
 	}
 
-	summary, err := h.summarizer.Summarize(ctx, content)
+	var summary strings.Builder
+	err = AsStreamingSummarizer(h.summarizer).SummarizeStream(ctx, contentsOf(hits), func(text string) error {
+		summary.WriteString(text)
+		return delta(text)
+	})
 	if err != nil {
 		return nil, err
 	}
+	result.Summary = summary.String()
+	return result, nil
+}
+
+// retrieve returns the page of hits of a query with a set limit.
+func (h *SearchDocumentsHandler) retrieve(ctx context.Context, query SearchDocumentsQuery) ([]SearchHit, error) {
+	if query.Limit < 0 || query.Limit > MaxSearchLimit {
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidSearchQuery, MaxSearchLimit)
+	}
+	if query.Offset < 0 {
+		return nil, fmt.Errorf("%w: offset must not be negative", ErrInvalidSearchQuery)
+	}
+	alpha := DefaultHybridAlpha
+	if query.Alpha != nil {
+		alpha = *query.Alpha
+	}
+	if alpha < 0 || alpha > 1 {
+		return nil, fmt.Errorf("%w: alpha must be between 0 and 1", ErrInvalidSearchQuery)
+	}
+	lambda := DefaultMMRLambda
+	if query.MMRLambda != nil {
+		lambda = *query.MMRLambda
+	}
+	if lambda < 0 || lambda > 1 {
+		return nil, fmt.Errorf("%w: lambda must be between 0 and 1", ErrInvalidSearchQuery)
+	}
+
+	embedding, err := h.embedder.Generate(ctx, query.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := SearchOptions{
+		Query:     query.Query,
+		Embedding: embedding,
+		Filter:    query.Filter,
+		Limit:     query.Limit,
+		Offset:    query.Offset,
+		MinScore:  query.MinScore,
+	}
+	if query.Hybrid {
+		opts.Text = query.Query
+		opts.Alpha = alpha
+	}
+	// Reranking and MMR select the page from a larger pool of candidates ranked from the top.
+	window := query.Offset + query.Limit
+	overFetch := query.MMR || h.reranker != nil
+	if overFetch {
+		opts.Limit = window
+		if h.reranker != nil {
+			opts.Limit = max(opts.Limit, min(window*rerankCandidateFactor, maxRerankCandidates))
+		}
+		if query.MMR {
+			opts.Limit = max(opts.Limit, min(window*mmrCandidateFactor, maxMMRCandidates))
+		}
+		opts.Offset = 0
+	}
+
+	hits, err := h.store.Search(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if h.reranker != nil && len(hits) > 0 {
+		hits, err = rerankHits(ctx, h.reranker, query.Query, hits)
+		if err != nil {
+			return nil, fmt.Errorf("failed to rerank hits: %w", err)
+		}
+	}
+	if query.MMR {
+		hits = MaximalMarginalRelevance(hits, window, lambda)
+	}
+	if overFetch {
+		hits = hits[min(query.Offset, len(hits)):min(window, len(hits))]
+	}
+	return hits, nil
+}
+
+func contentsOf(hits []SearchHit) []string {
+	content := make([]string, len(hits))
+	for i, hit := range hits {
+		content[i] = hit.Document.Content
+	}
+	return content
+}
 
+func sourcesOf(hits []SearchHit) []Source {
 	var sources []Source
-	for _, doc := range docs {
+	for _, hit := range hits {
+		doc := hit.Document
 		sources = append(sources, Source{
-			DocumentID: doc.ID,
-			Snippet:    doc.Content, // Using full content as snippet for now
+			DocumentID:  doc.ID,
+			ParentID:    doc.ParentID,
+			ChunkIndex:  doc.ChunkIndex,
+			StartOffset: doc.StartOffset,
+			EndOffset:   doc.EndOffset,
+			Snippet:     doc.Content, // Using full content as snippet for now
+			Metadata:    doc.Metadata,
+			Distance:    hit.Distance,
+			Score:       hit.Score,
+			Similarity:  hit.Similarity,
+			TextMatch:   hit.TextMatch,
+			RerankScore: hit.RerankScore,
 		})
 	}
+	return sources
+}
 
-	return &SearchResult{
-		Summary: summary,
-		Sources: sources,
-	}, nil
+// DocumentChunk is a stored chunk of an indexed document.
+type DocumentChunk struct {
+	ID          string
+	Index       int
+	StartOffset int
+	EndOffset   int
+	Content     string
+}
+
+// DocumentResult represents an indexed document with its chunks in order.
+type DocumentResult struct {
+	ID       string
+	Metadata domain.Metadata
+	Chunks   []DocumentChunk
+}
+
+// GetDocumentQuery represents a query for an indexed document by the ID it was indexed with.
+type GetDocumentQuery struct {
+	ID string
+}
+
+// GetDocumentHandler handles the GetDocumentQuery.
+type GetDocumentHandler struct {
+	store DocumentStore
+}
+
+// NewGetDocumentHandler creates a new GetDocumentHandler.
+func NewGetDocumentHandler(store DocumentStore) *GetDocumentHandler {
+	return &GetDocumentHandler{store: store}
+}
+
+// Handle handles the GetDocumentQuery. It returns domain.ErrDocumentNotFound when no chunk of
+// the document is stored.
+func (h *GetDocumentHandler) Handle(ctx context.Context, query GetDocumentQuery) (*DocumentResult, error) {
+	chunks, err := loadChunks(ctx, h.store, query.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DocumentResult{ID: query.ID, Metadata: chunks[0].Metadata}
+	for _, doc := range chunks {
+		result.Chunks = append(result.Chunks, DocumentChunk{
+			ID:          doc.ID,
+			Index:       doc.ChunkIndex,
+			StartOffset: doc.StartOffset,
+			EndOffset:   doc.EndOffset,
+			Content:     doc.Content,
+		})
+	}
+	return result, nil
+}
+
+// loadChunks returns all stored chunks of a document ordered by index. A document indexed before
+// chunking was introduced is stored under its own ID and returned as its only chunk.
+func loadChunks(ctx context.Context, store DocumentStore, id string) ([]domain.Document, error) {
+	var chunks []domain.Document
+	opts := ListOptions{Filter: EqualFilter{Field: "parent_id", Value: id}, Limit: MaxListLimit}
+	for {
+		page, err := store.List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, page.Documents...)
+		if page.NextCursor == "" {
+			break
+		}
+		opts.Cursor = page.NextCursor
+	}
+
+	if len(chunks) == 0 {
+		doc, err := store.FindByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if doc.ParentID != "" {
+			return nil, domain.ErrDocumentNotFound
+		}
+		return []domain.Document{*doc}, nil
+	}
+
+	sort.Slice(chunks, func(i, j int) bool {
+		return chunks[i].ChunkIndex < chunks[j].ChunkIndex
+	})
+	return chunks, nil
+}
+
+// ErrInvalidListQuery is returned for a list query with an out-of-range limit.
+var ErrInvalidListQuery = errors.New("invalid list query")
+
+// ListDocumentsQuery represents a query for a page of indexed documents.
+type ListDocumentsQuery struct {
+	// Filter restricts the listing to documents matching the metadata filter. Nil matches all.
+	Filter Filter
+	// Cursor is the NextCursor of the previous page; empty starts at the first page.
+	Cursor string
+	// Limit is the page size; zero means DefaultListLimit.
+	Limit int
+}
+
+// ListedDocument represents an indexed document in a listing.
+type ListedDocument struct {
+	ID       string
+	Metadata domain.Metadata
+}
+
+// ListDocumentsResult represents a page of indexed documents.
+type ListDocumentsResult struct {
+	Documents []ListedDocument
+	// NextCursor requests the next page; empty on the last page.
+	NextCursor string
+}
+
+// ListDocumentsHandler handles the ListDocumentsQuery.
+type ListDocumentsHandler struct {
+	store DocumentStore
+}
+
+// NewListDocumentsHandler creates a new ListDocumentsHandler.
+func NewListDocumentsHandler(store DocumentStore) *ListDocumentsHandler {
+	return &ListDocumentsHandler{store: store}
+}
+
+// Handle handles the ListDocumentsQuery. Every indexed document is listed once, represented by
+// its first chunk, which shares the metadata of the others.
+func (h *ListDocumentsHandler) Handle(ctx context.Context, query ListDocumentsQuery) (*ListDocumentsResult, error) {
+	if query.Limit == 0 {
+		query.Limit = DefaultListLimit
+	}
+	if query.Limit < 0 || query.Limit > MaxListLimit {
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidListQuery, MaxListLimit)
+	}
+
+	first := 0.0
+	var filter Filter = RangeFilter{Field: "chunk_index", Min: &first, Max: &first}
+	if query.Filter != nil {
+		filter = AndFilter{Filters: []Filter{query.Filter, filter}}
+	}
+
+	page, err := h.store.List(ctx, ListOptions{Filter: filter, Cursor: query.Cursor, Limit: query.Limit})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ListDocumentsResult{NextCursor: page.NextCursor}
+	for _, doc := range page.Documents {
+		id := doc.ParentID
+		if id == "" {
+			id = doc.ID
+		}
+		result.Documents = append(result.Documents, ListedDocument{ID: id, Metadata: doc.Metadata})
+	}
+	return result, nil
 }
@@ -19,13 +19,21 @@ func (m *MockEmbeddingGenerator) Generate(ctx context.Context, content string) (
 	return args.Get(0).([]float32), args.Error(1)
 }
 
+func (m *MockEmbeddingGenerator) ModelName() string {
+	return "mock-embedding"
+}
+
+func (m *MockEmbeddingGenerator) Dimension() int {
+	return 3
+}
+
 type MockVectorStore struct {
 	mock.Mock
 }
 
-func (m *MockVectorStore) Search(ctx context.Context, embedding []float32) ([]domain.Document, error) {
-	args := m.Called(ctx, embedding)
-	return args.Get(0).([]domain.Document), args.Error(1)
+func (m *MockVectorStore) Search(ctx context.Context, opts SearchOptions) ([]SearchHit, error) {
+	args := m.Called(ctx, opts)
+	return args.Get(0).([]SearchHit), args.Error(1)
 }
 
 type MockSummarizer struct {
@@ -45,6 +53,10 @@ func TestSearchDocumentsHandler_Handle(t *testing.T) {
 		{ID: "doc1", Content: "This is a test document."},
 		{ID: "doc2", Content: "This is another test document."},
 	}
+	hits := []SearchHit{
+		{Document: docs[0], Distance: 0.1, Score: 0.9},
+		{Document: docs[1], Distance: 0.3, Score: 0.7},
+	}
 	summary := "This is a summary."
 
 	t.Run("Successful search", func(t *testing.T) {
@@ -53,14 +65,14 @@ func TestSearchDocumentsHandler_Handle(t *testing.T) {
 		summarizer := new(MockSummarizer)
 
 		embedder.On("Generate", ctx, query.Query).Return(embedding, nil)
-		store.On("Search", ctx, embedding).Return(docs, nil)
+		store.On("Search", ctx, SearchOptions{Query: query.Query, Embedding: embedding, Limit: DefaultSearchLimit}).Return(hits, nil)
 		var docContents []string
 		for _, doc := range docs {
 			docContents = append(docContents, doc.Content)
 		}
 		summarizer.On("Summarize", ctx, docContents).Return(summary, nil)
 
-		handler := NewSearchDocumentsHandler(embedder, store, summarizer)
+		handler := NewSearchDocumentsHandler(embedder, store, summarizer, nil)
 		result, err := handler.Handle(ctx, query)
 
 		assert.NoError(t, err)
@@ -70,10 +82,264 @@ func TestSearchDocumentsHandler_Handle(t *testing.T) {
 		for i, source := range result.Sources {
 			assert.Equal(t, docs[i].ID, source.DocumentID)
 			assert.Equal(t, docs[i].Content, source.Snippet)
+			assert.Equal(t, hits[i].Score, source.Score)
+			assert.Equal(t, hits[i].Distance, source.Distance)
 		}
 
 		embedder.AssertExpectations(t)
 		store.AssertExpectations(t)
 		summarizer.AssertExpectations(t)
 	})
+
+	t.Run("Paginated search without matches above the minimum score", func(t *testing.T) {
+		embedder := new(MockEmbeddingGenerator)
+		store := new(MockVectorStore)
+		summarizer := new(MockSummarizer)
+
+		minScore := 0.8
+		paged := SearchDocumentsQuery{Query: "test query", Limit: 5, Offset: 10, MinScore: &minScore}
+		embedder.On("Generate", ctx, paged.Query).Return(embedding, nil)
+		store.On("Search", ctx, SearchOptions{Query: paged.Query, Embedding: embedding, Limit: 5, Offset: 10, MinScore: &minScore}).Return([]SearchHit(nil), nil)
+
+		handler := NewSearchDocumentsHandler(embedder, store, summarizer, nil)
+		result, err := handler.Handle(ctx, paged)
+
+		assert.NoError(t, err)
+		assert.Empty(t, result.Summary)
+		assert.Empty(t, result.Sources)
+		assert.Equal(t, 5, result.Limit)
+		assert.Equal(t, 10, result.Offset)
+		summarizer.AssertNotCalled(t, "Summarize", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Hybrid search", func(t *testing.T) {
+		embedder := new(MockEmbeddingGenerator)
+		store := new(MockVectorStore)
+		summarizer := new(MockSummarizer)
+
+		alpha := 0.3
+		hybrid := SearchDocumentsQuery{Query: "E1234", Hybrid: true, Alpha: &alpha}
+		textHits := []SearchHit{{Document: docs[0], TextMatch: 578730123365187705}}
+		embedder.On("Generate", ctx, hybrid.Query).Return(embedding, nil)
+		store.On("Search", ctx, SearchOptions{Query: hybrid.Query, Embedding: embedding, Limit: DefaultSearchLimit, Text: "E1234", Alpha: 0.3}).Return(textHits, nil)
+		summarizer.On("Summarize", ctx, []string{docs[0].Content}).Return(summary, nil)
+
+		handler := NewSearchDocumentsHandler(embedder, store, summarizer, nil)
+		result, err := handler.Handle(ctx, hybrid)
+
+		assert.NoError(t, err)
+		assert.Equal(t, textHits[0].TextMatch, result.Sources[0].TextMatch)
+		store.AssertExpectations(t)
+	})
+
+	t.Run("Invalid alpha", func(t *testing.T) {
+		handler := NewSearchDocumentsHandler(new(MockEmbeddingGenerator), new(MockVectorStore), new(MockSummarizer), nil)
+
+		alpha := 1.5
+		_, err := handler.Handle(ctx, SearchDocumentsQuery{Query: "test query", Hybrid: true, Alpha: &alpha})
+
+		assert.ErrorIs(t, err, ErrInvalidSearchQuery)
+	})
+
+	t.Run("Invalid limit", func(t *testing.T) {
+		handler := NewSearchDocumentsHandler(new(MockEmbeddingGenerator), new(MockVectorStore), new(MockSummarizer), nil)
+
+		_, err := handler.Handle(ctx, SearchDocumentsQuery{Query: "test query", Limit: MaxSearchLimit + 1})
+
+		assert.ErrorIs(t, err, ErrInvalidSearchQuery)
+	})
+}
+
+type MockStreamingSummarizer struct {
+	MockSummarizer
+}
+
+func (m *MockStreamingSummarizer) SummarizeStream(ctx context.Context, content []string, yield func(delta string) error) error {
+	args := m.Called(ctx, content)
+	for _, delta := range args.Get(0).([]string) {
+		if err := yield(delta); err != nil {
+			return err
+		}
+	}
+	return args.Error(1)
+}
+
+func TestSearchDocumentsHandler_HandleStream(t *testing.T) {
+	ctx := context.Background()
+	query := SearchDocumentsQuery{Query: "test query"}
+	embedding := []float32{1.0, 2.0, 3.0}
+	hits := []SearchHit{{Document: domain.Document{ID: "doc1", Content: "first"}}}
+
+	newHandler := func(hits []SearchHit, summarizer Summarizer) *SearchDocumentsHandler {
+		embedder := new(MockEmbeddingGenerator)
+		embedder.On("Generate", ctx, query.Query).Return(embedding, nil)
+		store := new(MockVectorStore)
+		store.On("Search", ctx, SearchOptions{Query: query.Query, Embedding: embedding, Limit: DefaultSearchLimit}).Return(hits, nil)
+		return NewSearchDocumentsHandler(embedder, store, summarizer, nil)
+	}
+
+	t.Run("should stream the sources before the summary", func(t *testing.T) {
+		// Arrange
+		summarizer := new(MockStreamingSummarizer)
+		summarizer.On("SummarizeStream", ctx, []string{"first"}).Return([]string{"A ", "summary."}, nil)
+		var events []string
+
+		// Act
+		result, err := newHandler(hits, summarizer).HandleStream(ctx, query,
+			func(result *SearchResult) error {
+				events = append(events, "sources:"+result.Sources[0].DocumentID)
+				return nil
+			},
+			func(delta string) error {
+				events = append(events, "delta:"+delta)
+				return nil
+			},
+		)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"sources:doc1", "delta:A ", "delta:summary."}, events)
+		assert.Equal(t, "A summary.", result.Summary)
+	})
+
+	t.Run("should yield the whole summary of a summarizer that does not stream", func(t *testing.T) {
+		// Arrange
+		summarizer := new(MockSummarizer)
+		summarizer.On("Summarize", ctx, []string{"first"}).Return("A summary.", nil)
+		var deltas []string
+
+		// Act
+		_, err := newHandler(hits, summarizer).HandleStream(ctx, query,
+			func(*SearchResult) error { return nil },
+			func(delta string) error {
+				deltas = append(deltas, delta)
+				return nil
+			},
+		)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"A summary."}, deltas)
+	})
+
+	t.Run("should not summarize without hits", func(t *testing.T) {
+		// Arrange
+		summarizer := new(MockStreamingSummarizer)
+		sources := 0
+
+		// Act
+		result, err := newHandler([]SearchHit{}, summarizer).HandleStream(ctx, query,
+			func(*SearchResult) error {
+				sources++
+				return nil
+			},
+			func(string) error { return nil },
+		)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, 1, sources)
+		assert.Empty(t, result.Summary)
+		summarizer.AssertNotCalled(t, "SummarizeStream", mock.Anything, mock.Anything)
+	})
+}
+
+type MockDocumentStore struct {
+	mock.Mock
+}
+
+func (m *MockDocumentStore) Save(ctx context.Context, doc *domain.Document) error {
+	args := m.Called(ctx, doc)
+	return args.Error(0)
+}
+
+func (m *MockDocumentStore) FindByID(ctx context.Context, id string) (*domain.Document, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*domain.Document), args.Error(1)
+}
+
+func (m *MockDocumentStore) Delete(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockDocumentStore) List(ctx context.Context, opts ListOptions) (*DocumentPage, error) {
+	args := m.Called(ctx, opts)
+	return args.Get(0).(*DocumentPage), args.Error(1)
+}
+
+func TestGetDocumentHandler_Handle(t *testing.T) {
+	ctx := context.Background()
+	byParent := func(cursor string) ListOptions {
+		return ListOptions{Filter: EqualFilter{Field: "parent_id", Value: "doc"}, Cursor: cursor, Limit: MaxListLimit}
+	}
+	metadata := domain.Metadata{Title: "Doc"}
+
+	t.Run("should return the chunks of all pages in order", func(t *testing.T) {
+		store := new(MockDocumentStore)
+		second := domain.NewChunk("doc", 1, "world", 6, 11)
+		second.SetMetadata(metadata)
+		first := domain.NewChunk("doc", 0, "hello", 0, 5)
+		first.SetMetadata(metadata)
+		store.On("List", ctx, byParent("")).Return(&DocumentPage{Documents: []domain.Document{*second}, NextCursor: "next"}, nil)
+		store.On("List", ctx, byParent("next")).Return(&DocumentPage{Documents: []domain.Document{*first}}, nil)
+
+		result, err := NewGetDocumentHandler(store).Handle(ctx, GetDocumentQuery{ID: "doc"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "doc", result.ID)
+		assert.Equal(t, metadata, result.Metadata)
+		assert.Equal(t, []DocumentChunk{
+			{ID: first.ID, Index: 0, StartOffset: 0, EndOffset: 5, Content: "hello"},
+			{ID: second.ID, Index: 1, StartOffset: 6, EndOffset: 11, Content: "world"},
+		}, result.Chunks)
+	})
+
+	t.Run("should return a document indexed before chunking", func(t *testing.T) {
+		store := new(MockDocumentStore)
+		store.On("List", ctx, byParent("")).Return(&DocumentPage{}, nil)
+		store.On("FindByID", ctx, "doc").Return(domain.NewDocument("doc", "legacy"), nil)
+
+		result, err := NewGetDocumentHandler(store).Handle(ctx, GetDocumentQuery{ID: "doc"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "legacy", result.Chunks[0].Content)
+	})
+
+	t.Run("should not return a single chunk by its id", func(t *testing.T) {
+		store := new(MockDocumentStore)
+		store.On("List", ctx, byParent("")).Return(&DocumentPage{}, nil)
+		store.On("FindByID", ctx, "doc").Return(domain.NewChunk("parent", 0, "chunk", 0, 5), nil)
+
+		_, err := NewGetDocumentHandler(store).Handle(ctx, GetDocumentQuery{ID: "doc"})
+
+		assert.ErrorIs(t, err, domain.ErrDocumentNotFound)
+	})
+}
+
+func TestListDocumentsHandler_Handle(t *testing.T) {
+	ctx := context.Background()
+	first := 0.0
+	firstChunks := RangeFilter{Field: "chunk_index", Min: &first, Max: &first}
+
+	t.Run("should list the first chunk of every document by parent id", func(t *testing.T) {
+		store := new(MockDocumentStore)
+		tags := TagFilter{Tags: []string{"go"}}
+		store.On("List", ctx, ListOptions{Filter: AndFilter{Filters: []Filter{tags, firstChunks}}, Cursor: "c", Limit: DefaultListLimit}).Return(&DocumentPage{
+			Documents:  []domain.Document{*domain.NewChunk("doc", 0, "hello", 0, 5), *domain.NewDocument("legacy", "old")},
+			NextCursor: "d",
+		}, nil)
+
+		result, err := NewListDocumentsHandler(store).Handle(ctx, ListDocumentsQuery{Filter: tags, Cursor: "c"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []ListedDocument{{ID: "doc"}, {ID: "legacy"}}, result.Documents)
+		assert.Equal(t, "d", result.NextCursor)
+	})
+
+	t.Run("should reject a limit above the maximum", func(t *testing.T) {
+		_, err := NewListDocumentsHandler(new(MockDocumentStore)).Handle(ctx, ListDocumentsQuery{Limit: MaxListLimit + 1})
+
+		assert.ErrorIs(t, err, ErrInvalidListQuery)
+	})
 }
@@ -0,0 +1,107 @@
+package app
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/igorrius/go-vector-search/internal/domain"
+)
+
+const (
+	// rerankCandidateFactor is how many candidates per returned hit are fetched for reranking.
+	rerankCandidateFactor = 3
+	// maxRerankCandidates caps the candidate pool sent to the reranker.
+	maxRerankCandidates = 50
+)
+
+// RankedDocument is a document scored by a Reranker.
+type RankedDocument struct {
+	Document domain.Document
+	// Score is the relevance of the document to the query in [0, 1].
+	Score float64
+}
+
+// Reranker reorders candidate documents by their relevance to the query, most relevant first.
+type Reranker interface {
+	Rerank(ctx context.Context, query string, docs []domain.Document) ([]RankedDocument, error)
+}
+
+// LexicalReranker is a deterministic Reranker scoring documents by the share of query terms
+// they contain. Documents with equal scores keep their original order.
+type LexicalReranker struct{}
+
+// NewLexicalReranker creates a new LexicalReranker.
+func NewLexicalReranker() *LexicalReranker {
+	return &LexicalReranker{}
+}
+
+// Rerank scores the documents by term overlap with the query.
+func (r *LexicalReranker) Rerank(_ context.Context, query string, docs []domain.Document) ([]RankedDocument, error) {
+	queryTerms := termSet(query)
+
+	ranked := make([]RankedDocument, len(docs))
+	for i, doc := range docs {
+		ranked[i] = RankedDocument{Document: doc}
+		if len(queryTerms) == 0 {
+			continue
+		}
+		docTerms := termSet(doc.Content)
+		matched := 0
+		for term := range queryTerms {
+			if _, ok := docTerms[term]; ok {
+				matched++
+			}
+		}
+		ranked[i].Score = float64(matched) / float64(len(queryTerms))
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Score > ranked[j].Score
+	})
+	return ranked, nil
+}
+
+// Words returns the lower-cased words and numbers of a text in order. It is the tokenizer of
+// all keyword matching.
+func Words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// termSet returns the distinct words of a text.
+func termSet(text string) map[string]struct{} {
+	set := make(map[string]struct{})
+	for _, word := range Words(text) {
+		set[word] = struct{}{}
+	}
+	return set
+}
+
+// rerankHits reorders the hits by the reranker's scores. Hits the reranker did not return are dropped.
+func rerankHits(ctx context.Context, reranker Reranker, query string, hits []SearchHit) ([]SearchHit, error) {
+	docs := make([]domain.Document, len(hits))
+	byID := make(map[string]SearchHit, len(hits))
+	for i, hit := range hits {
+		docs[i] = hit.Document
+		byID[hit.Document.ID] = hit
+	}
+
+	ranked, err := reranker.Rerank(ctx, query, docs)
+	if err != nil {
+		return nil, err
+	}
+
+	reranked := make([]SearchHit, 0, len(ranked))
+	for _, r := range ranked {
+		hit, ok := byID[r.Document.ID]
+		if !ok {
+			continue
+		}
+		hit.RerankScore = r.Score
+		reranked = append(reranked, hit)
+	}
+	return reranked, nil
+}
@@ -0,0 +1,104 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/igorrius/go-vector-search/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLexicalReranker_Rerank(t *testing.T) {
+	docs := []domain.Document{
+		{ID: "unrelated", Content: "Typesense stores vectors."},
+		{ID: "partial", Content: "Go has goroutines."},
+		{ID: "full", Content: "Goroutines and channels in Go!"},
+	}
+
+	t.Run("should order documents by query term overlap", func(t *testing.T) {
+		// Act
+		ranked, err := NewLexicalReranker().Rerank(context.Background(), "go channels", docs)
+
+		// Assert
+		require.NoError(t, err)
+		require.Len(t, ranked, 3)
+		assert.Equal(t, "full", ranked[0].Document.ID)
+		assert.Equal(t, 1.0, ranked[0].Score)
+		assert.Equal(t, "partial", ranked[1].Document.ID)
+		assert.Equal(t, 0.5, ranked[1].Score)
+		assert.Equal(t, "unrelated", ranked[2].Document.ID)
+		assert.Zero(t, ranked[2].Score)
+	})
+
+	t.Run("should keep the original order for an empty query", func(t *testing.T) {
+		// Act
+		ranked, err := NewLexicalReranker().Rerank(context.Background(), " ", docs)
+
+		// Assert
+		require.NoError(t, err)
+		for i, r := range ranked {
+			assert.Equal(t, docs[i].ID, r.Document.ID)
+		}
+	})
+}
+
+type failingReranker struct{}
+
+func (failingReranker) Rerank(context.Context, string, []domain.Document) ([]RankedDocument, error) {
+	return nil, errors.New("model unavailable")
+}
+
+func TestSearchDocumentsHandler_Handle_Rerank(t *testing.T) {
+	ctx := context.Background()
+	embedding := []float32{1, 0}
+	candidates := []SearchHit{
+		{Document: domain.Document{ID: "a", Content: "vector databases"}, Score: 0.9},
+		{Document: domain.Document{ID: "b", Content: "hybrid keyword search"}, Score: 0.8},
+		{Document: domain.Document{ID: "c", Content: "keyword search engines"}, Score: 0.7},
+	}
+
+	t.Run("should over-fetch, rerank and truncate before summarizing", func(t *testing.T) {
+		// Arrange
+		embedder := new(MockEmbeddingGenerator)
+		store := new(MockVectorStore)
+		summarizer := new(MockSummarizer)
+		embedder.On("Generate", ctx, "keyword search").Return(embedding, nil)
+		store.On("Search", ctx, SearchOptions{Query: "keyword search", Embedding: embedding, Limit: 6}).Return(candidates, nil)
+		summarizer.On("Summarize", ctx, []string{"keyword search engines"}).Return("summary", nil)
+
+		handler := NewSearchDocumentsHandler(embedder, store, summarizer, NewLexicalReranker())
+
+		// Act
+		result, err := handler.Handle(ctx, SearchDocumentsQuery{Query: "keyword search", Limit: 1, Offset: 1})
+
+		// Assert
+		require.NoError(t, err)
+		require.Len(t, result.Sources, 1)
+		assert.Equal(t, "c", result.Sources[0].DocumentID)
+		assert.Equal(t, 1.0, result.Sources[0].RerankScore)
+		assert.Equal(t, 0.7, result.Sources[0].Score)
+		store.AssertExpectations(t)
+		summarizer.AssertExpectations(t)
+	})
+
+	t.Run("should fail when the reranker fails", func(t *testing.T) {
+		// Arrange
+		embedder := new(MockEmbeddingGenerator)
+		store := new(MockVectorStore)
+		summarizer := new(MockSummarizer)
+		embedder.On("Generate", ctx, "q").Return(embedding, nil)
+		store.On("Search", ctx, mock.Anything).Return(candidates, nil)
+
+		handler := NewSearchDocumentsHandler(embedder, store, summarizer, failingReranker{})
+
+		// Act
+		_, err := handler.Handle(ctx, SearchDocumentsQuery{Query: "q"})
+
+		// Assert
+		assert.ErrorContains(t, err, "model unavailable")
+		summarizer.AssertNotCalled(t, "Summarize", mock.Anything, mock.Anything)
+	})
+}
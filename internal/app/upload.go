@@ -0,0 +1,245 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/igorrius/go-vector-search/internal/domain"
+)
+
+// DefaultMaxUploadSize is the default limit of the body of an index request.
+const DefaultMaxUploadSize = 32 << 20
+
+// UploadConfig configures the document uploads of HTTPHandlers.
+type UploadConfig struct {
+	// MaxBodySize limits the body of an index request in bytes; zero means
+	// DefaultMaxUploadSize. Larger requests are rejected with 413.
+	MaxBodySize int64
+}
+
+// UploadResult lists the documents created from the files of a multipart upload, in the order
+// of the files.
+type UploadResult struct {
+	Files []UploadedFileResult
+}
+
+// UploadedFileResult is the ingestion job of an uploaded file.
+type UploadedFileResult struct {
+	Filename string
+	JobResult
+}
+
+// uploadedFile is a file part of a multipart upload.
+type uploadedFile struct {
+	// field is the form field name of the part.
+	field       string
+	filename    string
+	contentType string
+	// path is the temporary file the part was spooled to.
+	path string
+}
+
+// upload is a multipart request read by readUpload. Close deletes its spooled files.
+type upload struct {
+	files  []uploadedFile
+	values url.Values
+	dir    string
+}
+
+// readUpload reads the parts of a multipart request as they arrive. Every part with a file name
+// is a file, the others are form fields. Files are spooled to temporary files, so that the raw
+// files are read one at a time when the commands are built; the extracted texts of all files
+// are held in memory together until their jobs are persisted.
+func readUpload(r *http.Request) (*upload, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp("", "upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create upload directory: %w", err)
+	}
+
+	u := &upload{values: make(url.Values), dir: dir}
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return u, nil
+		}
+		if err != nil {
+			u.Close()
+			return nil, err
+		}
+
+		if part.FileName() == "" {
+			data, err := io.ReadAll(part)
+			part.Close()
+			if err != nil {
+				u.Close()
+				return nil, err
+			}
+			u.values.Add(part.FormName(), string(data))
+			continue
+		}
+		err = u.spool(part)
+		part.Close()
+		if err != nil {
+			u.Close()
+			return nil, err
+		}
+	}
+}
+
+// spool copies the file part to a temporary file.
+func (u *upload) spool(part *multipart.Part) error {
+	file, err := os.CreateTemp(u.dir, "part-*")
+	if err != nil {
+		return fmt.Errorf("failed to create upload file: %w", err)
+	}
+	_, err = io.Copy(file, part)
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to write upload file: %w", closeErr)
+	}
+	if err != nil {
+		return err
+	}
+	u.files = append(u.files, uploadedFile{
+		field:       part.FormName(),
+		filename:    part.FileName(),
+		contentType: part.Header.Get("Content-Type"),
+		path:        file.Name(),
+	})
+	return nil
+}
+
+// Close deletes the spooled files.
+func (u *upload) Close() error {
+	return os.RemoveAll(u.dir)
+}
+
+// uploadCommands builds the index command of every uploaded file. The form fields apply to all
+// files, except for fields prefixed with the field name of a file, e.g. "report.id", which apply
+// to the files of that field only and replace the shared field of the same name. All files are
+// checked before any is indexed, and an ID given to more than one file is an error.
+func (h *HTTPHandlers) uploadCommands(ctx context.Context, files []uploadedFile, values url.Values) ([]IndexDocumentCommand, error) {
+	fields := make(map[string]bool)
+	for _, file := range files {
+		fields[file.field] = true
+	}
+
+	var cmds []IndexDocumentCommand
+	ids := make(map[string]string)
+	for _, file := range files {
+		form := fileValues(values, fields, file.field)
+		cmd, err := h.uploadCommand(ctx, file, form)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file.filename, err)
+		}
+		if cmd.ID == "" {
+			cmd.ID = uuid.New().String()
+		} else if other, ok := ids[cmd.ID]; ok {
+			return nil, fmt.Errorf("document ID %q is given to both %s and %s", cmd.ID, other, file.filename)
+		}
+		ids[cmd.ID] = file.filename
+		cmds = append(cmds, cmd)
+	}
+	return cmds, nil
+}
+
+func (h *HTTPHandlers) uploadCommand(ctx context.Context, file uploadedFile, form url.Values) (IndexDocumentCommand, error) {
+	policy, err := requestDuplicatePolicy(form.Get("on_duplicate"))
+	if err != nil {
+		return IndexDocumentCommand{}, err
+	}
+	data, err := os.ReadFile(file.path)
+	if err != nil {
+		return IndexDocumentCommand{}, fmt.Errorf("failed to read upload file: %w", err)
+	}
+	cmd := IndexDocumentCommand{
+		ID:          form.Get("id"),
+		Content:     string(data),
+		Metadata:    metadataFromForm(form),
+		OnDuplicate: policy,
+	}
+	if cmd.Metadata.MIMEType == "" {
+		cmd.Metadata.MIMEType = file.contentType
+	}
+
+	if h.extractor != nil {
+		text, err := h.extractor.Extract(ctx, data, file.filename, cmd.Metadata.MIMEType)
+		if err != nil {
+			return IndexDocumentCommand{}, err
+		}
+		cmd.Content = text.Content
+		cmd.Metadata.MIMEType = text.MIMEType
+		if cmd.Metadata.Title == "" {
+			cmd.Metadata.Title = text.Title
+		}
+	}
+	if cmd.Metadata.Title == "" {
+		cmd.Metadata.Title = file.filename
+	}
+
+	if strings.TrimSpace(cmd.Content) == "" {
+		return IndexDocumentCommand{}, ErrEmptyDocument
+	}
+	return cmd, nil
+}
+
+// fileValues returns the form fields of the files of a field: the shared fields, replaced by the
+// fields prefixed with the field name.
+func fileValues(values url.Values, fields map[string]bool, field string) url.Values {
+	form := make(url.Values)
+	for key, v := range values {
+		if prefix, _, ok := strings.Cut(key, "."); !ok || !fields[prefix] {
+			form[key] = v
+		}
+	}
+	for key, v := range values {
+		if name, ok := strings.CutPrefix(key, field+"."); ok && name != "" {
+			form[name] = v
+		}
+	}
+	return form
+}
+
+// metadataFromForm reads the document metadata from form fields. Tags may be given as repeated
+// "tags" fields or as a comma-separated list.
+func metadataFromForm(form url.Values) domain.Metadata {
+	metadata := domain.Metadata{
+		Title:     form.Get("title"),
+		Summary:   form.Get("summary"),
+		SourceURI: form.Get("source_uri"),
+		MIMEType:  form.Get("mime_type"),
+	}
+
+	for _, value := range form["tags"] {
+		for _, tag := range strings.Split(value, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				metadata.Tags = append(metadata.Tags, tag)
+			}
+		}
+	}
+
+	for key, values := range form {
+		name, ok := strings.CutPrefix(key, AttributeFieldPrefix)
+		if !ok || name == "" || len(values) == 0 {
+			continue
+		}
+		if metadata.Attributes == nil {
+			metadata.Attributes = make(map[string]string)
+		}
+		metadata.Attributes[name] = values[0]
+	}
+
+	return metadata
+}
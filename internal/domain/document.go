@@ -1,12 +1,26 @@
 package domain
 
-import "context"
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrDocumentNotFound is returned by a DocumentRepository when no document has the requested ID.
+var ErrDocumentNotFound = errors.New("document not found")
 
 // Document is the aggregate root for our domain.
+// A document is a chunk split from a larger uploaded file; ParentID identifies that file and
+// StartOffset/EndOffset locate the chunk in it, counted in characters.
 type Document struct {
-	ID        string
-	Content   string
-	Embedding []float32
+	ID          string
+	ParentID    string
+	ChunkIndex  int
+	StartOffset int
+	EndOffset   int
+	Content     string
+	Embedding   []float32
+	Metadata    Metadata
 }
 
 // NewDocument creates a new Document.
@@ -17,13 +31,37 @@ func NewDocument(id, content string) *Document {
 	}
 }
 
+// NewChunk creates a new Document for the chunk with the given ordinal of a parent document.
+func NewChunk(parentID string, index int, content string, start, end int) *Document {
+	return &Document{
+		ID:          ChunkID(parentID, index),
+		ParentID:    parentID,
+		ChunkIndex:  index,
+		StartOffset: start,
+		EndOffset:   end,
+		Content:     content,
+	}
+}
+
+// ChunkID returns the ID of the chunk with the given ordinal of a parent document.
+func ChunkID(parentID string, index int) string {
+	return fmt.Sprintf("%s-chunk-%d", parentID, index)
+}
+
 // SetEmbedding sets the vector embedding for the document.
 func (d *Document) SetEmbedding(embedding []float32) {
 	d.Embedding = embedding
 }
 
+// SetMetadata sets the metadata of the document.
+func (d *Document) SetMetadata(metadata Metadata) {
+	d.Metadata = metadata
+}
+
 // DocumentRepository defines the contract for storing and retrieving Document aggregates.
 type DocumentRepository interface {
 	Save(ctx context.Context, doc *Document) error
 	FindByID(ctx context.Context, id string) (*Document, error)
+	// Delete removes the document with the given ID, returning ErrDocumentNotFound if there is none.
+	Delete(ctx context.Context, id string) error
 }
@@ -0,0 +1,19 @@
+package domain
+
+import "time"
+
+// Metadata describes the uploaded file a document was split from.
+// All chunks of a file share the same metadata.
+type Metadata struct {
+	Title     string
+	Summary   string
+	SourceURI string
+	MIMEType  string
+	Tags      []string
+	// ContentHash is a hash of the normalized content of the file, shared by identical uploads.
+	ContentHash string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	// Attributes holds arbitrary user-defined key/value pairs.
+	Attributes map[string]string
+}
@@ -0,0 +1,55 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/option"
+
+	"github.com/igorrius/go-vector-search/internal/app"
+)
+
+// GoogleAnswerer answers questions from passages using the Google AI API.
+type GoogleAnswerer struct {
+	client *genai.GenerativeModel
+}
+
+// NewGoogleAnswerer creates a new GoogleAnswerer.
+func NewGoogleAnswerer(ctx context.Context, apiKey string, opts ...option.ClientOption) (*GoogleAnswerer, error) {
+	opts = append(opts, option.WithAPIKey(apiKey))
+	client, err := genai.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new genai client: %w", err)
+	}
+
+	return &GoogleAnswerer{
+		client: client.GenerativeModel("gemini-pro"),
+	}, nil
+}
+
+// Answer answers the question from the passages, citing them as [n].
+func (a *GoogleAnswerer) Answer(ctx context.Context, question string, passages []string) (string, error) {
+	return generateText(ctx, a.client, answerPrompt(question, passages))
+}
+
+// AnswerStream answers the question from the passages, yielding the answer as it is generated.
+func (a *GoogleAnswerer) AnswerStream(ctx context.Context, question string, passages []string, yield func(delta string) error) error {
+	return streamText(ctx, a.client, answerPrompt(question, passages), yield)
+}
+
+// answerPrompt grounds the question in the numbered passages and asks for citations.
+func answerPrompt(question string, passages []string) string {
+	var sb strings.Builder
+	sb.WriteString("Answer the question using only the numbered sources below. ")
+	sb.WriteString("Cite the sources supporting each statement by their number in square brackets, e.g. [1] or [2][3]. ")
+	sb.WriteString("If the sources do not contain the answer, say so instead of guessing.\n\nSources:\n")
+	for i, passage := range passages {
+		fmt.Fprintf(&sb, "\n[%d] %s\n", i+1, strings.TrimSpace(passage))
+	}
+	fmt.Fprintf(&sb, "\nQuestion: %s\nAnswer:", strings.TrimSpace(question))
+	return sb.String()
+}
+
+var _ app.StreamingAnswerer = (*GoogleAnswerer)(nil)
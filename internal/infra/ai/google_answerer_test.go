@@ -0,0 +1,44 @@
+package ai
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/option"
+)
+
+func TestGoogleAnswerer_Answer(t *testing.T) {
+	t.Run("should return the answer of the model", func(t *testing.T) {
+		// Arrange
+		mockResp := &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{"candidates":[{"content":{"parts":[{"text":"Go is fast [1]."}]}}]}`)),
+		}
+		httpClient := &http.Client{Transport: &mockTransport{response: mockResp}}
+		answerer, err := NewGoogleAnswerer(context.Background(), "fake-api-key", option.WithHTTPClient(httpClient))
+		require.NoError(t, err)
+
+		// Act
+		answer, err := answerer.Answer(context.Background(), "Is Go fast?", []string{"Go compiles fast."})
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, "Go is fast [1].", answer)
+	})
+}
+
+func TestAnswerPrompt(t *testing.T) {
+	// Act
+	prompt := answerPrompt(" Is Go fast? ", []string{"Go compiles fast.\n", "Rust has no GC."})
+
+	// Assert
+	assert.Contains(t, prompt, "[1] Go compiles fast.\n")
+	assert.Contains(t, prompt, "[2] Rust has no GC.\n")
+	assert.Contains(t, prompt, "square brackets")
+	assert.True(t, strings.HasSuffix(prompt, "Question: Is Go fast?\nAnswer:"))
+}
@@ -5,9 +5,19 @@ import (
 	"fmt"
 
 	"github.com/google/generative-ai-go/genai"
+	"github.com/igorrius/go-vector-search/internal/app"
 	"google.golang.org/api/option"
 )
 
+const (
+	// googleEmbeddingModel is the embedding model used by GoogleEmbeddingGenerator.
+	googleEmbeddingModel = "embedding-001"
+	// googleEmbeddingDimension is the length of the vectors returned by googleEmbeddingModel.
+	googleEmbeddingDimension = 768
+	// googleMaxEmbeddingBatch is the largest number of contents the API embeds in one request.
+	googleMaxEmbeddingBatch = 100
+)
+
 // GoogleEmbeddingGenerator generates vector embeddings using the Google AI API.
 type GoogleEmbeddingGenerator struct {
 	client *genai.EmbeddingModel
@@ -22,7 +32,7 @@ func NewGoogleEmbeddingGenerator(ctx context.Context, apiKey string, opts ...opt
 	}
 
 	return &GoogleEmbeddingGenerator{
-		client: client.EmbeddingModel("embedding-001"),
+		client: client.EmbeddingModel(googleEmbeddingModel),
 	}, nil
 }
 
@@ -39,3 +49,45 @@ func (g *GoogleEmbeddingGenerator) Generate(ctx context.Context, content string)
 
 	return res.Embedding.Values, nil
 }
+
+// GenerateBatch generates the vector embeddings of the contents in order. Contents beyond the
+// maximum batch size of the API are sent in further requests.
+func (g *GoogleEmbeddingGenerator) GenerateBatch(ctx context.Context, contents []string) ([][]float32, error) {
+	embeddings := make([][]float32, 0, len(contents))
+	for start := 0; start < len(contents); start += googleMaxEmbeddingBatch {
+		part := contents[start:min(start+googleMaxEmbeddingBatch, len(contents))]
+
+		batch := g.client.NewBatch()
+		for _, content := range part {
+			batch.AddContent(genai.Text(content))
+		}
+		res, err := g.client.BatchEmbedContents(ctx, batch)
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed batch: %w", err)
+		}
+
+		if res == nil || len(res.Embeddings) != len(part) {
+			return nil, fmt.Errorf("received a batch of the wrong size from the API")
+		}
+		for _, embedding := range res.Embeddings {
+			if embedding == nil {
+				return nil, fmt.Errorf("received an empty embedding from the API")
+			}
+			embeddings = append(embeddings, embedding.Values)
+		}
+	}
+
+	return embeddings, nil
+}
+
+// ModelName returns the name of the embedding model.
+func (g *GoogleEmbeddingGenerator) ModelName() string {
+	return googleEmbeddingModel
+}
+
+// Dimension returns the length of the vectors produced by the model.
+func (g *GoogleEmbeddingGenerator) Dimension() int {
+	return googleEmbeddingDimension
+}
+
+var _ app.BatchEmbeddingGenerator = (*GoogleEmbeddingGenerator)(nil)
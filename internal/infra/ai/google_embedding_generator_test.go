@@ -2,12 +2,15 @@ package ai
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"google.golang.org/api/option"
 )
 
@@ -33,3 +36,53 @@ func TestGoogleEmbeddingGenerator_Generate(t *testing.T) {
 		assert.Equal(t, []float32{0.1, 0.2, 0.3}, embedding)
 	})
 }
+
+// batchTransport answers batch embedding requests with one embedding per requested content,
+// whose single value is the position of the content in its batch, and records the batch sizes.
+type batchTransport struct {
+	sizes []int
+}
+
+func (t *batchTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body struct {
+		Requests []json.RawMessage `json:"requests"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	t.sizes = append(t.sizes, len(body.Requests))
+
+	embeddings := make([]string, len(body.Requests))
+	for i := range embeddings {
+		embeddings[i] = fmt.Sprintf(`{"values":[%d]}`, i)
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"embeddings":[` + strings.Join(embeddings, ",") + `]}`)),
+	}, nil
+}
+
+func TestGoogleEmbeddingGenerator_GenerateBatch(t *testing.T) {
+	t.Run("should split contents into batches of the maximum size", func(t *testing.T) {
+		// Arrange
+		transport := &batchTransport{}
+		opts := option.WithHTTPClient(&http.Client{Transport: transport})
+		generator, err := NewGoogleEmbeddingGenerator(context.Background(), "fake-api-key", opts)
+		require.NoError(t, err)
+		contents := make([]string, googleMaxEmbeddingBatch+5)
+		for i := range contents {
+			contents[i] = fmt.Sprintf("content %d", i)
+		}
+
+		// Act
+		embeddings, err := generator.GenerateBatch(context.Background(), contents)
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, []int{googleMaxEmbeddingBatch, 5}, transport.sizes)
+		require.Len(t, embeddings, len(contents))
+		assert.Equal(t, []float32{99}, embeddings[99])
+		assert.Equal(t, []float32{4}, embeddings[googleMaxEmbeddingBatch+4])
+	})
+}
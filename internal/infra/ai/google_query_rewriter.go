@@ -0,0 +1,62 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/option"
+
+	"github.com/igorrius/go-vector-search/internal/app"
+)
+
+// GoogleQueryRewriter rewrites follow-up questions into standalone queries using the Google AI API.
+type GoogleQueryRewriter struct {
+	client *genai.GenerativeModel
+}
+
+// NewGoogleQueryRewriter creates a new GoogleQueryRewriter.
+func NewGoogleQueryRewriter(ctx context.Context, apiKey string, opts ...option.ClientOption) (*GoogleQueryRewriter, error) {
+	opts = append(opts, option.WithAPIKey(apiKey))
+	client, err := genai.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new genai client: %w", err)
+	}
+
+	return &GoogleQueryRewriter{
+		client: client.GenerativeModel("gemini-pro"),
+	}, nil
+}
+
+// Rewrite rewrites the question into a standalone search query.
+func (r *GoogleQueryRewriter) Rewrite(ctx context.Context, history []app.Message, question string) (string, error) {
+	query, err := generateText(ctx, r.client, rewritePrompt(history, question))
+	if err != nil {
+		return "", err
+	}
+	return strings.Trim(strings.TrimSpace(query), `"`), nil
+}
+
+// rewritePrompt lists the history with the titles of the sources of every answer, so that
+// references such as "the second one" can be resolved.
+func rewritePrompt(history []app.Message, question string) string {
+	var sb strings.Builder
+	sb.WriteString("Rewrite the follow-up question into a standalone search query that can be understood without the conversation. ")
+	sb.WriteString("Resolve pronouns and references such as \"the second one\" using the conversation and the sources listed with its answers. ")
+	sb.WriteString("Return only the query.\n\nConversation:\n")
+	for _, m := range history {
+		role := "User"
+		if m.Role == app.RoleAssistant {
+			role = "Assistant"
+		}
+		fmt.Fprintf(&sb, "%s: %s\n", role, strings.TrimSpace(m.Content))
+		for i, source := range m.Sources {
+			fmt.Fprintf(&sb, "  Source [%d]: %s\n", i+1, source.Label())
+		}
+	}
+	fmt.Fprintf(&sb, "\nFollow-up question: %s\nStandalone query:", strings.TrimSpace(question))
+	return sb.String()
+}
+
+var _ app.QueryRewriter = (*GoogleQueryRewriter)(nil)
@@ -0,0 +1,54 @@
+package ai
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/igorrius/go-vector-search/internal/app"
+	"github.com/igorrius/go-vector-search/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/option"
+)
+
+func TestGoogleQueryRewriter_Rewrite(t *testing.T) {
+	t.Run("should return the query of the model without quotes", func(t *testing.T) {
+		// Arrange
+		mockResp := &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{"candidates":[{"content":{"parts":[{"text":"\"How is Typesense configured?\"\n"}]}}]}`)),
+		}
+		httpClient := &http.Client{Transport: &mockTransport{response: mockResp}}
+		rewriter, err := NewGoogleQueryRewriter(context.Background(), "fake-api-key", option.WithHTTPClient(httpClient))
+		require.NoError(t, err)
+
+		// Act
+		query, err := rewriter.Rewrite(context.Background(), []app.Message{{Role: app.RoleUser, Content: "Which stores?"}}, "And the second one?")
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, "How is Typesense configured?", query)
+	})
+}
+
+func TestRewritePrompt(t *testing.T) {
+	// Arrange
+	history := []app.Message{
+		{Role: app.RoleUser, Content: "Which stores are supported?"},
+		{Role: app.RoleAssistant, Content: "Memory [1] and Typesense [2].", Sources: []app.Source{
+			{DocumentID: "memory#0", Metadata: domain.Metadata{Title: "Memory store"}},
+			{DocumentID: "typesense#0"},
+		}},
+	}
+
+	// Act
+	prompt := rewritePrompt(history, "How is the second one configured?")
+
+	// Assert
+	assert.Contains(t, prompt, "User: Which stores are supported?\nAssistant: Memory [1] and Typesense [2].\n")
+	assert.Contains(t, prompt, "  Source [1]: Memory store\n  Source [2]: typesense#0\n")
+	assert.True(t, strings.HasSuffix(prompt, "Follow-up question: How is the second one configured?\nStandalone query:"))
+}
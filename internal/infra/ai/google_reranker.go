@@ -0,0 +1,108 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+	"github.com/igorrius/go-vector-search/internal/app"
+	"github.com/igorrius/go-vector-search/internal/domain"
+	"google.golang.org/api/option"
+)
+
+// maxRelevanceGrade is the top of the relevance scale the model grades passages on.
+const maxRelevanceGrade = 10
+
+// GoogleReranker reranks documents by relevance grades assigned by a Google generative model.
+type GoogleReranker struct {
+	client *genai.GenerativeModel
+}
+
+// NewGoogleReranker creates a new GoogleReranker.
+func NewGoogleReranker(ctx context.Context, apiKey string, opts ...option.ClientOption) (*GoogleReranker, error) {
+	opts = append(opts, option.WithAPIKey(apiKey))
+	client, err := genai.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new genai client: %w", err)
+	}
+
+	model := client.GenerativeModel("gemini-pro")
+	model.SetTemperature(0)
+	return &GoogleReranker{
+		client: model,
+	}, nil
+}
+
+// Rerank asks the model to grade every document against the query and orders them by grade.
+func (r *GoogleReranker) Rerank(ctx context.Context, query string, docs []domain.Document) ([]app.RankedDocument, error) {
+	if len(docs) == 0 {
+		return nil, nil
+	}
+
+	resp, err := r.client.GenerateContent(ctx, genai.Text(rerankPrompt(query, docs)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate content: %w", err)
+	}
+
+	var text strings.Builder
+	for _, cand := range resp.Candidates {
+		if cand.Content != nil {
+			for _, part := range cand.Content.Parts {
+				if txt, ok := part.(genai.Text); ok {
+					text.WriteString(string(txt))
+				}
+			}
+		}
+	}
+
+	grades, err := parseGrades(text.String(), len(docs))
+	if err != nil {
+		return nil, err
+	}
+
+	ranked := make([]app.RankedDocument, len(docs))
+	for i, doc := range docs {
+		ranked[i] = app.RankedDocument{Document: doc, Score: grades[i] / maxRelevanceGrade}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Score > ranked[j].Score
+	})
+	return ranked, nil
+}
+
+func rerankPrompt(query string, docs []domain.Document) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Grade how relevant each passage is to the query on a scale from 0 (irrelevant) to %d (fully answers it).\n", maxRelevanceGrade)
+	fmt.Fprintf(&b, "Respond with only a JSON array of %d numbers, one grade per passage in the given order.\n\n", len(docs))
+	fmt.Fprintf(&b, "Query: %s\n", query)
+	for i, doc := range docs {
+		fmt.Fprintf(&b, "\nPassage %d:\n%s\n", i+1, doc.Content)
+	}
+	return b.String()
+}
+
+// parseGrades extracts the JSON array of grades from the model response, tolerating text or
+// code fences around it, and clamps the grades to the relevance scale.
+func parseGrades(text string, n int) ([]float64, error) {
+	start, end := strings.Index(text, "["), strings.LastIndex(text, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("unexpected response format from the API, no grades found")
+	}
+
+	var grades []float64
+	if err := json.Unmarshal([]byte(text[start:end+1]), &grades); err != nil {
+		return nil, fmt.Errorf("failed to parse grades: %w", err)
+	}
+	if len(grades) != n {
+		return nil, fmt.Errorf("received %d grades for %d documents", len(grades), n)
+	}
+	for i, g := range grades {
+		grades[i] = min(max(g, 0), maxRelevanceGrade)
+	}
+	return grades, nil
+}
+
+var _ app.Reranker = (*GoogleReranker)(nil)
@@ -0,0 +1,64 @@
+package ai
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/igorrius/go-vector-search/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/option"
+)
+
+func newTestReranker(t *testing.T, body string) *GoogleReranker {
+	t.Helper()
+	mockResp := &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+	httpClient := &http.Client{
+		Transport: &mockTransport{response: mockResp},
+	}
+	reranker, err := NewGoogleReranker(context.Background(), "fake-api-key", option.WithHTTPClient(httpClient))
+	require.NoError(t, err)
+	return reranker
+}
+
+func TestGoogleReranker_Rerank(t *testing.T) {
+	docs := []domain.Document{
+		{ID: "doc1", Content: "first"},
+		{ID: "doc2", Content: "second"},
+		{ID: "doc3", Content: "third"},
+	}
+
+	t.Run("should order documents by the grades of the model", func(t *testing.T) {
+		// Arrange
+		reranker := newTestReranker(t, `{"candidates":[{"content":{"parts":[{"text":"`+"```json\\n[2, 9, 12]\\n```"+`"}]}}]}`)
+
+		// Act
+		ranked, err := reranker.Rerank(context.Background(), "query", docs)
+
+		// Assert
+		require.NoError(t, err)
+		require.Len(t, ranked, 3)
+		assert.Equal(t, "doc3", ranked[0].Document.ID)
+		assert.Equal(t, 1.0, ranked[0].Score)
+		assert.Equal(t, "doc2", ranked[1].Document.ID)
+		assert.InDelta(t, 0.9, ranked[1].Score, 1e-9)
+		assert.Equal(t, "doc1", ranked[2].Document.ID)
+	})
+
+	t.Run("should fail when the number of grades does not match", func(t *testing.T) {
+		// Arrange
+		reranker := newTestReranker(t, `{"candidates":[{"content":{"parts":[{"text":"[5, 5]"}]}}]}`)
+
+		// Act
+		_, err := reranker.Rerank(context.Background(), "query", docs)
+
+		// Assert
+		assert.ErrorContains(t, err, "received 2 grades for 3 documents")
+	})
+}
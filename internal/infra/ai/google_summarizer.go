@@ -2,11 +2,15 @@ package ai
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
+
+	"github.com/igorrius/go-vector-search/internal/app"
 )
 
 // GoogleSummarizer summarizes text using the Google AI API.
@@ -29,8 +33,21 @@ func NewGoogleSummarizer(ctx context.Context, apiKey string, opts ...option.Clie
 
 // Summarize summarizes the given content.
 func (s *GoogleSummarizer) Summarize(ctx context.Context, content []string) (string, error) {
-	prompt := fmt.Sprintf("Provide a concise summary of the following documents:\n\n%s", strings.Join(content, "\n---\n"))
-	resp, err := s.client.GenerateContent(ctx, genai.Text(prompt))
+	return generateText(ctx, s.client, summaryPrompt(content))
+}
+
+// SummarizeStream summarizes the given content, yielding the summary as it is generated.
+func (s *GoogleSummarizer) SummarizeStream(ctx context.Context, content []string, yield func(delta string) error) error {
+	return streamText(ctx, s.client, summaryPrompt(content), yield)
+}
+
+func summaryPrompt(content []string) string {
+	return fmt.Sprintf("Provide a concise summary of the following documents:\n\n%s", strings.Join(content, "\n---\n"))
+}
+
+// generateText generates content for a prompt and returns the text of its candidates.
+func generateText(ctx context.Context, model *genai.GenerativeModel, prompt string) (string, error) {
+	resp, err := model.GenerateContent(ctx, genai.Text(prompt))
 	if err != nil {
 		return "", fmt.Errorf("failed to generate content: %w", err)
 	}
@@ -39,20 +56,57 @@ func (s *GoogleSummarizer) Summarize(ctx context.Context, content []string) (str
 		return "", fmt.Errorf("received an empty response from the API")
 	}
 
-	var summary strings.Builder
+	var text strings.Builder
 	for _, cand := range resp.Candidates {
 		if cand.Content != nil {
 			for _, part := range cand.Content.Parts {
 				if txt, ok := part.(genai.Text); ok {
-					summary.WriteString(string(txt))
+					text.WriteString(string(txt))
 				}
 			}
 		}
 	}
 
-	if summary.Len() == 0 {
+	if text.Len() == 0 {
 		return "", fmt.Errorf("unexpected response format from the API, no text part found")
 	}
 
-	return summary.String(), nil
+	return text.String(), nil
 }
+
+// streamText generates content for a prompt, yielding the text of the candidates as it arrives.
+// The request is cancelled with the context.
+func streamText(ctx context.Context, model *genai.GenerativeModel, prompt string, yield func(delta string) error) error {
+	iter := model.GenerateContentStream(ctx, genai.Text(prompt))
+	streamed := false
+	for {
+		resp, err := iter.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to generate content: %w", err)
+		}
+
+		for _, cand := range resp.Candidates {
+			if cand.Content == nil {
+				continue
+			}
+			for _, part := range cand.Content.Parts {
+				if txt, ok := part.(genai.Text); ok && txt != "" {
+					streamed = true
+					if err := yield(string(txt)); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+
+	if !streamed {
+		return fmt.Errorf("unexpected response format from the API, no text part found")
+	}
+	return nil
+}
+
+var _ app.StreamingSummarizer = (*GoogleSummarizer)(nil)
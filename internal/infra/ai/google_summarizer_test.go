@@ -2,6 +2,7 @@ package ai
 
 import (
 	"context"
+	"errors"
 	"io"
 	"net/http"
 	"strings"
@@ -33,3 +34,30 @@ func TestGoogleSummarizer_Summarize(t *testing.T) {
 		assert.Equal(t, "This is a summary.", summary)
 	})
 }
+
+func TestGoogleSummarizer_SummarizeStream(t *testing.T) {
+	t.Run("should yield the summary as it arrives and stop when yield fails", func(t *testing.T) {
+		// Arrange
+		mockResp := &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`[{"candidates":[{"content":{"parts":[{"text":"This is "}]}}]},{"candidates":[{"content":{"parts":[{"text":"a summary."}]}}]}]`)),
+		}
+		httpClient := &http.Client{
+			Transport: &mockTransport{response: mockResp},
+		}
+		summarizer, err := NewGoogleSummarizer(context.Background(), "fake-api-key", option.WithHTTPClient(httpClient))
+		assert.NoError(t, err)
+		errStop := errors.New("client gone")
+
+		// Act
+		var deltas []string
+		err = summarizer.SummarizeStream(context.Background(), []string{"doc1"}, func(delta string) error {
+			deltas = append(deltas, delta)
+			return errStop
+		})
+
+		// Assert
+		assert.ErrorIs(t, err, errStop)
+		assert.Equal(t, []string{"This is "}, deltas)
+	})
+}
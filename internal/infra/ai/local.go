@@ -0,0 +1,221 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/igorrius/go-vector-search/internal/app"
+)
+
+const (
+	// DefaultLocalEmbeddingDimension is the default length of the vectors of LocalEmbeddingGenerator.
+	DefaultLocalEmbeddingDimension = 256
+
+	// localSummarySentences is the number of sentences of a LocalSummarizer summary.
+	localSummarySentences = 3
+	// localAnswerSentences is the number of cited sentences of a LocalAnswerer answer.
+	localAnswerSentences = 2
+)
+
+// LocalEmbeddingGenerator embeds texts without an external service by hashing their words and
+// word pairs into a fixed number of dimensions. Texts sharing words are close, which suits local
+// development and tests but does not capture meaning.
+type LocalEmbeddingGenerator struct {
+	dim int
+}
+
+// NewLocalEmbeddingGenerator creates a new LocalEmbeddingGenerator producing vectors of the given
+// length, DefaultLocalEmbeddingDimension if it is not positive.
+func NewLocalEmbeddingGenerator(dim int) *LocalEmbeddingGenerator {
+	if dim <= 0 {
+		dim = DefaultLocalEmbeddingDimension
+	}
+	return &LocalEmbeddingGenerator{dim: dim}
+}
+
+// Generate returns the normalized hashed embedding of the content; zero for content without words.
+func (g *LocalEmbeddingGenerator) Generate(_ context.Context, content string) ([]float32, error) {
+	embedding := make([]float32, g.dim)
+	terms := app.Words(content)
+	for i, term := range terms {
+		g.add(embedding, term)
+		if i > 0 {
+			g.add(embedding, terms[i-1]+" "+term)
+		}
+	}
+
+	var norm float64
+	for _, v := range embedding {
+		norm += float64(v) * float64(v)
+	}
+	if norm > 0 {
+		norm = math.Sqrt(norm)
+		for i := range embedding {
+			embedding[i] = float32(float64(embedding[i]) / norm)
+		}
+	}
+	return embedding, nil
+}
+
+// add counts a feature into the dimension selected by its hash, with a sign from the hash so
+// that collisions cancel out on average.
+func (g *LocalEmbeddingGenerator) add(embedding []float32, feature string) {
+	h := fnv.New64a()
+	h.Write([]byte(feature))
+	sum := h.Sum64()
+	if sum>>63 == 1 {
+		embedding[sum%uint64(g.dim)]--
+	} else {
+		embedding[sum%uint64(g.dim)]++
+	}
+}
+
+// ModelName returns the name of the embedding model, which includes the dimension.
+func (g *LocalEmbeddingGenerator) ModelName() string {
+	return fmt.Sprintf("local-hash-%d", g.dim)
+}
+
+// Dimension returns the length of the vectors.
+func (g *LocalEmbeddingGenerator) Dimension() int {
+	return g.dim
+}
+
+// LocalSummarizer summarizes without an external service by extracting the first sentences of
+// the documents, the best ranked first.
+type LocalSummarizer struct{}
+
+// NewLocalSummarizer creates a new LocalSummarizer.
+func NewLocalSummarizer() *LocalSummarizer {
+	return &LocalSummarizer{}
+}
+
+// Summarize returns the first sentence of each of the first documents.
+func (s *LocalSummarizer) Summarize(_ context.Context, content []string) (string, error) {
+	var summary []string
+	for _, doc := range content {
+		if first := sentences(doc); len(first) > 0 {
+			summary = append(summary, first[0])
+		}
+		if len(summary) == localSummarySentences {
+			break
+		}
+	}
+	return strings.Join(summary, " "), nil
+}
+
+// LocalAnswerer answers without an external service by quoting the sentences of the passages that
+// share the most words with the question, each citing its passage.
+type LocalAnswerer struct{}
+
+// NewLocalAnswerer creates a new LocalAnswerer.
+func NewLocalAnswerer() *LocalAnswerer {
+	return &LocalAnswerer{}
+}
+
+// Answer quotes the best matching sentences in passage order, citing them as [n]. Without any
+// matching sentence, the first sentence of the first passage is quoted.
+func (a *LocalAnswerer) Answer(_ context.Context, question string, passages []string) (string, error) {
+	type candidate struct {
+		passage, index int
+		text           string
+		matches        int
+	}
+
+	questionTerms := make(map[string]bool)
+	for _, term := range app.Words(question) {
+		questionTerms[term] = true
+	}
+
+	var candidates []candidate
+	for p, passage := range passages {
+		for i, sentence := range sentences(passage) {
+			c := candidate{passage: p, index: i, text: sentence}
+			for _, term := range app.Words(sentence) {
+				if questionTerms[term] {
+					c.matches++
+				}
+			}
+			candidates = append(candidates, c)
+		}
+	}
+	if len(candidates) == 0 {
+		return "", nil
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].matches > candidates[j].matches
+	})
+	best := candidates[:1]
+	for _, c := range candidates[1:min(localAnswerSentences, len(candidates))] {
+		if c.matches > 0 {
+			best = append(best, c)
+		}
+	}
+	sort.Slice(best, func(i, j int) bool {
+		if best[i].passage != best[j].passage {
+			return best[i].passage < best[j].passage
+		}
+		return best[i].index < best[j].index
+	})
+
+	answer := make([]string, len(best))
+	for i, c := range best {
+		answer[i] = fmt.Sprintf("%s [%d]", c.text, c.passage+1)
+	}
+	return strings.Join(answer, " "), nil
+}
+
+// LocalQueryRewriter rewrites follow-ups without an external service by adding the previous
+// question, so that the words it refers to are searched as well.
+type LocalQueryRewriter struct{}
+
+// NewLocalQueryRewriter creates a new LocalQueryRewriter.
+func NewLocalQueryRewriter() *LocalQueryRewriter {
+	return &LocalQueryRewriter{}
+}
+
+// Rewrite prefixes the question with the most recent question of the history.
+func (r *LocalQueryRewriter) Rewrite(_ context.Context, history []app.Message, question string) (string, error) {
+	for i := len(history) - 1; i >= 0; i-- {
+		if m := history[i]; m.Role == app.RoleUser {
+			return strings.TrimSpace(m.Content) + " " + strings.TrimSpace(question), nil
+		}
+	}
+	return question, nil
+}
+
+// sentences splits a text after sentence-ending punctuation and at line breaks.
+func sentences(text string) []string {
+	var out []string
+	var sb strings.Builder
+	flush := func() {
+		if s := strings.TrimSpace(sb.String()); s != "" {
+			out = append(out, strings.Join(strings.Fields(s), " "))
+		}
+		sb.Reset()
+	}
+
+	runes := []rune(text)
+	for i, r := range runes {
+		if r == '\n' {
+			flush()
+			continue
+		}
+		sb.WriteRune(r)
+		if (r == '.' || r == '!' || r == '?') && (i+1 == len(runes) || unicode.IsSpace(runes[i+1])) {
+			flush()
+		}
+	}
+	flush()
+	return out
+}
+
+var _ app.EmbeddingGenerator = (*LocalEmbeddingGenerator)(nil)
+var _ app.Summarizer = (*LocalSummarizer)(nil)
+var _ app.Answerer = (*LocalAnswerer)(nil)
+var _ app.QueryRewriter = (*LocalQueryRewriter)(nil)
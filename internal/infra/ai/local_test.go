@@ -0,0 +1,86 @@
+package ai
+
+import (
+	"context"
+	"testing"
+
+	"github.com/igorrius/go-vector-search/internal/app"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalEmbeddingGenerator_Generate(t *testing.T) {
+	ctx := context.Background()
+	g := NewLocalEmbeddingGenerator(64)
+
+	t.Run("should embed the same text identically", func(t *testing.T) {
+		first, err := g.Generate(ctx, "Vector search in Go")
+		require.NoError(t, err)
+		second, err := g.Generate(ctx, "vector SEARCH in go!")
+		require.NoError(t, err)
+
+		assert.Len(t, first, 64)
+		assert.Equal(t, first, second)
+	})
+
+	t.Run("should place texts sharing words closer", func(t *testing.T) {
+		query, _ := g.Generate(ctx, "typesense schema migration")
+		related, _ := g.Generate(ctx, "The schema migration of typesense collections")
+		unrelated, _ := g.Generate(ctx, "Bananas are yellow fruit")
+
+		assert.Greater(t, app.Cosine.Score(app.Cosine.Distance(query, related)), app.Cosine.Score(app.Cosine.Distance(query, unrelated)))
+	})
+
+	t.Run("should return a zero vector without words", func(t *testing.T) {
+		embedding, err := g.Generate(ctx, " ... ")
+
+		require.NoError(t, err)
+		assert.Equal(t, make([]float32, 64), embedding)
+	})
+}
+
+func TestLocalSummarizer_Summarize(t *testing.T) {
+	summary, err := NewLocalSummarizer().Summarize(context.Background(), []string{
+		"Go is fast. It compiles quickly.",
+		"",
+		"Typesense stores vectors.\nIt is written in C++.",
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, "Go is fast. Typesense stores vectors.", summary)
+}
+
+func TestLocalAnswerer_Answer(t *testing.T) {
+	ctx := context.Background()
+	passages := []string{
+		"Go is fast. It compiles quickly.",
+		"Typesense stores vectors. It is written in C++.",
+	}
+
+	t.Run("should quote the matching sentences with citations", func(t *testing.T) {
+		answer, err := NewLocalAnswerer().Answer(ctx, "Where are vectors stored, and is Go fast?", passages)
+
+		require.NoError(t, err)
+		assert.Equal(t, "Go is fast. [1] Typesense stores vectors. [2]", answer)
+		assert.Len(t, app.ParseCitations(answer, make([]app.Source, 2)), 2)
+	})
+
+	t.Run("should quote the first passage without a match", func(t *testing.T) {
+		answer, err := NewLocalAnswerer().Answer(ctx, "Why?", passages)
+
+		require.NoError(t, err)
+		assert.Equal(t, "Go is fast. [1]", answer)
+	})
+}
+
+func TestLocalQueryRewriter_Rewrite(t *testing.T) {
+	history := []app.Message{
+		{Role: app.RoleUser, Content: "Which stores are supported?"},
+		{Role: app.RoleAssistant, Content: "Memory and Typesense [1]."},
+	}
+
+	query, err := NewLocalQueryRewriter().Rewrite(context.Background(), history, "How is the second one configured?")
+
+	require.NoError(t, err)
+	assert.Equal(t, "Which stores are supported? How is the second one configured?", query)
+}
@@ -0,0 +1,146 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/igorrius/go-vector-search/internal/app"
+)
+
+// maxDOCXPartSize bounds the decompressed size of a part read from a DOCX archive.
+const maxDOCXPartSize = 64 << 20
+
+// extractDOCX extracts the paragraphs of a Word document, one per line. Headings are prefixed
+// with "#" by their level and list paragraphs with "- ". The title is read from the document
+// properties.
+func extractDOCX(data []byte, _ string) (*app.ExtractedText, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open DOCX archive: %w", err)
+	}
+
+	body, err := readZipPart(archive, "word/document.xml")
+	if err != nil {
+		return nil, err
+	}
+	content, err := docxText(body)
+	if err != nil {
+		return nil, err
+	}
+
+	text := &app.ExtractedText{Content: content}
+	if core, err := readZipPart(archive, "docProps/core.xml"); err == nil {
+		text.Title = docxTitle(core)
+	}
+	return text, nil
+}
+
+func readZipPart(archive *zip.Reader, name string) ([]byte, error) {
+	f, err := archive.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, maxDOCXPartSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	if len(data) > maxDOCXPartSize {
+		return nil, fmt.Errorf("%s is too large", name)
+	}
+	return data, nil
+}
+
+// docxText renders the paragraphs of word/document.xml. Elements are matched by local name, as
+// WordprocessingML is always in the "w" namespace.
+func docxText(body []byte) (string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+
+	var sb, paragraph strings.Builder
+	var prefix string
+	inText := false
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to parse DOCX document: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				paragraph.WriteByte('\t')
+			case "br", "cr":
+				paragraph.WriteByte('\n')
+			case "pStyle":
+				if level := docxHeadingLevel(xmlAttr(t, "val")); level > 0 {
+					prefix = strings.Repeat("#", level) + " "
+				}
+			case "numPr":
+				if prefix == "" {
+					prefix = "- "
+				}
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				if line := strings.TrimSpace(paragraph.String()); line != "" {
+					sb.WriteString(prefix + line + "\n\n")
+				}
+				paragraph.Reset()
+				prefix = ""
+			}
+		case xml.CharData:
+			if inText {
+				paragraph.Write(t)
+			}
+		}
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// docxHeadingLevel returns the level of a heading paragraph style such as "Heading2", or 0.
+func docxHeadingLevel(style string) int {
+	if style == "Title" {
+		return 1
+	}
+	level, err := strconv.Atoi(strings.TrimPrefix(style, "Heading"))
+	if err != nil || !strings.HasPrefix(style, "Heading") || level < 1 {
+		return 0
+	}
+	return min(level, 6)
+}
+
+// docxTitle returns the dc:title of docProps/core.xml.
+func docxTitle(core []byte) string {
+	var props struct {
+		Title string `xml:"title"`
+	}
+	if err := xml.Unmarshal(core, &props); err != nil {
+		return ""
+	}
+	return strings.TrimSpace(props.Title)
+}
+
+func xmlAttr(e xml.StartElement, local string) string {
+	for _, attr := range e.Attr {
+		if attr.Name.Local == local {
+			return attr.Value
+		}
+	}
+	return ""
+}
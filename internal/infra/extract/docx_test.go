@@ -0,0 +1,60 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newDOCX(t *testing.T, parts map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range parts {
+		f, err := w.Create(name)
+		require.NoError(t, err)
+		_, err = f.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestExtractDOCX(t *testing.T) {
+	t.Run("should extract paragraphs, headings and lists", func(t *testing.T) {
+		// Arrange
+		data := newDOCX(t, map[string]string{
+			"word/document.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>
+<w:p><w:pPr><w:pStyle w:val="Heading2"/></w:pPr><w:r><w:t>Setup</w:t></w:r></w:p>
+<w:p><w:r><w:t xml:space="preserve">Install the </w:t></w:r><w:r><w:t>server.</w:t></w:r></w:p>
+<w:p><w:pPr><w:numPr><w:ilvl w:val="0"/></w:numPr></w:pPr><w:r><w:t>Step one</w:t></w:r></w:p>
+<w:p></w:p>
+</w:body></w:document>`,
+			"docProps/core.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:title>Manual</dc:title></cp:coreProperties>`,
+		})
+
+		// Act
+		text, err := extractDOCX(data, DOCX)
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, "Manual", text.Title)
+		assert.Equal(t, "## Setup\n\nInstall the server.\n\n- Step one", text.Content)
+	})
+
+	t.Run("should fail without a document part", func(t *testing.T) {
+		// Arrange
+		data := newDOCX(t, map[string]string{"other.xml": "<a/>"})
+
+		// Act
+		_, err := extractDOCX(data, DOCX)
+
+		// Assert
+		assert.Error(t, err)
+	})
+}
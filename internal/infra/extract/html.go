@@ -0,0 +1,225 @@
+package extract
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+
+	"github.com/igorrius/go-vector-search/internal/app"
+)
+
+// boilerplate holds the elements whose content is not part of the main text of a page.
+var boilerplate = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Template: true,
+	atom.Nav:      true,
+	atom.Header:   true,
+	atom.Footer:   true,
+	atom.Aside:    true,
+	atom.Form:     true,
+	atom.Button:   true,
+	atom.Iframe:   true,
+	atom.Svg:      true,
+	atom.Head:     true,
+}
+
+// boilerplateRoles holds the ARIA roles of navigation and page chrome.
+var boilerplateRoles = map[string]bool{
+	"navigation":    true,
+	"banner":        true,
+	"contentinfo":   true,
+	"complementary": true,
+	"search":        true,
+}
+
+// blocks holds the elements that start a new paragraph.
+var blocks = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Section: true, atom.Article: true, atom.Main: true,
+	atom.Blockquote: true, atom.Table: true, atom.Tr: true, atom.Ul: true, atom.Ol: true,
+	atom.Dl: true, atom.Dt: true, atom.Dd: true, atom.Figure: true, atom.Figcaption: true,
+	atom.Hr: true, atom.Address: true, atom.Details: true, atom.Summary: true,
+}
+
+var headingLevels = map[atom.Atom]int{
+	atom.H1: 1, atom.H2: 2, atom.H3: 3, atom.H4: 4, atom.H5: 5, atom.H6: 6,
+}
+
+// extractHTML extracts the main text of an HTML page as Markdown-like text: headings, list items
+// and preformatted blocks keep their structure, while scripts, navigation, headers, footers and
+// other page chrome are dropped. The text of a <main> or <article> element is preferred over the
+// whole body. The <title> is the title.
+func extractHTML(data []byte, contentType string) (*app.ExtractedText, error) {
+	r, err := charset.NewReader(bytes.NewReader(data), contentType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect HTML charset: %w", err)
+	}
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML: %w", err)
+	}
+
+	text := &app.ExtractedText{}
+	if title := findElement(doc, atom.Title); title != nil {
+		text.Title = strings.Join(strings.Fields(textOf(title)), " ")
+	}
+
+	root := findElement(doc, atom.Main)
+	if root == nil {
+		root = findElement(doc, atom.Article)
+	}
+	if root == nil {
+		root = doc
+	}
+
+	w := &blockWriter{}
+	w.node(root)
+	text.Content = w.String()
+	return text, nil
+}
+
+// findElement returns the first element of the type in document order.
+func findElement(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// textOf returns the concatenated text nodes below n.
+func textOf(n *html.Node) string {
+	var sb strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			sb.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return sb.String()
+}
+
+func isBoilerplate(n *html.Node) bool {
+	if boilerplate[n.DataAtom] {
+		return true
+	}
+	for _, attr := range n.Attr {
+		switch {
+		case attr.Key == "hidden",
+			attr.Key == "aria-hidden" && attr.Val == "true",
+			attr.Key == "role" && boilerplateRoles[attr.Val]:
+			return true
+		}
+	}
+	return false
+}
+
+// blockWriter renders nodes as paragraphs separated by blank lines, collapsing whitespace within
+// them.
+type blockWriter struct {
+	sb strings.Builder
+	// line holds the words of the current paragraph.
+	line strings.Builder
+	// prefix starts the current paragraph, e.g. "## " or "- ".
+	prefix string
+}
+
+func (w *blockWriter) node(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		w.words(n.Data)
+		return
+	case html.ElementNode:
+		if isBoilerplate(n) {
+			return
+		}
+	case html.DocumentNode:
+	default:
+		return
+	}
+
+	switch {
+	case n.DataAtom == atom.Pre:
+		w.flush()
+		w.sb.WriteString("```\n" + strings.Trim(textOf(n), "\n") + "\n```\n\n")
+		return
+	case n.DataAtom == atom.Br:
+		w.flush()
+		return
+	case headingLevels[n.DataAtom] > 0:
+		w.flush()
+		w.prefix = strings.Repeat("#", headingLevels[n.DataAtom]) + " "
+		w.children(n)
+		w.flush()
+		return
+	case n.DataAtom == atom.Li:
+		w.flush()
+		w.prefix = "- "
+		w.children(n)
+		w.flush()
+		return
+	case n.DataAtom == atom.Td || n.DataAtom == atom.Th:
+		w.words(" ")
+		w.children(n)
+		w.words(" ")
+		return
+	case blocks[n.DataAtom]:
+		w.flush()
+		w.children(n)
+		w.flush()
+		return
+	}
+	w.children(n)
+}
+
+func (w *blockWriter) children(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		w.node(c)
+	}
+}
+
+// words appends text to the current paragraph, collapsing whitespace.
+func (w *blockWriter) words(s string) {
+	if s == "" {
+		return
+	}
+	if w.line.Len() > 0 && isSpace(s[0]) {
+		w.line.WriteByte(' ')
+	}
+	w.line.WriteString(strings.Join(strings.Fields(s), " "))
+	if len(strings.Fields(s)) > 0 && isSpace(s[len(s)-1]) {
+		w.line.WriteByte(' ')
+	}
+}
+
+// flush ends the current paragraph.
+func (w *blockWriter) flush() {
+	line := strings.Join(strings.Fields(w.line.String()), " ")
+	if line != "" {
+		w.sb.WriteString(w.prefix + line + "\n\n")
+	}
+	w.line.Reset()
+	w.prefix = ""
+}
+
+func (w *blockWriter) String() string {
+	w.flush()
+	return strings.TrimSpace(w.sb.String())
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r' || b == '\f'
+}
@@ -0,0 +1,58 @@
+package extract
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractHTML(t *testing.T) {
+	t.Run("should keep the structure of the main content", func(t *testing.T) {
+		// Arrange
+		page := `<!DOCTYPE html>
+<html><head><title> Vector
+  search </title><style>p { color: red }</style></head>
+<body>
+  <header><a href="/">Home</a></header>
+  <nav><ul><li>Docs</li></ul></nav>
+  <main>
+    <h1>Indexing</h1>
+    <p>Documents are   <b>split</b> into
+    chunks.</p>
+    <ul><li>First</li><li>Second</li></ul>
+    <pre>go run .
+go test ./...</pre>
+    <div role="navigation">Previous | Next</div>
+    <script>track()</script>
+  </main>
+  <footer>Copyright</footer>
+</body></html>`
+
+		// Act
+		text, err := extractHTML([]byte(page), "text/html")
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, "Vector search", text.Title)
+		assert.Equal(t, "# Indexing\n\nDocuments are split into chunks.\n\n- First\n\n- Second\n\n```\ngo run .\ngo test ./...\n```", text.Content)
+	})
+
+	t.Run("should fall back to the body", func(t *testing.T) {
+		// Act
+		text, err := extractHTML([]byte("<p>One</p><p>Two<br>Three</p>"), "text/html")
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, "One\n\nTwo\n\nThree", text.Content)
+	})
+
+	t.Run("should decode the declared charset", func(t *testing.T) {
+		// Act
+		text, err := extractHTML([]byte("<p>caf\xe9</p>"), "text/html; charset=iso-8859-1")
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, "café", text.Content)
+	})
+}
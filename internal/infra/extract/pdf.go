@@ -0,0 +1,884 @@
+package extract
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf16"
+
+	"github.com/igorrius/go-vector-search/internal/app"
+)
+
+// maxPDFStreamSize bounds the decompressed size of a PDF stream.
+const maxPDFStreamSize = 64 << 20
+
+var (
+	pdfObjectHeader = regexp.MustCompile(`(\d+)\s+\d+\s+obj\b`)
+	pdfFontDict     = regexp.MustCompile(`(?s)/Font\s*<<(.*?)>>`)
+	pdfNamedRef     = regexp.MustCompile(`/([^\s/<>\[\]()]+)\s+(\d+)\s+\d+\s+R`)
+	pdfFilterArray  = regexp.MustCompile(`/Filter\s*\[([^\]]*)\]`)
+	pdfFilterName   = regexp.MustCompile(`/Filter\s*/(\w+)`)
+	pdfPageType     = regexp.MustCompile(`/Type\s*/Page\b`)
+	pdfName         = regexp.MustCompile(`/(\w+)`)
+	pdfRef          = regexp.MustCompile(`(\d+)\s+\d+\s+R`)
+	multipleBlanks  = regexp.MustCompile(`\n{3,}`)
+
+	// pdfKeyPatterns caches the patterns compiled by keyPattern by their expression.
+	pdfKeyPatterns sync.Map
+)
+
+// keyPattern returns the compiled pattern of the format with the dictionary key in place of %s.
+// The keys are constants of this file, so the cache stays small.
+func keyPattern(format, key string) *regexp.Regexp {
+	expr := fmt.Sprintf(format, key)
+	if re, ok := pdfKeyPatterns.Load(expr); ok {
+		return re.(*regexp.Regexp)
+	}
+	re, _ := pdfKeyPatterns.LoadOrStore(expr, regexp.MustCompile(expr))
+	return re.(*regexp.Regexp)
+}
+
+// extractPDF extracts the text shown on the pages of a PDF, page by page. Text is decoded with
+// the ToUnicode maps of the fonts; text in fonts without one is read as Latin-1, so glyphs of
+// embedded fonts with custom encodings may come out wrong. Encrypted files are rejected, and
+// scanned pages without a text layer yield no text. The title is read from the document
+// information dictionary.
+func extractPDF(data []byte, _ string) (*app.ExtractedText, error) {
+	doc := parsePDF(data)
+	if doc.encrypted() {
+		return nil, errors.New("encrypted PDF files are not supported")
+	}
+
+	var sb strings.Builder
+	for _, page := range doc.pages() {
+		fonts := doc.pageFonts(page)
+		for _, ref := range doc.refs(doc.objects[page].dict, "Contents") {
+			content, err := doc.stream(ref)
+			if err != nil {
+				continue
+			}
+			sb.WriteString(showText(content, fonts))
+			sb.WriteString("\n")
+		}
+		sb.WriteString("\n")
+	}
+
+	return &app.ExtractedText{
+		Content: cleanPDFText(sb.String()),
+		Title:   doc.title(),
+	}, nil
+}
+
+// pdfObject is an indirect object: its dictionary or value and the raw data of its stream.
+type pdfObject struct {
+	dict   []byte
+	stream []byte
+}
+
+// pdfDocument holds the objects of a PDF. The file is scanned for objects rather than read
+// through its cross-reference table, which also recovers damaged files.
+type pdfDocument struct {
+	objects map[int]*pdfObject
+	trailer []byte
+	cmaps   map[int]*cmap
+}
+
+func parsePDF(data []byte) *pdfDocument {
+	doc := &pdfDocument{objects: make(map[int]*pdfObject), cmaps: make(map[int]*cmap)}
+
+	pos := 0
+	for {
+		loc := pdfObjectHeader.FindSubmatchIndex(data[pos:])
+		if loc == nil {
+			break
+		}
+		num, _ := strconv.Atoi(string(data[pos+loc[2] : pos+loc[3]]))
+		start := pos + loc[1]
+		obj, end := parsePDFObject(data, start)
+		doc.objects[num] = obj
+		pos = end
+	}
+
+	if i := bytes.LastIndex(data, []byte("trailer")); i >= 0 {
+		doc.trailer = data[i:]
+	}
+
+	// Objects stored in object streams only exist in compressed form.
+	for _, obj := range doc.objectsOfType("ObjStm") {
+		doc.expandObjectStream(obj)
+	}
+	return doc
+}
+
+// parsePDFObject parses the body of the object starting at start and returns it with the
+// offset after its end.
+func parsePDFObject(data []byte, start int) (*pdfObject, int) {
+	rest := data[start:]
+	endobj := bytes.Index(rest, []byte("endobj"))
+	if endobj < 0 {
+		endobj = len(rest)
+	}
+
+	streamAt := bytes.Index(rest[:endobj], []byte("stream"))
+	if streamAt < 0 {
+		return &pdfObject{dict: rest[:endobj]}, start + min(endobj+len("endobj"), len(rest))
+	}
+
+	obj := &pdfObject{dict: rest[:streamAt]}
+	body := rest[streamAt+len("stream"):]
+	body = bytes.TrimPrefix(body, []byte("\r"))
+	body = bytes.TrimPrefix(body, []byte("\n"))
+	offset := len(rest) - len(body)
+
+	end := bytes.Index(body, []byte("endstream"))
+	if end < 0 {
+		end = len(body)
+	}
+	obj.stream = bytes.TrimRight(body[:end], "\r\n")
+
+	after := offset + end
+	if i := bytes.Index(rest[after:], []byte("endobj")); i >= 0 {
+		after += i + len("endobj")
+	} else {
+		after = len(rest)
+	}
+	return obj, start + after
+}
+
+// expandObjectStream adds the objects compressed in an object stream.
+func (d *pdfDocument) expandObjectStream(obj *pdfObject) {
+	data, err := decodePDFStream(obj)
+	if err != nil {
+		return
+	}
+	n, _ := dictInt(obj.dict, "N")
+	first, _ := dictInt(obj.dict, "First")
+	if first > len(data) {
+		return
+	}
+
+	header := strings.Fields(string(data[:first]))
+	type entry struct{ num, offset int }
+	var entries []entry
+	for i := 0; i+1 < len(header) && len(entries) < n; i += 2 {
+		num, err1 := strconv.Atoi(header[i])
+		offset, err2 := strconv.Atoi(header[i+1])
+		if err1 != nil || err2 != nil || first+offset > len(data) {
+			return
+		}
+		entries = append(entries, entry{num, first + offset})
+	}
+
+	for i, e := range entries {
+		end := len(data)
+		if i+1 < len(entries) {
+			end = max(entries[i+1].offset, e.offset)
+		}
+		if _, ok := d.objects[e.num]; !ok {
+			d.objects[e.num] = &pdfObject{dict: data[e.offset:end]}
+		}
+	}
+}
+
+// encrypted reports whether the file declares an encryption dictionary.
+func (d *pdfDocument) encrypted() bool {
+	if bytes.Contains(d.trailer, []byte("/Encrypt")) {
+		return true
+	}
+	for _, obj := range d.objectsOfType("XRef") {
+		if bytes.Contains(obj.dict, []byte("/Encrypt")) {
+			return true
+		}
+	}
+	return false
+}
+
+// objectsOfType returns the objects whose /Type is the name, ordered by object number.
+func (d *pdfDocument) objectsOfType(name string) []*pdfObject {
+	re := keyPattern(`/Type\s*/%s\b`, name)
+	var nums []int
+	for num, obj := range d.objects {
+		if re.Match(obj.dict) {
+			nums = append(nums, num)
+		}
+	}
+	sort.Ints(nums)
+
+	objects := make([]*pdfObject, len(nums))
+	for i, num := range nums {
+		objects[i] = d.objects[num]
+	}
+	return objects
+}
+
+// pages returns the object numbers of the pages in the order of the page tree, or in object
+// order when the tree cannot be followed.
+func (d *pdfDocument) pages() []int {
+	var pages []int
+	visited := make(map[int]bool)
+	var walk func(num int)
+	walk = func(num int) {
+		obj, ok := d.objects[num]
+		if !ok || visited[num] {
+			return
+		}
+		visited[num] = true
+		if kids := d.refs(obj.dict, "Kids"); len(kids) > 0 {
+			for _, kid := range kids {
+				walk(kid)
+			}
+			return
+		}
+		if pdfPageType.Match(obj.dict) {
+			pages = append(pages, num)
+		}
+	}
+
+	for _, catalog := range d.objectsOfType("Catalog") {
+		if root, ok := dictRef(catalog.dict, "Pages"); ok {
+			walk(root)
+		}
+	}
+	if len(pages) > 0 {
+		return pages
+	}
+
+	for num, obj := range d.objects {
+		if pdfPageType.Match(obj.dict) {
+			pages = append(pages, num)
+		}
+	}
+	sort.Ints(pages)
+	return pages
+}
+
+// pageFonts returns the ToUnicode maps of the fonts of a page by resource name. Resources are
+// inherited from the parent nodes of the page tree.
+func (d *pdfDocument) pageFonts(page int) map[string]*cmap {
+	fonts := make(map[string]*cmap)
+	for depth, num := 0, page; depth < 32; depth++ {
+		obj, ok := d.objects[num]
+		if !ok {
+			break
+		}
+		resources := obj.dict
+		if ref, ok := dictRef(obj.dict, "Resources"); ok {
+			if res, ok := d.objects[ref]; ok {
+				resources = res.dict
+			}
+		}
+
+		var fontDict []byte
+		if ref, ok := dictRef(resources, "Font"); ok {
+			if res, ok := d.objects[ref]; ok {
+				fontDict = res.dict
+			}
+		} else if m := pdfFontDict.FindSubmatch(resources); m != nil {
+			fontDict = m[1]
+		}
+		for _, m := range pdfNamedRef.FindAllSubmatch(fontDict, -1) {
+			name := string(m[1])
+			if _, ok := fonts[name]; ok {
+				continue
+			}
+			ref, _ := strconv.Atoi(string(m[2]))
+			fonts[name] = d.fontCMap(ref)
+		}
+
+		parent, ok := dictRef(obj.dict, "Parent")
+		if !ok {
+			break
+		}
+		num = parent
+	}
+	return fonts
+}
+
+// fontCMap returns the parsed ToUnicode map of a font, or nil if it has none.
+func (d *pdfDocument) fontCMap(font int) *cmap {
+	if m, ok := d.cmaps[font]; ok {
+		return m
+	}
+	d.cmaps[font] = nil
+
+	obj, ok := d.objects[font]
+	if !ok {
+		return nil
+	}
+	ref, ok := dictRef(obj.dict, "ToUnicode")
+	if !ok {
+		return nil
+	}
+	data, err := d.stream(ref)
+	if err != nil {
+		return nil
+	}
+	d.cmaps[font] = parseCMap(data)
+	return d.cmaps[font]
+}
+
+// stream returns the decoded stream of an object.
+func (d *pdfDocument) stream(num int) ([]byte, error) {
+	obj, ok := d.objects[num]
+	if !ok || obj.stream == nil {
+		return nil, fmt.Errorf("object %d is not a stream", num)
+	}
+	return decodePDFStream(obj)
+}
+
+// title returns the /Title of the document information dictionary.
+func (d *pdfDocument) title() string {
+	dicts := [][]byte{d.trailer}
+	for _, obj := range d.objectsOfType("XRef") {
+		dicts = append(dicts, obj.dict)
+	}
+	for _, dict := range dicts {
+		ref, ok := dictRef(dict, "Info")
+		if !ok {
+			continue
+		}
+		info, ok := d.objects[ref]
+		if !ok {
+			continue
+		}
+		i := bytes.Index(info.dict, []byte("/Title"))
+		if i < 0 {
+			continue
+		}
+		lex := &pdfLexer{data: info.dict[i+len("/Title"):]}
+		if tok, ok := lex.next(); ok && tok.kind == pdfString {
+			return strings.TrimSpace(decodePDFTextString(tok.value))
+		}
+	}
+	return ""
+}
+
+// refs returns the objects referenced by a key holding a reference or an array of references.
+func (d *pdfDocument) refs(dict []byte, key string) []int {
+	m := keyPattern(`/%s\s*(\[[^\]]*\]|\d+\s+\d+\s+R)`, key).FindSubmatch(dict)
+	if m == nil {
+		return nil
+	}
+	value := m[1]
+	// A reference to an array of references.
+	if value[0] != '[' {
+		num, _ := strconv.Atoi(string(pdfRef.FindSubmatch(value)[1]))
+		if obj, ok := d.objects[num]; ok && obj.stream == nil && bytes.HasPrefix(bytes.TrimSpace(obj.dict), []byte("[")) {
+			value = obj.dict
+		}
+	}
+
+	var refs []int
+	for _, m := range pdfRef.FindAllSubmatch(value, -1) {
+		num, _ := strconv.Atoi(string(m[1]))
+		refs = append(refs, num)
+	}
+	return refs
+}
+
+func dictRef(dict []byte, key string) (int, bool) {
+	m := keyPattern(`/%s\s+(\d+)\s+\d+\s+R`, key).FindSubmatch(dict)
+	if m == nil {
+		return 0, false
+	}
+	num, err := strconv.Atoi(string(m[1]))
+	return num, err == nil
+}
+
+func dictInt(dict []byte, key string) (int, bool) {
+	m := keyPattern(`/%s\s+(\d+)\b`, key).FindSubmatch(dict)
+	if m == nil {
+		return 0, false
+	}
+	n, err := strconv.Atoi(string(m[1]))
+	return n, err == nil
+}
+
+// decodePDFStream applies the FlateDecode and ASCIIHexDecode filters of a stream. Other filters,
+// such as the image filters, are not supported.
+func decodePDFStream(obj *pdfObject) ([]byte, error) {
+	var filters []string
+	if m := pdfFilterArray.FindSubmatch(obj.dict); m != nil {
+		for _, name := range pdfName.FindAllSubmatch(m[1], -1) {
+			filters = append(filters, string(name[1]))
+		}
+	} else if m := pdfFilterName.FindSubmatch(obj.dict); m != nil {
+		filters = []string{string(m[1])}
+	}
+
+	data := obj.stream
+	for _, filter := range filters {
+		var err error
+		switch filter {
+		case "FlateDecode", "Fl":
+			data, err = inflate(data)
+		case "ASCIIHexDecode", "AHx":
+			data, err = hex.DecodeString(hexDigits(data))
+		default:
+			err = fmt.Errorf("unsupported filter %s", filter)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// inflate decompresses zlib data, falling back to raw deflate data. A truncated stream yields
+// the data decompressed so far.
+func inflate(data []byte) ([]byte, error) {
+	var r io.ReadCloser
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		r = flate.NewReader(bytes.NewReader(data))
+	}
+	defer r.Close()
+
+	out, err := io.ReadAll(io.LimitReader(r, maxPDFStreamSize))
+	if err != nil && len(out) == 0 {
+		return nil, fmt.Errorf("failed to inflate stream: %w", err)
+	}
+	return out, nil
+}
+
+// hexDigits returns the hex digits of ASCIIHex data up to its ">" end marker, padded to an even
+// count.
+func hexDigits(data []byte) string {
+	var sb strings.Builder
+	for _, c := range data {
+		if c == '>' {
+			break
+		}
+		if isHexDigit(c) {
+			sb.WriteByte(c)
+		}
+	}
+	if sb.Len()%2 == 1 {
+		sb.WriteByte('0')
+	}
+	return sb.String()
+}
+
+func isHexDigit(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+// showText interprets a content stream and returns the text it shows. Line moves become line
+// breaks, and large negative kerning in TJ arrays becomes a space.
+func showText(content []byte, fonts map[string]*cmap) string {
+	var sb strings.Builder
+	var font *cmap
+	var operands []pdfToken
+	lineY, hasLine := 0.0, false
+
+	newline := func() {
+		if sb.Len() > 0 && !strings.HasSuffix(sb.String(), "\n") {
+			sb.WriteByte('\n')
+		}
+	}
+	space := func() {
+		if s := sb.String(); s != "" && !strings.HasSuffix(s, " ") && !strings.HasSuffix(s, "\n") {
+			sb.WriteByte(' ')
+		}
+	}
+	show := func(tok pdfToken) {
+		if tok.kind == pdfString {
+			sb.WriteString(font.decode(tok.value))
+		}
+	}
+	operand := func(i int) pdfToken {
+		if i < 0 || i >= len(operands) {
+			return pdfToken{}
+		}
+		return operands[i]
+	}
+
+	lex := &pdfLexer{data: content}
+	for {
+		tok, ok := lex.next()
+		if !ok {
+			break
+		}
+		if tok.kind != pdfOperator {
+			operands = append(operands, tok)
+			continue
+		}
+
+		switch string(tok.value) {
+		case "ET":
+			newline()
+			hasLine = false
+		case "Tf":
+			font = fonts[string(operand(len(operands)-2).value)]
+		case "Tj":
+			show(operand(len(operands) - 1))
+		case "'", `"`:
+			newline()
+			show(operand(len(operands) - 1))
+		case "TJ":
+			for _, elem := range operand(len(operands) - 1).array {
+				if elem.kind == pdfNumber && elem.number < -200 {
+					space()
+				}
+				show(elem)
+			}
+		case "Td", "TD":
+			if operand(len(operands)-1).number != 0 {
+				newline()
+			} else {
+				space()
+			}
+		case "T*":
+			newline()
+		case "Tm":
+			y := operand(len(operands) - 1).number
+			if hasLine && y != lineY {
+				newline()
+			} else {
+				space()
+			}
+			lineY, hasLine = y, true
+		case "BI":
+			lex.skipInlineImage()
+		}
+		operands = operands[:0]
+	}
+	return sb.String()
+}
+
+// cleanPDFText trims the lines of extracted text and collapses runs of blank lines.
+func cleanPDFText(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.Join(strings.Fields(line), " ")
+	}
+	return strings.TrimSpace(multipleBlanks.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
+
+// cmap is a ToUnicode character map from character codes to text.
+type cmap struct {
+	// codeLen is the length of the character codes in bytes.
+	codeLen int
+	text    map[uint32]string
+}
+
+// parseCMap parses the codespace ranges and the bfchar and bfrange mappings of a ToUnicode map.
+func parseCMap(data []byte) *cmap {
+	m := &cmap{codeLen: 1, text: make(map[uint32]string)}
+	var operands []pdfToken
+
+	lex := &pdfLexer{data: data}
+	for {
+		tok, ok := lex.next()
+		if !ok {
+			break
+		}
+		if tok.kind != pdfOperator {
+			operands = append(operands, tok)
+			continue
+		}
+
+		switch string(tok.value) {
+		case "endcodespacerange":
+			for _, op := range operands {
+				if op.kind == pdfString {
+					m.codeLen = max(m.codeLen, len(op.value))
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				m.codeLen = max(m.codeLen, len(operands[i].value))
+				m.text[codeOf(operands[i].value)] = decodeUTF16BE(operands[i+1].value)
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, hi, dst := codeOf(operands[i].value), codeOf(operands[i+1].value), operands[i+2]
+				m.codeLen = max(m.codeLen, len(operands[i].value))
+				for code := lo; code <= hi && code-lo < 1<<16; code++ {
+					offset := int(code - lo)
+					switch {
+					case dst.kind == pdfArray && offset < len(dst.array):
+						m.text[code] = decodeUTF16BE(dst.array[offset].value)
+					case dst.kind == pdfString:
+						m.text[code] = decodeUTF16BE(addToLastUnit(dst.value, offset))
+					}
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+	return m
+}
+
+// decode maps the character codes of a string to text. Without a map, the string is read as a
+// PDF text string.
+func (m *cmap) decode(s []byte) string {
+	if m == nil {
+		return decodePDFTextString(s)
+	}
+	var sb strings.Builder
+	for i := 0; i+m.codeLen <= len(s); i += m.codeLen {
+		sb.WriteString(m.text[codeOf(s[i:i+m.codeLen])])
+	}
+	return sb.String()
+}
+
+func codeOf(b []byte) uint32 {
+	var code uint32
+	for _, c := range b {
+		code = code<<8 | uint32(c)
+	}
+	return code
+}
+
+// addToLastUnit adds n to the last UTF-16 code unit of a bfrange destination.
+func addToLastUnit(dst []byte, n int) []byte {
+	out := append([]byte(nil), dst...)
+	if len(out) < 2 {
+		return out
+	}
+	unit := int(out[len(out)-2])<<8 | int(out[len(out)-1]) + n
+	out[len(out)-2], out[len(out)-1] = byte(unit>>8), byte(unit)
+	return out
+}
+
+func decodeUTF16BE(b []byte) string {
+	units := make([]uint16, len(b)/2)
+	for i := range units {
+		units[i] = uint16(b[2*i])<<8 | uint16(b[2*i+1])
+	}
+	return string(utf16.Decode(units))
+}
+
+// decodePDFTextString decodes a string as UTF-16BE when it starts with a byte order mark and
+// as Latin-1 otherwise, which approximates PDFDocEncoding.
+func decodePDFTextString(s []byte) string {
+	if bytes.HasPrefix(s, []byte{0xFE, 0xFF}) {
+		return decodeUTF16BE(s[2:])
+	}
+	runes := make([]rune, len(s))
+	for i, c := range s {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+type pdfTokenKind int
+
+const (
+	pdfOperator pdfTokenKind = iota + 1
+	pdfNumber
+	pdfString
+	pdfNameToken
+	pdfArray
+	pdfDict
+)
+
+// pdfToken is a token of a content stream or CMap. Names hold their value without the slash,
+// strings their decoded bytes, and arrays their elements.
+type pdfToken struct {
+	kind   pdfTokenKind
+	value  []byte
+	number float64
+	array  []pdfToken
+}
+
+// pdfLexer tokenizes PDF content streams.
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+// next returns the next token, reading arrays as a whole.
+func (l *pdfLexer) next() (pdfToken, bool) {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return pdfToken{}, false
+	}
+
+	c := l.data[l.pos]
+	switch {
+	case c == '(':
+		l.pos++
+		return pdfToken{kind: pdfString, value: l.literalString()}, true
+	case c == '<' && l.peek(1) == '<':
+		l.pos += 2
+		return pdfToken{kind: pdfDict}, true
+	case c == '>' && l.peek(1) == '>':
+		l.pos += 2
+		return pdfToken{kind: pdfDict}, true
+	case c == '<':
+		end := bytes.IndexByte(l.data[l.pos:], '>')
+		if end < 0 {
+			end = len(l.data) - l.pos
+		}
+		value, _ := hex.DecodeString(hexDigits(l.data[l.pos+1 : l.pos+end]))
+		l.pos += end + 1
+		return pdfToken{kind: pdfString, value: value}, true
+	case c == '[':
+		l.pos++
+		var elems []pdfToken
+		for {
+			l.skipSpace()
+			if l.pos >= len(l.data) {
+				break
+			}
+			if l.data[l.pos] == ']' {
+				l.pos++
+				break
+			}
+			elem, ok := l.next()
+			if !ok {
+				break
+			}
+			elems = append(elems, elem)
+		}
+		return pdfToken{kind: pdfArray, array: elems}, true
+	case c == ']' || c == '{' || c == '}' || c == ')' || c == '>':
+		l.pos++
+		return l.next()
+	case c == '/':
+		l.pos++
+		return pdfToken{kind: pdfNameToken, value: l.regular()}, true
+	default:
+		word := l.regular()
+		if len(word) == 0 {
+			l.pos++
+			return l.next()
+		}
+		if n, err := strconv.ParseFloat(string(word), 64); err == nil {
+			return pdfToken{kind: pdfNumber, number: n, value: word}, true
+		}
+		return pdfToken{kind: pdfOperator, value: word}, true
+	}
+}
+
+func (l *pdfLexer) peek(offset int) byte {
+	if l.pos+offset < len(l.data) {
+		return l.data[l.pos+offset]
+	}
+	return 0
+}
+
+// skipSpace skips whitespace and comments.
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		switch {
+		case c == '%':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		case isPDFSpace(c):
+			l.pos++
+		default:
+			return
+		}
+	}
+}
+
+// regular reads a run of regular characters.
+func (l *pdfLexer) regular() []byte {
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	return l.data[start:l.pos]
+}
+
+// literalString reads a literal string after its opening parenthesis, resolving escapes.
+func (l *pdfLexer) literalString() []byte {
+	var out []byte
+	depth := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return out
+			}
+		case '\\':
+			if l.pos >= len(l.data) {
+				return out
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				if l.peek(0) == '\n' {
+					l.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if '0' <= e && e <= '7' {
+					code := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && '0' <= l.data[l.pos] && l.data[l.pos] <= '7'; i++ {
+						code = code*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					c = byte(code)
+				} else {
+					c = e
+				}
+			}
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// skipInlineImage skips the data of an inline image up to its EI operator.
+func (l *pdfLexer) skipInlineImage() {
+	id := bytes.Index(l.data[l.pos:], []byte("ID"))
+	if id < 0 {
+		l.pos = len(l.data)
+		return
+	}
+	l.pos += id + len("ID")
+	for l.pos < len(l.data) {
+		ei := bytes.Index(l.data[l.pos:], []byte("EI"))
+		if ei < 0 {
+			l.pos = len(l.data)
+			return
+		}
+		l.pos += ei + len("EI")
+		if l.pos >= len(l.data) || isPDFSpace(l.data[l.pos]) {
+			if isPDFSpace(l.data[l.pos-len("EI")-1]) {
+				return
+			}
+		}
+	}
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
@@ -0,0 +1,117 @@
+package extract
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newPDF builds a PDF from object bodies numbered from 1 and a trailer dictionary. Streams are
+// given as a dictionary and data, which are joined with the stream keywords.
+func newPDF(objects []string, trailer string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n")
+	for i, obj := range objects {
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	fmt.Fprintf(&buf, "trailer\n%s\n%%%%EOF\n", trailer)
+	return buf.Bytes()
+}
+
+func pdfStream(dict string, data []byte) string {
+	return fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", dict, len(data), data)
+}
+
+func deflate(t *testing.T, data string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	_, err := w.Write([]byte(data))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestExtractPDF(t *testing.T) {
+	t.Run("should extract the text of the pages in order", func(t *testing.T) {
+		// Arrange
+		data := newPDF([]string{
+			"<< /Type /Catalog /Pages 2 0 R >>",
+			"<< /Type /Pages /Kids [4 0 R 3 0 R] /Count 2 /Resources << /Font << /F1 5 0 R >> >> >>",
+			"<< /Type /Page /Parent 2 0 R /Contents 6 0 R >>",
+			"<< /Type /Page /Parent 2 0 R /Contents 7 0 R >>",
+			"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+			pdfStream("", []byte("BT /F1 12 Tf 72 700 Td (Second page) Tj ET")),
+			pdfStream("/Filter /FlateDecode", deflate(t,
+				"BT /F1 12 Tf 72 720 Td (First \\(1\\) line) Tj 0 -14 Td [(spaced)-400(out)] TJ T* (caf\\351) Tj ET")),
+			"<< /Title (Quarterly report) >>",
+		}, "<< /Root 1 0 R /Info 8 0 R >>")
+
+		// Act
+		text, err := extractPDF(data, PDF)
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, "Quarterly report", text.Title)
+		assert.Equal(t, "First (1) line\nspaced out\ncafé\n\nSecond page", text.Content)
+	})
+
+	t.Run("should decode fonts with a ToUnicode map", func(t *testing.T) {
+		// Arrange
+		cmap := `/CIDInit /ProcSet findresource begin
+begincmap
+1 begincodespacerange <0000> <FFFF> endcodespacerange
+2 beginbfchar <0001> <0048> <0002> <0069> endbfchar
+1 beginbfrange <0010> <0012> <0061> endbfrange
+endcmap`
+		data := newPDF([]string{
+			"<< /Type /Catalog /Pages 2 0 R >>",
+			"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+			"<< /Type /Page /Parent 2 0 R /Resources << /Font << /F1 4 0 R >> >> /Contents 6 0 R >>",
+			"<< /Type /Font /Subtype /Type0 /ToUnicode 5 0 R >>",
+			pdfStream("/Filter /FlateDecode", deflate(t, cmap)),
+			pdfStream("", []byte("BT /F1 12 Tf [<00010002> -500 <001000110012>] TJ ET")),
+		}, "<< /Root 1 0 R >>")
+
+		// Act
+		text, err := extractPDF(data, PDF)
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, "Hi abc", text.Content)
+	})
+
+	t.Run("should read objects from object streams", func(t *testing.T) {
+		// Arrange
+		objects := "1 0 2 32 << /Type /Catalog /Pages 2 0 R >> << /Type /Pages /Kids [4 0 R] /Count 1 >>"
+		data := newPDF([]string{
+			"",
+			"",
+			pdfStream("/Type /ObjStm /N 2 /First 9 /Filter /FlateDecode", deflate(t, objects)),
+			"<< /Type /Page /Parent 2 0 R /Contents 5 0 R >>",
+			pdfStream("", []byte("BT 1 0 0 1 72 700 Tm (Top) Tj 1 0 0 1 72 680 Tm (Bottom) Tj ET")),
+		}, "<< /Root 1 0 R >>")
+
+		// Act
+		text, err := extractPDF(data, PDF)
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, "Top\nBottom", text.Content)
+	})
+
+	t.Run("should reject encrypted files", func(t *testing.T) {
+		// Arrange
+		data := newPDF([]string{"<< /Type /Catalog >>", "<< /Filter /Standard >>"}, "<< /Root 1 0 R /Encrypt 2 0 R >>")
+
+		// Act
+		_, err := extractPDF(data, PDF)
+
+		// Assert
+		assert.Error(t, err)
+	})
+}
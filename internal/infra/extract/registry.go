@@ -0,0 +1,118 @@
+// Package extract provides the text extractors of uploaded files, keyed by MIME type.
+package extract
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/igorrius/go-vector-search/internal/app"
+)
+
+// MIME types of the built-in extractors.
+const (
+	PlainText = "text/plain"
+	Markdown  = "text/markdown"
+	HTML      = "text/html"
+	PDF       = "application/pdf"
+	DOCX      = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+)
+
+// Extractor extracts the text of files of one type. The content type holds the detected MIME
+// type with the parameters declared for the file, e.g. its charset.
+type Extractor interface {
+	Extract(data []byte, contentType string) (*app.ExtractedText, error)
+}
+
+// ExtractorFunc adapts a function to an Extractor.
+type ExtractorFunc func(data []byte, contentType string) (*app.ExtractedText, error)
+
+// Extract calls f.
+func (f ExtractorFunc) Extract(data []byte, contentType string) (*app.ExtractedText, error) {
+	return f(data, contentType)
+}
+
+// Registry is a TextExtractor dispatching files to the Extractor registered for their type.
+type Registry struct {
+	extractors map[string]Extractor
+}
+
+// NewRegistry creates a Registry without extractors.
+func NewRegistry() *Registry {
+	return &Registry{extractors: make(map[string]Extractor)}
+}
+
+// NewDefaultRegistry creates a Registry with the extractors for plain text, Markdown, HTML, PDF
+// and DOCX.
+func NewDefaultRegistry() *Registry {
+	r := NewRegistry()
+	r.Register(PlainText, ExtractorFunc(extractPlainText))
+	r.Register(Markdown, ExtractorFunc(extractMarkdown))
+	r.Register(HTML, ExtractorFunc(extractHTML))
+	r.Register(PDF, ExtractorFunc(extractPDF))
+	r.Register(DOCX, ExtractorFunc(extractDOCX))
+	return r
+}
+
+// Register sets the extractor of a MIME type, replacing a previous one.
+func (r *Registry) Register(mimeType string, e Extractor) {
+	r.extractors[mimeType] = e
+}
+
+// Extract detects the type of the file and extracts its text with the registered extractor. An
+// unregistered type yields app.ErrUnsupportedMediaType.
+func (r *Registry) Extract(_ context.Context, data []byte, filename, contentType string) (*app.ExtractedText, error) {
+	mimeType, params := DetectType(data, filename, contentType)
+	e, ok := r.extractors[mimeType]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", app.ErrUnsupportedMediaType, mimeType)
+	}
+
+	text, err := e.Extract(data, mime.FormatMediaType(mimeType, params))
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract %s: %w", mimeType, err)
+	}
+	text.MIMEType = mimeType
+	return text, nil
+}
+
+// DetectType returns the MIME type of a file and the parameters declared for it. The type is
+// sniffed from the data; the declared content type and the file name extension only refine it,
+// telling Markdown from plain text or DOCX from other ZIP archives.
+func DetectType(data []byte, filename, contentType string) (string, map[string]string) {
+	declared, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		declared, params = "", nil
+	}
+	ext := strings.ToLower(filepath.Ext(filename))
+
+	sniffed, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+	switch sniffed {
+	case PDF:
+		return PDF, nil
+	case HTML:
+		return HTML, params
+	case "text/xml":
+		if declared == "application/xhtml+xml" || ext == ".xhtml" {
+			return HTML, params
+		}
+	case "application/zip":
+		if declared == DOCX || ext == ".docx" {
+			return DOCX, nil
+		}
+	case PlainText:
+		switch {
+		case declared == Markdown || declared == "text/x-markdown" || ext == ".md" || ext == ".markdown":
+			return Markdown, params
+		case declared == HTML || declared == "application/xhtml+xml" || ext == ".html" || ext == ".htm":
+			return HTML, params
+		}
+		return PlainText, params
+	}
+	return sniffed, nil
+}
+
+var _ app.TextExtractor = (*Registry)(nil)
@@ -0,0 +1,95 @@
+package extract
+
+import (
+	"context"
+	"testing"
+
+	"github.com/igorrius/go-vector-search/internal/app"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectType(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		filename    string
+		contentType string
+		want        string
+	}{
+		{name: "should sniff PDF whatever the declared type", data: "%PDF-1.7\n", filename: "a.txt", contentType: "text/plain", want: PDF},
+		{name: "should sniff HTML", data: "<!DOCTYPE html><p>hi</p>", filename: "page", want: HTML},
+		{name: "should use the Markdown extension", data: "# Title\n", filename: "notes.md", want: Markdown},
+		{name: "should use the declared Markdown type", data: "# Title\n", filename: "notes", contentType: "text/markdown", want: Markdown},
+		{name: "should default text to plain text", data: "just text", filename: "notes.txt", want: PlainText},
+		{name: "should tell DOCX by its extension", data: "PK\x03\x04rest", filename: "report.docx", want: DOCX},
+		{name: "should not trust the extension of other data", data: "\x89PNG\r\n\x1a\n", filename: "report.docx", want: "image/png"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			got, _ := DetectType([]byte(tt.data), tt.filename, tt.contentType)
+
+			// Assert
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRegistry_Extract(t *testing.T) {
+	ctx := context.Background()
+	r := NewDefaultRegistry()
+
+	t.Run("should reject unsupported types", func(t *testing.T) {
+		// Act
+		_, err := r.Extract(ctx, []byte("\x89PNG\r\n\x1a\n\x00\x00"), "image.png", "image/png")
+
+		// Assert
+		assert.ErrorIs(t, err, app.ErrUnsupportedMediaType)
+	})
+
+	t.Run("should record the detected type", func(t *testing.T) {
+		// Act
+		text, err := r.Extract(ctx, []byte("# Guide\n\nSome *text*.\r\n"), "guide.md", "")
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, Markdown, text.MIMEType)
+		assert.Equal(t, "Guide", text.Title)
+		assert.Equal(t, "# Guide\n\nSome *text*.\n", text.Content)
+	})
+
+	t.Run("should decode the declared charset", func(t *testing.T) {
+		// Act
+		text, err := r.Extract(ctx, []byte("caf\xe9"), "menu.txt", "text/plain; charset=iso-8859-1")
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, PlainText, text.MIMEType)
+		assert.Equal(t, "café", text.Content)
+	})
+
+	t.Run("should strip a UTF-8 byte order mark", func(t *testing.T) {
+		// Act
+		text, err := r.Extract(ctx, []byte("\xef\xbb\xbfhello"), "a.txt", "")
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, "hello", text.Content)
+	})
+
+	t.Run("should use a registered extractor", func(t *testing.T) {
+		// Arrange
+		custom := NewRegistry()
+		custom.Register(PlainText, ExtractorFunc(func(data []byte, _ string) (*app.ExtractedText, error) {
+			return &app.ExtractedText{Content: "custom " + string(data)}, nil
+		}))
+
+		// Act
+		text, err := custom.Extract(ctx, []byte("text"), "a.txt", "")
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, "custom text", text.Content)
+	})
+}
@@ -0,0 +1,64 @@
+package extract
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+
+	"github.com/igorrius/go-vector-search/internal/app"
+)
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// extractPlainText decodes a text file to UTF-8.
+func extractPlainText(data []byte, contentType string) (*app.ExtractedText, error) {
+	content, err := decodeText(data, contentType)
+	if err != nil {
+		return nil, err
+	}
+	return &app.ExtractedText{Content: content}, nil
+}
+
+// extractMarkdown decodes a Markdown file to UTF-8, keeping its markup so that headings, lists
+// and code blocks remain visible to the Markdown chunker. The first level-one heading is the
+// title.
+func extractMarkdown(data []byte, contentType string) (*app.ExtractedText, error) {
+	content, err := decodeText(data, contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	text := &app.ExtractedText{Content: content}
+	for _, line := range strings.Split(content, "\n") {
+		if title, ok := strings.CutPrefix(line, "# "); ok {
+			text.Title = strings.TrimSpace(title)
+			break
+		}
+	}
+	return text, nil
+}
+
+// decodeText decodes text in the charset given by a byte order mark or the content type. Without
+// either, valid UTF-8 is kept and anything else is read as Windows-1252. Line endings are
+// normalized to "\n".
+func decodeText(data []byte, contentType string) (string, error) {
+	_, params, _ := mime.ParseMediaType(contentType)
+	if params["charset"] == "" && !bytes.HasPrefix(data, utf8BOM) && utf8.Valid(data) {
+		return normalizeLineEndings(string(data)), nil
+	}
+
+	encoding, name, _ := charset.DetermineEncoding(data, contentType)
+	decoded, err := encoding.NewDecoder().Bytes(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode %s text: %w", name, err)
+	}
+	return normalizeLineEndings(strings.TrimPrefix(string(decoded), "\uFEFF")), nil
+}
+
+func normalizeLineEndings(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\r", "\n")
+}
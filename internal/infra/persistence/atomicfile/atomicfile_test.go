@@ -0,0 +1,68 @@
+package atomicfile
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFile(t *testing.T) {
+	t.Run("should replace the file and leave no temporary file", func(t *testing.T) {
+		// Arrange
+		dir := t.TempDir()
+		path := filepath.Join(dir, "state.json")
+		require.NoError(t, os.WriteFile(path, []byte("old"), 0o644))
+
+		// Act
+		err := WriteFile(path, []byte("new"))
+
+		// Assert
+		require.NoError(t, err)
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "new", string(data))
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Len(t, entries, 1)
+	})
+
+	t.Run("should fail when the directory does not exist", func(t *testing.T) {
+		// Act
+		err := WriteFile(filepath.Join(t.TempDir(), "missing", "state.json"), []byte("new"))
+
+		// Assert
+		assert.Error(t, err)
+	})
+}
+
+func TestWrite(t *testing.T) {
+	t.Run("should keep the previous content when writing fails", func(t *testing.T) {
+		// Arrange
+		dir := t.TempDir()
+		path := filepath.Join(dir, "state.json")
+		require.NoError(t, os.WriteFile(path, []byte("old"), 0o644))
+		failure := errors.New("encoding failed")
+
+		// Act
+		err := Write(path, func(w io.Writer) error {
+			if _, err := w.Write([]byte("partial")); err != nil {
+				return err
+			}
+			return failure
+		})
+
+		// Assert
+		assert.ErrorIs(t, err, failure)
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "old", string(data))
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Len(t, entries, 1)
+	})
+}
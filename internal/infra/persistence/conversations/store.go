@@ -0,0 +1,129 @@
+// Package conversations provides in-memory and file-backed stores for the conversations of
+// app.ConversationHandler.
+package conversations
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/igorrius/go-vector-search/internal/app"
+	"github.com/igorrius/go-vector-search/internal/infra/persistence/atomicfile"
+)
+
+const fileExt = ".json"
+
+// MemoryStore is a ConversationStore keeping conversations in memory until the process exits.
+type MemoryStore struct {
+	mu            sync.RWMutex
+	conversations map[string]*app.Conversation
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{conversations: make(map[string]*app.Conversation)}
+}
+
+// Save stores a copy of the conversation, replacing a conversation with the same ID.
+func (s *MemoryStore) Save(_ context.Context, conversation *app.Conversation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conversations[conversation.ID] = clone(conversation)
+	return nil
+}
+
+// FindByID returns a copy of the conversation with the given ID.
+func (s *MemoryStore) FindByID(_ context.Context, id string) (*app.Conversation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	conversation, ok := s.conversations[id]
+	if !ok {
+		return nil, app.ErrConversationNotFound
+	}
+	return clone(conversation), nil
+}
+
+// clone copies a conversation so that appending messages to the copy leaves the original intact.
+func clone(conversation *app.Conversation) *app.Conversation {
+	c := *conversation
+	c.Messages = slices.Clone(conversation.Messages)
+	return &c
+}
+
+// FileStore is a ConversationStore keeping every conversation in its own JSON file. Files are
+// replaced atomically, so a crash leaves either the previous or the new state of a conversation.
+type FileStore struct {
+	dir string
+
+	mu sync.Mutex
+}
+
+// NewFileStore creates a FileStore in dir, creating the directory if it does not exist.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create conversation directory: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Save writes the conversation, replacing a conversation with the same ID.
+func (s *FileStore) Save(_ context.Context, conversation *app.Conversation) error {
+	if !validID(conversation.ID) {
+		return fmt.Errorf("invalid conversation id %q", conversation.ID)
+	}
+	data, err := json.Marshal(conversation)
+	if err != nil {
+		return fmt.Errorf("failed to encode conversation: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := atomicfile.WriteFile(s.path(conversation.ID), data); err != nil {
+		return fmt.Errorf("failed to save conversation file: %w", err)
+	}
+	return nil
+}
+
+// FindByID reads the conversation with the given ID.
+func (s *FileStore) FindByID(_ context.Context, id string) (*app.Conversation, error) {
+	if !validID(id) {
+		return nil, app.ErrConversationNotFound
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, app.ErrConversationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read conversation file: %w", err)
+	}
+
+	var conversation app.Conversation
+	if err := json.Unmarshal(data, &conversation); err != nil {
+		return nil, fmt.Errorf("failed to decode conversation file %s: %w", id+fileExt, err)
+	}
+	return &conversation, nil
+}
+
+func (s *FileStore) path(id string) string {
+	return filepath.Join(s.dir, id+fileExt)
+}
+
+func validID(id string) bool {
+	return id != "" && !strings.ContainsAny(id, `/\`) && id != "." && id != ".."
+}
+
+var (
+	_ app.ConversationStore = (*MemoryStore)(nil)
+	_ app.ConversationStore = (*FileStore)(nil)
+)
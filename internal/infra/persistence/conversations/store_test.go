@@ -0,0 +1,112 @@
+package conversations
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/igorrius/go-vector-search/internal/app"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStores(t *testing.T) {
+	ctx := context.Background()
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	conversation := &app.Conversation{
+		ID: "conv",
+		Messages: []app.Message{
+			{Role: app.RoleUser, Content: "Is Go fast?", Query: "Is Go fast?", CreatedAt: created},
+			{
+				Role:      app.RoleAssistant,
+				Content:   "Yes [1].",
+				Query:     "Is Go fast?",
+				Sources:   []app.Source{{DocumentID: "a#0", ParentID: "a", Snippet: "Go compiles fast."}},
+				Citations: []app.Citation{{Source: 1, Start: 4, End: 7, DocumentID: "a#0", ParentID: "a"}},
+				CreatedAt: created,
+			},
+		},
+		CreatedAt: created,
+		UpdatedAt: created,
+	}
+
+	stores := map[string]func(t *testing.T) app.ConversationStore{
+		"memory": func(*testing.T) app.ConversationStore { return NewMemoryStore() },
+		"file": func(t *testing.T) app.ConversationStore {
+			s, err := NewFileStore(t.TempDir())
+			require.NoError(t, err)
+			return s
+		},
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			t.Run("should find a saved conversation", func(t *testing.T) {
+				// Arrange
+				s := newStore(t)
+				require.NoError(t, s.Save(ctx, conversation))
+
+				// Act
+				found, err := s.FindByID(ctx, "conv")
+
+				// Assert
+				require.NoError(t, err)
+				assert.Equal(t, conversation, found)
+			})
+
+			t.Run("should not share messages with the caller", func(t *testing.T) {
+				// Arrange
+				s := newStore(t)
+				require.NoError(t, s.Save(ctx, conversation))
+				found, err := s.FindByID(ctx, "conv")
+				require.NoError(t, err)
+
+				// Act
+				found.Messages = append(found.Messages[:1], app.Message{Role: app.RoleUser, Content: "changed"})
+				again, err := s.FindByID(ctx, "conv")
+
+				// Assert
+				require.NoError(t, err)
+				assert.Equal(t, conversation.Messages, again.Messages)
+			})
+
+			t.Run("should return ErrConversationNotFound for an unknown ID", func(t *testing.T) {
+				// Act
+				_, err := newStore(t).FindByID(ctx, "missing")
+
+				// Assert
+				assert.ErrorIs(t, err, app.ErrConversationNotFound)
+			})
+		})
+	}
+
+	t.Run("should keep conversations across file store instances", func(t *testing.T) {
+		// Arrange
+		dir := t.TempDir()
+		s, err := NewFileStore(dir)
+		require.NoError(t, err)
+		require.NoError(t, s.Save(ctx, conversation))
+
+		// Act
+		reopened, err := NewFileStore(dir)
+		require.NoError(t, err)
+		found, err := reopened.FindByID(ctx, "conv")
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, conversation, found)
+	})
+
+	t.Run("should reject IDs that are not file names", func(t *testing.T) {
+		// Arrange
+		s, err := NewFileStore(t.TempDir())
+		require.NoError(t, err)
+
+		// Act
+		err = s.Save(ctx, &app.Conversation{ID: "../escape"})
+		_, findErr := s.FindByID(ctx, "../escape")
+
+		// Assert
+		assert.Error(t, err)
+		assert.ErrorIs(t, findErr, app.ErrConversationNotFound)
+	})
+}
@@ -0,0 +1,334 @@
+// Package embeddings provides a file-backed persistent tier for app.CachingEmbeddingGenerator.
+package embeddings
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/igorrius/go-vector-search/internal/app"
+	"github.com/igorrius/go-vector-search/internal/infra/persistence/atomicfile"
+)
+
+const (
+	fileName        = "embeddings.log"
+	defaultMaxBytes = 256 << 20
+	// maxRecordSize bounds the allocation for a record whose length header was torn.
+	maxRecordSize = 64 << 20
+)
+
+var errCorruptRecord = errors.New("corrupt embedding cache record")
+
+// Config holds the configuration of a FileStore. Zero values select the defaults.
+type Config struct {
+	// Dir is the directory of the cache file.
+	Dir string
+	// MaxBytes is the size beyond which the file is compacted in the background to the most
+	// recent half of its entries. Default 256 MiB.
+	MaxBytes int64
+}
+
+// FileStore is an EmbeddingCacheStore keeping the embeddings in an append-only file indexed in
+// memory. Every record is framed by its length and CRC-32, so a record torn by a crash is
+// discarded when the file is opened.
+type FileStore struct {
+	path     string
+	maxBytes int64
+
+	mu          sync.Mutex
+	file        *os.File
+	size        int64
+	index       map[string]record
+	compacting  bool
+	compactions sync.WaitGroup
+}
+
+// record locates an entry in the file.
+type record struct {
+	offset    int64
+	size      int64
+	createdAt time.Time
+}
+
+// Open opens the FileStore in cfg.Dir, creating the directory and the file if needed.
+func Open(cfg Config) (*FileStore, error) {
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = defaultMaxBytes
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create embedding cache directory: %w", err)
+	}
+
+	s := &FileStore{
+		path:     filepath.Join(cfg.Dir, fileName),
+		maxBytes: cfg.MaxBytes,
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Get returns the embedding stored for the key, or app.ErrEmbeddingNotCached.
+func (s *FileStore) Get(_ context.Context, key string) (*app.CachedEmbedding, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.index[key]
+	if !ok {
+		return nil, app.ErrEmbeddingNotCached
+	}
+	frame := make([]byte, rec.size)
+	if _, err := s.file.ReadAt(frame, rec.offset); err != nil {
+		return nil, fmt.Errorf("failed to read embedding cache: %w", err)
+	}
+	_, entry, err := decodeFrame(frame)
+	if err != nil {
+		delete(s.index, key)
+		return nil, err
+	}
+	return entry, nil
+}
+
+// Put appends the entry, replacing an entry with the same key, and starts compacting the file in
+// the background once it exceeds MaxBytes.
+func (s *FileStore) Put(_ context.Context, key string, entry *app.CachedEmbedding) error {
+	frame := encodeFrame(key, entry)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.file.WriteAt(frame, s.size); err != nil {
+		return fmt.Errorf("failed to write embedding cache: %w", err)
+	}
+	s.index[key] = record{offset: s.size, size: int64(len(frame)), createdAt: entry.CreatedAt}
+	s.size += int64(len(frame))
+
+	if s.size > s.maxBytes && !s.compacting {
+		s.compacting = true
+		s.compactions.Add(1)
+		go func() {
+			defer s.compactions.Done()
+			if err := s.compact(); err != nil {
+				log.Printf("Embedding cache: %v", err)
+			}
+		}()
+	}
+	return nil
+}
+
+// Len returns the number of stored embeddings.
+func (s *FileStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.index)
+}
+
+// Close waits for a running compaction and closes the file.
+func (s *FileStore) Close() error {
+	s.compactions.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+// open opens the file and indexes its intact records, truncating it after the last one.
+func (s *FileStore) open() error {
+	file, err := os.OpenFile(s.path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open embedding cache: %w", err)
+	}
+
+	index := make(map[string]record)
+	r := bufio.NewReader(file)
+	var offset int64
+	for {
+		key, entry, n, err := readFrame(r)
+		if err != nil {
+			break
+		}
+		index[key] = record{offset: offset, size: n, createdAt: entry.CreatedAt}
+		offset += n
+	}
+	if err := file.Truncate(offset); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to truncate embedding cache: %w", err)
+	}
+
+	s.file = file
+	s.size = offset
+	s.index = index
+	return nil
+}
+
+// compact rewrites the file with the most recent entries fitting into half of MaxBytes. The
+// entries are copied without holding the lock, so that reads and writes continue meanwhile; the
+// entries written in the meantime are carried over when the new file replaces the old one.
+func (s *FileStore) compact() error {
+	defer func() {
+		s.mu.Lock()
+		s.compacting = false
+		s.mu.Unlock()
+	}()
+
+	s.mu.Lock()
+	old, end := s.file, s.size
+	keys := make([]string, 0, len(s.index))
+	records := make(map[string]record, len(s.index))
+	for key, rec := range s.index {
+		keys = append(keys, key)
+		records[key] = rec
+	}
+	s.mu.Unlock()
+	sort.Slice(keys, func(i, j int) bool {
+		return records[keys[i]].createdAt.After(records[keys[j]].createdAt)
+	})
+
+	// The old file stays open until it is replaced, and the records up to end are never
+	// rewritten, so they can be read without the lock. The lock is taken to carry over the
+	// entries written meanwhile and held until the new file is in place.
+	locked := false
+	defer func() {
+		if locked {
+			s.mu.Unlock()
+		}
+	}()
+	index := make(map[string]record)
+	var size int64
+	err := atomicfile.Write(s.path, func(w io.Writer) error {
+		for _, key := range keys {
+			rec := records[key]
+			if size+rec.size > s.maxBytes/2 {
+				break
+			}
+			if err := copyFrame(old, w, rec); err != nil {
+				return err
+			}
+			index[key] = record{offset: size, size: rec.size, createdAt: rec.createdAt}
+			size += rec.size
+		}
+
+		s.mu.Lock()
+		locked = true
+		for key, rec := range s.index {
+			if rec.offset < end {
+				continue
+			}
+			if err := copyFrame(s.file, w, rec); err != nil {
+				return err
+			}
+			index[key] = record{offset: size, size: rec.size, createdAt: rec.createdAt}
+			size += rec.size
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to replace embedding cache: %w", err)
+	}
+	file, err := os.OpenFile(s.path, os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open embedding cache: %w", err)
+	}
+
+	for key := range index {
+		// Entries found corrupt meanwhile were dropped from the index.
+		if _, ok := s.index[key]; !ok {
+			delete(index, key)
+		}
+	}
+	s.file = file
+	s.size = size
+	s.index = index
+	if err := old.Close(); err != nil {
+		return fmt.Errorf("failed to close embedding cache: %w", err)
+	}
+	return nil
+}
+
+// copyFrame copies the record from the file to w.
+func copyFrame(from *os.File, w io.Writer, rec record) error {
+	frame := make([]byte, rec.size)
+	if _, err := from.ReadAt(frame, rec.offset); err != nil {
+		return fmt.Errorf("failed to read embedding cache: %w", err)
+	}
+	if _, err := w.Write(frame); err != nil {
+		return fmt.Errorf("failed to write embedding cache: %w", err)
+	}
+	return nil
+}
+
+// encodeFrame encodes the entry as a record framed by its length and CRC-32. The payload is the
+// key length and key, the creation time in Unix nanoseconds and the little-endian components.
+func encodeFrame(key string, entry *app.CachedEmbedding) []byte {
+	payload := make([]byte, 0, 2+len(key)+8+4*len(entry.Embedding))
+	payload = binary.LittleEndian.AppendUint16(payload, uint16(len(key)))
+	payload = append(payload, key...)
+	payload = binary.LittleEndian.AppendUint64(payload, uint64(entry.CreatedAt.UnixNano()))
+	for _, x := range entry.Embedding {
+		payload = binary.LittleEndian.AppendUint32(payload, math.Float32bits(x))
+	}
+
+	frame := make([]byte, 8, 8+len(payload))
+	binary.LittleEndian.PutUint32(frame[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(frame[4:8], crc32.ChecksumIEEE(payload))
+	return append(frame, payload...)
+}
+
+// readFrame reads one framed record and returns it with its size on disk.
+func readFrame(r io.Reader) (string, *app.CachedEmbedding, int64, error) {
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return "", nil, 0, err
+	}
+	size := binary.LittleEndian.Uint32(header[0:4])
+	if size > maxRecordSize {
+		return "", nil, 0, errCorruptRecord
+	}
+	frame := append(header, make([]byte, size)...)
+	if _, err := io.ReadFull(r, frame[8:]); err != nil {
+		return "", nil, 0, err
+	}
+	key, entry, err := decodeFrame(frame)
+	return key, entry, int64(len(frame)), err
+}
+
+// decodeFrame decodes a record written by encodeFrame.
+func decodeFrame(frame []byte) (string, *app.CachedEmbedding, error) {
+	if len(frame) < 8 {
+		return "", nil, errCorruptRecord
+	}
+	payload := frame[8:]
+	if int(binary.LittleEndian.Uint32(frame[0:4])) != len(payload) ||
+		crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(frame[4:8]) || len(payload) < 2 {
+		return "", nil, errCorruptRecord
+	}
+
+	keyLen := int(binary.LittleEndian.Uint16(payload[0:2]))
+	payload = payload[2:]
+	if len(payload) < keyLen+8 || (len(payload)-keyLen-8)%4 != 0 {
+		return "", nil, errCorruptRecord
+	}
+	key := string(payload[:keyLen])
+	createdAt := time.Unix(0, int64(binary.LittleEndian.Uint64(payload[keyLen:]))).UTC()
+	payload = payload[keyLen+8:]
+
+	embedding := make([]float32, len(payload)/4)
+	for i := range embedding {
+		embedding[i] = math.Float32frombits(binary.LittleEndian.Uint32(payload[4*i:]))
+	}
+	return key, &app.CachedEmbedding{Embedding: embedding, CreatedAt: createdAt}, nil
+}
+
+var _ app.EmbeddingCacheStore = (*FileStore)(nil)
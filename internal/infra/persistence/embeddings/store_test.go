@@ -0,0 +1,125 @@
+package embeddings
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/igorrius/go-vector-search/internal/app"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	t.Run("should keep embeddings across instances", func(t *testing.T) {
+		// Arrange
+		dir := t.TempDir()
+		s, err := Open(Config{Dir: dir})
+		require.NoError(t, err)
+		entry := &app.CachedEmbedding{Embedding: []float32{0.5, -1, 2}, CreatedAt: created}
+		require.NoError(t, s.Put(ctx, "key", entry))
+		require.NoError(t, s.Close())
+
+		// Act
+		reopened, err := Open(Config{Dir: dir})
+		require.NoError(t, err)
+		defer reopened.Close()
+		found, err := reopened.Get(ctx, "key")
+		_, missing := reopened.Get(ctx, "missing")
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, entry, found)
+		assert.ErrorIs(t, missing, app.ErrEmbeddingNotCached)
+	})
+
+	t.Run("should discard a torn record", func(t *testing.T) {
+		// Arrange
+		dir := t.TempDir()
+		s, err := Open(Config{Dir: dir})
+		require.NoError(t, err)
+		require.NoError(t, s.Put(ctx, "a", &app.CachedEmbedding{Embedding: []float32{1}, CreatedAt: created}))
+		require.NoError(t, s.Put(ctx, "b", &app.CachedEmbedding{Embedding: []float32{2}, CreatedAt: created}))
+		require.NoError(t, s.Close())
+		path := filepath.Join(dir, fileName)
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		require.NoError(t, os.Truncate(path, info.Size()-2))
+
+		// Act
+		reopened, err := Open(Config{Dir: dir})
+		require.NoError(t, err)
+		defer reopened.Close()
+		_, errA := reopened.Get(ctx, "a")
+		_, errB := reopened.Get(ctx, "b")
+
+		// Assert
+		assert.NoError(t, errA)
+		assert.ErrorIs(t, errB, app.ErrEmbeddingNotCached)
+	})
+
+	t.Run("should compact to the most recent entries", func(t *testing.T) {
+		// Arrange
+		s, err := Open(Config{Dir: t.TempDir(), MaxBytes: 4096})
+		require.NoError(t, err)
+		defer s.Close()
+		embedding := make([]float32, 16)
+
+		// Act
+		for i := 0; i < 100; i++ {
+			entry := &app.CachedEmbedding{Embedding: embedding, CreatedAt: created.Add(time.Duration(i) * time.Second)}
+			require.NoError(t, s.Put(ctx, fmt.Sprintf("key-%d", i), entry))
+		}
+		s.compactions.Wait()
+
+		// Assert
+		assert.Less(t, s.Len(), 100)
+		_, oldest := s.Get(ctx, "key-0")
+		assert.ErrorIs(t, oldest, app.ErrEmbeddingNotCached)
+		newest, err := s.Get(ctx, "key-99")
+		require.NoError(t, err)
+		assert.Equal(t, embedding, newest.Embedding)
+	})
+	t.Run("should keep serving and persist the entries while compacting", func(t *testing.T) {
+		// Arrange
+		dir := t.TempDir()
+		s, err := Open(Config{Dir: dir, MaxBytes: 4096})
+		require.NoError(t, err)
+		embedding := make([]float32, 16)
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				s.Get(ctx, fmt.Sprintf("key-%d", i))
+			}
+		}()
+
+		// Act
+		for i := 0; i < 500; i++ {
+			entry := &app.CachedEmbedding{Embedding: embedding, CreatedAt: created.Add(time.Duration(i) * time.Second)}
+			require.NoError(t, s.Put(ctx, fmt.Sprintf("key-%d", i), entry))
+		}
+		wg.Wait()
+		require.NoError(t, s.Close())
+		reopened, err := Open(Config{Dir: dir, MaxBytes: 4096})
+		require.NoError(t, err)
+		defer reopened.Close()
+
+		// Assert
+		assert.Less(t, reopened.Len(), 500)
+		newest, err := reopened.Get(ctx, "key-499")
+		require.NoError(t, err)
+		assert.Equal(t, embedding, newest.Embedding)
+		matches, err := filepath.Glob(filepath.Join(dir, "*.tmp"))
+		require.NoError(t, err)
+		assert.Empty(t, matches)
+	})
+}
@@ -0,0 +1,272 @@
+package hnsw
+
+import (
+	"container/heap"
+	"math"
+	"math/rand/v2"
+	"sort"
+
+	"github.com/igorrius/go-vector-search/internal/app"
+	"github.com/igorrius/go-vector-search/internal/domain"
+)
+
+// node is a vector in the graph. A deleted node keeps its vector and links so the graph stays
+// navigable, but is never returned.
+type node struct {
+	vector []float32
+	// doc is the stored document, nil once the node is deleted.
+	doc   *domain.Document
+	level int
+	// friends holds the neighbour IDs of the node on every layer up to its level.
+	friends [][]uint32
+}
+
+// graph is a hierarchical navigable small world graph as described by Malkov and Yashunin.
+// It is not safe for concurrent use.
+type graph struct {
+	metric         app.DistanceMetric
+	m              int
+	efConstruction int
+	levelMult      float64
+	rng            *rand.Rand
+
+	nodes []*node
+	// entry is the ID of the node on the top layer that searches start from, -1 when empty.
+	entry    int
+	maxLevel int
+}
+
+func newGraph(metric app.DistanceMetric, m, efConstruction int, seed uint64) *graph {
+	return &graph{
+		metric:         metric,
+		m:              m,
+		efConstruction: efConstruction,
+		levelMult:      1 / math.Log(float64(m)),
+		rng:            rand.New(rand.NewPCG(seed, seed)),
+		entry:          -1,
+	}
+}
+
+type candidate struct {
+	id   uint32
+	dist float64
+}
+
+func (g *graph) distance(q []float32, id uint32) float64 {
+	return g.metric.Distance(q, g.nodes[id].vector)
+}
+
+// maxFriends is the maximum number of links of a node on a layer; the bottom layer is denser.
+func (g *graph) maxFriends(layer int) int {
+	if layer == 0 {
+		return 2 * g.m
+	}
+	return g.m
+}
+
+func (g *graph) randomLevel() int {
+	return int(math.Floor(-math.Log(1-g.rng.Float64()) * g.levelMult))
+}
+
+// insert adds a node for the document and links it into every layer up to a random level.
+func (g *graph) insert(vector []float32, doc *domain.Document) uint32 {
+	id := uint32(len(g.nodes))
+	level := g.randomLevel()
+	n := &node{vector: vector, doc: doc, level: level, friends: make([][]uint32, level+1)}
+	g.nodes = append(g.nodes, n)
+	g.link(id, level)
+	return id
+}
+
+// link connects an appended node to its nearest neighbours.
+func (g *graph) link(id uint32, level int) {
+	if g.entry < 0 {
+		g.entry, g.maxLevel = int(id), level
+		return
+	}
+
+	vector := g.nodes[id].vector
+	ep := []candidate{{id: uint32(g.entry), dist: g.distance(vector, uint32(g.entry))}}
+	for layer := g.maxLevel; layer > level; layer-- {
+		ep = g.searchLayer(vector, ep, 1, layer)
+	}
+	for layer := min(level, g.maxLevel); layer >= 0; layer-- {
+		found := g.searchLayer(vector, ep, g.efConstruction, layer)
+		neighbours := g.selectNeighbours(found, g.m)
+		g.nodes[id].friends[layer] = candidateIDs(neighbours)
+		for _, nb := range neighbours {
+			g.addFriend(nb.id, id, layer)
+		}
+		ep = found
+	}
+
+	if level > g.maxLevel {
+		g.entry, g.maxLevel = int(id), level
+	}
+}
+
+// addFriend links from to to on the layer, pruning the links of from when it has too many.
+func (g *graph) addFriend(from, to uint32, layer int) {
+	n := g.nodes[from]
+	n.friends[layer] = append(n.friends[layer], to)
+	if len(n.friends[layer]) <= g.maxFriends(layer) {
+		return
+	}
+
+	cands := make([]candidate, len(n.friends[layer]))
+	for i, f := range n.friends[layer] {
+		cands[i] = candidate{id: f, dist: g.distance(n.vector, f)}
+	}
+	sortCandidates(cands)
+	n.friends[layer] = candidateIDs(g.selectNeighbours(cands, g.maxFriends(layer)))
+}
+
+// selectNeighbours picks up to m of the candidates, sorted by distance, preferring candidates
+// closer to the base than to any already selected one so that links spread in all directions.
+// Pruned candidates fill the remaining slots.
+func (g *graph) selectNeighbours(cands []candidate, m int) []candidate {
+	if len(cands) <= m {
+		return cands
+	}
+
+	selected := make([]candidate, 0, m)
+	var pruned []candidate
+	for _, c := range cands {
+		if len(selected) == m {
+			break
+		}
+		diverse := true
+		for _, s := range selected {
+			if g.distance(g.nodes[c.id].vector, s.id) < c.dist {
+				diverse = false
+				break
+			}
+		}
+		if diverse {
+			selected = append(selected, c)
+		} else {
+			pruned = append(pruned, c)
+		}
+	}
+	for _, c := range pruned {
+		if len(selected) == m {
+			break
+		}
+		selected = append(selected, c)
+	}
+	return selected
+}
+
+// searchLayer returns the ef nodes of the layer closest to q reachable from the entry points,
+// sorted by distance.
+func (g *graph) searchLayer(q []float32, entryPoints []candidate, ef, layer int) []candidate {
+	visited := make(map[uint32]struct{}, ef*4)
+	cands := &minHeap{}
+	results := &maxHeap{}
+	for _, ep := range entryPoints {
+		visited[ep.id] = struct{}{}
+		heap.Push(cands, ep)
+		heap.Push(results, ep)
+		if results.Len() > ef {
+			heap.Pop(results)
+		}
+	}
+
+	for cands.Len() > 0 {
+		c := heap.Pop(cands).(candidate)
+		if results.Len() >= ef && c.dist > (*results)[0].dist {
+			break
+		}
+		for _, f := range g.nodes[c.id].friends[layer] {
+			if _, ok := visited[f]; ok {
+				continue
+			}
+			visited[f] = struct{}{}
+			d := g.distance(q, f)
+			if results.Len() < ef || d < (*results)[0].dist {
+				heap.Push(cands, candidate{id: f, dist: d})
+				heap.Push(results, candidate{id: f, dist: d})
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+
+	found := []candidate(*results)
+	sortCandidates(found)
+	return found
+}
+
+// search returns up to k live nodes nearest to q that are accepted, exploring ef candidates.
+func (g *graph) search(q []float32, k, ef int, accept func(*node) bool) []candidate {
+	if g.entry < 0 {
+		return nil
+	}
+
+	ep := []candidate{{id: uint32(g.entry), dist: g.distance(q, uint32(g.entry))}}
+	for layer := g.maxLevel; layer > 0; layer-- {
+		ep = g.searchLayer(q, ep, 1, layer)
+	}
+
+	var found []candidate
+	for _, c := range g.searchLayer(q, ep, max(ef, k), 0) {
+		if n := g.nodes[c.id]; n.doc != nil && accept(n) {
+			found = append(found, c)
+			if len(found) == k {
+				break
+			}
+		}
+	}
+	return found
+}
+
+// exhaustiveSearch compares q with every accepted live node.
+func (g *graph) exhaustiveSearch(q []float32, k int, accept func(*node) bool) []candidate {
+	var found []candidate
+	for id, n := range g.nodes {
+		if n.doc != nil && accept(n) {
+			found = append(found, candidate{id: uint32(id), dist: g.distance(q, uint32(id))})
+		}
+	}
+	sortCandidates(found)
+	return found[:min(k, len(found))]
+}
+
+func sortCandidates(cands []candidate) {
+	sort.Slice(cands, func(i, j int) bool { return cands[i].dist < cands[j].dist })
+}
+
+func candidateIDs(cands []candidate) []uint32 {
+	ids := make([]uint32, len(cands))
+	for i, c := range cands {
+		ids[i] = c.id
+	}
+	return ids
+}
+
+type minHeap []candidate
+
+func (h minHeap) Len() int            { return len(h) }
+func (h minHeap) Less(i, j int) bool  { return h[i].dist < h[j].dist }
+func (h minHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *minHeap) Push(x interface{}) { *h = append(*h, x.(candidate)) }
+func (h *minHeap) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+type maxHeap []candidate
+
+func (h maxHeap) Len() int            { return len(h) }
+func (h maxHeap) Less(i, j int) bool  { return h[i].dist > h[j].dist }
+func (h maxHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *maxHeap) Push(x interface{}) { *h = append(*h, x.(candidate)) }
+func (h *maxHeap) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
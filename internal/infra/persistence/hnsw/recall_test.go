@@ -0,0 +1,125 @@
+package hnsw
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/igorrius/go-vector-search/internal/app"
+	"github.com/igorrius/go-vector-search/internal/domain"
+	"github.com/igorrius/go-vector-search/internal/infra/persistence/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	recallDimension = 32
+	recallK         = 10
+)
+
+type vectorStore interface {
+	domain.DocumentRepository
+	app.VectorStore
+}
+
+func randomVectors(rng *rand.Rand, n int) [][]float32 {
+	vectors := make([][]float32, n)
+	for i := range vectors {
+		vectors[i] = make([]float32, recallDimension)
+		for j := range vectors[i] {
+			vectors[i][j] = float32(rng.NormFloat64())
+		}
+	}
+	return vectors
+}
+
+func fill(tb testing.TB, vectors [][]float32, stores ...vectorStore) {
+	tb.Helper()
+	for i, v := range vectors {
+		doc := &domain.Document{ID: fmt.Sprintf("doc-%d", i), Embedding: v}
+		for _, s := range stores {
+			require.NoError(tb, s.Save(context.Background(), doc))
+		}
+	}
+}
+
+// recall returns the share of the exact top-k neighbours the index finds, averaged over queries.
+func recall(tb testing.TB, index, exact app.VectorStore, queries [][]float32) float64 {
+	tb.Helper()
+	ctx := context.Background()
+	found := 0
+	for _, q := range queries {
+		opts := app.SearchOptions{Embedding: q, Limit: recallK}
+		want, err := exact.Search(ctx, opts)
+		require.NoError(tb, err)
+		got, err := index.Search(ctx, opts)
+		require.NoError(tb, err)
+
+		truth := make(map[string]bool)
+		for _, hit := range want {
+			truth[hit.Document.ID] = true
+		}
+		for _, hit := range got {
+			if truth[hit.Document.ID] {
+				found++
+			}
+		}
+	}
+	return float64(found) / float64(len(queries)*recallK)
+}
+
+func TestStore_Recall(t *testing.T) {
+	// Arrange
+	rng := rand.New(rand.NewPCG(1, 2))
+	index := openStore(t, Config{Dimension: recallDimension, Seed: 42})
+	exact := memory.NewStore(app.Cosine)
+	fill(t, randomVectors(rng, 2000), index, exact)
+
+	// Act
+	r := recall(t, index, exact, randomVectors(rng, 50))
+
+	// Assert
+	assert.GreaterOrEqual(t, r, 0.95)
+}
+
+// BenchmarkSearch compares HNSW search with the brute-force memory store and reports the recall
+// of each HNSW configuration.
+func BenchmarkSearch(b *testing.B) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	vectors := randomVectors(rng, 10000)
+	queries := randomVectors(rng, 100)
+
+	exact := memory.NewStore(app.Cosine)
+	fill(b, vectors, exact)
+	b.Run("brute-force", func(b *testing.B) {
+		benchmarkQueries(b, exact, queries)
+	})
+
+	for _, cfg := range []Config{
+		{M: 8, EfSearch: 32},
+		{M: 16, EfSearch: 64},
+		{M: 32, EfSearch: 128},
+	} {
+		cfg.Dimension = recallDimension
+		index, err := Open(cfg)
+		require.NoError(b, err)
+		fill(b, vectors, index)
+
+		b.Run(fmt.Sprintf("hnsw/M=%d/ef=%d", cfg.M, cfg.EfSearch), func(b *testing.B) {
+			benchmarkQueries(b, index, queries)
+			b.ReportMetric(recall(b, index, exact, queries), "recall@10")
+		})
+	}
+}
+
+func benchmarkQueries(b *testing.B, s app.VectorStore, queries [][]float32) {
+	ctx := context.Background()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := s.Search(ctx, app.SearchOptions{Embedding: queries[i%len(queries)], Limit: recallK}); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+}
@@ -0,0 +1,107 @@
+package hnsw
+
+import (
+	"bufio"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/igorrius/go-vector-search/internal/app"
+	"github.com/igorrius/go-vector-search/internal/domain"
+	"github.com/igorrius/go-vector-search/internal/infra/persistence/atomicfile"
+)
+
+// snapshotVersion is the version of the snapshot file format.
+const snapshotVersion = 1
+
+// snapshot is the serialized state of the graph.
+type snapshot struct {
+	Version   int
+	Dimension int
+	Metric    app.DistanceMetric
+	Entry     int
+	MaxLevel  int
+	Nodes     []snapshotNode
+}
+
+type snapshotNode struct {
+	Vector  []float32
+	Level   int
+	Friends [][]uint32
+	// Doc is stored without its embedding, which is Vector; nil for a deleted node.
+	Doc *domain.Document
+}
+
+// writeSnapshot atomically replaces the snapshot file with the state of the graph.
+func writeSnapshot(path string, dimension int, g *graph) error {
+	snap := snapshot{
+		Version:   snapshotVersion,
+		Dimension: dimension,
+		Metric:    g.metric,
+		Entry:     g.entry,
+		MaxLevel:  g.maxLevel,
+		Nodes:     make([]snapshotNode, len(g.nodes)),
+	}
+	for i, n := range g.nodes {
+		sn := snapshotNode{Vector: n.vector, Level: n.level, Friends: n.friends}
+		if n.doc != nil {
+			doc := *n.doc
+			doc.Embedding = nil
+			sn.Doc = &doc
+		}
+		snap.Nodes[i] = sn
+	}
+
+	err := atomicfile.Write(path, func(w io.Writer) error {
+		if err := gob.NewEncoder(w).Encode(snap); err != nil {
+			return fmt.Errorf("failed to encode snapshot: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return nil
+}
+
+// readSnapshot restores the graph from the snapshot file. A missing file leaves it empty.
+func readSnapshot(path string, dimension int, g *graph) error {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer file.Close()
+
+	var snap snapshot
+	if err := gob.NewDecoder(bufio.NewReader(file)).Decode(&snap); err != nil {
+		return fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+	switch {
+	case snap.Version != snapshotVersion:
+		return fmt.Errorf("unsupported snapshot version %d", snap.Version)
+	case snap.Dimension != dimension:
+		return fmt.Errorf("snapshot has dimension %d, index is configured for %d", snap.Dimension, dimension)
+	case snap.Metric != g.metric:
+		return fmt.Errorf("snapshot uses metric %q, index is configured for %q", snap.Metric, g.metric)
+	}
+
+	g.entry, g.maxLevel = snap.Entry, snap.MaxLevel
+	g.nodes = make([]*node, len(snap.Nodes))
+	for i, sn := range snap.Nodes {
+		n := &node{vector: sn.Vector, level: sn.Level, friends: sn.Friends, doc: sn.Doc}
+		if n.doc != nil {
+			n.doc.Embedding = n.vector
+		}
+		// Gob omits empty slices, so layers without links decode as missing.
+		for len(n.friends) <= n.level {
+			n.friends = append(n.friends, nil)
+		}
+		g.nodes[i] = n
+	}
+	return nil
+}
@@ -0,0 +1,334 @@
+// Package hnsw provides an embedded approximate nearest neighbour store backed by a hierarchical
+// navigable small world graph, persisted to a snapshot file and a write-ahead log.
+package hnsw
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/igorrius/go-vector-search/internal/app"
+	"github.com/igorrius/go-vector-search/internal/domain"
+	"github.com/igorrius/go-vector-search/internal/infra/persistence/memory"
+)
+
+const (
+	defaultM              = 16
+	defaultEfConstruction = 200
+	defaultEfSearch       = 64
+	defaultSnapshotEvery  = 1000
+
+	snapshotFile = "index.snapshot"
+	walFile      = "index.wal"
+
+	// minCompaction is the number of deleted nodes below which the graph is never rebuilt.
+	minCompaction = 64
+)
+
+// Config holds the configuration of a Store. Zero values select the defaults.
+type Config struct {
+	// Dimension is the length of the stored embeddings. Required.
+	Dimension int
+	// Metric is the similarity function; the default is cosine.
+	Metric app.DistanceMetric
+	// M is the number of links per node and layer, twice that on the bottom layer. Higher values
+	// raise recall and memory use. Default 16.
+	M int
+	// EfConstruction is the size of the candidate list when inserting. Default 200.
+	EfConstruction int
+	// EfSearch is the size of the candidate list when searching, raised to the requested number
+	// of hits if lower. Default 64.
+	EfSearch int
+	// Dir is the directory of the snapshot and write-ahead log. Empty keeps the index in memory.
+	Dir string
+	// SnapshotEvery is the number of logged changes after which a snapshot is written. Default 1000.
+	SnapshotEvery int
+	// Seed seeds the random level assignment.
+	Seed uint64
+}
+
+// Store is an embedded DocumentRepository and VectorStore searching an HNSW graph.
+// Every change is logged durably before it is applied, so an index opened after a crash recovers
+// from the latest snapshot and the log.
+type Store struct {
+	cfg Config
+
+	mu         sync.RWMutex
+	graph      *graph
+	ids        map[string]uint32
+	deleted    int
+	wal        *wal
+	walRecords int
+}
+
+// Open opens the index in cfg.Dir, creating it if it does not exist.
+func Open(cfg Config) (*Store, error) {
+	if cfg.Dimension <= 0 {
+		return nil, fmt.Errorf("embedding dimension must be positive, got %d", cfg.Dimension)
+	}
+	if cfg.Metric == "" {
+		cfg.Metric = app.Cosine
+	}
+	if cfg.M == 0 {
+		cfg.M = defaultM
+	}
+	if cfg.M < 2 {
+		return nil, fmt.Errorf("M must be at least 2, got %d", cfg.M)
+	}
+	if cfg.EfConstruction == 0 {
+		cfg.EfConstruction = defaultEfConstruction
+	}
+	if cfg.EfSearch == 0 {
+		cfg.EfSearch = defaultEfSearch
+	}
+	if cfg.SnapshotEvery == 0 {
+		cfg.SnapshotEvery = defaultSnapshotEvery
+	}
+
+	s := &Store{
+		cfg:   cfg,
+		graph: newGraph(cfg.Metric, cfg.M, cfg.EfConstruction, cfg.Seed),
+		ids:   make(map[string]uint32),
+	}
+	if cfg.Dir == "" {
+		return s, nil
+	}
+
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create index directory: %w", err)
+	}
+	if err := readSnapshot(filepath.Join(cfg.Dir, snapshotFile), cfg.Dimension, s.graph); err != nil {
+		return nil, err
+	}
+	for id, n := range s.graph.nodes {
+		if n.doc != nil {
+			s.ids[n.doc.ID] = uint32(id)
+		} else {
+			s.deleted++
+		}
+	}
+
+	w, err := openWAL(filepath.Join(cfg.Dir, walFile))
+	if err != nil {
+		return nil, err
+	}
+	s.walRecords, err = w.replay(s.apply)
+	if err != nil {
+		w.close()
+		return nil, err
+	}
+	s.wal = w
+	return s, nil
+}
+
+// EmbeddingDimension returns the configured embedding dimension.
+func (s *Store) EmbeddingDimension() int {
+	return s.cfg.Dimension
+}
+
+// Save inserts the document, replacing a document with the same ID.
+func (s *Store) Save(_ context.Context, doc *domain.Document) error {
+	if len(doc.Embedding) != s.cfg.Dimension {
+		return &app.DimensionMismatchError{Expected: s.cfg.Dimension, Actual: len(doc.Embedding)}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.log(walRecord{Op: walPut, ID: doc.ID, Doc: memory.CloneDocument(doc)})
+}
+
+// Delete removes the document with the given ID.
+func (s *Store) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.ids[id]; !ok {
+		return domain.ErrDocumentNotFound
+	}
+	return s.log(walRecord{Op: walDelete, ID: id})
+}
+
+// FindByID returns a copy of the document with the given ID.
+func (s *Store) FindByID(_ context.Context, id string) (*domain.Document, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i, ok := s.ids[id]
+	if !ok {
+		return nil, domain.ErrDocumentNotFound
+	}
+	return memory.CloneDocument(s.graph.nodes[i].doc), nil
+}
+
+// List returns a page of documents ordered by ID.
+func (s *Store) List(_ context.Context, opts app.ListOptions) (*app.DocumentPage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	docs := make([]*domain.Document, 0, len(s.ids))
+	for _, i := range s.ids {
+		docs = append(docs, s.graph.nodes[i].doc)
+	}
+	return memory.List(docs, opts)
+}
+
+// Search returns the approximate nearest documents to the query embedding. A filter is applied
+// to the candidates collected by walking the graph; when the filter or deleted nodes leave too
+// few hits, the matching documents are compared exhaustively instead. With opts.Text set,
+// keyword matches are fused in as in memory.Store.
+func (s *Store) Search(ctx context.Context, opts app.SearchOptions) ([]app.SearchHit, error) {
+	match, err := memory.CompileFilter(opts.Filter)
+	if err != nil {
+		return nil, err
+	}
+	accept := func(n *node) bool { return match(n.doc) }
+	want := opts.Offset + opts.Limit
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	byID := make(map[uint32]*app.SearchHit)
+	hit := func(id uint32) *app.SearchHit {
+		h, ok := byID[id]
+		if !ok {
+			n := s.graph.nodes[id]
+			// Stored documents are never mutated, so the shallow copy is cloned only if returned.
+			h = &app.SearchHit{Document: *n.doc}
+			if len(opts.Embedding) == s.cfg.Dimension {
+				memory.Score(h, s.cfg.Metric, opts.Embedding, n.vector)
+			}
+			byID[id] = h
+		}
+		return h
+	}
+
+	var vectorHits []*app.SearchHit
+	if len(opts.Embedding) > 0 && (opts.Text == "" || opts.Alpha > 0) {
+		if len(opts.Embedding) != s.cfg.Dimension {
+			return nil, &app.DimensionMismatchError{Expected: s.cfg.Dimension, Actual: len(opts.Embedding)}
+		}
+		found := s.graph.search(opts.Embedding, want, s.cfg.EfSearch, accept)
+		if len(found) < want {
+			found = s.graph.exhaustiveSearch(opts.Embedding, want, accept)
+		}
+		for _, c := range found {
+			if h := hit(c.id); opts.MeetsMinScore(h.Score) {
+				vectorHits = append(vectorHits, h)
+			}
+		}
+	}
+
+	ranked := vectorHits
+	if opts.Text != "" {
+		queryTerms := memory.Terms(opts.Text)
+		var textHits []*app.SearchHit
+		for _, id := range s.ids {
+			n := s.graph.nodes[id]
+			if !accept(n) {
+				continue
+			}
+			if matches := memory.CountMatches(queryTerms, n.doc.Content); matches > 0 {
+				h := hit(id)
+				h.TextMatch = float64(matches)
+				textHits = append(textHits, h)
+			}
+		}
+		ranked = memory.FuseRanks(vectorHits, textHits, opts.Alpha)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return memory.Page(ranked, opts.Offset, opts.Limit), nil
+}
+
+// Snapshot writes the index to the snapshot file and empties the write-ahead log.
+func (s *Store) Snapshot() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.snapshot()
+}
+
+// Close writes a final snapshot and releases the index files.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.wal == nil {
+		return nil
+	}
+	err := s.snapshot()
+	return errors.Join(err, s.wal.close())
+}
+
+// log durably records the change, applies it and snapshots the index when the log has grown. A
+// failed snapshot is logged, not returned, as the change is already durable in the log; it is
+// retried with the next change.
+func (s *Store) log(rec walRecord) error {
+	if s.wal == nil {
+		s.apply(rec)
+		return nil
+	}
+
+	if err := s.wal.append(rec); err != nil {
+		return err
+	}
+	s.apply(rec)
+	s.walRecords++
+	if s.walRecords >= s.cfg.SnapshotEvery {
+		if err := s.snapshot(); err != nil {
+			log.Printf("HNSW store: %v", err)
+		}
+	}
+	return nil
+}
+
+// apply changes the in-memory index. Replaying an applied record leaves the index equivalent.
+func (s *Store) apply(rec walRecord) {
+	if old, ok := s.ids[rec.ID]; ok {
+		s.graph.nodes[old].doc = nil
+		delete(s.ids, rec.ID)
+		s.deleted++
+	}
+	if rec.Op == walPut {
+		s.ids[rec.ID] = s.graph.insert(rec.Doc.Embedding, rec.Doc)
+	}
+
+	if s.deleted >= minCompaction && s.deleted > len(s.ids) {
+		s.compact()
+	}
+}
+
+// compact rebuilds the graph from the live documents, dropping deleted nodes.
+func (s *Store) compact() {
+	old := s.graph
+	s.graph = newGraph(s.cfg.Metric, s.cfg.M, s.cfg.EfConstruction, s.cfg.Seed)
+	s.ids = make(map[string]uint32, len(s.ids))
+	s.deleted = 0
+	for _, n := range old.nodes {
+		if n.doc != nil {
+			s.ids[n.doc.ID] = s.graph.insert(n.vector, n.doc)
+		}
+	}
+}
+
+func (s *Store) snapshot() error {
+	if s.wal == nil {
+		return nil
+	}
+	if err := writeSnapshot(filepath.Join(s.cfg.Dir, snapshotFile), s.cfg.Dimension, s.graph); err != nil {
+		return err
+	}
+	s.walRecords = 0
+	return s.wal.reset()
+}
+
+var (
+	_ app.DocumentStore    = (*Store)(nil)
+	_ app.VectorStore      = (*Store)(nil)
+	_ app.DimensionedStore = (*Store)(nil)
+)
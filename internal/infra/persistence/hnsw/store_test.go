@@ -0,0 +1,231 @@
+package hnsw
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/igorrius/go-vector-search/internal/app"
+	"github.com/igorrius/go-vector-search/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ids(hits []app.SearchHit) []string {
+	var result []string
+	for _, hit := range hits {
+		result = append(result, hit.Document.ID)
+	}
+	return result
+}
+
+func openStore(t *testing.T, cfg Config) *Store {
+	t.Helper()
+	if cfg.Dimension == 0 {
+		cfg.Dimension = 2
+	}
+	s, err := Open(cfg)
+	require.NoError(t, err)
+	return s
+}
+
+func TestStore_Search(t *testing.T) {
+	ctx := context.Background()
+	s := openStore(t, Config{})
+	for _, doc := range []*domain.Document{
+		{ID: "east", Content: "sunrise over the sea", Embedding: []float32{1, 0}, Metadata: domain.Metadata{Tags: []string{"morning"}}},
+		{ID: "north-east", Content: "error E1234 in the logs", Embedding: []float32{1, 1}},
+		{ID: "north", Content: "polar night", Embedding: []float32{0, 1}, Metadata: domain.Metadata{Tags: []string{"night"}}},
+	} {
+		require.NoError(t, s.Save(ctx, doc))
+	}
+
+	t.Run("should return the nearest documents", func(t *testing.T) {
+		// Act
+		hits, err := s.Search(ctx, app.SearchOptions{Embedding: []float32{1, 0.1}, Limit: 2})
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, []string{"east", "north-east"}, ids(hits))
+		assert.Greater(t, hits[0].Score, hits[1].Score)
+	})
+
+	t.Run("should apply metadata filters", func(t *testing.T) {
+		// Act
+		hits, err := s.Search(ctx, app.SearchOptions{Embedding: []float32{1, 0}, Filter: app.TagFilter{Tags: []string{"night"}}, Limit: 10})
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, []string{"north"}, ids(hits))
+	})
+
+	t.Run("should find exact identifiers by keyword", func(t *testing.T) {
+		// Act
+		hits, err := s.Search(ctx, app.SearchOptions{Embedding: []float32{1, 0}, Text: "E1234", Limit: 10})
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, []string{"north-east"}, ids(hits))
+	})
+
+	t.Run("should reject embeddings of the wrong dimension", func(t *testing.T) {
+		// Act
+		err := s.Save(ctx, &domain.Document{ID: "bad", Embedding: []float32{1, 2, 3}})
+
+		// Assert
+		var mismatch *app.DimensionMismatchError
+		assert.ErrorAs(t, err, &mismatch)
+	})
+}
+
+func TestStore_UpdateAndDelete(t *testing.T) {
+	ctx := context.Background()
+	s := openStore(t, Config{})
+	require.NoError(t, s.Save(ctx, &domain.Document{ID: "a", Content: "old", Embedding: []float32{1, 0}}))
+	require.NoError(t, s.Save(ctx, &domain.Document{ID: "b", Embedding: []float32{0, 1}}))
+
+	t.Run("should replace a document saved again", func(t *testing.T) {
+		// Act
+		require.NoError(t, s.Save(ctx, &domain.Document{ID: "a", Content: "new", Embedding: []float32{0, 1}}))
+		hits, err := s.Search(ctx, app.SearchOptions{Embedding: []float32{0, 1}, Limit: 10})
+
+		// Assert
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"a", "b"}, ids(hits))
+		found, err := s.FindByID(ctx, "a")
+		require.NoError(t, err)
+		assert.Equal(t, "new", found.Content)
+	})
+
+	t.Run("should not return deleted documents", func(t *testing.T) {
+		// Act
+		require.NoError(t, s.Delete(ctx, "a"))
+		hits, err := s.Search(ctx, app.SearchOptions{Embedding: []float32{0, 1}, Limit: 10})
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, []string{"b"}, ids(hits))
+		_, err = s.FindByID(ctx, "a")
+		assert.ErrorIs(t, err, domain.ErrDocumentNotFound)
+		assert.ErrorIs(t, s.Delete(ctx, "a"), domain.ErrDocumentNotFound)
+	})
+
+	t.Run("should fill the limit when the nearest nodes are deleted", func(t *testing.T) {
+		// Arrange
+		s := openStore(t, Config{EfSearch: 2})
+		for i, id := range []string{"n1", "n2", "n3", "n4", "f1", "f2"} {
+			require.NoError(t, s.Save(ctx, &domain.Document{ID: id, Embedding: []float32{1, float32(i)}}))
+		}
+		for _, id := range []string{"n1", "n2", "n3", "n4"} {
+			require.NoError(t, s.Delete(ctx, id))
+		}
+
+		// Act
+		hits, err := s.Search(ctx, app.SearchOptions{Embedding: []float32{1, 0}, Limit: 2})
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, []string{"f1", "f2"}, ids(hits))
+	})
+
+	t.Run("should compact the graph when most nodes are deleted", func(t *testing.T) {
+		// Act
+		for i := 0; i < 2*minCompaction; i++ {
+			require.NoError(t, s.Save(ctx, &domain.Document{ID: "b", Embedding: []float32{float32(i), 1}}))
+		}
+
+		// Assert
+		assert.Less(t, len(s.graph.nodes), 2*minCompaction)
+		found, err := s.FindByID(ctx, "b")
+		require.NoError(t, err)
+		assert.Equal(t, []float32{2*minCompaction - 1, 1}, found.Embedding)
+	})
+}
+
+func TestStore_Persistence(t *testing.T) {
+	ctx := context.Background()
+	docs := []*domain.Document{
+		{ID: "a", Content: "alpha", Embedding: []float32{1, 0}, Metadata: domain.Metadata{Title: "A"}},
+		{ID: "b", Content: "beta", Embedding: []float32{0, 1}},
+		{ID: "c", Content: "gamma", Embedding: []float32{1, 1}},
+	}
+
+	t.Run("should restore the index from the snapshot", func(t *testing.T) {
+		// Arrange
+		dir := t.TempDir()
+		s := openStore(t, Config{Dir: dir})
+		for _, doc := range docs {
+			require.NoError(t, s.Save(ctx, doc))
+		}
+		require.NoError(t, s.Close())
+
+		// Act
+		reopened := openStore(t, Config{Dir: dir})
+		hits, err := reopened.Search(ctx, app.SearchOptions{Embedding: []float32{1, 0}, Limit: 10})
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a", "c", "b"}, ids(hits))
+		assert.Equal(t, "A", hits[0].Document.Metadata.Title)
+		assert.Equal(t, []float32{1, 0}, hits[0].Document.Embedding)
+	})
+
+	t.Run("should recover changes after the last snapshot from the log", func(t *testing.T) {
+		// Arrange
+		dir := t.TempDir()
+		s := openStore(t, Config{Dir: dir, SnapshotEvery: 2})
+		for _, doc := range docs {
+			require.NoError(t, s.Save(ctx, doc))
+		}
+		require.NoError(t, s.Delete(ctx, "b"))
+		// The store is abandoned without Close, as in a crash.
+
+		// Act
+		recovered := openStore(t, Config{Dir: dir})
+		hits, err := recovered.Search(ctx, app.SearchOptions{Embedding: []float32{0, 1}, Limit: 10})
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, []string{"c", "a"}, ids(hits))
+	})
+
+	t.Run("should discard a record torn by a crash", func(t *testing.T) {
+		// Arrange
+		dir := t.TempDir()
+		s := openStore(t, Config{Dir: dir})
+		require.NoError(t, s.Save(ctx, docs[0]))
+		require.NoError(t, s.Save(ctx, docs[1]))
+		walPath := filepath.Join(dir, walFile)
+		info, err := os.Stat(walPath)
+		require.NoError(t, err)
+		require.NoError(t, os.Truncate(walPath, info.Size()-3))
+
+		// Act
+		recovered := openStore(t, Config{Dir: dir})
+		_, errA := recovered.FindByID(ctx, "a")
+		_, errB := recovered.FindByID(ctx, "b")
+		require.NoError(t, recovered.Save(ctx, docs[2]))
+		reopened := openStore(t, Config{Dir: dir})
+		_, errC := reopened.FindByID(ctx, "c")
+
+		// Assert
+		assert.NoError(t, errA)
+		assert.ErrorIs(t, errB, domain.ErrDocumentNotFound)
+		assert.NoError(t, errC)
+	})
+
+	t.Run("should refuse a snapshot of another dimension", func(t *testing.T) {
+		// Arrange
+		dir := t.TempDir()
+		s := openStore(t, Config{Dir: dir})
+		require.NoError(t, s.Save(ctx, docs[0]))
+		require.NoError(t, s.Close())
+
+		// Act
+		_, err := Open(Config{Dir: dir, Dimension: 3})
+
+		// Assert
+		assert.ErrorContains(t, err, "dimension")
+	})
+}
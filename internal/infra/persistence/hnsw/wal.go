@@ -0,0 +1,137 @@
+package hnsw
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+
+	"github.com/igorrius/go-vector-search/internal/domain"
+)
+
+type walOp uint8
+
+const (
+	walPut walOp = iota + 1
+	walDelete
+)
+
+// walRecord is a change logged before it is applied to the index.
+type walRecord struct {
+	Op  walOp
+	ID  string
+	Doc *domain.Document
+}
+
+// wal is an append-only log of the changes made since the last snapshot. Every record is framed
+// by its length and CRC-32 so that a record torn by a crash is detected and discarded on replay.
+type wal struct {
+	file *os.File
+}
+
+func openWAL(path string) (*wal, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open write-ahead log: %w", err)
+	}
+	return &wal{file: file}, nil
+}
+
+// replay calls apply for every intact record and truncates the log after the last one.
+func (w *wal) replay(apply func(walRecord)) (int, error) {
+	if _, err := w.file.Seek(0, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("failed to seek write-ahead log: %w", err)
+	}
+
+	r := bufio.NewReader(w.file)
+	var offset int64
+	count := 0
+	for {
+		rec, n, err := readRecord(r)
+		if err != nil {
+			break
+		}
+		apply(rec)
+		offset += n
+		count++
+	}
+
+	if err := w.file.Truncate(offset); err != nil {
+		return 0, fmt.Errorf("failed to truncate write-ahead log: %w", err)
+	}
+	if _, err := w.file.Seek(offset, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("failed to seek write-ahead log: %w", err)
+	}
+	return count, nil
+}
+
+// append durably writes the record to the log.
+func (w *wal) append(rec walRecord) error {
+	var payload bytes.Buffer
+	if err := gob.NewEncoder(&payload).Encode(rec); err != nil {
+		return fmt.Errorf("failed to encode write-ahead log record: %w", err)
+	}
+
+	frame := make([]byte, 8, 8+payload.Len())
+	binary.LittleEndian.PutUint32(frame[0:4], uint32(payload.Len()))
+	binary.LittleEndian.PutUint32(frame[4:8], crc32.ChecksumIEEE(payload.Bytes()))
+	frame = append(frame, payload.Bytes()...)
+
+	if _, err := w.file.Write(frame); err != nil {
+		return fmt.Errorf("failed to write write-ahead log: %w", err)
+	}
+	if err := w.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync write-ahead log: %w", err)
+	}
+	return nil
+}
+
+// reset empties the log once its records are covered by a snapshot.
+func (w *wal) reset() error {
+	if err := w.file.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate write-ahead log: %w", err)
+	}
+	if _, err := w.file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek write-ahead log: %w", err)
+	}
+	return w.file.Sync()
+}
+
+func (w *wal) close() error {
+	return w.file.Close()
+}
+
+// maxRecordSize bounds the allocation for a record whose length header was torn.
+const maxRecordSize = 64 << 20
+
+var errCorruptRecord = errors.New("corrupt write-ahead log record")
+
+// readRecord reads one framed record and returns it with its size on disk.
+func readRecord(r io.Reader) (walRecord, int64, error) {
+	var header [8]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return walRecord{}, 0, err
+	}
+	size := binary.LittleEndian.Uint32(header[0:4])
+	if size > maxRecordSize {
+		return walRecord{}, 0, errCorruptRecord
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return walRecord{}, 0, err
+	}
+	if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(header[4:8]) {
+		return walRecord{}, 0, errCorruptRecord
+	}
+
+	var rec walRecord
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&rec); err != nil {
+		return walRecord{}, 0, errCorruptRecord
+	}
+	return rec, int64(len(header)) + int64(size), nil
+}
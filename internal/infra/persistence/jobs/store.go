@@ -0,0 +1,172 @@
+// Package jobs provides a file-backed store for the ingestion jobs of app.JobQueue.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/igorrius/go-vector-search/internal/app"
+	"github.com/igorrius/go-vector-search/internal/infra/persistence/atomicfile"
+)
+
+const (
+	fileExt = ".json"
+	// corruptExt is appended to job files that cannot be decoded, which are then ignored.
+	corruptExt = ".corrupt"
+)
+
+// errCorruptJob is returned by read for a job file that cannot be decoded.
+var errCorruptJob = errors.New("corrupt job file")
+
+// FileStore is a JobStore keeping every job in its own JSON file. Files are replaced atomically,
+// so a crash leaves either the previous or the new state of a job.
+type FileStore struct {
+	dir string
+
+	mu sync.Mutex
+}
+
+// NewFileStore creates a FileStore in dir, creating the directory if it does not exist.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create job directory: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Save writes the job, replacing a job with the same ID.
+func (s *FileStore) Save(_ context.Context, job *app.Job) error {
+	if job.ID == "" || strings.ContainsAny(job.ID, `/\`) {
+		return fmt.Errorf("invalid job id %q", job.ID)
+	}
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to encode job: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := atomicfile.WriteFile(s.path(job.ID), data); err != nil {
+		return fmt.Errorf("failed to save job file: %w", err)
+	}
+	return nil
+}
+
+// FindByID reads the job with the given ID.
+func (s *FileStore) FindByID(_ context.Context, id string) (*app.Job, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) {
+		return nil, app.ErrJobNotFound
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.read(s.path(id))
+}
+
+// Unfinished returns the pending and running jobs, oldest first.
+func (s *FileStore) Unfinished(_ context.Context) ([]*app.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var jobs []*app.Job
+	err := s.each(func(path string, job *app.Job) error {
+		if job.Status == app.JobPending || job.Status == app.JobRunning {
+			jobs = append(jobs, job)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+	})
+	return jobs, nil
+}
+
+// DeleteFinished deletes the files of the succeeded and failed jobs last updated before the
+// given time.
+func (s *FileStore) DeleteFinished(_ context.Context, before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted := 0
+	err := s.each(func(path string, job *app.Job) error {
+		if (job.Status != app.JobSucceeded && job.Status != app.JobFailed) || !job.UpdatedAt.Before(before) {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to delete job file: %w", err)
+		}
+		deleted++
+		return nil
+	})
+	return deleted, err
+}
+
+// each calls fn for every job in the directory. Files that cannot be read are logged and
+// skipped, and files that cannot be decoded are renamed with corruptExt, so that one damaged file
+// neither stops the server from starting nor the pruning of the others. The caller holds the
+// lock.
+func (s *FileStore) each(fn func(path string, job *app.Job) error) error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read job directory: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != fileExt {
+			continue
+		}
+		path := filepath.Join(s.dir, entry.Name())
+		job, err := s.read(path)
+		switch {
+		case errors.Is(err, app.ErrJobNotFound):
+			continue
+		case errors.Is(err, errCorruptJob):
+			log.Printf("Job store: %v, moving it aside", err)
+			if err := os.Rename(path, path+corruptExt); err != nil {
+				log.Printf("Job store: %v", err)
+			}
+			continue
+		case err != nil:
+			log.Printf("Job store: %v", err)
+			continue
+		}
+		if err := fn(path, job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *FileStore) path(id string) string {
+	return filepath.Join(s.dir, id+fileExt)
+}
+
+func (s *FileStore) read(path string) (*app.Job, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, app.ErrJobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read job file: %w", err)
+	}
+
+	var job app.Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("%w %s: %v", errCorruptJob, filepath.Base(path), err)
+	}
+	return &job, nil
+}
+
+var _ app.JobStore = (*FileStore)(nil)
@@ -0,0 +1,121 @@
+package jobs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/igorrius/go-vector-search/internal/app"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	t.Run("should keep jobs across instances", func(t *testing.T) {
+		// Arrange
+		dir := t.TempDir()
+		s, err := NewFileStore(dir)
+		require.NoError(t, err)
+		job := &app.Job{
+			ID:        "job",
+			Status:    app.JobPending,
+			Command:   app.IndexDocumentCommand{ID: "doc", Content: "hello"},
+			CreatedAt: created,
+			UpdatedAt: created,
+		}
+		require.NoError(t, s.Save(ctx, job))
+
+		// Act
+		reopened, err := NewFileStore(dir)
+		require.NoError(t, err)
+		found, err := reopened.FindByID(ctx, "job")
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, job, found)
+	})
+
+	t.Run("should list unfinished jobs oldest first", func(t *testing.T) {
+		// Arrange
+		s, err := NewFileStore(t.TempDir())
+		require.NoError(t, err)
+		for i, status := range []app.JobStatus{app.JobRunning, app.JobSucceeded, app.JobPending, app.JobFailed} {
+			job := &app.Job{ID: string(status), Status: status, CreatedAt: created.Add(-time.Duration(i) * time.Hour)}
+			require.NoError(t, s.Save(ctx, job))
+		}
+
+		// Act
+		jobs, err := s.Unfinished(ctx)
+
+		// Assert
+		require.NoError(t, err)
+		require.Len(t, jobs, 2)
+		assert.Equal(t, "pending", jobs[0].ID)
+		assert.Equal(t, "running", jobs[1].ID)
+	})
+
+	t.Run("should delete finished jobs updated before the given time", func(t *testing.T) {
+		// Arrange
+		s, err := NewFileStore(t.TempDir())
+		require.NoError(t, err)
+		for i, status := range []app.JobStatus{app.JobRunning, app.JobSucceeded, app.JobPending, app.JobFailed} {
+			job := &app.Job{ID: string(status), Status: status, UpdatedAt: created.Add(-time.Duration(i) * time.Hour)}
+			require.NoError(t, s.Save(ctx, job))
+		}
+		recent := &app.Job{ID: "recent", Status: app.JobSucceeded, UpdatedAt: created}
+		require.NoError(t, s.Save(ctx, recent))
+
+		// Act
+		deleted, err := s.DeleteFinished(ctx, created.Add(-30*time.Minute))
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, 2, deleted)
+		for _, id := range []string{"succeeded", "failed"} {
+			_, err := s.FindByID(ctx, id)
+			assert.ErrorIs(t, err, app.ErrJobNotFound)
+		}
+		for _, id := range []string{"running", "pending", "recent"} {
+			_, err := s.FindByID(ctx, id)
+			assert.NoError(t, err)
+		}
+	})
+
+	t.Run("should move a corrupt job file aside and list the others", func(t *testing.T) {
+		// Arrange
+		dir := t.TempDir()
+		s, err := NewFileStore(dir)
+		require.NoError(t, err)
+		require.NoError(t, s.Save(ctx, &app.Job{ID: "job", Status: app.JobPending, CreatedAt: created}))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "torn.json"), []byte(`{"ID": "to`), 0o644))
+
+		// Act
+		jobs, err := s.Unfinished(ctx)
+
+		// Assert
+		require.NoError(t, err)
+		require.Len(t, jobs, 1)
+		assert.Equal(t, "job", jobs[0].ID)
+		assert.FileExists(t, filepath.Join(dir, "torn.json"+corruptExt))
+		assert.NoFileExists(t, filepath.Join(dir, "torn.json"))
+	})
+
+	t.Run("should return ErrJobNotFound for an unknown or invalid id", func(t *testing.T) {
+		// Arrange
+		s, err := NewFileStore(t.TempDir())
+		require.NoError(t, err)
+
+		// Act
+		_, missing := s.FindByID(ctx, "missing")
+		_, invalid := s.FindByID(ctx, "../job")
+
+		// Assert
+		assert.ErrorIs(t, missing, app.ErrJobNotFound)
+		assert.ErrorIs(t, invalid, app.ErrJobNotFound)
+	})
+}
@@ -0,0 +1,133 @@
+package memory
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/igorrius/go-vector-search/internal/app"
+	"github.com/igorrius/go-vector-search/internal/domain"
+)
+
+// Predicate reports whether a document matches a filter.
+type Predicate func(doc *domain.Document) bool
+
+// CompileFilter turns a filter into a Predicate. A nil filter matches all documents.
+func CompileFilter(f app.Filter) (Predicate, error) {
+	switch f := f.(type) {
+	case nil:
+		return func(*domain.Document) bool { return true }, nil
+	case app.EqualFilter:
+		value, err := stringField(f.Field)
+		if err != nil {
+			return nil, err
+		}
+		return func(doc *domain.Document) bool { return value(doc) == f.Value }, nil
+	case app.InFilter:
+		value, err := stringField(f.Field)
+		if err != nil {
+			return nil, err
+		}
+		return func(doc *domain.Document) bool { return slices.Contains(f.Values, value(doc)) }, nil
+	case app.RangeFilter:
+		value, err := numberField(f.Field)
+		if err != nil {
+			return nil, err
+		}
+		return func(doc *domain.Document) bool {
+			v := value(doc)
+			return (f.Min == nil || v >= *f.Min) && (f.Max == nil || v <= *f.Max)
+		}, nil
+	case app.TagFilter:
+		return func(doc *domain.Document) bool {
+			for _, tag := range f.Tags {
+				if slices.Contains(doc.Metadata.Tags, tag) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	case app.AndFilter:
+		preds, err := compileFilters(f.Filters)
+		if err != nil {
+			return nil, err
+		}
+		return func(doc *domain.Document) bool {
+			for _, p := range preds {
+				if !p(doc) {
+					return false
+				}
+			}
+			return true
+		}, nil
+	case app.OrFilter:
+		preds, err := compileFilters(f.Filters)
+		if err != nil {
+			return nil, err
+		}
+		return func(doc *domain.Document) bool {
+			for _, p := range preds {
+				if p(doc) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	default:
+		return nil, &app.FilterError{Reason: fmt.Sprintf("unsupported filter %T", f)}
+	}
+}
+
+func compileFilters(filters []app.Filter) ([]Predicate, error) {
+	preds := make([]Predicate, len(filters))
+	for i, f := range filters {
+		p, err := CompileFilter(f)
+		if err != nil {
+			return nil, err
+		}
+		preds[i] = p
+	}
+	return preds, nil
+}
+
+// stringField returns the accessor of a filterable string field.
+func stringField(field string) (func(*domain.Document) string, error) {
+	if key, ok := strings.CutPrefix(field, app.AttributeFieldPrefix); ok && key != "" {
+		return func(doc *domain.Document) string { return doc.Metadata.Attributes[key] }, nil
+	}
+	switch field {
+	case "parent_id":
+		return func(doc *domain.Document) string { return doc.ParentID }, nil
+	case "title":
+		return func(doc *domain.Document) string { return doc.Metadata.Title }, nil
+	case "source_uri":
+		return func(doc *domain.Document) string { return doc.Metadata.SourceURI }, nil
+	case "mime_type":
+		return func(doc *domain.Document) string { return doc.Metadata.MIMEType }, nil
+	case "content_hash":
+		return func(doc *domain.Document) string { return doc.Metadata.ContentHash }, nil
+	}
+	return nil, &app.FilterError{Reason: fmt.Sprintf("field %q cannot be compared to a value", field)}
+}
+
+// numberField returns the accessor of a filterable numeric or date field. Dates are Unix seconds,
+// and a missing date is 0, as in Typesense.
+func numberField(field string) (func(*domain.Document) float64, error) {
+	switch field {
+	case "chunk_index":
+		return func(doc *domain.Document) float64 { return float64(doc.ChunkIndex) }, nil
+	case "created_at":
+		return func(doc *domain.Document) float64 { return unixOrZero(doc.Metadata.CreatedAt) }, nil
+	case "updated_at":
+		return func(doc *domain.Document) float64 { return unixOrZero(doc.Metadata.UpdatedAt) }, nil
+	}
+	return nil, &app.FilterError{Reason: fmt.Sprintf("field %q does not support ranges", field)}
+}
+
+func unixOrZero(t time.Time) float64 {
+	if t.IsZero() {
+		return 0
+	}
+	return float64(t.Unix())
+}
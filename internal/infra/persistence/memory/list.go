@@ -0,0 +1,38 @@
+package memory
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/igorrius/go-vector-search/internal/app"
+	"github.com/igorrius/go-vector-search/internal/domain"
+)
+
+// List returns the page of documents matching opts.Filter in ascending ID order, cloned. The
+// cursor is the ID of the last document of the previous page, so documents saved or deleted
+// between pages neither shift nor repeat the listing.
+func List(docs []*domain.Document, opts app.ListOptions) (*app.DocumentPage, error) {
+	match, err := CompileFilter(opts.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var matching []*domain.Document
+	for _, doc := range docs {
+		if doc.ID > opts.Cursor && match(doc) {
+			matching = append(matching, doc)
+		}
+	}
+	slices.SortFunc(matching, func(a, b *domain.Document) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+
+	page := &app.DocumentPage{}
+	for _, doc := range matching[:min(opts.Limit, len(matching))] {
+		page.Documents = append(page.Documents, *CloneDocument(doc))
+	}
+	if len(matching) > opts.Limit && opts.Limit > 0 {
+		page.NextCursor = page.Documents[len(page.Documents)-1].ID
+	}
+	return page, nil
+}
@@ -0,0 +1,82 @@
+package memory
+
+import (
+	"maps"
+	"slices"
+	"sort"
+
+	"github.com/igorrius/go-vector-search/internal/app"
+)
+
+// Score sets the distance and scores of a hit for the query and document embeddings.
+func Score(hit *app.SearchHit, metric app.DistanceMetric, query, embedding []float32) {
+	hit.Distance = metric.Distance(query, embedding)
+	hit.Score = metric.Score(hit.Distance)
+	hit.Similarity = metric.Similarity(hit.Distance)
+}
+
+// FuseRanks merges the vector and keyword rankings by the weighted sum of their reciprocal ranks,
+// which is recorded as the FusionScore of the hits.
+func FuseRanks(vectorHits, textHits []*app.SearchHit, alpha float64) []*app.SearchHit {
+	SortHits(textHits, func(h *app.SearchHit) float64 { return h.TextMatch })
+
+	fused := make(map[*app.SearchHit]float64)
+	for rank, hit := range vectorHits {
+		fused[hit] += alpha / float64(rank+1)
+	}
+	for rank, hit := range textHits {
+		fused[hit] += (1 - alpha) / float64(rank+1)
+	}
+
+	ranked := slices.Collect(maps.Keys(fused))
+	for _, hit := range ranked {
+		hit.FusionScore = fused[hit]
+	}
+	SortHits(ranked, func(h *app.SearchHit) float64 { return h.FusionScore })
+	return ranked
+}
+
+// SortHits sorts hits by descending relevance, breaking ties by document ID.
+func SortHits(hits []*app.SearchHit, relevance func(*app.SearchHit) float64) {
+	sort.Slice(hits, func(i, j int) bool {
+		ri, rj := relevance(hits[i]), relevance(hits[j])
+		if ri != rj {
+			return ri > rj
+		}
+		return hits[i].Document.ID < hits[j].Document.ID
+	})
+}
+
+// Terms returns the distinct lower-cased words and numbers of a text, sorted.
+func Terms(text string) []string {
+	words := app.Words(text)
+	slices.Sort(words)
+	return slices.Compact(words)
+}
+
+// CountMatches returns how many of the query terms occur in the content.
+func CountMatches(queryTerms []string, content string) int {
+	contentTerms := Terms(content)
+	matches := 0
+	for _, term := range queryTerms {
+		if _, found := slices.BinarySearch(contentTerms, term); found {
+			matches++
+		}
+	}
+	return matches
+}
+
+// Page returns copies of the ranked hits in [offset, offset+limit) that share no state with the
+// stored documents.
+func Page(ranked []*app.SearchHit, offset, limit int) []app.SearchHit {
+	if offset >= len(ranked) {
+		return nil
+	}
+	ranked = ranked[offset:min(offset+limit, len(ranked))]
+	hits := make([]app.SearchHit, len(ranked))
+	for i, hit := range ranked {
+		hits[i] = *hit
+		hits[i].Document = *CloneDocument(&hit.Document)
+	}
+	return hits
+}
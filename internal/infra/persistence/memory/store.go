@@ -0,0 +1,136 @@
+// Package memory provides a pure-Go, in-process document store for local development and tests.
+// Its filters, scoring and keyword ranking are shared with the other local stores.
+package memory
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/igorrius/go-vector-search/internal/app"
+	"github.com/igorrius/go-vector-search/internal/domain"
+)
+
+// Store is a concurrency-safe in-memory DocumentRepository and VectorStore. Search compares the
+// query with every document, which is exact but linear in the number of documents.
+type Store struct {
+	metric app.DistanceMetric
+
+	mu   sync.RWMutex
+	docs map[string]*domain.Document
+}
+
+// NewStore creates a new empty Store comparing vectors with the metric.
+func NewStore(metric app.DistanceMetric) *Store {
+	if metric == "" {
+		metric = app.Cosine
+	}
+	return &Store{
+		metric: metric,
+		docs:   make(map[string]*domain.Document),
+	}
+}
+
+// Save saves a copy of the document, replacing a document with the same ID.
+func (s *Store) Save(_ context.Context, doc *domain.Document) error {
+	stored := CloneDocument(doc)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.docs[doc.ID] = stored
+	return nil
+}
+
+// FindByID returns a copy of the document with the given ID.
+func (s *Store) FindByID(_ context.Context, id string) (*domain.Document, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	doc, ok := s.docs[id]
+	if !ok {
+		return nil, domain.ErrDocumentNotFound
+	}
+	return CloneDocument(doc), nil
+}
+
+// Delete removes the document with the given ID.
+func (s *Store) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.docs[id]; !ok {
+		return domain.ErrDocumentNotFound
+	}
+	delete(s.docs, id)
+	return nil
+}
+
+// List returns a page of documents ordered by ID.
+func (s *Store) List(_ context.Context, opts app.ListOptions) (*app.DocumentPage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return List(slices.Collect(maps.Values(s.docs)), opts)
+}
+
+// Search returns the documents closest to the query embedding. Documents without an embedding of
+// the query's length are only found by keyword. With opts.Text set, the vector and keyword
+// rankings are fused like Typesense hybrid search, weighting the vector rank by opts.Alpha.
+func (s *Store) Search(ctx context.Context, opts app.SearchOptions) ([]app.SearchHit, error) {
+	match, err := CompileFilter(opts.Filter)
+	if err != nil {
+		return nil, err
+	}
+	queryTerms := Terms(opts.Text)
+
+	var vectorHits, textHits []*app.SearchHit
+	s.mu.RLock()
+	for _, doc := range s.docs {
+		if !match(doc) {
+			continue
+		}
+		// Stored documents are never mutated, so the shallow copy is cloned only if returned.
+		hit := &app.SearchHit{Document: *doc}
+		comparable := len(opts.Embedding) > 0 && len(doc.Embedding) == len(opts.Embedding)
+		if comparable {
+			Score(hit, s.metric, opts.Embedding, doc.Embedding)
+		}
+		if len(queryTerms) > 0 {
+			hit.TextMatch = float64(CountMatches(queryTerms, doc.Content))
+		}
+
+		if comparable && opts.MeetsMinScore(hit.Score) && (opts.Text == "" || opts.Alpha > 0) {
+			vectorHits = append(vectorHits, hit)
+		}
+		if hit.TextMatch > 0 {
+			textHits = append(textHits, hit)
+		}
+	}
+	s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	SortHits(vectorHits, func(h *app.SearchHit) float64 { return -h.Distance })
+	ranked := vectorHits
+	if opts.Text != "" {
+		ranked = FuseRanks(vectorHits, textHits, opts.Alpha)
+	}
+
+	return Page(ranked, opts.Offset, opts.Limit), nil
+}
+
+// CloneDocument deep-copies a document so callers cannot mutate stored state.
+func CloneDocument(doc *domain.Document) *domain.Document {
+	c := *doc
+	c.Embedding = slices.Clone(doc.Embedding)
+	c.Metadata.Tags = slices.Clone(doc.Metadata.Tags)
+	c.Metadata.Attributes = maps.Clone(doc.Metadata.Attributes)
+	return &c
+}
+
+var (
+	_ app.DocumentStore = (*Store)(nil)
+	_ app.VectorStore   = (*Store)(nil)
+)
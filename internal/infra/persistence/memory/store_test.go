@@ -0,0 +1,261 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/igorrius/go-vector-search/internal/app"
+	"github.com/igorrius/go-vector-search/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ids(hits []app.SearchHit) []string {
+	var result []string
+	for _, hit := range hits {
+		result = append(result, hit.Document.ID)
+	}
+	return result
+}
+
+func seed(t *testing.T, s *Store, docs ...*domain.Document) {
+	t.Helper()
+	for _, doc := range docs {
+		require.NoError(t, s.Save(context.Background(), doc))
+	}
+}
+
+func TestStore_FindByID(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should return a copy of the saved document", func(t *testing.T) {
+		// Arrange
+		s := NewStore(app.Cosine)
+		doc := &domain.Document{ID: "a", Content: "alpha", Embedding: []float32{1, 0}, Metadata: domain.Metadata{Tags: []string{"go"}}}
+		seed(t, s, doc)
+
+		// Act
+		found, err := s.FindByID(ctx, "a")
+		found.Metadata.Tags[0] = "changed"
+		again, _ := s.FindByID(ctx, "a")
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, "alpha", found.Content)
+		assert.Equal(t, []string{"go"}, again.Metadata.Tags)
+	})
+
+	t.Run("should return ErrDocumentNotFound for an unknown id", func(t *testing.T) {
+		// Act
+		_, err := NewStore(app.Cosine).FindByID(ctx, "missing")
+
+		// Assert
+		assert.ErrorIs(t, err, domain.ErrDocumentNotFound)
+	})
+}
+
+func TestStore_Delete(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should remove the document", func(t *testing.T) {
+		// Arrange
+		s := NewStore(app.Cosine)
+		seed(t, s, &domain.Document{ID: "a", Embedding: []float32{1, 0}})
+
+		// Act
+		err := s.Delete(ctx, "a")
+
+		// Assert
+		require.NoError(t, err)
+		_, err = s.FindByID(ctx, "a")
+		assert.ErrorIs(t, err, domain.ErrDocumentNotFound)
+	})
+
+	t.Run("should return ErrDocumentNotFound for an unknown id", func(t *testing.T) {
+		// Act
+		err := NewStore(app.Cosine).Delete(ctx, "missing")
+
+		// Assert
+		assert.ErrorIs(t, err, domain.ErrDocumentNotFound)
+	})
+}
+
+func TestStore_List(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore(app.Cosine)
+	seed(t, s,
+		&domain.Document{ID: "c", ParentID: "p"},
+		&domain.Document{ID: "a", ParentID: "p"},
+		&domain.Document{ID: "d", ParentID: "q"},
+		&domain.Document{ID: "b", ParentID: "p"},
+	)
+
+	t.Run("should page through matching documents in id order", func(t *testing.T) {
+		// Arrange
+		opts := app.ListOptions{Filter: app.EqualFilter{Field: "parent_id", Value: "p"}, Limit: 2}
+
+		// Act
+		first, err := s.List(ctx, opts)
+		require.NoError(t, err)
+		opts.Cursor = first.NextCursor
+		second, err := s.List(ctx, opts)
+		require.NoError(t, err)
+
+		// Assert
+		assert.Equal(t, "b", first.NextCursor)
+		assert.Equal(t, "a", first.Documents[0].ID)
+		assert.Equal(t, "b", first.Documents[1].ID)
+		require.Len(t, second.Documents, 1)
+		assert.Equal(t, "c", second.Documents[0].ID)
+		assert.Empty(t, second.NextCursor)
+	})
+
+	t.Run("should reject an unknown filter field", func(t *testing.T) {
+		// Act
+		_, err := s.List(ctx, app.ListOptions{Filter: app.EqualFilter{Field: "unknown", Value: "x"}, Limit: 10})
+
+		// Assert
+		var filterErr *app.FilterError
+		assert.ErrorAs(t, err, &filterErr)
+	})
+}
+
+func TestStore_Search(t *testing.T) {
+	ctx := context.Background()
+	docs := []*domain.Document{
+		{ID: "east", Content: "sunrise over the sea", Embedding: []float32{1, 0}, Metadata: domain.Metadata{Tags: []string{"morning"}}},
+		{ID: "north-east", Content: "error E1234 in the logs", Embedding: []float32{2, 2}, ChunkIndex: 1},
+		{ID: "north", Content: "polar night", Embedding: []float32{0, 3}, Metadata: domain.Metadata{Attributes: map[string]string{"author": "ann"}}},
+	}
+
+	tests := []struct {
+		name   string
+		metric app.DistanceMetric
+		want   []string
+	}{
+		{"should rank by cosine similarity", app.Cosine, []string{"north-east", "east", "north"}},
+		{"should rank by inner product", app.InnerProduct, []string{"north-east", "north", "east"}},
+		{"should rank by euclidean distance", app.Euclidean, []string{"east", "north-east", "north"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			s := NewStore(tt.metric)
+			seed(t, s, docs...)
+
+			// Act
+			hits, err := s.Search(ctx, app.SearchOptions{Embedding: []float32{1, 0.5}, Limit: 10})
+
+			// Assert
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, ids(hits))
+		})
+	}
+
+	s := NewStore(app.Cosine)
+	seed(t, s, docs...)
+
+	t.Run("should report cosine distance and score", func(t *testing.T) {
+		// Act
+		hits, err := s.Search(ctx, app.SearchOptions{Embedding: []float32{1, 0}, Limit: 1})
+
+		// Assert
+		require.NoError(t, err)
+		assert.InDelta(t, 0, hits[0].Distance, 1e-9)
+		assert.InDelta(t, 1, hits[0].Score, 1e-9)
+	})
+
+	t.Run("should paginate and drop hits below the minimum score", func(t *testing.T) {
+		// Arrange
+		minScore := 0.5
+
+		// Act
+		hits, err := s.Search(ctx, app.SearchOptions{Embedding: []float32{1, 0}, Limit: 5, Offset: 1, MinScore: &minScore})
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, []string{"north-east"}, ids(hits))
+	})
+
+	t.Run("should keep hits with a negative score without a minimum score", func(t *testing.T) {
+		// Act
+		hits, err := s.Search(ctx, app.SearchOptions{Embedding: []float32{-1, 0}, Limit: 10})
+
+		// Assert
+		require.NoError(t, err)
+		require.Len(t, hits, 3)
+		assert.Equal(t, "north", hits[0].Document.ID)
+		assert.Less(t, hits[2].Score, 0.0)
+	})
+
+	t.Run("should apply metadata filters", func(t *testing.T) {
+		// Arrange
+		filter, err := app.ParseFilter("tags:morning OR (attributes.author:ann AND chunk_index:..0)")
+		require.NoError(t, err)
+
+		// Act
+		hits, err := s.Search(ctx, app.SearchOptions{Embedding: []float32{1, 0}, Filter: filter, Limit: 10})
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, []string{"east", "north"}, ids(hits))
+	})
+
+	t.Run("should reject a range on a string field", func(t *testing.T) {
+		// Act
+		_, err := s.Search(ctx, app.SearchOptions{Embedding: []float32{1, 0}, Filter: app.RangeFilter{Field: "title"}, Limit: 10})
+
+		// Assert
+		var filterErr *app.FilterError
+		assert.ErrorAs(t, err, &filterErr)
+	})
+
+	t.Run("should find exact identifiers by keyword", func(t *testing.T) {
+		// Act
+		hits, err := s.Search(ctx, app.SearchOptions{Embedding: []float32{1, 0}, Text: "E1234", Alpha: 0, Limit: 10})
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, []string{"north-east"}, ids(hits))
+		assert.Equal(t, 1.0, hits[0].TextMatch)
+	})
+
+	t.Run("should fuse vector and keyword ranks in hybrid search", func(t *testing.T) {
+		// Act
+		hits, err := s.Search(ctx, app.SearchOptions{Embedding: []float32{1, 0}, Text: "E1234", Alpha: 0.3, Limit: 10})
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, []string{"north-east", "east", "north"}, ids(hits))
+	})
+}
+
+func TestStore_ConcurrentAccess(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore(app.Cosine)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				id := fmt.Sprintf("doc-%d-%d", i, j)
+				doc := &domain.Document{ID: id, Embedding: []float32{float32(i), float32(j)}, Metadata: domain.Metadata{CreatedAt: time.Now()}}
+				assert.NoError(t, s.Save(ctx, doc))
+				_, err := s.Search(ctx, app.SearchOptions{Embedding: []float32{1, 1}, Limit: 3})
+				assert.NoError(t, err)
+				_, err = s.FindByID(ctx, id)
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	hits, err := s.Search(ctx, app.SearchOptions{Embedding: []float32{1, 1}, Limit: app.MaxSearchLimit})
+	require.NoError(t, err)
+	assert.Len(t, hits, app.MaxSearchLimit)
+}
@@ -0,0 +1,234 @@
+package quantized
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+
+	"github.com/igorrius/go-vector-search/internal/app"
+)
+
+// Quantization selects how a Store compresses vectors.
+type Quantization string
+
+const (
+	// Int8 stores every component as an 8-bit integer scaled to the range seen in training:
+	// four times smaller than float32, with a small loss of precision.
+	Int8 Quantization = "int8"
+	// Product splits vectors into subspaces and stores the index of the nearest of 256 trained
+	// centroids for each: much smaller than Int8, and coarser.
+	Product Quantization = "pq"
+)
+
+// pqCentroids is the number of centroids per subspace, so that a code fits into a byte.
+const pqCentroids = 256
+
+// kmeansIterations is the number of Lloyd iterations when training product quantization.
+const kmeansIterations = 15
+
+// quantizer compresses vectors into codes.
+type quantizer interface {
+	encode(v []float32) []byte
+	// estimator returns a function approximating the distance between q and an encoded vector
+	// under the metric, lower being closer.
+	estimator(q []float32) func(code []byte) float64
+}
+
+// scalarQuantizer maps every component linearly from its trained [min, max] to an int8.
+type scalarQuantizer struct {
+	metric app.DistanceMetric
+	min    []float32
+	step   []float32
+}
+
+func trainScalar(metric app.DistanceMetric, sample [][]float32) *scalarQuantizer {
+	dim := len(sample[0])
+	q := &scalarQuantizer{metric: metric, min: make([]float32, dim), step: make([]float32, dim)}
+	for i := 0; i < dim; i++ {
+		lo, hi := sample[0][i], sample[0][i]
+		for _, v := range sample[1:] {
+			lo, hi = min(lo, v[i]), max(hi, v[i])
+		}
+		q.min[i] = lo
+		q.step[i] = (hi - lo) / 255
+	}
+	return q
+}
+
+func (q *scalarQuantizer) encode(v []float32) []byte {
+	code := make([]byte, len(v))
+	for i, x := range v {
+		level := 0.0
+		if q.step[i] > 0 {
+			level = math.Round(float64((x - q.min[i]) / q.step[i]))
+		}
+		// Components outside the trained range saturate.
+		code[i] = byte(int8(min(max(level, 0), 255) - 128))
+	}
+	return code
+}
+
+func (q *scalarQuantizer) decode(code []byte, v []float32) {
+	for i, c := range code {
+		v[i] = q.min[i] + float32(int(int8(c))+128)*q.step[i]
+	}
+}
+
+func (q *scalarQuantizer) estimator(query []float32) func([]byte) float64 {
+	decoded := make([]float32, len(query))
+	return func(code []byte) float64 {
+		q.decode(code, decoded)
+		return q.metric.Distance(query, decoded)
+	}
+}
+
+// productQuantizer encodes every subspace of a vector as its nearest trained centroid. Cosine
+// vectors are normalized first, so that their distance is derived from the inner product.
+type productQuantizer struct {
+	metric    app.DistanceMetric
+	subDim    int
+	centroids [][][]float32 // [subspace][centroid][component]
+}
+
+func trainProduct(metric app.DistanceMetric, sample [][]float32, subspaces int, rng *rand.Rand) (*productQuantizer, error) {
+	dim := len(sample[0])
+	if subspaces <= 0 || dim%subspaces != 0 {
+		return nil, fmt.Errorf("dimension %d is not divisible into %d subspaces", dim, subspaces)
+	}
+
+	q := &productQuantizer{metric: metric, subDim: dim / subspaces, centroids: make([][][]float32, subspaces)}
+	points := make([][]float32, len(sample))
+	for s := range q.centroids {
+		for i, v := range sample {
+			points[i] = q.prepare(v)[s*q.subDim : (s+1)*q.subDim]
+		}
+		q.centroids[s] = kmeans(points, min(pqCentroids, len(points)), rng)
+	}
+	return q, nil
+}
+
+// prepare normalizes cosine vectors; other vectors are returned unchanged.
+func (q *productQuantizer) prepare(v []float32) []float32 {
+	if q.metric != app.Cosine {
+		return v
+	}
+	var norm float64
+	for _, x := range v {
+		norm += float64(x) * float64(x)
+	}
+	if norm == 0 {
+		return v
+	}
+	scale := float32(1 / math.Sqrt(norm))
+	normalized := make([]float32, len(v))
+	for i, x := range v {
+		normalized[i] = x * scale
+	}
+	return normalized
+}
+
+func (q *productQuantizer) encode(v []float32) []byte {
+	v = q.prepare(v)
+	code := make([]byte, len(q.centroids))
+	for s, centroids := range q.centroids {
+		code[s] = byte(nearest(v[s*q.subDim:(s+1)*q.subDim], centroids))
+	}
+	return code
+}
+
+// estimator precomputes the partial inner products or squared distances between every query
+// subvector and the centroids, so that a code is scored with one table lookup per subspace.
+func (q *productQuantizer) estimator(query []float32) func([]byte) float64 {
+	query = q.prepare(query)
+	tables := make([][]float64, len(q.centroids))
+	for s, centroids := range q.centroids {
+		sub := query[s*q.subDim : (s+1)*q.subDim]
+		tables[s] = make([]float64, len(centroids))
+		for c, centroid := range centroids {
+			if q.metric == app.Euclidean {
+				tables[s][c] = squaredDistance(sub, centroid)
+			} else {
+				tables[s][c] = app.Dot(sub, centroid)
+			}
+		}
+	}
+
+	return func(code []byte) float64 {
+		var sum float64
+		for s, c := range code {
+			sum += tables[s][c]
+		}
+		switch q.metric {
+		case app.Euclidean:
+			return math.Sqrt(sum)
+		default:
+			return 1 - sum
+		}
+	}
+}
+
+// kmeans clusters the points into k centroids, initialized from distinct random points.
+func kmeans(points [][]float32, k int, rng *rand.Rand) [][]float32 {
+	dim := len(points[0])
+	centroids := make([][]float32, k)
+	for i, p := range rng.Perm(len(points))[:k] {
+		centroids[i] = append([]float32(nil), points[p]...)
+	}
+
+	assignment := make([]int, len(points))
+	sums := make([][]float64, k)
+	counts := make([]int, k)
+	for iter := 0; iter < kmeansIterations; iter++ {
+		changed := false
+		for i, p := range points {
+			if c := nearest(p, centroids); c != assignment[i] {
+				assignment[i] = c
+				changed = true
+			}
+		}
+		if iter > 0 && !changed {
+			break
+		}
+
+		for c := range sums {
+			sums[c] = make([]float64, dim)
+			counts[c] = 0
+		}
+		for i, p := range points {
+			c := assignment[i]
+			counts[c]++
+			for j, x := range p {
+				sums[c][j] += float64(x)
+			}
+		}
+		for c := range centroids {
+			// An empty cluster keeps its centroid.
+			if counts[c] == 0 {
+				continue
+			}
+			for j := range centroids[c] {
+				centroids[c][j] = float32(sums[c][j] / float64(counts[c]))
+			}
+		}
+	}
+	return centroids
+}
+
+func nearest(v []float32, centroids [][]float32) int {
+	best, bestDist := 0, math.Inf(1)
+	for c, centroid := range centroids {
+		if d := squaredDistance(v, centroid); d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	return best
+}
+
+func squaredDistance(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		d := float64(a[i]) - float64(b[i])
+		sum += d * d
+	}
+	return sum
+}
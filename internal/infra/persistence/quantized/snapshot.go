@@ -0,0 +1,148 @@
+package quantized
+
+import (
+	"bufio"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/igorrius/go-vector-search/internal/app"
+	"github.com/igorrius/go-vector-search/internal/domain"
+	"github.com/igorrius/go-vector-search/internal/infra/persistence/atomicfile"
+)
+
+// snapshotVersion is the version of the snapshot file format.
+const snapshotVersion = 1
+
+// snapshotHeader precedes the Count records of a snapshot file. Records are encoded one by one,
+// so that writing and reading a snapshot holds a single full-precision vector in memory.
+type snapshotHeader struct {
+	Version      int
+	Dimension    int
+	Metric       app.DistanceMetric
+	Quantization Quantization
+	// Scalar or Product is the trained quantizer; both are nil before training.
+	Scalar    *scalarSnapshot
+	Product   *productSnapshot
+	TrainedOn int
+	Count     int
+}
+
+type scalarSnapshot struct {
+	Min  []float32
+	Step []float32
+}
+
+type productSnapshot struct {
+	SubDim    int
+	Centroids [][][]float32
+}
+
+// snapshotRecord is a stored document with its code and full-precision vector.
+type snapshotRecord struct {
+	// Doc is stored without its embedding, which is Vector.
+	Doc    *domain.Document
+	Code   []byte
+	Vector []float32
+}
+
+// writeSnapshot atomically replaces the snapshot file with the documents, codes and vectors of
+// the store.
+func writeSnapshot(path string, s *Store) error {
+	header := snapshotHeader{
+		Version:      snapshotVersion,
+		Dimension:    s.cfg.Dimension,
+		Metric:       s.cfg.Metric,
+		Quantization: s.cfg.Quantization,
+		TrainedOn:    s.trainedOn,
+		Count:        len(s.entries),
+	}
+	switch q := s.quantizer.(type) {
+	case *scalarQuantizer:
+		header.Scalar = &scalarSnapshot{Min: q.min, Step: q.step}
+	case *productQuantizer:
+		header.Product = &productSnapshot{SubDim: q.subDim, Centroids: q.centroids}
+	}
+
+	vector := make([]float32, s.cfg.Dimension)
+	err := atomicfile.Write(path, func(w io.Writer) error {
+		enc := gob.NewEncoder(w)
+		if err := enc.Encode(header); err != nil {
+			return fmt.Errorf("failed to encode snapshot: %w", err)
+		}
+		for slot, e := range s.entries {
+			if err := s.vectors.read(slot, vector); err != nil {
+				return err
+			}
+			if err := enc.Encode(snapshotRecord{Doc: e.doc, Code: e.code, Vector: vector}); err != nil {
+				return fmt.Errorf("failed to encode snapshot: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return nil
+}
+
+// readSnapshot restores the store from the snapshot file, writing the vectors to its vector
+// storage. A missing file leaves the store empty.
+func readSnapshot(path string, s *Store) error {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer file.Close()
+
+	dec := gob.NewDecoder(bufio.NewReader(file))
+	var header snapshotHeader
+	if err := dec.Decode(&header); err != nil {
+		return fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+	switch {
+	case header.Version != snapshotVersion:
+		return fmt.Errorf("unsupported snapshot version %d", header.Version)
+	case header.Dimension != s.cfg.Dimension:
+		return fmt.Errorf("snapshot has dimension %d, store is configured for %d", header.Dimension, s.cfg.Dimension)
+	case header.Metric != s.cfg.Metric:
+		return fmt.Errorf("snapshot uses metric %q, store is configured for %q", header.Metric, s.cfg.Metric)
+	}
+
+	// A quantizer of another kind is dropped, and the store trains the configured one anew.
+	switch {
+	case header.Scalar != nil && s.cfg.Quantization == Int8:
+		s.quantizer = &scalarQuantizer{metric: s.cfg.Metric, min: header.Scalar.Min, step: header.Scalar.Step}
+	case header.Product != nil && s.cfg.Quantization == Product && len(header.Product.Centroids) == s.cfg.Subspaces:
+		s.quantizer = &productQuantizer{metric: s.cfg.Metric, subDim: header.Product.SubDim, centroids: header.Product.Centroids}
+	}
+	if s.quantizer != nil {
+		s.trainedOn = header.TrainedOn
+	}
+
+	s.entries = make([]entry, 0, header.Count)
+	for slot := 0; slot < header.Count; slot++ {
+		var rec snapshotRecord
+		if err := dec.Decode(&rec); err != nil {
+			return fmt.Errorf("failed to decode snapshot: %w", err)
+		}
+		if len(rec.Vector) != s.cfg.Dimension {
+			return fmt.Errorf("snapshot record %d has dimension %d", slot, len(rec.Vector))
+		}
+		if err := s.vectors.write(slot, rec.Vector); err != nil {
+			return err
+		}
+		e := entry{doc: rec.Doc}
+		if s.quantizer != nil {
+			e.code = rec.Code
+		}
+		s.entries = append(s.entries, e)
+		s.ids[rec.Doc.ID] = slot
+	}
+	return nil
+}
@@ -0,0 +1,501 @@
+// Package quantized provides an in-process vector store for memory-constrained deployments that
+// searches compact quantized codes and rescores the best candidates at full precision. The store
+// is persisted to a snapshot file.
+package quantized
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/igorrius/go-vector-search/internal/app"
+	"github.com/igorrius/go-vector-search/internal/domain"
+	"github.com/igorrius/go-vector-search/internal/infra/persistence/memory"
+)
+
+const (
+	defaultTrainingSize  = 1000
+	defaultRescoreFactor = 4
+	defaultRetrainGrowth = 2
+	defaultSnapshotEvery = 1000
+	// defaultSubspaceDim is the number of components per product quantization subspace by default.
+	defaultSubspaceDim = 4
+
+	vectorFile   = "vectors.f32"
+	snapshotFile = "store.snapshot"
+)
+
+// Config holds the configuration of a Store. Zero values select the defaults.
+type Config struct {
+	// Dimension is the length of the stored embeddings. Required.
+	Dimension int
+	// Metric is the similarity function; the default is cosine.
+	Metric app.DistanceMetric
+	// Quantization selects the code format; the default is Int8.
+	Quantization Quantization
+	// Subspaces is the number of product quantization subspaces, which must divide Dimension.
+	// The default is one subspace per 4 components.
+	Subspaces int
+	// TrainingSize is the number of documents sampled to train the quantizer. Until that many
+	// documents are stored and the quantizer is trained in the background, search is exact.
+	// Default 1000.
+	TrainingSize int
+	// RetrainGrowth is the factor by which the number of documents has to grow after training
+	// before the quantizer is retrained in the background. Default 2; negative never retrains.
+	RetrainGrowth float64
+	// RescoreFactor is how many candidates per requested hit are rescored at full precision.
+	// Default 4.
+	RescoreFactor int
+	// Dir is the directory of the snapshot and of the file holding the full-precision vectors.
+	// Empty keeps the vectors in memory, which saves nothing and is meant for tests, and persists
+	// nothing.
+	Dir string
+	// SnapshotEvery is the number of changes after which a snapshot is written. Changes made
+	// since the last snapshot are lost on a crash. Default 1000.
+	SnapshotEvery int
+	// Seed seeds the training of product quantization.
+	Seed uint64
+}
+
+// entry is a stored document without its embedding, which lives in the vector storage.
+type entry struct {
+	doc  *domain.Document
+	code []byte
+}
+
+// Store is a DocumentRepository and VectorStore keeping only quantized codes of the embeddings in
+// memory. Search ranks all codes by estimated distance and computes the exact distance for the
+// best candidates. The documents, codes and vectors are written to a snapshot every
+// SnapshotEvery changes and on Close, and restored from it when the store is created.
+type Store struct {
+	cfg Config
+
+	mu        sync.RWMutex
+	entries   []entry
+	ids       map[string]int
+	vectors   vectorStorage
+	quantizer quantizer
+	// trainedOn is the number of documents stored when the quantizer was trained.
+	trainedOn int
+	training  bool
+	trainings sync.WaitGroup
+	changes   int
+}
+
+// NewStore creates a Store, restoring the snapshot in Dir if there is one.
+func NewStore(cfg Config) (*Store, error) {
+	if cfg.Dimension <= 0 {
+		return nil, fmt.Errorf("embedding dimension must be positive, got %d", cfg.Dimension)
+	}
+	if cfg.Metric == "" {
+		cfg.Metric = app.Cosine
+	}
+	switch cfg.Quantization {
+	case "":
+		cfg.Quantization = Int8
+	case Int8, Product:
+	default:
+		return nil, fmt.Errorf("unknown quantization %q", cfg.Quantization)
+	}
+	if cfg.Quantization == Product {
+		if cfg.Subspaces == 0 {
+			cfg.Subspaces = max(1, cfg.Dimension/defaultSubspaceDim)
+		}
+		if cfg.Dimension%cfg.Subspaces != 0 {
+			return nil, fmt.Errorf("dimension %d is not divisible into %d subspaces", cfg.Dimension, cfg.Subspaces)
+		}
+	}
+	if cfg.TrainingSize == 0 {
+		cfg.TrainingSize = defaultTrainingSize
+	}
+	if cfg.RescoreFactor == 0 {
+		cfg.RescoreFactor = defaultRescoreFactor
+	}
+	if cfg.RetrainGrowth == 0 {
+		cfg.RetrainGrowth = defaultRetrainGrowth
+	}
+	if cfg.SnapshotEvery == 0 {
+		cfg.SnapshotEvery = defaultSnapshotEvery
+	}
+
+	s := &Store{
+		cfg:     cfg,
+		ids:     make(map[string]int),
+		vectors: &memoryVectors{},
+	}
+	if cfg.Dir != "" {
+		if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create store directory: %w", err)
+		}
+		vectors, err := openFileVectors(filepath.Join(cfg.Dir, vectorFile), cfg.Dimension)
+		if err != nil {
+			return nil, err
+		}
+		s.vectors = vectors
+		if err := readSnapshot(filepath.Join(cfg.Dir, snapshotFile), s); err != nil {
+			vectors.close()
+			return nil, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.startTraining()
+	return s, nil
+}
+
+// EmbeddingDimension returns the configured embedding dimension.
+func (s *Store) EmbeddingDimension() int {
+	return s.cfg.Dimension
+}
+
+// Save stores the document, replacing a document with the same ID. Once TrainingSize documents
+// are stored, the quantizer is trained on them in the background and all codes are computed.
+func (s *Store) Save(_ context.Context, doc *domain.Document) error {
+	if len(doc.Embedding) != s.cfg.Dimension {
+		return &app.DimensionMismatchError{Expected: s.cfg.Dimension, Actual: len(doc.Embedding)}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	slot, ok := s.ids[doc.ID]
+	if !ok {
+		slot = len(s.entries)
+		s.entries = append(s.entries, entry{})
+	}
+	if err := s.vectors.write(slot, doc.Embedding); err != nil {
+		return err
+	}
+
+	stored := memory.CloneDocument(doc)
+	stored.Embedding = nil
+	s.entries[slot] = entry{doc: stored}
+	s.ids[doc.ID] = slot
+	if s.quantizer != nil {
+		s.entries[slot].code = s.quantizer.encode(doc.Embedding)
+	}
+	s.startTraining()
+	return s.changed()
+}
+
+// FindByID returns a copy of the document with the given ID.
+func (s *Store) FindByID(_ context.Context, id string) (*domain.Document, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	slot, ok := s.ids[id]
+	if !ok {
+		return nil, domain.ErrDocumentNotFound
+	}
+	doc := memory.CloneDocument(s.entries[slot].doc)
+	doc.Embedding = make([]float32, s.cfg.Dimension)
+	if err := s.vectors.read(slot, doc.Embedding); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// Delete removes the document with the given ID. The last stored document takes over its slot.
+func (s *Store) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	slot, ok := s.ids[id]
+	if !ok {
+		return domain.ErrDocumentNotFound
+	}
+	last := len(s.entries) - 1
+	if slot != last {
+		v := make([]float32, s.cfg.Dimension)
+		if err := s.vectors.read(last, v); err != nil {
+			return err
+		}
+		if err := s.vectors.write(slot, v); err != nil {
+			return err
+		}
+		s.entries[slot] = s.entries[last]
+		s.ids[s.entries[slot].doc.ID] = slot
+	}
+	s.entries[last] = entry{}
+	s.entries = s.entries[:last]
+	delete(s.ids, id)
+	return s.changed()
+}
+
+// List returns a page of documents ordered by ID, with their full-precision embeddings.
+func (s *Store) List(_ context.Context, opts app.ListOptions) (*app.DocumentPage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	docs := make([]*domain.Document, len(s.entries))
+	for slot, e := range s.entries {
+		docs[slot] = e.doc
+	}
+	page, err := memory.List(docs, opts)
+	if err != nil {
+		return nil, err
+	}
+	for i := range page.Documents {
+		doc := &page.Documents[i]
+		doc.Embedding = make([]float32, s.cfg.Dimension)
+		if err := s.vectors.read(s.ids[doc.ID], doc.Embedding); err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+// Search ranks the documents by the distance estimated from their codes, rescores the
+// RescoreFactor best candidates per requested hit with their full-precision vectors and returns
+// them in exact order. With opts.Text set, keyword matches are fused in as in memory.Store.
+func (s *Store) Search(ctx context.Context, opts app.SearchOptions) ([]app.SearchHit, error) {
+	match, err := memory.CompileFilter(opts.Filter)
+	if err != nil {
+		return nil, err
+	}
+	vectorSearch := len(opts.Embedding) > 0 && (opts.Text == "" || opts.Alpha > 0)
+	if vectorSearch && len(opts.Embedding) != s.cfg.Dimension {
+		return nil, &app.DimensionMismatchError{Expected: s.cfg.Dimension, Actual: len(opts.Embedding)}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	bySlot := make(map[int]*app.SearchHit)
+	hit := func(slot int) (*app.SearchHit, error) {
+		if h, ok := bySlot[slot]; ok {
+			return h, nil
+		}
+		// Stored documents are never mutated, so the shallow copy is cloned only if returned.
+		h := &app.SearchHit{Document: *s.entries[slot].doc}
+		h.Document.Embedding = make([]float32, s.cfg.Dimension)
+		if err := s.vectors.read(slot, h.Document.Embedding); err != nil {
+			return nil, err
+		}
+		if len(opts.Embedding) == s.cfg.Dimension {
+			memory.Score(h, s.cfg.Metric, opts.Embedding, h.Document.Embedding)
+		}
+		bySlot[slot] = h
+		return h, nil
+	}
+
+	var vectorHits []*app.SearchHit
+	if vectorSearch {
+		for _, slot := range s.candidates(opts.Embedding, match, (opts.Offset+opts.Limit)*s.cfg.RescoreFactor) {
+			h, err := hit(slot)
+			if err != nil {
+				return nil, err
+			}
+			if opts.MeetsMinScore(h.Score) {
+				vectorHits = append(vectorHits, h)
+			}
+		}
+		memory.SortHits(vectorHits, func(h *app.SearchHit) float64 { return -h.Distance })
+	}
+
+	ranked := vectorHits
+	if opts.Text != "" {
+		queryTerms := memory.Terms(opts.Text)
+		var textHits []*app.SearchHit
+		for slot, e := range s.entries {
+			if !match(e.doc) {
+				continue
+			}
+			if matches := memory.CountMatches(queryTerms, e.doc.Content); matches > 0 {
+				h, err := hit(slot)
+				if err != nil {
+					return nil, err
+				}
+				h.TextMatch = float64(matches)
+				textHits = append(textHits, h)
+			}
+		}
+		ranked = memory.FuseRanks(vectorHits, textHits, opts.Alpha)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return memory.Page(ranked, opts.Offset, opts.Limit), nil
+}
+
+// candidates returns the slots of the n matching documents with the smallest estimated distance
+// to q. Before the quantizer is trained, all matching documents are candidates.
+func (s *Store) candidates(q []float32, match memory.Predicate, n int) []int {
+	if s.quantizer == nil {
+		var slots []int
+		for slot, e := range s.entries {
+			if match(e.doc) {
+				slots = append(slots, slot)
+			}
+		}
+		return slots
+	}
+
+	type estimate struct {
+		slot int
+		dist float64
+	}
+	estimateDistance := s.quantizer.estimator(q)
+	var estimates []estimate
+	for slot, e := range s.entries {
+		if match(e.doc) {
+			estimates = append(estimates, estimate{slot: slot, dist: estimateDistance(e.code)})
+		}
+	}
+	sort.Slice(estimates, func(i, j int) bool { return estimates[i].dist < estimates[j].dist })
+
+	slots := make([]int, min(n, len(estimates)))
+	for i := range slots {
+		slots[i] = estimates[i].slot
+	}
+	return slots
+}
+
+// startTraining trains the quantizer in the background once TrainingSize documents are stored,
+// and retrains it when their number has grown by RetrainGrowth since. The caller holds the write
+// lock.
+func (s *Store) startTraining() {
+	switch {
+	case s.training, len(s.entries) < s.cfg.TrainingSize:
+		return
+	case s.quantizer != nil && (s.cfg.RetrainGrowth < 0 || float64(len(s.entries)) < s.cfg.RetrainGrowth*float64(s.trainedOn)):
+		return
+	}
+
+	s.training = true
+	s.trainings.Add(1)
+	go func() {
+		defer s.trainings.Done()
+		if err := s.train(); err != nil {
+			log.Printf("Quantized store: %v", err)
+		}
+	}()
+}
+
+// Retrain trains the quantizer on a sample of the stored documents and re-encodes them, for
+// example after the distribution of the embeddings has changed. It returns once training is done.
+func (s *Store) Retrain() error {
+	s.mu.Lock()
+	if s.training {
+		s.mu.Unlock()
+		return errors.New("quantizer is already training")
+	}
+	s.training = true
+	s.mu.Unlock()
+	return s.train()
+}
+
+// train fits a quantizer to a sample of the stored vectors without blocking reads and writes,
+// then encodes all vectors with it and swaps it in.
+func (s *Store) train() error {
+	defer func() {
+		s.mu.Lock()
+		s.training = false
+		s.mu.Unlock()
+	}()
+
+	s.mu.RLock()
+	sample, err := s.sample()
+	s.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	if len(sample) == 0 {
+		return nil
+	}
+
+	var q quantizer
+	switch s.cfg.Quantization {
+	case Product:
+		pq, err := trainProduct(s.cfg.Metric, sample, s.cfg.Subspaces, rand.New(rand.NewPCG(s.cfg.Seed, s.cfg.Seed)))
+		if err != nil {
+			return fmt.Errorf("failed to train quantizer: %w", err)
+		}
+		q = pq
+	default:
+		q = trainScalar(s.cfg.Metric, sample)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	codes := make([][]byte, len(s.entries))
+	v := make([]float32, s.cfg.Dimension)
+	for slot := range s.entries {
+		if err := s.vectors.read(slot, v); err != nil {
+			return err
+		}
+		codes[slot] = q.encode(v)
+	}
+	for slot, code := range codes {
+		s.entries[slot].code = code
+	}
+	s.quantizer = q
+	s.trainedOn = len(s.entries)
+	return s.snapshot()
+}
+
+// sample reads up to TrainingSize stored vectors spread evenly over the slots. The caller holds
+// the read lock.
+func (s *Store) sample() ([][]float32, error) {
+	n := min(s.cfg.TrainingSize, len(s.entries))
+	sample := make([][]float32, n)
+	for i := range sample {
+		sample[i] = make([]float32, s.cfg.Dimension)
+		if err := s.vectors.read(i*len(s.entries)/n, sample[i]); err != nil {
+			return nil, err
+		}
+	}
+	return sample, nil
+}
+
+// changed counts a change and snapshots the store every SnapshotEvery changes. The caller holds
+// the write lock.
+func (s *Store) changed() error {
+	s.changes++
+	if s.changes >= s.cfg.SnapshotEvery {
+		return s.snapshot()
+	}
+	return nil
+}
+
+// Snapshot writes the store to the snapshot file.
+func (s *Store) Snapshot() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.snapshot()
+}
+
+func (s *Store) snapshot() error {
+	if s.cfg.Dir == "" {
+		return nil
+	}
+	if err := writeSnapshot(filepath.Join(s.cfg.Dir, snapshotFile), s); err != nil {
+		return err
+	}
+	s.changes = 0
+	return nil
+}
+
+// Close waits for training to finish, writes a final snapshot and releases the vector file.
+func (s *Store) Close() error {
+	s.trainings.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.snapshot()
+	return errors.Join(err, s.vectors.close())
+}
+
+var (
+	_ app.DocumentStore    = (*Store)(nil)
+	_ app.VectorStore      = (*Store)(nil)
+	_ app.DimensionedStore = (*Store)(nil)
+)
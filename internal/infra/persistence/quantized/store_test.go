@@ -0,0 +1,384 @@
+package quantized
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"path/filepath"
+	"testing"
+
+	"github.com/igorrius/go-vector-search/internal/app"
+	"github.com/igorrius/go-vector-search/internal/domain"
+	"github.com/igorrius/go-vector-search/internal/infra/persistence/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	recallDimension = 32
+	recallK         = 10
+)
+
+func ids(hits []app.SearchHit) []string {
+	var result []string
+	for _, hit := range hits {
+		result = append(result, hit.Document.ID)
+	}
+	return result
+}
+
+func newStore(t *testing.T, cfg Config) *Store {
+	t.Helper()
+	if cfg.Dimension == 0 {
+		cfg.Dimension = 2
+	}
+	s, err := NewStore(cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// clusteredVectors returns vectors scattered around a few centres, like embeddings of a corpus
+// with several topics.
+func clusteredVectors(rng *rand.Rand, n int) [][]float32 {
+	centres := make([][]float32, 16)
+	for i := range centres {
+		centres[i] = make([]float32, recallDimension)
+		for j := range centres[i] {
+			centres[i][j] = float32(rng.NormFloat64())
+		}
+	}
+	vectors := make([][]float32, n)
+	for i := range vectors {
+		centre := centres[rng.IntN(len(centres))]
+		vectors[i] = make([]float32, recallDimension)
+		for j := range vectors[i] {
+			vectors[i][j] = centre[j] + float32(0.5*rng.NormFloat64())
+		}
+	}
+	return vectors
+}
+
+// recall returns the share of the exact top-k neighbours the store finds, averaged over queries.
+func recall(t *testing.T, s, exact app.VectorStore, queries [][]float32) float64 {
+	t.Helper()
+	ctx := context.Background()
+	found := 0
+	for _, q := range queries {
+		opts := app.SearchOptions{Embedding: q, Limit: recallK}
+		want, err := exact.Search(ctx, opts)
+		require.NoError(t, err)
+		got, err := s.Search(ctx, opts)
+		require.NoError(t, err)
+
+		truth := make(map[string]bool)
+		for _, hit := range want {
+			truth[hit.Document.ID] = true
+		}
+		for _, hit := range got {
+			if truth[hit.Document.ID] {
+				found++
+			}
+		}
+	}
+	return float64(found) / float64(len(queries)*recallK)
+}
+
+func TestStore_RecallLoss(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	vectors := clusteredVectors(rng, 2000)
+	queries := clusteredVectors(rng, 50)
+
+	exact := memory.NewStore(app.Euclidean)
+	for i, v := range vectors {
+		require.NoError(t, exact.Save(context.Background(), &domain.Document{ID: fmt.Sprintf("doc-%d", i), Embedding: v}))
+	}
+
+	tests := []struct {
+		name      string
+		cfg       Config
+		minRecall float64
+	}{
+		{"int8 without rescoring", Config{Quantization: Int8, RescoreFactor: 1}, 0.9},
+		{"int8 with rescoring", Config{Quantization: Int8}, 0.99},
+		{"pq without rescoring", Config{Quantization: Product, Subspaces: 8, RescoreFactor: 1}, 0.45},
+		{"pq with rescoring", Config{Quantization: Product, Subspaces: 8, RescoreFactor: 8}, 0.95},
+	}
+	for _, tt := range tests {
+		t.Run("should keep recall of "+tt.name, func(t *testing.T) {
+			// Arrange
+			tt.cfg.Dimension = recallDimension
+			tt.cfg.Metric = app.Euclidean
+			tt.cfg.TrainingSize = 1000
+			s := newStore(t, tt.cfg)
+			for i, v := range vectors {
+				require.NoError(t, s.Save(context.Background(), &domain.Document{ID: fmt.Sprintf("doc-%d", i), Embedding: v}))
+			}
+			s.trainings.Wait()
+
+			// Act
+			r := recall(t, s, exact, queries)
+
+			// Assert
+			t.Logf("recall@%d = %.3f", recallK, r)
+			assert.GreaterOrEqual(t, r, tt.minRecall)
+		})
+	}
+}
+
+func TestStore_Codes(t *testing.T) {
+	ctx := context.Background()
+	rng := rand.New(rand.NewPCG(3, 4))
+	vectors := clusteredVectors(rng, 300)
+
+	tests := []struct {
+		name     string
+		cfg      Config
+		codeSize int
+	}{
+		{"should store one byte per component with int8", Config{Quantization: Int8}, recallDimension},
+		{"should store one byte per subspace with pq", Config{Quantization: Product, Subspaces: 4}, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			tt.cfg.Dimension = recallDimension
+			tt.cfg.TrainingSize = 256
+			tt.cfg.Dir = t.TempDir()
+			s := newStore(t, tt.cfg)
+
+			// Act
+			for i, v := range vectors {
+				require.NoError(t, s.Save(ctx, &domain.Document{ID: fmt.Sprintf("doc-%d", i), Embedding: v}))
+			}
+			s.trainings.Wait()
+			found, err := s.FindByID(ctx, "doc-7")
+
+			// Assert
+			require.NoError(t, err)
+			for _, e := range s.entries {
+				assert.Len(t, e.code, tt.codeSize)
+				assert.Nil(t, e.doc.Embedding)
+			}
+			assert.Equal(t, vectors[7], found.Embedding)
+		})
+	}
+}
+
+func TestStore_Search(t *testing.T) {
+	ctx := context.Background()
+	s := newStore(t, Config{TrainingSize: 3})
+	for _, doc := range []*domain.Document{
+		{ID: "east", Content: "sunrise over the sea", Embedding: []float32{1, 0}},
+		{ID: "north-east", Content: "error E1234 in the logs", Embedding: []float32{1, 1}},
+		{ID: "north", Content: "polar night", Embedding: []float32{0, 1}, Metadata: domain.Metadata{Tags: []string{"night"}}},
+	} {
+		require.NoError(t, s.Save(ctx, doc))
+	}
+
+	t.Run("should return exact scores of the nearest documents", func(t *testing.T) {
+		// Act
+		hits, err := s.Search(ctx, app.SearchOptions{Embedding: []float32{1, 0}, Limit: 2})
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, []string{"east", "north-east"}, ids(hits))
+		assert.InDelta(t, 1, hits[0].Score, 1e-9)
+		assert.Equal(t, []float32{1, 0}, hits[0].Document.Embedding)
+	})
+
+	t.Run("should apply metadata filters", func(t *testing.T) {
+		// Act
+		hits, err := s.Search(ctx, app.SearchOptions{Embedding: []float32{1, 0}, Filter: app.TagFilter{Tags: []string{"night"}}, Limit: 10})
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, []string{"north"}, ids(hits))
+	})
+
+	t.Run("should find exact identifiers by keyword", func(t *testing.T) {
+		// Act
+		hits, err := s.Search(ctx, app.SearchOptions{Embedding: []float32{1, 0}, Text: "E1234", Limit: 10})
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, []string{"north-east"}, ids(hits))
+	})
+
+	t.Run("should replace a document saved again", func(t *testing.T) {
+		// Act
+		require.NoError(t, s.Save(ctx, &domain.Document{ID: "east", Content: "moved", Embedding: []float32{0, 1}}))
+		hits, err := s.Search(ctx, app.SearchOptions{Embedding: []float32{0, 1}, Limit: 1})
+
+		// Assert
+		require.NoError(t, err)
+		require.Len(t, hits, 1)
+		assert.Contains(t, []string{"east", "north"}, hits[0].Document.ID)
+		assert.Len(t, s.entries, 3)
+	})
+}
+
+func TestStore_Delete(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should move the last document into the freed slot", func(t *testing.T) {
+		// Arrange
+		s := newStore(t, Config{TrainingSize: 2})
+		for _, doc := range []*domain.Document{
+			{ID: "east", Embedding: []float32{1, 0}},
+			{ID: "north-east", Embedding: []float32{1, 1}},
+			{ID: "north", Embedding: []float32{0, 1}},
+		} {
+			require.NoError(t, s.Save(ctx, doc))
+		}
+
+		// Act
+		err := s.Delete(ctx, "east")
+
+		// Assert
+		require.NoError(t, err)
+		assert.Len(t, s.entries, 2)
+		found, err := s.FindByID(ctx, "north")
+		require.NoError(t, err)
+		assert.Equal(t, []float32{0, 1}, found.Embedding)
+		hits, err := s.Search(ctx, app.SearchOptions{Embedding: []float32{1, 0}, Limit: 10})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"north-east", "north"}, ids(hits))
+	})
+
+	t.Run("should return ErrDocumentNotFound for an unknown id", func(t *testing.T) {
+		// Act
+		err := newStore(t, Config{}).Delete(ctx, "missing")
+
+		// Assert
+		assert.ErrorIs(t, err, domain.ErrDocumentNotFound)
+	})
+}
+
+func TestStore_Training(t *testing.T) {
+	ctx := context.Background()
+	vectors := clusteredVectors(rand.New(rand.NewPCG(5, 6)), 400)
+
+	t.Run("should retrain once the documents have grown by RetrainGrowth", func(t *testing.T) {
+		// Arrange
+		s := newStore(t, Config{Dimension: recallDimension, TrainingSize: 100})
+
+		// Act
+		for i, v := range vectors {
+			require.NoError(t, s.Save(ctx, &domain.Document{ID: fmt.Sprintf("doc-%d", i), Embedding: v}))
+			s.trainings.Wait()
+		}
+
+		// Assert
+		assert.Equal(t, 400, s.trainedOn)
+		for _, e := range s.entries {
+			assert.Len(t, e.code, recallDimension)
+		}
+	})
+
+	t.Run("should retrain on request", func(t *testing.T) {
+		// Arrange
+		s := newStore(t, Config{Dimension: recallDimension, TrainingSize: 100, RetrainGrowth: -1})
+		for i, v := range vectors {
+			require.NoError(t, s.Save(ctx, &domain.Document{ID: fmt.Sprintf("doc-%d", i), Embedding: v}))
+			s.trainings.Wait()
+		}
+		require.Equal(t, 100, s.trainedOn)
+
+		// Act
+		err := s.Retrain()
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, 400, s.trainedOn)
+	})
+}
+
+func TestStore_Reopen(t *testing.T) {
+	ctx := context.Background()
+	vectors := clusteredVectors(rand.New(rand.NewPCG(7, 8)), 300)
+
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{"should restore documents, vectors and int8 codes", Config{Quantization: Int8}},
+		{"should restore documents, vectors and pq codes", Config{Quantization: Product, Subspaces: 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			tt.cfg.Dimension = recallDimension
+			tt.cfg.TrainingSize = 200
+			tt.cfg.Dir = t.TempDir()
+			s, err := NewStore(tt.cfg)
+			require.NoError(t, err)
+			for i, v := range vectors {
+				require.NoError(t, s.Save(ctx, &domain.Document{ID: fmt.Sprintf("doc-%d", i), Content: fmt.Sprintf("content %d", i), Embedding: v}))
+			}
+			require.NoError(t, s.Delete(ctx, "doc-3"))
+			s.trainings.Wait()
+			query := app.SearchOptions{Embedding: vectors[42], Limit: 5}
+			want, err := s.Search(ctx, query)
+			require.NoError(t, err)
+			codes := make(map[string][]byte)
+			for _, e := range s.entries {
+				codes[e.doc.ID] = e.code
+			}
+			require.NoError(t, s.Close())
+
+			// Act
+			reopened := newStore(t, tt.cfg)
+
+			// Assert
+			require.NotNil(t, reopened.quantizer)
+			assert.Len(t, reopened.entries, 299)
+			for _, e := range reopened.entries {
+				assert.Equal(t, codes[e.doc.ID], e.code)
+			}
+			found, err := reopened.FindByID(ctx, "doc-7")
+			require.NoError(t, err)
+			assert.Equal(t, vectors[7], found.Embedding)
+			assert.Equal(t, "content 7", found.Content)
+			_, err = reopened.FindByID(ctx, "doc-3")
+			assert.ErrorIs(t, err, domain.ErrDocumentNotFound)
+			got, err := reopened.Search(ctx, query)
+			require.NoError(t, err)
+			assert.Equal(t, ids(want), ids(got))
+		})
+	}
+
+	t.Run("should start empty without a snapshot", func(t *testing.T) {
+		// Act
+		s := newStore(t, Config{Dir: t.TempDir()})
+
+		// Assert
+		assert.Empty(t, s.entries)
+	})
+
+	t.Run("should create a missing directory", func(t *testing.T) {
+		// Arrange
+		dir := filepath.Join(t.TempDir(), "data", "quantized")
+
+		// Act
+		newStore(t, Config{Dir: dir})
+
+		// Assert
+		assert.DirExists(t, dir)
+	})
+
+	t.Run("should reject a snapshot of another dimension", func(t *testing.T) {
+		// Arrange
+		dir := t.TempDir()
+		s, err := NewStore(Config{Dimension: 2, Dir: dir})
+		require.NoError(t, err)
+		require.NoError(t, s.Save(ctx, &domain.Document{ID: "east", Embedding: []float32{1, 0}}))
+		require.NoError(t, s.Close())
+
+		// Act
+		_, err = NewStore(Config{Dimension: 3, Dir: dir})
+
+		// Assert
+		assert.ErrorContains(t, err, "dimension")
+	})
+}
@@ -0,0 +1,79 @@
+package quantized
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+)
+
+// vectorStorage holds the full-precision vectors used for rescoring, addressed by slot.
+type vectorStorage interface {
+	write(slot int, v []float32) error
+	read(slot int, v []float32) error
+	close() error
+}
+
+// memoryVectors keeps full-precision vectors in memory.
+type memoryVectors struct {
+	vectors [][]float32
+}
+
+func (m *memoryVectors) write(slot int, v []float32) error {
+	for len(m.vectors) <= slot {
+		m.vectors = append(m.vectors, nil)
+	}
+	m.vectors[slot] = append([]float32(nil), v...)
+	return nil
+}
+
+func (m *memoryVectors) read(slot int, v []float32) error {
+	copy(v, m.vectors[slot])
+	return nil
+}
+
+func (m *memoryVectors) close() error {
+	return nil
+}
+
+// fileVectors keeps full-precision vectors in a file of fixed-size little-endian records, so
+// that only the compact codes occupy memory. The file is a working copy, rewritten from the
+// snapshot on every start.
+type fileVectors struct {
+	file *os.File
+	dim  int
+}
+
+func openFileVectors(path string, dim int) (*fileVectors, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open vector file: %w", err)
+	}
+	return &fileVectors{file: file, dim: dim}, nil
+}
+
+func (f *fileVectors) write(slot int, v []float32) error {
+	buf := make([]byte, 4*f.dim)
+	for i, x := range v {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(x))
+	}
+	if _, err := f.file.WriteAt(buf, int64(slot)*int64(len(buf))); err != nil {
+		return fmt.Errorf("failed to write vector: %w", err)
+	}
+	return nil
+}
+
+func (f *fileVectors) read(slot int, v []float32) error {
+	buf := make([]byte, 4*f.dim)
+	if _, err := f.file.ReadAt(buf, int64(slot)*int64(len(buf))); err != nil {
+		return fmt.Errorf("failed to read vector: %w", err)
+	}
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:]))
+	}
+	return nil
+}
+
+func (f *fileVectors) close() error {
+	return f.file.Close()
+}
@@ -0,0 +1,118 @@
+package persistence
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/igorrius/go-vector-search/internal/app"
+)
+
+// typesenseFilter translates a metadata filter into a Typesense filter_by expression.
+// A nil filter yields an empty expression.
+func typesenseFilter(f app.Filter) (string, error) {
+	switch f := f.(type) {
+	case nil:
+		return "", nil
+	case app.EqualFilter:
+		field, err := typesenseFilterField(f.Field)
+		if err != nil {
+			return "", err
+		}
+		value, err := typesenseFilterValue(f.Value)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s:=%s", field, value), nil
+	case app.InFilter:
+		field, err := typesenseFilterField(f.Field)
+		if err != nil {
+			return "", err
+		}
+		values, err := typesenseFilterValues(f.Values)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s:=[%s]", field, values), nil
+	case app.TagFilter:
+		values, err := typesenseFilterValues(f.Tags)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("tags:=[%s]", values), nil
+	case app.RangeFilter:
+		field, err := typesenseFilterField(f.Field)
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case f.Min != nil && f.Max != nil:
+			return fmt.Sprintf("%s:[%s..%s]", field, formatNumber(*f.Min), formatNumber(*f.Max)), nil
+		case f.Min != nil:
+			return fmt.Sprintf("%s:>=%s", field, formatNumber(*f.Min)), nil
+		case f.Max != nil:
+			return fmt.Sprintf("%s:<=%s", field, formatNumber(*f.Max)), nil
+		default:
+			return "", &app.FilterError{Reason: fmt.Sprintf("range for %q has no bounds", f.Field)}
+		}
+	case app.AndFilter:
+		return joinTypesenseFilters(f.Filters, " && ")
+	case app.OrFilter:
+		return joinTypesenseFilters(f.Filters, " || ")
+	default:
+		return "", &app.FilterError{Reason: fmt.Sprintf("unsupported filter %T", f)}
+	}
+}
+
+func joinTypesenseFilters(filters []app.Filter, op string) (string, error) {
+	if len(filters) == 0 {
+		return "", &app.FilterError{Reason: "empty filter group"}
+	}
+	parts := make([]string, len(filters))
+	for i, f := range filters {
+		part, err := typesenseFilter(f)
+		if err != nil {
+			return "", err
+		}
+		parts[i] = part
+	}
+	return "(" + strings.Join(parts, op) + ")", nil
+}
+
+// typesenseFilterField maps a filter field to the collection field storing it.
+func typesenseFilterField(field string) (string, error) {
+	if _, ok := app.FilterFieldKind(field); !ok {
+		return "", &app.FilterError{Reason: fmt.Sprintf("unknown field %q", field)}
+	}
+	if name, ok := strings.CutPrefix(field, app.AttributeFieldPrefix); ok {
+		return attributeFieldPrefix + name, nil
+	}
+	return field, nil
+}
+
+// typesenseFilterValue quotes a string value with backticks, which cannot themselves be escaped.
+func typesenseFilterValue(value string) (string, error) {
+	if strings.Contains(value, "`") {
+		return "", &app.FilterError{Reason: fmt.Sprintf("value %q must not contain backticks", value)}
+	}
+	return "`" + value + "`", nil
+}
+
+func typesenseFilterValues(values []string) (string, error) {
+	if len(values) == 0 {
+		return "", &app.FilterError{Reason: "empty value list"}
+	}
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		q, err := typesenseFilterValue(v)
+		if err != nil {
+			return "", err
+		}
+		quoted[i] = q
+	}
+	return strings.Join(quoted, ","), nil
+}
+
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
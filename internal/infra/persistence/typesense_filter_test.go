@@ -0,0 +1,52 @@
+package persistence
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/igorrius/go-vector-search/internal/app"
+)
+
+func TestTypesenseFilter(t *testing.T) {
+	lo, hi := 2.0, 5.0
+
+	t.Run("should translate a filter expression", func(t *testing.T) {
+		filter := app.AndFilter{Filters: []app.Filter{
+			app.EqualFilter{Field: "mime_type", Value: "text/plain"},
+			app.OrFilter{Filters: []app.Filter{
+				app.TagFilter{Tags: []string{"go", "rust"}},
+				app.InFilter{Field: "attributes.author", Values: []string{"a", "b"}},
+			}},
+			app.RangeFilter{Field: "chunk_index", Min: &lo, Max: &hi},
+			app.RangeFilter{Field: "created_at", Min: &lo},
+		}}
+
+		filterBy, err := typesenseFilter(filter)
+
+		require.NoError(t, err)
+		assert.Equal(t, "(mime_type:=`text/plain` && (tags:=[`go`,`rust`] || attr_author:=[`a`,`b`]) && chunk_index:[2..5] && created_at:>=2)", filterBy)
+	})
+
+	t.Run("should return an empty expression for a nil filter", func(t *testing.T) {
+		filterBy, err := typesenseFilter(nil)
+
+		require.NoError(t, err)
+		assert.Empty(t, filterBy)
+	})
+
+	t.Run("should reject values that cannot be quoted", func(t *testing.T) {
+		_, err := typesenseFilter(app.EqualFilter{Field: "title", Value: "a`b"})
+
+		var filterErr *app.FilterError
+		assert.ErrorAs(t, err, &filterErr)
+	})
+
+	t.Run("should reject unknown fields", func(t *testing.T) {
+		_, err := typesenseFilter(app.EqualFilter{Field: "embedding", Value: "x"})
+
+		var filterErr *app.FilterError
+		assert.ErrorAs(t, err, &filterErr)
+	})
+}
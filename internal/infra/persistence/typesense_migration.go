@@ -0,0 +1,519 @@
+package persistence
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/igorrius/go-vector-search/internal/app"
+
+	"github.com/typesense/typesense-go/typesense"
+	"github.com/typesense/typesense-go/typesense/api"
+)
+
+const (
+	// schemaVersion is the version of the documents collection layout defined by desiredSchema.
+	// Bump it whenever the field list changes.
+	schemaVersion = 4
+
+	// defaultVectorDistance is the distance Typesense uses for vector fields that do not declare one.
+	defaultVectorDistance = "cosine"
+
+	migrationsCollectionName = "schema_migrations"
+
+	// copyBatchSize is the number of documents imported per request when a collection is copied.
+	copyBatchSize = 500
+)
+
+// ErrReindexRequired is returned when stored documents cannot be converted to the desired schema
+// and have to be indexed again.
+var ErrReindexRequired = errors.New("re-index required")
+
+// schemaDefinition is a versioned collection schema.
+type schemaDefinition struct {
+	Version int
+	Schema  *api.CollectionSchema
+	// VectorDistance is the vec_dist of the vector fields, "cosine" or "ip". Empty means cosine.
+	VectorDistance string
+}
+
+func (d schemaDefinition) vectorDistance() string {
+	if d.VectorDistance == "" {
+		return defaultVectorDistance
+	}
+	return d.VectorDistance
+}
+
+// schemaDiff describes the changes required to turn a live collection schema into the desired one.
+type schemaDiff struct {
+	// Added holds fields that are missing from the live schema.
+	Added []api.Field
+	// Changed holds fields whose attributes differ but whose stored values remain valid.
+	Changed []api.Field
+	// Removed holds the names of live fields that are no longer part of the desired schema.
+	Removed []string
+	// Breaking holds the names of fields whose stored values are incompatible with the desired schema,
+	// e.g. a vector field with a different num_dim.
+	Breaking []string
+}
+
+// IsEmpty reports whether the live schema already matches the desired one.
+func (d schemaDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Changed) == 0 && len(d.Removed) == 0 && len(d.Breaking) == 0
+}
+
+// IsBreaking reports whether the collection has to be recreated.
+func (d schemaDiff) IsBreaking() bool {
+	return len(d.Breaking) > 0
+}
+
+// diffSchema compares the live collection fields with the desired ones.
+// The implicit "id" field is ignored as Typesense manages it itself.
+func diffSchema(live, desired []api.Field) schemaDiff {
+	var diff schemaDiff
+
+	liveByName := make(map[string]api.Field, len(live))
+	for _, f := range live {
+		liveByName[f.Name] = f
+	}
+
+	desiredNames := make(map[string]struct{}, len(desired))
+	for _, want := range desired {
+		desiredNames[want.Name] = struct{}{}
+		if want.Name == "id" {
+			continue
+		}
+
+		have, ok := liveByName[want.Name]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, want)
+		case have.Type != want.Type || intValue(have.NumDim) != intValue(want.NumDim):
+			diff.Breaking = append(diff.Breaking, want.Name)
+		case !sameFieldAttributes(have, want):
+			diff.Changed = append(diff.Changed, want)
+		}
+	}
+
+	for _, have := range live {
+		if have.Name == "id" {
+			continue
+		}
+		if _, ok := desiredNames[have.Name]; !ok {
+			diff.Removed = append(diff.Removed, have.Name)
+		}
+	}
+
+	return diff
+}
+
+// sameFieldAttributes compares the index attributes of two fields, applying Typesense defaults
+// to attributes that were not set explicitly.
+func sameFieldAttributes(a, b api.Field) bool {
+	return boolValue(a.Facet, false) == boolValue(b.Facet, false) &&
+		boolValue(a.Optional, false) == boolValue(b.Optional, false) &&
+		boolValue(a.Index, true) == boolValue(b.Index, true) &&
+		boolValue(a.Infix, false) == boolValue(b.Infix, false)
+}
+
+// updateSchemaFor builds the collection update request for a non-breaking diff.
+// Changed fields are dropped and re-added in the same request so Typesense re-indexes them.
+func updateSchemaFor(diff schemaDiff) *api.CollectionUpdateSchema {
+	update := &api.CollectionUpdateSchema{}
+	for _, name := range diff.Removed {
+		update.Fields = append(update.Fields, api.Field{Name: name, Drop: boolPtr(true)})
+	}
+	for _, f := range diff.Changed {
+		update.Fields = append(update.Fields, api.Field{Name: f.Name, Drop: boolPtr(true)}, f)
+	}
+	update.Fields = append(update.Fields, diff.Added...)
+	return update
+}
+
+// schemaMigrator brings a Typesense collection in line with a schemaDefinition without losing data.
+type schemaMigrator struct {
+	client *typesense.Client
+	// api creates collections from raw JSON, since the typed schema of the client cannot declare
+	// the vector distance.
+	api api.ClientInterface
+	// embedder re-embeds the stored chunks when the embedding field changes incompatibly. Nil
+	// makes such a change fail with ErrReindexRequired.
+	embedder app.BatchEmbeddingGenerator
+}
+
+type migrationRecord struct {
+	Version        int
+	VectorDistance string
+}
+
+// Migrate creates the collection if it does not exist, updates it in place for additive changes and
+// recreates it, copying existing documents over, when a breaking change is detected. A changed
+// vector distance is breaking, as Typesense cannot alter it in place. Stored values of breaking
+// fields are converted to the new layout, or ErrReindexRequired is returned before anything is
+// changed when they cannot be.
+// The applied version and vector distance are recorded in the schema_migrations collection.
+func (m *schemaMigrator) Migrate(ctx context.Context, def schemaDefinition) error {
+	if err := m.ensureMigrationsCollection(ctx); err != nil {
+		return err
+	}
+
+	applied, err := m.appliedMigration(ctx, def.Schema.Name)
+	if err != nil {
+		return err
+	}
+	if applied.Version > def.Version {
+		return fmt.Errorf("collection %q is at schema version %d, newer than supported version %d", def.Schema.Name, applied.Version, def.Version)
+	}
+
+	live, err := m.client.Collection(def.Schema.Name).Retrieve(ctx)
+	if err != nil {
+		if !isNotFound(err) {
+			return fmt.Errorf("failed to retrieve collection %q: %w", def.Schema.Name, err)
+		}
+		if err := m.createCollection(ctx, def.Schema, def.vectorDistance()); err != nil {
+			return fmt.Errorf("failed to create collection %q: %w", def.Schema.Name, err)
+		}
+		return m.recordVersion(ctx, def)
+	}
+
+	diff := diffSchema(live.Fields, def.Schema.Fields)
+	switch {
+	case diff.IsBreaking():
+		transform, err := m.convert(def.Schema.Name, diff.Breaking)
+		if err != nil {
+			return err
+		}
+		log.Printf("Collection %q has breaking schema changes in fields %v, recreating", def.Schema.Name, diff.Breaking)
+		if err := m.recreate(ctx, def, live, applied.VectorDistance, transform); err != nil {
+			return err
+		}
+	case applied.VectorDistance != def.vectorDistance():
+		log.Printf("Collection %q changes vector distance from %q to %q, recreating", def.Schema.Name, applied.VectorDistance, def.vectorDistance())
+		if err := m.recreate(ctx, def, live, applied.VectorDistance, nil); err != nil {
+			return err
+		}
+	case !diff.IsEmpty():
+		log.Printf("Updating collection %q schema in place", def.Schema.Name)
+		if _, err := m.client.Collection(def.Schema.Name).Update(ctx, updateSchemaFor(diff)); err != nil {
+			return fmt.Errorf("failed to update collection %q: %w", def.Schema.Name, err)
+		}
+	case applied.Version == def.Version:
+		return nil
+	}
+
+	return m.recordVersion(ctx, def)
+}
+
+// convert returns the transform that turns documents stored with the breaking fields into ones
+// valid for the new layout. Only the embedding field can be converted, by re-embedding the content.
+func (m *schemaMigrator) convert(collection string, breaking []string) (documentTransform, error) {
+	for _, name := range breaking {
+		if name != "embedding" {
+			return nil, fmt.Errorf("%w: collection %q has incompatible fields %v, drop it and index the documents again", ErrReindexRequired, collection, breaking)
+		}
+	}
+	if m.embedder == nil {
+		return nil, fmt.Errorf("%w: the embeddings of collection %q have a different dimension and no embedder is configured to re-embed them", ErrReindexRequired, collection)
+	}
+	return reembed(m.embedder), nil
+}
+
+// recreate rebuilds the collection with the desired schema. Documents are first copied unchanged
+// into a backup collection with the live schema, so a failure at any step leaves a full copy
+// behind. They are then copied back through the transform, if any. When that fails after the
+// live collection was deleted, it is restored from the backup with its previous schema, so that
+// the next start finds it as before and migrates again.
+func (m *schemaMigrator) recreate(ctx context.Context, def schemaDefinition, live *api.CollectionResponse, liveDistance string, transform documentTransform) error {
+	schema := def.Schema
+	backup := &api.CollectionSchema{
+		Name:                fmt.Sprintf("%s_migration_%d", schema.Name, time.Now().Unix()),
+		Fields:              live.Fields,
+		DefaultSortingField: live.DefaultSortingField,
+		EnableNestedFields:  live.EnableNestedFields,
+		SymbolsToIndex:      live.SymbolsToIndex,
+		TokenSeparators:     live.TokenSeparators,
+	}
+
+	if err := m.createCollection(ctx, backup, liveDistance); err != nil {
+		return fmt.Errorf("failed to create backup collection %q: %w", backup.Name, err)
+	}
+	if err := m.copyDocuments(ctx, schema.Name, backup.Name, nil); err != nil {
+		return err
+	}
+
+	if _, err := m.client.Collection(schema.Name).Delete(ctx); err != nil {
+		return fmt.Errorf("failed to delete collection %q: %w", schema.Name, err)
+	}
+	failed := func(err error) error {
+		if restoreErr := m.restore(context.WithoutCancel(ctx), schema.Name, backup, liveDistance); restoreErr != nil {
+			return fmt.Errorf("%w; restoring the collection failed, documents are kept in %q: %v", err, backup.Name, restoreErr)
+		}
+		return fmt.Errorf("%w; the collection was restored with its previous schema", err)
+	}
+	if err := m.createCollection(ctx, schema, def.vectorDistance()); err != nil {
+		return failed(fmt.Errorf("failed to recreate collection %q: %w", schema.Name, err))
+	}
+	if err := m.copyDocuments(ctx, backup.Name, schema.Name, transform); err != nil {
+		return failed(err)
+	}
+
+	if _, err := m.client.Collection(backup.Name).Delete(ctx); err != nil {
+		return fmt.Errorf("failed to delete backup collection %q: %w", backup.Name, err)
+	}
+	return nil
+}
+
+// restore rebuilds the collection from the backup with the schema and vector distance of the
+// backup, and deletes the backup.
+func (m *schemaMigrator) restore(ctx context.Context, name string, backup *api.CollectionSchema, vecDist string) error {
+	if _, err := m.client.Collection(name).Delete(ctx); err != nil && !isNotFound(err) {
+		return fmt.Errorf("failed to delete collection %q: %w", name, err)
+	}
+	live := *backup
+	live.Name = name
+	if err := m.createCollection(ctx, &live, vecDist); err != nil {
+		return fmt.Errorf("failed to create collection %q: %w", name, err)
+	}
+	if err := m.copyDocuments(ctx, backup.Name, name, nil); err != nil {
+		return err
+	}
+	if _, err := m.client.Collection(backup.Name).Delete(ctx); err != nil {
+		return fmt.Errorf("failed to delete backup collection %q: %w", backup.Name, err)
+	}
+	return nil
+}
+
+// createCollection creates a collection whose vector fields use the given vec_dist.
+func (m *schemaMigrator) createCollection(ctx context.Context, schema *api.CollectionSchema, vecDist string) error {
+	body, err := collectionSchemaJSON(schema, vecDist)
+	if err != nil {
+		return err
+	}
+
+	res, err := m.api.CreateCollectionWithBody(ctx, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusCreated {
+		msg, _ := io.ReadAll(res.Body)
+		return &typesense.HTTPError{Status: res.StatusCode, Body: msg}
+	}
+	return nil
+}
+
+// collectionSchemaJSON encodes the schema, adding vec_dist to every field with num_dim.
+func collectionSchemaJSON(schema *api.CollectionSchema, vecDist string) ([]byte, error) {
+	encoded, err := json.Marshal(schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode schema of %q: %w", schema.Name, err)
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(encoded, &doc); err != nil {
+		return nil, fmt.Errorf("failed to encode schema of %q: %w", schema.Name, err)
+	}
+	fields, _ := doc["fields"].([]interface{})
+	for _, f := range fields {
+		if field, ok := f.(map[string]interface{}); ok && field["num_dim"] != nil {
+			field["vec_dist"] = vecDist
+		}
+	}
+	return json.Marshal(doc)
+}
+
+// documentTransform rewrites a batch of exported documents before they are imported.
+type documentTransform func(ctx context.Context, docs []map[string]interface{}) error
+
+// reembed replaces the embedding of every document with the embedding of its content.
+func reembed(embedder app.BatchEmbeddingGenerator) documentTransform {
+	return func(ctx context.Context, docs []map[string]interface{}) error {
+		contents := make([]string, len(docs))
+		for i, doc := range docs {
+			contents[i], _ = doc["content"].(string)
+		}
+		embeddings, err := embedder.GenerateBatch(ctx, contents)
+		if err != nil {
+			return fmt.Errorf("failed to re-embed documents: %w", err)
+		}
+		for i, doc := range docs {
+			doc["embedding"] = embeddings[i]
+		}
+		return nil
+	}
+}
+
+// copyDocuments streams all documents of one collection into another, importing them in batches
+// of copyBatchSize through the transform, if any.
+func (m *schemaMigrator) copyDocuments(ctx context.Context, from, to string, transform documentTransform) error {
+	export, err := m.client.Collection(from).Documents().Export(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to export documents from %q: %w", from, err)
+	}
+	defer export.Close()
+
+	scanner := bufio.NewScanner(export)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	var batch [][]byte
+	for scanner.Scan() {
+		batch = append(batch, bytes.Clone(scanner.Bytes()))
+		if len(batch) < copyBatchSize {
+			continue
+		}
+		if err := m.importDocuments(ctx, to, batch, transform); err != nil {
+			return err
+		}
+		batch = batch[:0]
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read documents exported from %q: %w", from, err)
+	}
+	if len(batch) == 0 {
+		return nil
+	}
+	return m.importDocuments(ctx, to, batch, transform)
+}
+
+// importDocuments upserts a batch of exported JSON documents.
+func (m *schemaMigrator) importDocuments(ctx context.Context, to string, lines [][]byte, transform documentTransform) error {
+	if transform != nil {
+		docs := make([]map[string]interface{}, len(lines))
+		for i, line := range lines {
+			if err := json.Unmarshal(line, &docs[i]); err != nil {
+				return fmt.Errorf("failed to decode document imported into %q: %w", to, err)
+			}
+		}
+		if err := transform(ctx, docs); err != nil {
+			return err
+		}
+		for i, doc := range docs {
+			line, err := json.Marshal(doc)
+			if err != nil {
+				return fmt.Errorf("failed to encode document imported into %q: %w", to, err)
+			}
+			lines[i] = line
+		}
+	}
+
+	action := "upsert"
+	body := bytes.NewReader(bytes.Join(lines, []byte("\n")))
+	res, err := m.client.Collection(to).Documents().ImportJsonl(ctx, body, &api.ImportDocumentsParams{Action: &action})
+	if err != nil {
+		return fmt.Errorf("failed to import documents into %q: %w", to, err)
+	}
+	defer res.Close()
+
+	failed, err := countImportFailures(res)
+	if err != nil {
+		return fmt.Errorf("failed to read import result for %q: %w", to, err)
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to import %d of %d documents into %q", failed, len(lines), to)
+	}
+	return nil
+}
+
+func countImportFailures(r io.Reader) (int, error) {
+	failed := 0
+	decoder := json.NewDecoder(r)
+	for decoder.More() {
+		var res api.ImportDocumentResponse
+		if err := decoder.Decode(&res); err != nil {
+			return failed, err
+		}
+		if !res.Success {
+			failed++
+		}
+	}
+	return failed, nil
+}
+
+func (m *schemaMigrator) ensureMigrationsCollection(ctx context.Context) error {
+	_, err := m.client.Collection(migrationsCollectionName).Retrieve(ctx)
+	if err == nil {
+		return nil
+	}
+	if !isNotFound(err) {
+		return fmt.Errorf("failed to retrieve collection %q: %w", migrationsCollectionName, err)
+	}
+
+	schema := &api.CollectionSchema{
+		Name: migrationsCollectionName,
+		Fields: []api.Field{
+			{Name: "version", Type: "int32"},
+			{Name: "applied_at", Type: "int64"},
+		},
+	}
+	if _, err := m.client.Collections().Create(ctx, schema); err != nil && !isAlreadyExists(err) {
+		return fmt.Errorf("failed to create collection %q: %w", migrationsCollectionName, err)
+	}
+	return nil
+}
+
+// appliedMigration returns the recorded state of the collection, version 0 if none was recorded.
+// Collections recorded before the vector distance was configurable use the default distance.
+func (m *schemaMigrator) appliedMigration(ctx context.Context, collection string) (migrationRecord, error) {
+	record := migrationRecord{VectorDistance: defaultVectorDistance}
+	doc, err := m.client.Collection(migrationsCollectionName).Document(collection).Retrieve(ctx)
+	if err != nil {
+		if isNotFound(err) {
+			return record, nil
+		}
+		return record, fmt.Errorf("failed to retrieve schema version of %q: %w", collection, err)
+	}
+
+	version, ok := doc["version"].(float64)
+	if !ok {
+		return record, fmt.Errorf("schema version of %q is not a number", collection)
+	}
+	record.Version = int(version)
+	if vecDist, ok := doc["vector_distance"].(string); ok && vecDist != "" {
+		record.VectorDistance = vecDist
+	}
+	return record, nil
+}
+
+func (m *schemaMigrator) recordVersion(ctx context.Context, def schemaDefinition) error {
+	record := map[string]interface{}{
+		"id":              def.Schema.Name,
+		"version":         def.Version,
+		"vector_distance": def.vectorDistance(),
+		"applied_at":      time.Now().Unix(),
+	}
+	if _, err := m.client.Collection(migrationsCollectionName).Documents().Upsert(ctx, record); err != nil {
+		return fmt.Errorf("failed to record schema version of %q: %w", def.Schema.Name, err)
+	}
+	return nil
+}
+
+func isNotFound(err error) bool {
+	var httpErr *typesense.HTTPError
+	return errors.As(err, &httpErr) && httpErr.Status == http.StatusNotFound
+}
+
+func isAlreadyExists(err error) bool {
+	var httpErr *typesense.HTTPError
+	return errors.As(err, &httpErr) && httpErr.Status == http.StatusConflict
+}
+
+func boolValue(b *bool, fallback bool) bool {
+	if b == nil {
+		return fallback
+	}
+	return *b
+}
+
+func intValue(i *int) int {
+	if i == nil {
+		return 0
+	}
+	return *i
+}
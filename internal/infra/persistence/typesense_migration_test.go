@@ -0,0 +1,294 @@
+package persistence
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/igorrius/go-vector-search/internal/app"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/typesense/typesense-go/typesense"
+	"github.com/typesense/typesense-go/typesense/api"
+)
+
+// liveFields returns the desired fields as Typesense reports them, without the implicit id field,
+// with the named field replaced.
+func liveFields(desired []api.Field, replace api.Field) []api.Field {
+	var live []api.Field
+	for _, f := range desired {
+		switch f.Name {
+		case "id":
+		case replace.Name:
+			live = append(live, replace)
+		default:
+			live = append(live, f)
+		}
+	}
+	return live
+}
+
+func TestDiffSchema(t *testing.T) {
+	desired := desiredSchema(8).Schema.Fields
+
+	t.Run("should report no changes for an identical schema", func(t *testing.T) {
+		diff := diffSchema(desired, desired)
+
+		assert.True(t, diff.IsEmpty())
+	})
+
+	t.Run("should ignore the id field and explicit defaults", func(t *testing.T) {
+		live := liveFields(desired, api.Field{Name: "content", Type: "string", Facet: boolPtr(false), Index: boolPtr(true), Optional: boolPtr(false)})
+
+		diff := diffSchema(live, desired)
+
+		assert.True(t, diff.IsEmpty())
+	})
+
+	t.Run("should add missing fields", func(t *testing.T) {
+		live := []api.Field{
+			{Name: "content", Type: "string"},
+		}
+
+		diff := diffSchema(live, desired)
+
+		var added []string
+		for _, f := range diff.Added {
+			added = append(added, f.Name)
+		}
+		assert.False(t, diff.IsBreaking())
+		assert.Len(t, added, len(desired)-2)
+		assert.Contains(t, added, "parent_id")
+		assert.Contains(t, added, "tags")
+		assert.Contains(t, added, "embedding")
+		assert.NotContains(t, added, "content")
+	})
+
+	t.Run("should treat a different num_dim as breaking", func(t *testing.T) {
+		live := liveFields(desired, api.Field{Name: "embedding", Type: "float[]", Optional: boolPtr(true), NumDim: intPtr(768)})
+
+		diff := diffSchema(live, desired)
+
+		assert.True(t, diff.IsBreaking())
+		assert.Equal(t, []string{"embedding"}, diff.Breaking)
+	})
+
+	t.Run("should drop and re-add fields with changed attributes", func(t *testing.T) {
+		live := liveFields(desired, api.Field{Name: "content", Type: "string", Facet: boolPtr(true)})
+		live = append(live, api.Field{Name: "legacy", Type: "string"})
+
+		diff := diffSchema(live, desired)
+		update := updateSchemaFor(diff)
+
+		assert.False(t, diff.IsBreaking())
+		assert.Equal(t, []string{"legacy"}, diff.Removed)
+		assert.Equal(t, []api.Field{
+			{Name: "legacy", Drop: boolPtr(true)},
+			{Name: "content", Drop: boolPtr(true)},
+			{Name: "content", Type: "string"},
+		}, update.Fields)
+	})
+}
+
+func TestCollectionSchemaJSON(t *testing.T) {
+	t.Run("should declare the vector distance of vector fields only", func(t *testing.T) {
+		body, err := collectionSchemaJSON(desiredSchema(8).Schema, "ip")
+		require.NoError(t, err)
+
+		var schema struct {
+			Name   string `json:"name"`
+			Fields []struct {
+				Name    string `json:"name"`
+				VecDist string `json:"vec_dist"`
+			} `json:"fields"`
+		}
+		require.NoError(t, json.Unmarshal(body, &schema))
+
+		assert.Equal(t, collectionName, schema.Name)
+		for _, f := range schema.Fields {
+			if f.Name == "embedding" {
+				assert.Equal(t, "ip", f.VecDist)
+			} else {
+				assert.Empty(t, f.VecDist, f.Name)
+			}
+		}
+	})
+}
+
+func TestTypesenseVectorDistance(t *testing.T) {
+	t.Run("should map supported metrics", func(t *testing.T) {
+		cosine, err := typesenseVectorDistance(app.Cosine)
+		require.NoError(t, err)
+		ip, err := typesenseVectorDistance(app.InnerProduct)
+		require.NoError(t, err)
+
+		assert.Equal(t, "cosine", cosine)
+		assert.Equal(t, "ip", ip)
+	})
+
+	t.Run("should reject euclidean distance", func(t *testing.T) {
+		_, err := typesenseVectorDistance(app.Euclidean)
+
+		assert.Error(t, err)
+	})
+}
+
+// lengthEmbedder embeds a content as its length, repeated to the dimension.
+type lengthEmbedder struct {
+	dim int
+}
+
+func (e lengthEmbedder) Generate(_ context.Context, content string) ([]float32, error) {
+	embedding := make([]float32, e.dim)
+	for i := range embedding {
+		embedding[i] = float32(len(content))
+	}
+	return embedding, nil
+}
+
+func (e lengthEmbedder) GenerateBatch(ctx context.Context, contents []string) ([][]float32, error) {
+	embeddings := make([][]float32, len(contents))
+	for i, content := range contents {
+		embeddings[i], _ = e.Generate(ctx, content)
+	}
+	return embeddings, nil
+}
+
+func (e lengthEmbedder) ModelName() string { return "length" }
+func (e lengthEmbedder) Dimension() int    { return e.dim }
+
+func TestSchemaMigrator_Convert(t *testing.T) {
+	t.Run("should require a re-index for breaking fields other than the embedding", func(t *testing.T) {
+		m := &schemaMigrator{embedder: lengthEmbedder{dim: 2}}
+
+		_, err := m.convert(collectionName, []string{"chunk_index", "embedding"})
+
+		assert.ErrorIs(t, err, ErrReindexRequired)
+	})
+
+	t.Run("should require a re-index for a new embedding dimension without an embedder", func(t *testing.T) {
+		m := &schemaMigrator{}
+
+		_, err := m.convert(collectionName, []string{"embedding"})
+
+		assert.ErrorIs(t, err, ErrReindexRequired)
+	})
+
+	t.Run("should re-embed the content for a new embedding dimension", func(t *testing.T) {
+		m := &schemaMigrator{embedder: lengthEmbedder{dim: 2}}
+		docs := []map[string]interface{}{{"content": "abc", "embedding": []interface{}{1.0, 2.0, 3.0}}}
+
+		transform, err := m.convert(collectionName, []string{"embedding"})
+		require.NoError(t, err)
+		require.NoError(t, transform(context.Background(), docs))
+
+		assert.Equal(t, []float32{3, 3}, docs[0]["embedding"])
+	})
+}
+
+// fakeTypesense serves the export of a collection and records the imports into others and the
+// collections created and deleted. Creating the collection named failCreate fails once.
+type fakeTypesense struct {
+	exported   []string
+	imports    [][]string
+	importedTo []string
+	created    []string
+	deleted    []string
+	failCreate string
+}
+
+func (f *fakeTypesense) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/documents/export"):
+		fmt.Fprint(w, strings.Join(f.exported, "\n"))
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/documents/import"):
+		var lines []string
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		f.imports = append(f.imports, lines)
+		f.importedTo = append(f.importedTo, strings.Split(r.URL.Path, "/")[2])
+		fmt.Fprint(w, strings.Repeat(`{"success": true}`+"\n", len(lines)))
+	case r.Method == http.MethodPost && r.URL.Path == "/collections":
+		var schema struct{ Name string }
+		json.NewDecoder(r.Body).Decode(&schema)
+		f.created = append(f.created, schema.Name)
+		if schema.Name == f.failCreate {
+			f.failCreate = ""
+			http.Error(w, `{"message": "bad schema"}`, http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"name": %q}`, schema.Name)
+	case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/collections/"):
+		name := strings.TrimPrefix(r.URL.Path, "/collections/")
+		f.deleted = append(f.deleted, name)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"name": %q}`, name)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestSchemaMigrator_CopyDocuments(t *testing.T) {
+	newMigrator := func(t *testing.T, fake *fakeTypesense) *schemaMigrator {
+		server := httptest.NewServer(fake)
+		t.Cleanup(server.Close)
+		return &schemaMigrator{client: typesense.NewClient(typesense.WithServer(server.URL), typesense.WithAPIKey("key"))}
+	}
+
+	t.Run("should copy every field in batches", func(t *testing.T) {
+		fake := &fakeTypesense{}
+		for i := 0; i < copyBatchSize*2+1; i++ {
+			fake.exported = append(fake.exported, fmt.Sprintf(`{"id":"doc%d","content":"text","embedding":[0.5,0.25]}`, i))
+		}
+		m := newMigrator(t, fake)
+
+		err := m.copyDocuments(context.Background(), "from", "to", nil)
+
+		require.NoError(t, err)
+		require.Len(t, fake.imports, 3)
+		assert.Len(t, fake.imports[0], copyBatchSize)
+		assert.Len(t, fake.imports[2], 1)
+		assert.Equal(t, fake.exported[copyBatchSize*2], fake.imports[2][0])
+	})
+
+	t.Run("should import the transformed documents", func(t *testing.T) {
+		fake := &fakeTypesense{exported: []string{`{"id":"doc","content":"abcd","embedding":[0.5]}`}}
+		m := newMigrator(t, fake)
+
+		err := m.copyDocuments(context.Background(), "from", "to", reembed(lengthEmbedder{dim: 2}))
+
+		require.NoError(t, err)
+		require.Len(t, fake.imports, 1)
+		assert.JSONEq(t, `{"id":"doc","content":"abcd","embedding":[4,4]}`, fake.imports[0][0])
+	})
+}
+
+func TestSchemaMigrator_Recreate(t *testing.T) {
+	t.Run("should restore the collection from the backup when recreating it fails", func(t *testing.T) {
+		fake := &fakeTypesense{exported: []string{`{"id":"doc","content":"text","embedding":[0.5]}`}, failCreate: "documents"}
+		server := httptest.NewServer(fake)
+		t.Cleanup(server.Close)
+		client, err := api.NewClient(server.URL, api.WithAPIKey("key"))
+		require.NoError(t, err)
+		m := &schemaMigrator{client: typesense.NewClient(typesense.WithServer(server.URL), typesense.WithAPIKey("key")), api: client}
+		def := desiredSchema(2)
+		live := &api.CollectionResponse{Fields: liveFields(def.Schema.Fields, api.Field{Name: "embedding", Type: "float[]", NumDim: intPtr(1)})}
+
+		err = m.recreate(context.Background(), def, live, "cosine", nil)
+
+		require.ErrorContains(t, err, "restored with its previous schema")
+		require.Len(t, fake.created, 3)
+		backup := fake.created[0]
+		assert.Equal(t, []string{backup, "documents", "documents"}, fake.created)
+		assert.Equal(t, []string{backup, "documents"}, fake.importedTo)
+		assert.Equal(t, []string{"documents", "documents", backup}, fake.deleted)
+	})
+}
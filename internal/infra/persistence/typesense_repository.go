@@ -2,7 +2,9 @@ package persistence
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -15,11 +17,18 @@ import (
 
 const (
 	collectionName = "documents"
+
+	// attributeFieldPrefix prefixes the fields holding custom metadata attributes.
+	attributeFieldPrefix = "attr_"
 )
 
 // TypesenseRepository implements the domain.DocumentRepository and app.VectorStore interfaces.
 type TypesenseRepository struct {
-	client *typesense.Client
+	client   *typesense.Client
+	api      api.ClientInterface
+	numDim   int
+	metric   app.DistanceMetric
+	embedder app.BatchEmbeddingGenerator
 }
 
 // TypesenseConfig holds the configuration for the Typesense client.
@@ -27,23 +36,52 @@ type TypesenseConfig struct {
 	Host   string
 	Port   int
 	APIKey string
+	// EmbeddingDimension is the length of the vectors stored in the embedding field.
+	// It must match the dimension of the configured EmbeddingGenerator.
+	EmbeddingDimension int
+	// Metric is the distance of the embedding field, app.Cosine or app.InnerProduct. Empty means
+	// cosine. Changing it recreates the collection.
+	Metric app.DistanceMetric
+	// Embedder re-embeds the stored chunks when EmbeddingDimension changes. Without it such a
+	// change fails with ErrReindexRequired.
+	Embedder app.BatchEmbeddingGenerator
 }
 
 // NewTypesenseRepository creates a new TypesenseRepository.
 func NewTypesenseRepository(config TypesenseConfig) (*TypesenseRepository, error) {
+	if config.EmbeddingDimension <= 0 {
+		return nil, fmt.Errorf("invalid embedding dimension %d", config.EmbeddingDimension)
+	}
+
+	metric := config.Metric
+	if metric == "" {
+		metric = app.Cosine
+	}
+	if _, err := typesenseVectorDistance(metric); err != nil {
+		return nil, err
+	}
+
+	serverURL := fmt.Sprintf("http://%s:%d", config.Host, config.Port)
 	client := typesense.NewClient(
-		typesense.WithServer(fmt.Sprintf("http://%s:%d", config.Host, config.Port)),
+		typesense.WithServer(serverURL),
 		typesense.WithAPIKey(config.APIKey),
 	)
+	apiClient, err := api.NewClient(serverURL, api.WithAPIKey(config.APIKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Typesense API client: %w", err)
+	}
 
 	repo := &TypesenseRepository{
-		client: client,
+		client:   client,
+		api:      apiClient,
+		numDim:   config.EmbeddingDimension,
+		metric:   metric,
+		embedder: config.Embedder,
 	}
 
-	var err error
 	for i := 0; i < 30; i++ {
 		err = repo.ensureCollectionExists(context.Background())
-		if err == nil {
+		if err == nil || errors.Is(err, ErrReindexRequired) {
 			break
 		}
 		time.Sleep(2 * time.Second)
@@ -55,77 +93,185 @@ func NewTypesenseRepository(config TypesenseConfig) (*TypesenseRepository, error
 	return repo, nil
 }
 
+// ensureCollectionExists migrates the documents collection to the desired schema, keeping stored documents.
 func (r *TypesenseRepository) ensureCollectionExists(ctx context.Context) error {
-	_, err := r.client.Collection(collectionName).Retrieve(ctx)
-	if err == nil {
-		r.client.Collection(collectionName).Delete(ctx)
-	}
-
-	// NumDim should match the dimension of your embeddings, here assumed to be 8.
-	// For gemini-embedding-001 model it can be: Flexible, supports: 128 - 3072, Recommended: 768, 1536, 3072
-	numDim := 8
-	schema := &api.CollectionSchema{
-		Name: collectionName,
-		Fields: []api.Field{
-			{Name: "id", Type: "string"},
-			{Name: "content", Type: "string"},
-			{Name: "embedding", Type: "float[]", Index: boolPtr(true), Optional: boolPtr(true), NumDim: intPtr(numDim)},
-		},
+	migrator := &schemaMigrator{client: r.client, api: r.api, embedder: r.embedder}
+	def := desiredSchema(r.numDim)
+	def.VectorDistance, _ = typesenseVectorDistance(r.metric)
+	return migrator.Migrate(ctx, def)
+}
+
+// typesenseVectorDistance returns the vec_dist of a metric. Typesense has no Euclidean distance.
+func typesenseVectorDistance(metric app.DistanceMetric) (string, error) {
+	switch metric {
+	case app.Cosine:
+		return "cosine", nil
+	case app.InnerProduct:
+		return "ip", nil
+	default:
+		return "", fmt.Errorf("distance metric %q is not supported by Typesense", metric)
 	}
+}
 
-	_, err = r.client.Collections().Create(ctx, schema)
-	if err != nil {
-		if !strings.Contains(err.Error(), "already exists") {
-			return err
-		}
+// desiredSchema returns the current versioned schema of the documents collection
+// with an embedding field of the given dimension.
+func desiredSchema(numDim int) schemaDefinition {
+	return schemaDefinition{
+		Version: schemaVersion,
+		Schema: &api.CollectionSchema{
+			Name: collectionName,
+			Fields: []api.Field{
+				{Name: "id", Type: "string"},
+				{Name: "parent_id", Type: "string", Facet: boolPtr(true), Optional: boolPtr(true)},
+				{Name: "chunk_index", Type: "int32", Optional: boolPtr(true)},
+				{Name: "start_offset", Type: "int32", Optional: boolPtr(true)},
+				{Name: "end_offset", Type: "int32", Optional: boolPtr(true)},
+				{Name: "content", Type: "string"},
+				{Name: "title", Type: "string", Optional: boolPtr(true)},
+				{Name: "summary", Type: "string", Optional: boolPtr(true)},
+				{Name: "source_uri", Type: "string", Facet: boolPtr(true), Optional: boolPtr(true)},
+				{Name: "mime_type", Type: "string", Facet: boolPtr(true), Optional: boolPtr(true)},
+				{Name: "tags", Type: "string[]", Facet: boolPtr(true), Optional: boolPtr(true)},
+				{Name: "content_hash", Type: "string", Facet: boolPtr(true), Optional: boolPtr(true)},
+				{Name: "created_at", Type: "int64", Optional: boolPtr(true)},
+				{Name: "updated_at", Type: "int64", Optional: boolPtr(true)},
+				{Name: attributeFieldPrefix + ".*", Type: "string", Facet: boolPtr(true), Optional: boolPtr(true)},
+				{Name: "embedding", Type: "float[]", Index: boolPtr(true), Optional: boolPtr(true), NumDim: intPtr(numDim)},
+			},
+		},
 	}
+}
 
-	return nil
+// EmbeddingDimension returns the dimension declared for the embedding field.
+func (r *TypesenseRepository) EmbeddingDimension() int {
+	return r.numDim
 }
 
 // Save persists a document to Typesense.
 func (r *TypesenseRepository) Save(ctx context.Context, doc *domain.Document) error {
-	document := map[string]interface{}{
-		"id":        doc.ID,
-		"content":   doc.Content,
-		"embedding": doc.Embedding,
+	_, err := r.client.Collection(collectionName).Documents().Upsert(ctx, toTypesenseDocument(doc))
+	return err
+}
+
+// SaveBatch upserts the documents with a single import request.
+func (r *TypesenseRepository) SaveBatch(ctx context.Context, docs []*domain.Document) ([]error, error) {
+	documents := make([]interface{}, len(docs))
+	for i, doc := range docs {
+		documents[i] = toTypesenseDocument(doc)
 	}
 
-	_, err := r.client.Collection(collectionName).Documents().Upsert(ctx, document)
-	return err
+	action := "upsert"
+	res, err := r.client.Collection(collectionName).Documents().Import(ctx, documents, &api.ImportDocumentsParams{Action: &action})
+	if err != nil {
+		return nil, fmt.Errorf("failed to import documents: %w", err)
+	}
+	if len(res) != len(docs) {
+		return nil, fmt.Errorf("import returned %d results for %d documents", len(res), len(docs))
+	}
+
+	errs := make([]error, len(docs))
+	for i, result := range res {
+		if !result.Success {
+			errs[i] = fmt.Errorf("failed to import document %q: %s", docs[i].ID, result.Error)
+		}
+	}
+	return errs, nil
 }
 
 // FindByID retrieves a document from Typesense by its ID.
 func (r *TypesenseRepository) FindByID(ctx context.Context, id string) (*domain.Document, error) {
 	doc, err := r.client.Collection(collectionName).Document(id).Retrieve(ctx)
 	if err != nil {
+		if isNotFound(err) {
+			return nil, domain.ErrDocumentNotFound
+		}
 		return nil, err
 	}
 
-	embedding, ok := doc["embedding"].([]interface{})
-	if !ok {
-		return nil, fmt.Errorf("embedding is not a []interface{}")
+	return fromTypesenseDocument(doc)
+}
+
+// Delete removes the document with the given ID from Typesense.
+func (r *TypesenseRepository) Delete(ctx context.Context, id string) error {
+	if _, err := r.client.Collection(collectionName).Document(id).Delete(ctx); err != nil {
+		if isNotFound(err) {
+			return domain.ErrDocumentNotFound
+		}
+		return err
 	}
+	return nil
+}
 
-	floatEmbedding := make([]float32, len(embedding))
-	for i, v := range embedding {
-		floatEmbedding[i] = float32(v.(float64))
+// List returns a page of documents matching the filter in the default order of a wildcard
+// search. The cursor is the offset of the page, so documents indexed while paging may shift it.
+func (r *TypesenseRepository) List(ctx context.Context, opts app.ListOptions) (*app.DocumentPage, error) {
+	filterBy, err := typesenseFilter(opts.Filter)
+	if err != nil {
+		return nil, err
 	}
 
-	return &domain.Document{
-		ID:        doc["id"].(string),
-		Content:   doc["content"].(string),
-		Embedding: floatEmbedding,
-	}, nil
+	offset := 0
+	if opts.Cursor != "" {
+		offset, err = strconv.Atoi(opts.Cursor)
+		if err != nil || offset < 0 {
+			return nil, app.ErrInvalidCursor
+		}
+	}
+	searchRequest := &api.SearchCollectionParams{
+		Q:       "*",
+		QueryBy: "content",
+		Offset:  &offset,
+		Limit:   &opts.Limit,
+	}
+	if filterBy != "" {
+		searchRequest.FilterBy = &filterBy
+	}
+
+	res, err := r.client.Collection(collectionName).Documents().Search(ctx, searchRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &app.DocumentPage{}
+	for _, hit := range *res.Hits {
+		doc, err := fromTypesenseDocument(*hit.Document)
+		if err != nil {
+			return nil, err
+		}
+		page.Documents = append(page.Documents, *doc)
+	}
+	if next := offset + len(page.Documents); res.Found != nil && next < *res.Found && len(page.Documents) > 0 {
+		page.NextCursor = strconv.Itoa(next)
+	}
+	return page, nil
 }
 
-// Search performs a vector similarity search in Typesense.
-func (r *TypesenseRepository) Search(ctx context.Context, embedding []float32) ([]domain.Document, error) {
-	vectorQuery := fmt.Sprintf("embedding:([%s], k:10)", floatsToString(embedding))
+// Search performs a vector similarity search in Typesense, restricted to documents matching the
+// filter. When opts.Text is set the content is also matched by keyword and both rankings are
+// fused with the weight opts.Alpha; without an embedding only the keyword search runs. The
+// minimum score is passed to Typesense as a distance threshold, so that a page is filled with
+// hits above it; keyword-only hits of a hybrid search have no vector score and are kept.
+func (r *TypesenseRepository) Search(ctx context.Context, opts app.SearchOptions) ([]app.SearchHit, error) {
+	filterBy, err := typesenseFilter(opts.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	q := "*"
+	if opts.Text != "" {
+		q = opts.Text
+	}
 	searchRequest := &api.SearchCollectionParams{
-		Q:           "*",
-		QueryBy:     "content",
-		VectorQuery: &vectorQuery,
+		Q:       q,
+		QueryBy: "content",
+		Offset:  &opts.Offset,
+		Limit:   &opts.Limit,
+	}
+	if vectorQuery := typesenseVectorQuery(opts); vectorQuery != "" {
+		searchRequest.VectorQuery = &vectorQuery
+	}
+	if filterBy != "" {
+		searchRequest.FilterBy = &filterBy
 	}
 
 	res, err := r.client.Collection(collectionName).Documents().Search(ctx, searchRequest)
@@ -133,27 +279,158 @@ func (r *TypesenseRepository) Search(ctx context.Context, embedding []float32) (
 		return nil, err
 	}
 
-	var documents []domain.Document
-	for _, hit := range *res.Hits {
-		doc := *hit.Document
-		embedding, ok := doc["embedding"].([]interface{})
-		if !ok {
-			return nil, fmt.Errorf("embedding is not a []interface{}")
+	var hits []app.SearchHit
+	for rank, hit := range *res.Hits {
+		doc, err := fromTypesenseDocument(*hit.Document)
+		if err != nil {
+			return nil, err
 		}
 
-		floatEmbedding := make([]float32, len(embedding))
+		result := app.SearchHit{Document: *doc}
+		if hit.TextMatch != nil && opts.Text != "" {
+			result.TextMatch = float64(*hit.TextMatch)
+			// Typesense does not report its fused score, so the hybrid ranking is kept as the
+			// reciprocal rank.
+			result.FusionScore = 1 / float64(opts.Offset+rank+1)
+		}
+		if hit.VectorDistance != nil {
+			// Typesense reports 1-cosine or 1-dot, so the raw similarity is its complement.
+			result.Distance = float64(*hit.VectorDistance)
+			result.Score = r.metric.Score(result.Distance)
+			result.Similarity = r.metric.Similarity(result.Distance)
+			if !opts.MeetsMinScore(result.Score) {
+				continue
+			}
+		}
+
+		hits = append(hits, result)
+	}
+
+	return hits, nil
+}
+
+// typesenseVectorQuery builds the vector_query parameter, empty for a keyword-only search. k has to
+// cover the skipped hits as well as the returned ones; alpha only applies to hybrid search. The
+// minimum score becomes a distance threshold, the distances of both metrics supported by
+// Typesense being 1-score.
+func typesenseVectorQuery(opts app.SearchOptions) string {
+	if len(opts.Embedding) == 0 {
+		return ""
+	}
+	params := fmt.Sprintf("k:%d", opts.Offset+opts.Limit)
+	if opts.Text != "" {
+		params += ", alpha:" + formatNumber(opts.Alpha)
+	}
+	if opts.MinScore != nil {
+		params += ", distance_threshold:" + formatNumber(1-*opts.MinScore)
+	}
+	return fmt.Sprintf("embedding:([%s], %s)", floatsToString(opts.Embedding), params)
+}
+
+func toTypesenseDocument(doc *domain.Document) map[string]interface{} {
+	document := map[string]interface{}{
+		"id":           doc.ID,
+		"parent_id":    doc.ParentID,
+		"chunk_index":  doc.ChunkIndex,
+		"start_offset": doc.StartOffset,
+		"end_offset":   doc.EndOffset,
+		"content":      doc.Content,
+		"embedding":    doc.Embedding,
+		"title":        doc.Metadata.Title,
+		"summary":      doc.Metadata.Summary,
+		"source_uri":   doc.Metadata.SourceURI,
+		"mime_type":    doc.Metadata.MIMEType,
+		"tags":         doc.Metadata.Tags,
+		"content_hash": doc.Metadata.ContentHash,
+		"created_at":   unixOrZero(doc.Metadata.CreatedAt),
+		"updated_at":   unixOrZero(doc.Metadata.UpdatedAt),
+	}
+	if doc.Metadata.Tags == nil {
+		document["tags"] = []string{}
+	}
+	for key, value := range doc.Metadata.Attributes {
+		document[attributeFieldPrefix+key] = value
+	}
+	return document
+}
+
+// fromTypesenseDocument decodes a stored document. Chunk fields are optional since documents
+// indexed before chunking was introduced do not have them, and a missing embedding, e.g. one
+// excluded from the response, decodes as empty.
+func fromTypesenseDocument(doc map[string]interface{}) (*domain.Document, error) {
+	var floatEmbedding []float32
+	switch embedding := doc["embedding"].(type) {
+	case nil:
+	case []interface{}:
+		floatEmbedding = make([]float32, len(embedding))
 		for i, v := range embedding {
 			floatEmbedding[i] = float32(v.(float64))
 		}
+	default:
+		return nil, fmt.Errorf("embedding is not a []interface{}")
+	}
+
+	parentID, _ := doc["parent_id"].(string)
+	chunkIndex, _ := doc["chunk_index"].(float64)
+	startOffset, _ := doc["start_offset"].(float64)
+	endOffset, _ := doc["end_offset"].(float64)
+
+	return &domain.Document{
+		ID:          doc["id"].(string),
+		ParentID:    parentID,
+		ChunkIndex:  int(chunkIndex),
+		StartOffset: int(startOffset),
+		EndOffset:   int(endOffset),
+		Content:     doc["content"].(string),
+		Embedding:   floatEmbedding,
+		Metadata:    metadataFromTypesenseDocument(doc),
+	}, nil
+}
+
+func metadataFromTypesenseDocument(doc map[string]interface{}) domain.Metadata {
+	var metadata domain.Metadata
+	metadata.Title, _ = doc["title"].(string)
+	metadata.Summary, _ = doc["summary"].(string)
+	metadata.SourceURI, _ = doc["source_uri"].(string)
+	metadata.MIMEType, _ = doc["mime_type"].(string)
+	metadata.ContentHash, _ = doc["content_hash"].(string)
 
-		documents = append(documents, domain.Document{
-			ID:        doc["id"].(string),
-			Content:   doc["content"].(string),
-			Embedding: floatEmbedding,
-		})
+	if tags, ok := doc["tags"].([]interface{}); ok {
+		for _, tag := range tags {
+			if s, ok := tag.(string); ok {
+				metadata.Tags = append(metadata.Tags, s)
+			}
+		}
+	}
+
+	if createdAt, ok := doc["created_at"].(float64); ok && createdAt > 0 {
+		metadata.CreatedAt = time.Unix(int64(createdAt), 0).UTC()
+	}
+	if updatedAt, ok := doc["updated_at"].(float64); ok && updatedAt > 0 {
+		metadata.UpdatedAt = time.Unix(int64(updatedAt), 0).UTC()
+	}
+
+	for key, value := range doc {
+		name, ok := strings.CutPrefix(key, attributeFieldPrefix)
+		if !ok {
+			continue
+		}
+		if s, ok := value.(string); ok {
+			if metadata.Attributes == nil {
+				metadata.Attributes = make(map[string]string)
+			}
+			metadata.Attributes[name] = s
+		}
 	}
 
-	return documents, nil
+	return metadata
+}
+
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
 }
 
 func floatsToString(floats []float32) string {
@@ -167,8 +444,10 @@ func floatsToString(floats []float32) string {
 	return b.String()
 }
 
-var _ domain.DocumentRepository = (*TypesenseRepository)(nil)
+var _ app.DocumentStore = (*TypesenseRepository)(nil)
 var _ app.VectorStore = (*TypesenseRepository)(nil)
+var _ app.DimensionedStore = (*TypesenseRepository)(nil)
+var _ app.BatchSaver = (*TypesenseRepository)(nil)
 
 func boolPtr(b bool) *bool {
 	return &b
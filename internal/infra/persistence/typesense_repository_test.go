@@ -4,6 +4,7 @@ import (
 	"context"
 	"testing"
 
+	"github.com/igorrius/go-vector-search/internal/app"
 	"github.com/igorrius/go-vector-search/internal/domain"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -15,9 +16,10 @@ func TestTypesenseRepository_Integration(t *testing.T) {
 	}
 
 	config := TypesenseConfig{
-		Host:   "localhost",
-		Port:   8108,
-		APIKey: "xyz",
+		Host:               "localhost",
+		Port:               8108,
+		APIKey:             "xyz",
+		EmbeddingDimension: 8,
 	}
 
 	repo, err := NewTypesenseRepository(config)
@@ -41,12 +43,59 @@ func TestTypesenseRepository_Integration(t *testing.T) {
 	// assert.Equal(t, doc.Embedding, foundDoc.Embedding) // This might fail due to float precision
 
 	// Search for the document
-	results, err := repo.Search(context.Background(), []float32{0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8})
+	hits, err := repo.Search(context.Background(), app.SearchOptions{
+		Embedding: []float32{0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8},
+		Limit:     10,
+	})
 	require.NoError(t, err)
-	require.NotEmpty(t, results)
-	for _, result := range results {
+	require.NotEmpty(t, hits)
+	for _, hit := range hits {
+		result := hit.Document
 		assert.Equal(t, "test-id", result.ID)
 		assert.Equal(t, "this is a test document", result.Content)
 		assert.InDeltaSlice(t, doc.Embedding, result.Embedding, 0.001)
 	}
 }
+
+func TestTypesenseVectorQuery(t *testing.T) {
+	t.Run("should request enough neighbours for the page", func(t *testing.T) {
+		query := typesenseVectorQuery(app.SearchOptions{Embedding: []float32{0.5, 1}, Limit: 10, Offset: 20})
+
+		assert.Equal(t, "embedding:([0.500000, 1.000000], k:30)", query)
+	})
+
+	t.Run("should weight hybrid search with alpha", func(t *testing.T) {
+		query := typesenseVectorQuery(app.SearchOptions{Embedding: []float32{0.5}, Limit: 5, Text: "E1234", Alpha: 0.25})
+
+		assert.Equal(t, "embedding:([0.500000], k:5, alpha:0.25)", query)
+	})
+
+	t.Run("should pass the minimum score as a distance threshold", func(t *testing.T) {
+		minScore := 0.75
+		query := typesenseVectorQuery(app.SearchOptions{Embedding: []float32{0.5}, Limit: 5, MinScore: &minScore})
+
+		assert.Equal(t, "embedding:([0.500000], k:5, distance_threshold:0.25)", query)
+	})
+
+	t.Run("should omit the vector query of a keyword-only search", func(t *testing.T) {
+		query := typesenseVectorQuery(app.KeywordOnly(app.SearchOptions{Query: "E1234", Embedding: []float32{0.5}, Limit: 5}))
+
+		assert.Empty(t, query)
+	})
+}
+
+func TestFromTypesenseDocument(t *testing.T) {
+	t.Run("should decode a document without an embedding", func(t *testing.T) {
+		doc, err := fromTypesenseDocument(map[string]interface{}{"id": "doc", "content": "text"})
+
+		require.NoError(t, err)
+		assert.Equal(t, "doc", doc.ID)
+		assert.Empty(t, doc.Embedding)
+	})
+
+	t.Run("should reject an embedding of another type", func(t *testing.T) {
+		_, err := fromTypesenseDocument(map[string]interface{}{"id": "doc", "embedding": "text"})
+
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,392 @@
+// Package server wires the stores, models and handlers of the search service into an HTTP handler.
+package server
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/igorrius/go-vector-search/internal/app"
+	"github.com/igorrius/go-vector-search/internal/infra/ai"
+	"github.com/igorrius/go-vector-search/internal/infra/extract"
+	"github.com/igorrius/go-vector-search/internal/infra/persistence"
+	"github.com/igorrius/go-vector-search/internal/infra/persistence/conversations"
+	"github.com/igorrius/go-vector-search/internal/infra/persistence/embeddings"
+	"github.com/igorrius/go-vector-search/internal/infra/persistence/hnsw"
+	"github.com/igorrius/go-vector-search/internal/infra/persistence/jobs"
+	"github.com/igorrius/go-vector-search/internal/infra/persistence/memory"
+	"github.com/igorrius/go-vector-search/internal/infra/persistence/quantized"
+)
+
+// Config holds the configuration of the service.
+type Config struct {
+	Storage         string
+	VectorMetric    string
+	HNSWDir         string
+	HNSWM           int
+	HNSWEfConstruct int
+	HNSWEfSearch    int
+	Quantization    string
+	QuantizedDir    string
+	TypesenseHost   string
+	TypesensePort   int
+	TypesenseAPIKey string
+	// AIProvider selects the models: "google" or "local" for deterministic models that need no
+	// external service.
+	AIProvider     string
+	GoogleAIApiKey string
+	// LocalEmbeddingDimension is the vector length of the local embedding model.
+	LocalEmbeddingDimension int
+	// ChunkSize is the largest chunk in characters.
+	ChunkSize int
+	// ChunkOverlapSentences is the number of sentences shared by consecutive chunks of a section
+	// split at sentence boundaries.
+	ChunkOverlapSentences int
+	SearchFusion          string
+	Reranker              string
+	JobsDir               string
+	JobWorkers            int
+	JobMaxAttempts        int
+	// JobRetention is how long finished jobs are kept; negative keeps them forever.
+	JobRetention   time.Duration
+	CacheEntries   int
+	CacheTTL       time.Duration
+	CacheDir       string
+	CacheMaxMB     int
+	DedupThreshold float64
+	// DedupPolicy is the duplicate policy of documents indexed without one.
+	DedupPolicy      string
+	MaxUploadMB      int
+	ConversationsDir string
+	HistoryTokens    int
+}
+
+// Server is the search service.
+type Server struct {
+	handler http.Handler
+	cache   *app.CachingEmbeddingGenerator
+	closers closers
+}
+
+// closers releases resources in the reverse order of their acquisition.
+type closers []func() error
+
+// add registers the release of a resource.
+func (c *closers) add(close func() error) {
+	*c = append(*c, close)
+}
+
+// close releases the resources, the most recently acquired first, and returns all errors.
+func (c closers) close() error {
+	var errs []error
+	for i := len(c) - 1; i >= 0; i-- {
+		errs = append(errs, c[i]())
+	}
+	return errors.Join(errs...)
+}
+
+// storage is a document store that can also be searched.
+type storage interface {
+	app.DocumentStore
+	app.VectorStore
+}
+
+// models are the machine learning models used by the service.
+type models struct {
+	embedder   app.EmbeddingGenerator
+	summarizer app.Summarizer
+	answerer   app.Answerer
+	rewriter   app.QueryRewriter
+}
+
+// New creates the service and starts its background jobs.
+func New(ctx context.Context, cfg Config) (_ *Server, err error) {
+	// Whatever was acquired before a failing step is released again.
+	var cleanup closers
+	defer func() {
+		if err != nil {
+			err = errors.Join(err, cleanup.close())
+		}
+	}()
+
+	m, err := newModels(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	embeddingGenerator, err := newEmbeddingCache(cfg, m.embedder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embedding cache: %w", err)
+	}
+	cleanup.add(embeddingGenerator.Close)
+
+	repo, err := newStorage(cfg, embeddingGenerator)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s storage: %w", cfg.Storage, err)
+	}
+	if closer, ok := repo.(io.Closer); ok {
+		cleanup.add(closer.Close)
+	}
+
+	reranker, err := newReranker(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create reranker: %w", err)
+	}
+
+	// Initialize application handlers
+	chunker := app.NewMarkdownChunker(cfg.ChunkSize, cfg.ChunkOverlapSentences)
+	dedupPolicy, err := app.ParseDuplicatePolicy(cfg.DedupPolicy)
+	if err != nil {
+		return nil, err
+	}
+	dedup := app.NewDuplicateDetector(repo, cfg.DedupThreshold, dedupPolicy)
+	indexDocumentHandler := app.NewIndexDocumentHandler(repo, embeddingGenerator, chunker, dedup)
+	jobStore, err := jobs.NewFileStore(cfg.JobsDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create job store: %w", err)
+	}
+	jobQueue := app.NewJobQueue(jobStore, indexDocumentHandler, app.JobQueueConfig{
+		Workers:     cfg.JobWorkers,
+		MaxAttempts: cfg.JobMaxAttempts,
+		Retention:   cfg.JobRetention,
+	})
+	if err := jobQueue.Start(ctx); err != nil {
+		return nil, fmt.Errorf("failed to start job queue: %w", err)
+	}
+	cleanup.add(func() error {
+		jobQueue.Stop()
+		return nil
+	})
+	store, err := newVectorStore(cfg, repo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create vector store: %w", err)
+	}
+	searchDocumentsHandler := app.NewSearchDocumentsHandler(embeddingGenerator, store, m.summarizer, reranker)
+	askHandler := app.NewAskHandler(searchDocumentsHandler, m.answerer)
+	conversationStore, err := newConversationStore(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create conversation store: %w", err)
+	}
+	httpHandlers := app.NewHTTPHandlers(
+		jobQueue,
+		searchDocumentsHandler,
+		app.NewGetDocumentHandler(repo),
+		app.NewListDocumentsHandler(repo),
+		app.NewUpdateDocumentHandler(repo, embeddingGenerator, chunker),
+		app.NewDeleteDocumentHandler(repo),
+		app.NewBulkIndexDocumentsHandler(repo, embeddingGenerator, chunker, dedup),
+		askHandler,
+		app.NewConversationHandler(conversationStore, askHandler, m.rewriter, app.ConversationConfig{
+			HistoryTokens: cfg.HistoryTokens,
+		}),
+		extract.NewDefaultRegistry(),
+		app.UploadConfig{MaxBodySize: int64(cfg.MaxUploadMB) << 20},
+	)
+
+	return &Server{
+		handler: newRouter(httpHandlers),
+		cache:   embeddingGenerator,
+		closers: cleanup,
+	}, nil
+}
+
+// newRouter routes the API endpoints to the handlers.
+func newRouter(httpHandlers *app.HTTPHandlers) *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/v1/documents", httpHandlers.IndexDocumentHandler).Methods("POST")
+	router.HandleFunc("/api/v1/documents", httpHandlers.ListDocumentsHandler).Methods("GET")
+	router.HandleFunc("/api/v1/documents:bulk", httpHandlers.BulkIndexDocumentsHandler).Methods("POST")
+	router.HandleFunc("/api/v1/documents/{id}", httpHandlers.GetDocumentHandler).Methods("GET")
+	router.HandleFunc("/api/v1/documents/{id}", httpHandlers.ReplaceDocumentHandler).Methods("PUT")
+	router.HandleFunc("/api/v1/documents/{id}", httpHandlers.PatchDocumentHandler).Methods("PATCH")
+	router.HandleFunc("/api/v1/documents/{id}", httpHandlers.DeleteDocumentHandler).Methods("DELETE")
+	router.HandleFunc("/api/v1/jobs/{id}", httpHandlers.GetJobHandler).Methods("GET")
+	router.HandleFunc("/api/v1/search", httpHandlers.SearchDocumentsHandler).Methods("GET")
+	router.HandleFunc("/api/v1/ask", httpHandlers.AskHandler).Methods("POST")
+	router.HandleFunc("/api/v1/conversations", httpHandlers.CreateConversationHandler).Methods("POST")
+	router.HandleFunc("/api/v1/conversations/{id}", httpHandlers.GetConversationHandler).Methods("GET")
+	router.HandleFunc("/api/v1/conversations/{id}/messages", httpHandlers.SendMessageHandler).Methods("POST")
+	router.Handle("/debug/vars", expvar.Handler()).Methods("GET")
+	router.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}).Methods("GET")
+	return router
+}
+
+// Handler returns the HTTP handler of the API.
+func (s *Server) Handler() http.Handler {
+	return s.handler
+}
+
+// EmbeddingCacheStats returns the hit and miss counters of the embedding cache.
+func (s *Server) EmbeddingCacheStats() app.EmbeddingCacheStats {
+	return s.cache.Stats()
+}
+
+// Close stops the background jobs after their current documents, then closes the storage, which
+// persists the in-process stores, and the embedding cache.
+func (s *Server) Close() error {
+	return s.closers.close()
+}
+
+// newModels returns the models selected by AI_PROVIDER: "google" for the Google AI API, or
+// "local" for deterministic models that need no external service.
+func newModels(ctx context.Context, cfg Config) (*models, error) {
+	switch cfg.AIProvider {
+	case "", "google":
+		embedder, err := ai.NewGoogleEmbeddingGenerator(ctx, cfg.GoogleAIApiKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Google embedding generator: %w", err)
+		}
+		summarizer, err := ai.NewGoogleSummarizer(ctx, cfg.GoogleAIApiKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Google summarizer: %w", err)
+		}
+		answerer, err := ai.NewGoogleAnswerer(ctx, cfg.GoogleAIApiKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Google answerer: %w", err)
+		}
+		rewriter, err := ai.NewGoogleQueryRewriter(ctx, cfg.GoogleAIApiKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Google query rewriter: %w", err)
+		}
+		return &models{embedder: embedder, summarizer: summarizer, answerer: answerer, rewriter: rewriter}, nil
+	case "local":
+		return &models{
+			embedder:   ai.NewLocalEmbeddingGenerator(cfg.LocalEmbeddingDimension),
+			summarizer: ai.NewLocalSummarizer(),
+			answerer:   ai.NewLocalAnswerer(),
+			rewriter:   ai.NewLocalQueryRewriter(),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown AI provider %q", cfg.AIProvider)
+	}
+}
+
+// newStorage returns the document store selected by STORAGE: "typesense", "hnsw" for an embedded
+// index persisted in HNSW_DIR, "quantized" for an in-process store of compressed vectors
+// snapshotted to QUANTIZED_DIR, or "memory" for an in-process store that needs no external
+// services. Only the memory store loses its documents on exit.
+func newStorage(cfg Config, embedder app.EmbeddingGenerator) (storage, error) {
+	embeddingDimension := embedder.Dimension()
+	metric, err := app.ParseDistanceMetric(cfg.VectorMetric)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.Storage {
+	case "typesense":
+		repo, err := persistence.NewTypesenseRepository(persistence.TypesenseConfig{
+			Host:               cfg.TypesenseHost,
+			Port:               cfg.TypesensePort,
+			APIKey:             cfg.TypesenseAPIKey,
+			EmbeddingDimension: embeddingDimension,
+			Metric:             metric,
+			Embedder:           app.AsBatchEmbeddingGenerator(embedder),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return repo, nil
+	case "hnsw":
+		store, err := hnsw.Open(hnsw.Config{
+			Dimension:      embeddingDimension,
+			Metric:         metric,
+			M:              cfg.HNSWM,
+			EfConstruction: cfg.HNSWEfConstruct,
+			EfSearch:       cfg.HNSWEfSearch,
+			Dir:            cfg.HNSWDir,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	case "quantized":
+		store, err := quantized.NewStore(quantized.Config{
+			Dimension:    embeddingDimension,
+			Metric:       metric,
+			Quantization: quantized.Quantization(cfg.Quantization),
+			Dir:          cfg.QuantizedDir,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	case "memory":
+		return memory.NewStore(metric), nil
+	default:
+		return nil, fmt.Errorf("unknown storage %q", cfg.Storage)
+	}
+}
+
+// newVectorStore returns the store used for search. With SEARCH_FUSION set to "rrf" or "weighted",
+// pure vector and pure keyword results from the repository are fused.
+func newVectorStore(cfg Config, repo storage) (app.VectorStore, error) {
+	var method app.FusionMethod
+	switch cfg.SearchFusion {
+	case "":
+		return repo, nil
+	case "rrf":
+		method = app.ReciprocalRankFusion
+	case "weighted":
+		method = app.WeightedScoreFusion
+	default:
+		return nil, fmt.Errorf("unknown search fusion %q", cfg.SearchFusion)
+	}
+
+	return app.NewFusionVectorStore(method,
+		app.Retriever{Name: "vector", Store: repo, Options: app.VectorOnly},
+		app.Retriever{Name: "keyword", Store: repo, Options: app.KeywordOnly},
+	), nil
+}
+
+// newReranker returns the reranker applied to search hits, or nil when RERANKER is not set.
+// "llm" grades hits with the generative model, "lexical" by query term overlap.
+func newReranker(ctx context.Context, cfg Config) (app.Reranker, error) {
+	switch cfg.Reranker {
+	case "":
+		return nil, nil
+	case "llm":
+		reranker, err := ai.NewGoogleReranker(ctx, cfg.GoogleAIApiKey)
+		if err != nil {
+			return nil, err
+		}
+		return reranker, nil
+	case "lexical":
+		return app.NewLexicalReranker(), nil
+	default:
+		return nil, fmt.Errorf("unknown reranker %q", cfg.Reranker)
+	}
+}
+
+// newEmbeddingCache wraps the generator in an embedding cache of EMBEDDING_CACHE_SIZE entries,
+// persisted in EMBEDDING_CACHE_DIR when it is set.
+func newEmbeddingCache(cfg Config, generator app.EmbeddingGenerator) (*app.CachingEmbeddingGenerator, error) {
+	cacheCfg := app.EmbeddingCacheConfig{
+		MaxEntries: cfg.CacheEntries,
+		TTL:        cfg.CacheTTL,
+	}
+	if cfg.CacheDir != "" {
+		store, err := embeddings.Open(embeddings.Config{
+			Dir:      cfg.CacheDir,
+			MaxBytes: int64(cfg.CacheMaxMB) << 20,
+		})
+		if err != nil {
+			return nil, err
+		}
+		cacheCfg.Store = store
+	}
+	return app.NewCachingEmbeddingGenerator(generator, cacheCfg), nil
+}
+
+// newConversationStore keeps conversations in files, or in memory when no directory is set.
+func newConversationStore(cfg Config) (app.ConversationStore, error) {
+	if cfg.ConversationsDir == "" {
+		return conversations.NewMemoryStore(), nil
+	}
+	return conversations.NewFileStore(cfg.ConversationsDir)
+}
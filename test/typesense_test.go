@@ -4,6 +4,7 @@ import (
 	"context"
 	"testing"
 
+	"github.com/igorrius/go-vector-search/internal/app"
 	"github.com/igorrius/go-vector-search/internal/domain"
 	"github.com/igorrius/go-vector-search/internal/infra/persistence"
 	"github.com/stretchr/testify/assert"
@@ -16,9 +17,10 @@ func TestTypesenseRepository_Integration(t *testing.T) {
 	}
 
 	config := persistence.TypesenseConfig{
-		Host:   "localhost",
-		Port:   8108,
-		APIKey: "xyz",
+		Host:               "localhost",
+		Port:               8108,
+		APIKey:             "xyz",
+		EmbeddingDimension: 8,
 	}
 
 	repo, err := persistence.NewTypesenseRepository(config)
@@ -41,10 +43,14 @@ func TestTypesenseRepository_Integration(t *testing.T) {
 	assert.Equal(t, doc.Content, foundDoc.Content)
 
 	// Search for the document
-	results, err := repo.Search(context.Background(), []float32{0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8})
+	hits, err := repo.Search(context.Background(), app.SearchOptions{
+		Embedding: []float32{0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8},
+		Limit:     10,
+	})
 	require.NoError(t, err)
-	require.NotEmpty(t, results)
-	for _, result := range results {
+	require.NotEmpty(t, hits)
+	for _, hit := range hits {
+		result := hit.Document
 		assert.Equal(t, "test-id", result.ID)
 		assert.Equal(t, "this is a test document", result.Content)
 		assert.InDeltaSlice(t, doc.Embedding, result.Embedding, 0.001)